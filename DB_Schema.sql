@@ -34,25 +34,47 @@ CREATE TABLE db_sets (
   env         env_type NOT NULL,
   name        TEXT NOT NULL,
   is_active   BOOLEAN NOT NULL DEFAULT true,
+  max_parallel   INT NOT NULL DEFAULT 1, -- default per-run concurrency
+  failure_policy TEXT NOT NULL DEFAULT 'stop_on_first_failure', -- stop_on_first_failure | continue_on_failure | max_failures=N
+  wave_plan      TEXT NOT NULL DEFAULT '', -- wave sizes such as '1,5,10' (last repeats); '' = one wave
+  wave_gate      TEXT NOT NULL DEFAULT 'none', -- between waves: none | manual | soak=<duration>
+  maintenance_window TEXT NOT NULL DEFAULT '', -- e.g. 'mon-fri 02:00-04:00 UTC'; '' = none
+  ssh_host       TEXT NOT NULL DEFAULT '', -- SSH bastion for the set's targets; '' = none
+  ssh_port       INT NOT NULL DEFAULT 22,
+  ssh_user       TEXT NOT NULL DEFAULT '',
+  ssh_host_key   TEXT NOT NULL DEFAULT '', -- pinned bastion public key
+  ssh_key_enc    BYTEA, -- encrypted PEM private key
+  ssh_password_enc BYTEA, -- encrypted
   created_by  UUID REFERENCES users(id),
   created_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
   UNIQUE (project_id, env, name)
 );
 
-CREATE TYPE db_engine AS ENUM ('postgres', 'mysql');
-
 -- Store secrets encrypted. encryption handled in app.
 CREATE TABLE db_targets (
   id            UUID PRIMARY KEY,
   db_set_id     UUID NOT NULL REFERENCES db_sets(id) ON DELETE CASCADE,
-  engine        db_engine NOT NULL,
+  engine        TEXT NOT NULL, -- a registered engine name (postgres, mysql, sqlite); checked by the app
   host          TEXT NOT NULL,
   port          INT NOT NULL,
   dbname        TEXT NOT NULL,
   username      TEXT NOT NULL,
   password_enc  BYTEA NOT NULL, -- encrypted
+  tls_mode      TEXT NOT NULL DEFAULT 'disable', -- disable | require | verify-ca | verify-full
+  tls_server_name TEXT NOT NULL DEFAULT '', -- verify-full name; empty uses host
+  tls_ca_enc    BYTEA, -- encrypted PEM CA bundle
+  tls_cert_enc  BYTEA, -- encrypted PEM client certificate
+  tls_key_enc   BYTEA, -- encrypted PEM client key
+  ssh_host      TEXT NOT NULL DEFAULT '', -- own SSH bastion, overrides the db set's; '' = none
+  ssh_port      INT NOT NULL DEFAULT 22,
+  ssh_user      TEXT NOT NULL DEFAULT '',
+  ssh_host_key  TEXT NOT NULL DEFAULT '', -- pinned bastion public key
+  ssh_key_enc   BYTEA, -- encrypted PEM private key
+  ssh_password_enc BYTEA, -- encrypted
   options_json  JSONB NOT NULL DEFAULT '{}'::jsonb,
+  fanout_pattern TEXT NOT NULL DEFAULT '', -- LIKE pattern of tenant schemas or databases; '' = a plain target
   is_active     BOOLEAN NOT NULL DEFAULT true,
+  priority      INT NOT NULL DEFAULT 100, -- rollout order, lowest first; the first target is the canary
   created_at    TIMESTAMPTZ NOT NULL DEFAULT now()
 );
 
@@ -71,13 +93,23 @@ CREATE TABLE migrations (
   checksum_down    TEXT,
   version          INT NOT NULL DEFAULT 1,
   transaction_mode tx_mode NOT NULL DEFAULT 'auto',
+  failure_policy   TEXT, -- overrides the db set policy when set
+  -- durations such as '30s'; override the target options_json defaults when set
+  advisory_lock_timeout TEXT,
+  lock_timeout          TEXT,
+  statement_timeout     TEXT,
+  -- assertion queries run around sql_up; expect 'true' (one truthy value) or 'no_rows'
+  precheck_sql          TEXT,
+  precheck_expect       TEXT NOT NULL DEFAULT 'true',
+  postcheck_sql         TEXT,
+  postcheck_expect      TEXT NOT NULL DEFAULT 'true',
   created_by       UUID REFERENCES users(id),
   created_at       TIMESTAMPTZ NOT NULL DEFAULT now(),
   updated_at       TIMESTAMPTZ NOT NULL DEFAULT now(),
   UNIQUE (project_id, migration_key)
 );
 
-CREATE TYPE run_type AS ENUM ('apply', 'rollback');
+CREATE TYPE run_type AS ENUM ('apply', 'rollback', 'dry_run', 'baseline');
 CREATE TYPE run_status AS ENUM (
   'queued',
   'awaiting_approval',
@@ -86,7 +118,8 @@ CREATE TYPE run_status AS ENUM (
   'running',
   'executed',
   'failed',
-  'canceled'
+  'canceled',
+  'paused'
 );
 
 CREATE TABLE runs (
@@ -107,9 +140,43 @@ CREATE TABLE runs (
   executed_by    UUID REFERENCES users(id),
   started_at     TIMESTAMPTZ,
   finished_at    TIMESTAMPTZ,
+  heartbeat_at   TIMESTAMPTZ, -- bumped by the executing instance; stale running runs are recovered
 
   checksum_up_at_request   TEXT NOT NULL,
-  checksum_down_at_request TEXT
+  checksum_down_at_request TEXT,
+
+  -- resolved at request time from the migration/db set
+  max_parallel   INT NOT NULL DEFAULT 1,
+  failure_policy TEXT NOT NULL DEFAULT 'stop_on_first_failure',
+
+  -- set when a cancel is requested; a running run is stopped by its executor
+  cancel_requested_at TIMESTAMPTZ,
+  cancel_requested_by UUID REFERENCES users(id),
+  cancel_reason       TEXT,
+
+  -- dry runs only: the script they try (apply = sql_up, rollback = sql_down); always rolled back
+  dry_run_of     TEXT CHECK (dry_run_of IN ('apply', 'rollback')),
+
+  -- baseline runs only: write the ledger row without executing sql_up, after this query passes
+  verify_sql     TEXT,
+  verify_expect  TEXT NOT NULL DEFAULT 'true',
+
+  -- latest pre-flight report; warn checks block execution unless their ids are accepted
+  preflight         JSONB,
+  accepted_warnings TEXT[] NOT NULL DEFAULT '{}',
+
+  -- resolved at request time from the request/db set; a manual gate pauses the run between waves
+  wave_plan TEXT NOT NULL DEFAULT '',
+  wave_gate TEXT NOT NULL DEFAULT 'none',
+
+  -- approved runs with scheduled_for are queued by the scheduler at that time;
+  -- scheduled_until is the end of the maintenance window they were scheduled into
+  scheduled_for        TIMESTAMPTZ,
+  scheduled_until      TIMESTAMPTZ,
+  scheduled_by         UUID REFERENCES users(id),
+  -- set when the scheduler dropped the schedule (window missed, checksum changed, approval invalidated)
+  schedule_skipped_at  TIMESTAMPTZ,
+  schedule_skip_reason TEXT
 );
 
 CREATE TYPE run_item_status AS ENUM (
@@ -129,7 +196,29 @@ CREATE TABLE run_items (
   started_at   TIMESTAMPTZ,
   finished_at  TIMESTAMPTZ,
   error        TEXT,
-  log          TEXT
+  error_code   TEXT, -- set for retryable failures, e.g. lock_timeout
+  log          TEXT,
+  attempt      INT NOT NULL DEFAULT 1, -- bumped on every retry
+  rows_affected BIGINT, -- total over the script's statements, set when the item executed
+  wave         INT NOT NULL DEFAULT 1, -- waves run in order; a failed wave stops the rollout
+  position     INT NOT NULL DEFAULT 0, -- rollout order of the target within the run
+  tenant       TEXT NOT NULL DEFAULT '' -- schema or database of a fan-out target; '' = the target itself
+);
+
+-- earlier attempts of a run item, archived when the item is retried
+CREATE TABLE run_item_attempts (
+  id          UUID PRIMARY KEY,
+  run_item_id UUID NOT NULL REFERENCES run_items(id) ON DELETE CASCADE,
+  attempt     INT NOT NULL,
+  status      run_item_status NOT NULL,
+  started_at  TIMESTAMPTZ,
+  finished_at TIMESTAMPTZ,
+  error       TEXT,
+  error_code  TEXT,
+  log         TEXT,
+  retried_by  UUID REFERENCES users(id),
+  retried_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+  UNIQUE (run_item_id, attempt)
 );
 
 CREATE TABLE approvals (
@@ -154,7 +243,35 @@ CREATE TABLE audit_events (
   created_at  TIMESTAMPTZ NOT NULL DEFAULT now()
 );
 
+-- latest ledger sync per target: what the target ledger holds compared with the hub
+CREATE TABLE target_syncs (
+  db_target_id  UUID PRIMARY KEY REFERENCES db_targets(id) ON DELETE CASCADE,
+  claimed_at    TIMESTAMPTZ NOT NULL DEFAULT now(),
+  checked_at    TIMESTAMPTZ,
+  status        TEXT, -- ok | drift | error; NULL until the first sync finishes
+  error         TEXT,
+  ledger        TEXT NOT NULL DEFAULT '',
+  drift_count   INT NOT NULL DEFAULT 0,
+  drift_since   TIMESTAMPTZ, -- first sync of the current drift; NULL when in sync
+  entries       JSONB NOT NULL DEFAULT '[]'::jsonb -- per-key DriftEntry list
+);
+
+CREATE TABLE ledger_imports (
+  id            UUID PRIMARY KEY, -- tool_run_id of the target ledger rows it seeded
+  project_id    UUID NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+  db_target_id  UUID NOT NULL REFERENCES db_targets(id) ON DELETE CASCADE,
+  source        TEXT NOT NULL, -- flyway | golang-migrate | goose | liquibase
+  source_table  TEXT NOT NULL,
+  ledger        TEXT NOT NULL,
+  entries       JSONB NOT NULL DEFAULT '[]'::jsonb, -- per-entry ImportEntry list
+  imported_by   UUID NOT NULL REFERENCES users(id),
+  imported_at   TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
 CREATE INDEX runs_status_idx ON runs(status);
 CREATE INDEX runs_env_idx ON runs(env);
+CREATE INDEX runs_running_heartbeat_idx ON runs(heartbeat_at) WHERE status = 'running';
+CREATE INDEX runs_scheduled_for_idx ON runs(scheduled_for) WHERE status = 'approved';
 CREATE INDEX migrations_project_idx ON migrations(project_id);
 CREATE INDEX audit_events_created_at_idx ON audit_events(created_at);
+CREATE INDEX ledger_imports_target_idx ON ledger_imports(db_target_id);
@@ -14,6 +14,7 @@ import (
 	"db_inner_migrator_syncer/internal/audit"
 	"db_inner_migrator_syncer/internal/auth"
 	"db_inner_migrator_syncer/internal/config"
+	"db_inner_migrator_syncer/internal/engine"
 	"db_inner_migrator_syncer/internal/executor"
 	httpserver "db_inner_migrator_syncer/internal/http"
 	"db_inner_migrator_syncer/internal/logging"
@@ -31,6 +32,7 @@ func main() {
 	}
 
 	logger := logging.NewLogger(cfg.LogLevel)
+	engine.SetSQLiteRoot(cfg.SQLiteRoot)
 
 	dbPool, err := store.Connect(ctx, cfg.DatabaseURL)
 	if err != nil {
@@ -64,16 +66,37 @@ func main() {
 
 	authHandler := httpserver.NewAuthHandler(cfg, logger, oidcProvider, sessions, dbPool)
 	projectHandler := httpserver.NewProjectHandler(dbPool, logger, sessions)
-	dbHandler := httpserver.NewDBInventoryHandler(dbPool, logger, sessions, cfg.SecretKeyBytes)
+	exec := executor.New(dbPool, cfg.SecretKeyBytes, logger, executor.Options{
+		RetryApprovalValidity: cfg.Executor.RetryApprovalValidity,
+		InstanceID:            cfg.Executor.InstanceID,
+	})
+	dbHandler := httpserver.NewDBInventoryHandler(dbPool, logger, sessions, cfg.SecretKeyBytes, exec)
 	migrationHandler := httpserver.NewMigrationHandler(dbPool, logger)
-	exec := executor.New(dbPool, cfg.SecretKeyBytes, logger)
 	runHandler := httpserver.NewRunHandler(dbPool, logger, exec)
 	renderer := httpserver.NewTemplateRenderer()
 	uiHandler := httpserver.NewUIHandler(dbPool, logger, sessions, authenticator, renderer, cfg.SecretKeyBytes, exec)
 	server := httpserver.New(cfg, logger, dbPool, authenticator, authHandler, projectHandler, dbHandler, migrationHandler, runHandler, uiHandler)
 
-	if err := server.Start(ctx); err != nil {
-		logger.Error("server stopped with error", "error", err)
+	workers := executor.NewWorkerPool(exec, executor.WorkerPoolConfig{
+		Workers:          cfg.Executor.Workers,
+		PollInterval:     cfg.Executor.PollInterval,
+		DrainTimeout:     cfg.Executor.DrainTimeout,
+		RecoveryInterval: cfg.Executor.RecoveryInterval,
+		StaleAfter:       cfg.Executor.StaleAfter,
+		ScheduleInterval: cfg.Executor.ScheduleInterval,
+		DriftInterval:    cfg.Executor.DriftInterval,
+	})
+	workersDone := make(chan struct{})
+	go func() {
+		workers.Run(ctx)
+		close(workersDone)
+	}()
+
+	serverErr := server.Start(ctx)
+	stop()
+	<-workersDone
+	if serverErr != nil {
+		logger.Error("server stopped with error", "error", serverErr)
 		os.Exit(1)
 	}
 }
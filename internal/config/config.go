@@ -3,8 +3,12 @@ package config
 import (
 	"encoding/base64"
 	"errors"
+	"fmt"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 )
 
 type Config struct {
@@ -13,7 +17,27 @@ type Config struct {
 	SecretKey      string
 	SecretKeyBytes []byte
 	LogLevel       string
-	OIDC           OIDCConfig
+	// SQLiteRoot is the directory SQLite target files must be under; empty
+	// disables SQLite targets.
+	SQLiteRoot string
+	OIDC       OIDCConfig
+	Executor   ExecutorConfig
+}
+
+type ExecutorConfig struct {
+	Workers          int
+	PollInterval     time.Duration
+	DrainTimeout     time.Duration
+	RecoveryInterval time.Duration
+	StaleAfter       time.Duration
+	// RetryApprovalValidity is how long after approval a failed run may be retried.
+	RetryApprovalValidity time.Duration
+	// ScheduleInterval is how often the scheduler looks for due scheduled runs.
+	ScheduleInterval time.Duration
+	// InstanceID names this server in the target ledger rows it writes.
+	InstanceID string
+	// DriftInterval is how often each target ledger is synced for drift; 0 disables.
+	DriftInterval time.Duration
 }
 
 type OIDCConfig struct {
@@ -28,6 +52,7 @@ func Load() (Config, error) {
 	cfg := Config{
 		HTTPAddress: getEnv("MIGRATEHUB_HTTP_ADDR", ":8080"),
 		LogLevel:    getEnv("MIGRATEHUB_LOG_LEVEL", "info"),
+		SQLiteRoot:  strings.TrimSpace(os.Getenv("MIGRATEHUB_SQLITE_ROOT")),
 		OIDC: OIDCConfig{
 			ClientID:       os.Getenv("MIGRATEHUB_OIDC_GOOGLE_CLIENT_ID"),
 			ClientSecret:   os.Getenv("MIGRATEHUB_OIDC_GOOGLE_CLIENT_SECRET"),
@@ -48,6 +73,55 @@ func Load() (Config, error) {
 		cfg.SecretKeyBytes = keyBytes
 	}
 
+	workers, err := getEnvInt("MIGRATEHUB_EXECUTOR_WORKERS", 2)
+	if err != nil {
+		return Config{}, err
+	}
+	pollInterval, err := getEnvDuration("MIGRATEHUB_EXECUTOR_POLL_INTERVAL", 2*time.Second)
+	if err != nil {
+		return Config{}, err
+	}
+	drainTimeout, err := getEnvDuration("MIGRATEHUB_EXECUTOR_DRAIN_TIMEOUT", 5*time.Minute)
+	if err != nil {
+		return Config{}, err
+	}
+	recoveryInterval, err := getEnvDuration("MIGRATEHUB_EXECUTOR_RECOVERY_INTERVAL", time.Minute)
+	if err != nil {
+		return Config{}, err
+	}
+	staleAfter, err := getEnvDuration("MIGRATEHUB_EXECUTOR_STALE_AFTER", 2*time.Minute)
+	if err != nil {
+		return Config{}, err
+	}
+	retryValidity, err := getEnvDuration("MIGRATEHUB_RETRY_APPROVAL_VALIDITY", 24*time.Hour)
+	if err != nil {
+		return Config{}, err
+	}
+	scheduleInterval, err := getEnvDuration("MIGRATEHUB_SCHEDULER_INTERVAL", 30*time.Second)
+	if err != nil {
+		return Config{}, err
+	}
+	driftInterval, err := getEnvDuration("MIGRATEHUB_DRIFT_INTERVAL", time.Hour)
+	if err != nil {
+		return Config{}, err
+	}
+	instanceID := os.Getenv("MIGRATEHUB_INSTANCE_ID")
+	if instanceID == "" {
+		instanceID, _ = os.Hostname()
+	}
+	cfg.Executor = ExecutorConfig{
+		Workers:          workers,
+		PollInterval:     pollInterval,
+		DrainTimeout:     drainTimeout,
+		RecoveryInterval: recoveryInterval,
+		StaleAfter:       staleAfter,
+
+		RetryApprovalValidity: retryValidity,
+		ScheduleInterval:      scheduleInterval,
+		InstanceID:            instanceID,
+		DriftInterval:         driftInterval,
+	}
+
 	if err := cfg.Validate(); err != nil {
 		return Config{}, err
 	}
@@ -71,6 +145,30 @@ func (c Config) Validate() error {
 	if c.OIDC.RedirectURL == "" {
 		return errors.New("MIGRATEHUB_OIDC_GOOGLE_REDIRECT_URL is required")
 	}
+	if c.Executor.Workers < 1 {
+		return errors.New("MIGRATEHUB_EXECUTOR_WORKERS must be at least 1")
+	}
+	if c.Executor.PollInterval <= 0 {
+		return errors.New("MIGRATEHUB_EXECUTOR_POLL_INTERVAL must be positive")
+	}
+	if c.Executor.RecoveryInterval <= 0 {
+		return errors.New("MIGRATEHUB_EXECUTOR_RECOVERY_INTERVAL must be positive")
+	}
+	if c.Executor.StaleAfter < 10*time.Second {
+		return errors.New("MIGRATEHUB_EXECUTOR_STALE_AFTER must be at least 10s")
+	}
+	if c.Executor.ScheduleInterval <= 0 {
+		return errors.New("MIGRATEHUB_SCHEDULER_INTERVAL must be positive")
+	}
+	if c.Executor.RetryApprovalValidity < 0 {
+		return errors.New("MIGRATEHUB_RETRY_APPROVAL_VALIDITY must not be negative")
+	}
+	if c.Executor.DriftInterval < 0 {
+		return errors.New("MIGRATEHUB_DRIFT_INTERVAL must not be negative")
+	}
+	if c.SQLiteRoot != "" && !filepath.IsAbs(c.SQLiteRoot) {
+		return errors.New("MIGRATEHUB_SQLITE_ROOT must be an absolute path")
+	}
 	return nil
 }
 
@@ -81,6 +179,30 @@ func getEnv(key, defaultVal string) string {
 	return defaultVal
 }
 
+func getEnvInt(key string, defaultVal int) (int, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return defaultVal, nil
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(v))
+	if err != nil {
+		return 0, fmt.Errorf("%s must be an integer", key)
+	}
+	return n, nil
+}
+
+func getEnvDuration(key string, defaultVal time.Duration) (time.Duration, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return defaultVal, nil
+	}
+	d, err := time.ParseDuration(strings.TrimSpace(v))
+	if err != nil {
+		return 0, fmt.Errorf("%s must be a duration (e.g. 30s, 5m)", key)
+	}
+	return d, nil
+}
+
 func splitAndTrim(input string) []string {
 	if input == "" {
 		return nil
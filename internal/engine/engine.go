@@ -0,0 +1,428 @@
+// Package engine holds the database engines targets can run on. An engine
+// opens sessions on a target; a session covers everything the executor does
+// there: locking, session time limits, statements and transactions, check
+// queries and the migration ledger. Engines register themselves by name, so
+// the executor, store validation and UI pick up a new engine without changes.
+package engine
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrUnknown is returned by Lookup for names no engine registered.
+	ErrUnknown = errors.New("invalid engine")
+	// ErrInvalidTarget matches the errors of Engine.Validate.
+	ErrInvalidTarget = errors.New("invalid target")
+	// ErrLocked is returned by Lock when another session holds the target lock.
+	ErrLocked = errors.New("target is locked by another run")
+	// ErrLockTimeout marks a statement that waited longer than the lock timeout.
+	ErrLockTimeout = errors.New("lock timeout")
+	// ErrStatementTimeout marks a statement that ran longer than the statement timeout.
+	ErrStatementTimeout = errors.New("statement timeout")
+)
+
+// Logger receives server notices, warnings and ledger upgrades of a session.
+type Logger interface {
+	Info(msg string, args ...any)
+	Error(msg string, args ...any)
+}
+
+// Target is what an engine needs to reach a target database.
+type Target struct {
+	ID       uuid.UUID
+	Host     string
+	Port     int
+	DBName   string
+	Username string
+	Password string
+	TLS      TLS
+	// Tunnel is the SSH bastion to connect through, or nil to dial directly.
+	Tunnel *Tunnel
+	// Options is the target's options_json; engines read their session
+	// options from it.
+	Options json.RawMessage
+	// Tenant is the schema or database a fan-out target runs an item in, or
+	// empty for the target itself. Sessions keep the ledger and the lock per
+	// tenant.
+	Tenant string
+}
+
+// Engine opens sessions on one kind of database server.
+type Engine interface {
+	// Name is the db_targets.engine value.
+	Name() string
+	// Dialect is the sqlscript dialect scripts are split and classified with.
+	Dialect() string
+	// Validate checks the connection fields and session options of a target
+	// before it is stored.
+	Validate(t Target) error
+	// SessionOptions lists the options_json keys the engine applies to its
+	// sessions.
+	SessionOptions() []string
+	// TenantKind names what fan-out targets expand to, "schema" or
+	// "database", or is empty when the engine has no fan-out targets.
+	TenantKind() string
+	// Address names the target database in logs and reports, without
+	// credentials.
+	Address(t Target) string
+	// Open connects to the target. Server notices and warnings go to log,
+	// which may be nil.
+	Open(ctx context.Context, t Target, log Logger) (Session, error)
+}
+
+// Session is one connection to a target. Methods that take a Tx run inside
+// that transaction, or on the session itself when it is nil. Only Cancel may
+// be called while another method is running.
+type Session interface {
+	Ping(ctx context.Context) error
+	Version(ctx context.Context) (Version, error)
+	Close(ctx context.Context) error
+
+	// Lock takes the per-target migration lock, waiting at most wait; it
+	// returns ErrLocked when another session keeps it.
+	Lock(ctx context.Context, wait time.Duration) error
+	Unlock(ctx context.Context) error
+	// LockFree reports whether the lock is free without taking it.
+	LockFree(ctx context.Context) (bool, error)
+	// SetTimeouts applies the lock and statement timeouts to the session; zero
+	// resets a limit to the server default.
+	SetTimeouts(ctx context.Context, lock time.Duration, statement time.Duration) error
+	// Cancel interrupts the statement in flight; the session stays usable.
+	Cancel(ctx context.Context) error
+
+	// Exec runs one statement. Statements stopped by a session limit return
+	// errors matching ErrLockTimeout or ErrStatementTimeout.
+	Exec(ctx context.Context, tx Tx, query string) (Result, error)
+	Begin(ctx context.Context) (Tx, error)
+	// Query runs a query in a savepoint of tx, or in a read-only transaction,
+	// and rolls it back. It returns the column count and up to limit rows.
+	Query(ctx context.Context, tx Tx, query string, limit int) (int, [][]any, error)
+	// QueryText runs a read-only query and returns every column as text.
+	QueryText(ctx context.Context, query string) ([][]sql.NullString, error)
+	// CastText returns an expression casting col to text.
+	CastText(col string) string
+	// FindTable returns the quoted name of a table in the session's schema
+	// or database, matched case-insensitively, or "" when there is none.
+	FindTable(ctx context.Context, name string) (string, error)
+	// Tenants lists the schemas or databases matching a LIKE pattern in
+	// name order, leaving out the server's own.
+	Tenants(ctx context.Context, pattern string) ([]string, error)
+
+	LedgerState(ctx context.Context, l Ledger) (LedgerState, error)
+	// EnsureLedger creates the ledger, or upgrades an older one in place, and
+	// returns its state. Call it under the target lock.
+	EnsureLedger(ctx context.Context, l Ledger, log Logger) (LedgerState, error)
+	// LookupLedger returns the row of key; rows marked rolled back count as
+	// not recorded and are reported with RolledBack set.
+	LookupLedger(ctx context.Context, l Ledger, st LedgerState, key string) (LedgerEntry, error)
+	// ReadLedger returns the rows that are not marked rolled back.
+	ReadLedger(ctx context.Context, l Ledger, st LedgerState) ([]LedgerRow, error)
+	// RecordApply writes the row of an apply, replacing a row marked rolled back.
+	RecordApply(ctx context.Context, tx Tx, l Ledger, r LedgerRecord) error
+	// RecordRollback marks the row of key rolled back by runID; the row is kept.
+	RecordRollback(ctx context.Context, tx Tx, l Ledger, key string, runID string) error
+	// LedgerAccess tells whether a run could read, create, upgrade and write
+	// the ledger, without changing anything.
+	LedgerAccess(ctx context.Context, l Ledger, st LedgerState) (LedgerAccess, error)
+}
+
+// Tx is a transaction opened by Session.Begin.
+type Tx interface {
+	Commit(ctx context.Context) error
+	Rollback(ctx context.Context) error
+}
+
+// Result is what a statement did.
+type Result struct {
+	RowsAffected int64
+	// Command is the command tag, when the server reports one.
+	Command string
+}
+
+// Version is the server version of a target.
+type Version struct {
+	Name string
+	// Warning is set for servers older than, or unlike, the tested ones.
+	Warning string
+}
+
+var (
+	mu      sync.RWMutex
+	engines = make(map[string]Engine)
+)
+
+// Register makes an engine available under its name. It panics when the
+// name is taken, like database/sql.Register.
+func Register(e Engine) {
+	mu.Lock()
+	defer mu.Unlock()
+	name := strings.ToLower(e.Name())
+	if _, dup := engines[name]; dup {
+		panic("engine: Register called twice for " + name)
+	}
+	engines[name] = e
+}
+
+// Lookup returns the engine registered under name.
+func Lookup(name string) (Engine, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+	e, ok := engines[strings.ToLower(name)]
+	if !ok {
+		return nil, ErrUnknown
+	}
+	return e, nil
+}
+
+// Names lists the registered engines in alphabetical order.
+func Names() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	out := make([]string, 0, len(engines))
+	for name := range engines {
+		out = append(out, name)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Dialects lists the sqlscript dialects of the registered engines, once each.
+func Dialects() []string {
+	var out []string
+	seen := make(map[string]bool)
+	for _, name := range Names() {
+		e, _ := Lookup(name)
+		if d := e.Dialect(); !seen[d] {
+			seen[d] = true
+			out = append(out, d)
+		}
+	}
+	return out
+}
+
+// invalid is a validation error; it matches ErrInvalidTarget.
+type invalid string
+
+func (e invalid) Error() string      { return string(e) }
+func (invalid) Is(target error) bool { return target == ErrInvalidTarget }
+
+// validateServer checks the fields of a target reached over the network.
+func validateServer(t Target) error {
+	if t.Port <= 0 {
+		return invalid("port must be positive")
+	}
+	if strings.TrimSpace(t.Host) == "" || strings.TrimSpace(t.DBName) == "" || strings.TrimSpace(t.Username) == "" {
+		return invalid("host, dbname, username required")
+	}
+	if _, err := t.TLS.Config(t.Host); err != nil {
+		return err
+	}
+	return t.Tunnel.Validate()
+}
+
+func serverAddress(t Target) string {
+	return fmt.Sprintf("%s:%d/%s", t.Host, t.Port, t.DBName)
+}
+
+// sqlQueryer is a database/sql connection or transaction.
+type sqlQueryer interface {
+	QueryContext(context.Context, string, ...any) (*sql.Rows, error)
+}
+
+// sqlText runs a query on a database/sql engine and returns every column as text.
+func sqlText(ctx context.Context, q sqlQueryer, query string, args ...any) ([][]sql.NullString, error) {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	var out [][]sql.NullString
+	for rows.Next() {
+		row := make([]sql.NullString, len(cols))
+		dest := make([]any, len(row))
+		for i := range row {
+			dest[i] = &row[i]
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		out = append(out, row)
+	}
+	return out, rows.Err()
+}
+
+// sqlValues reads up to limit rows and closes rows; see Session.Query.
+func sqlValues(rows *sql.Rows, limit int) (int, [][]any, error) {
+	defer rows.Close()
+	names, err := rows.Columns()
+	if err != nil {
+		return 0, nil, err
+	}
+	var values [][]any
+	for len(values) < limit && rows.Next() {
+		v := make([]any, len(names))
+		ptrs := make([]any, len(names))
+		for i := range v {
+			ptrs[i] = &v[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return 0, nil, err
+		}
+		values = append(values, v)
+	}
+	rows.Close()
+	return len(names), values, rows.Err()
+}
+
+// timeout marks err as stopped by a session limit without changing its message.
+type timeout struct {
+	kind error
+	err  error
+}
+
+func (t *timeout) Error() string   { return t.err.Error() }
+func (t *timeout) Unwrap() []error { return []error{t.kind, t.err} }
+
+func timedOut(kind error, err error) error {
+	return &timeout{kind: kind, err: err}
+}
+
+// LedgerVersion is the ledger schema engines write; older ledgers are
+// upgraded in place before a run writes to them.
+const LedgerVersion = 2
+
+// ledgerCommentPrefix marks a ledger table with its schema version in the
+// table comment. Tables created before the ledger was versioned have none.
+const ledgerCommentPrefix = "migrate-hub ledger v"
+
+// LedgerColumns are the ledger columns and the ledger version that added
+// each. Engines map them to their own column types.
+var LedgerColumns = []LedgerColumn{
+	{"migration_key", 1},
+	{"checksum_up", 1},
+	{"checksum_down", 1},
+	{"applied_at", 1},
+	{"applied_by", 1},
+	{"tool_run_id", 1},
+	{"migration_version", 2},
+	{"run_type", 2},
+	{"duration_ms", 2},
+	{"executed_by_email", 2},
+	{"tool_instance_id", 2},
+	{"rolled_back_at", 2},
+}
+
+type LedgerColumn struct {
+	Name  string
+	Since int
+}
+
+// Ledger locates the migration ledger table on a target. An empty Schema is
+// the connection's current schema (Postgres) or database (MySQL).
+type Ledger struct {
+	Schema string
+	Table  string
+}
+
+// String returns the ledger name as schema.table, or just the table.
+func (l Ledger) String() string {
+	if l.Schema == "" {
+		return l.Table
+	}
+	return l.Schema + "." + l.Table
+}
+
+// quoted returns the schema-qualified table name quoted with q. Quotes in
+// tenant schema names are doubled.
+func (l Ledger) quoted(q string) string {
+	table := q + strings.ReplaceAll(l.Table, q, q+q) + q
+	if l.Schema == "" {
+		return table
+	}
+	return q + strings.ReplaceAll(l.Schema, q, q+q) + q + "." + table
+}
+
+// LedgerState is the ledger table as found on a target.
+type LedgerState struct {
+	Exists  bool
+	Version int
+}
+
+func parseLedgerVersion(comment string) int {
+	v, err := strconv.Atoi(strings.TrimPrefix(comment, ledgerCommentPrefix))
+	if !strings.HasPrefix(comment, ledgerCommentPrefix) || err != nil || v < 1 {
+		return 1
+	}
+	return v
+}
+
+func ledgerComment() string {
+	return fmt.Sprintf("%s%d", ledgerCommentPrefix, LedgerVersion)
+}
+
+// LedgerEntry is the ledger row of a migration key. A row marked rolled back
+// is not Found; RolledBack is set and ToolRunID is the rollback run.
+type LedgerEntry struct {
+	Found      bool
+	RolledBack bool
+	ChecksumUp string
+	ToolRunID  string
+}
+
+// LedgerRow is a row of a target ledger that is not marked rolled back.
+type LedgerRow struct {
+	Key        string
+	ChecksumUp string
+	ToolRunID  string
+	AppliedAt  string
+}
+
+// LedgerRecord is the ledger row an apply writes.
+type LedgerRecord struct {
+	Key          string
+	ChecksumUp   string
+	ChecksumDown *string
+	AppliedBy    string
+	RunID        string
+	RunType      string
+	Version      int
+	Duration     time.Duration
+	Email        string
+	InstanceID   string
+}
+
+// LedgerAccess is what pre-flight reports about the ledger privileges.
+type LedgerAccess struct {
+	// Readable is false when reading the ledger would fail.
+	Readable bool
+	// Problem is why a run could not create, upgrade or write the ledger.
+	Problem string
+	// Unsure marks a Problem the engine cannot confirm, such as privileges
+	// granted through roles.
+	Unsure bool
+	// Detail describes a usable ledger.
+	Detail string
+}
+
+func upgradeDetail(st LedgerState) string {
+	if st.Exists && st.Version < LedgerVersion {
+		return fmt.Sprintf("ledger readable and writable; will be upgraded from v%d to v%d", st.Version, LedgerVersion)
+	}
+	return "ledger readable and writable"
+}
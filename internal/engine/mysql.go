@@ -0,0 +1,711 @@
+package engine
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"net"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// Oldest server version the executor is tested against.
+const (
+	minMySQLMajor = 5
+	minMySQLMinor = 7
+)
+
+var mysqlLedgerTypes = map[string]string{
+	"migration_key":     "VARCHAR(255) PRIMARY KEY",
+	"checksum_up":       "TEXT NOT NULL",
+	"checksum_down":     "TEXT",
+	"applied_at":        "DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP",
+	"applied_by":        "VARCHAR(255)",
+	"tool_run_id":       "VARCHAR(255)",
+	"migration_version": "INT",
+	"run_type":          "VARCHAR(32)",
+	"duration_ms":       "BIGINT",
+	"executed_by_email": "VARCHAR(255)",
+	"tool_instance_id":  "VARCHAR(255)",
+	"rolled_back_at":    "DATETIME NULL",
+}
+
+// mysqlTunnelNet is the driver network of tunnelled targets. The driver
+// only dials registered networks, so the session's tunnel travels in the
+// dial context; see tunnelConnector.
+const mysqlTunnelNet = "migrate-hub-ssh"
+
+type tunnelKey struct{}
+
+func init() {
+	Register(mysqlEngine{})
+	mysql.RegisterDialContext(mysqlTunnelNet, func(ctx context.Context, addr string) (net.Conn, error) {
+		tun, ok := ctx.Value(tunnelKey{}).(*tunnel)
+		if !ok {
+			return nil, errors.New("ssh tunnel closed")
+		}
+		return tun.dial(ctx, "tcp", addr)
+	})
+}
+
+// tunnelConnector connects the session's pool through its tunnel.
+type tunnelConnector struct {
+	driver.Connector
+	tun *tunnel
+}
+
+func (c tunnelConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	return c.Connector.Connect(context.WithValue(ctx, tunnelKey{}, c.tun))
+}
+
+type mysqlEngine struct{}
+
+func (mysqlEngine) Name() string             { return "mysql" }
+func (mysqlEngine) Dialect() string          { return "mysql" }
+func (mysqlEngine) Address(t Target) string  { return serverAddress(t) }
+func (mysqlEngine) SessionOptions() []string { return optionKeys(mysqlOptions{}) }
+func (mysqlEngine) TenantKind() string       { return "database" }
+
+func (mysqlEngine) Validate(t Target) error {
+	if err := validateServer(t); err != nil {
+		return err
+	}
+	_, err := mysqlSessionOptions(t)
+	return err
+}
+
+// mysqlOptions are the session options of MySQL targets. SQLMode is a
+// pointer because an empty sql_mode is a setting of its own.
+type mysqlOptions struct {
+	Charset   string  `json:"charset"`
+	Collation string  `json:"collation"`
+	SQLMode   *string `json:"sql_mode"`
+	TimeZone  string  `json:"time_zone"`
+}
+
+func mysqlSessionOptions(t Target) (mysqlOptions, error) {
+	var o mysqlOptions
+	if err := decodeOptions(t.Options, &o); err != nil {
+		return o, err
+	}
+	if err := checkOptionName("charset", o.Charset); err != nil {
+		return o, err
+	}
+	if err := checkOptionName("collation", o.Collation); err != nil {
+		return o, err
+	}
+	if o.Charset != "" && o.Collation != "" && !strings.HasPrefix(strings.ToLower(o.Collation), strings.ToLower(o.Charset)+"_") {
+		return o, invalid(fmt.Sprintf("option collation: %s is not a collation of charset %s", o.Collation, o.Charset))
+	}
+	if o.SQLMode != nil && strings.Trim(*o.SQLMode, "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz_,") != "" {
+		return o, invalid("option sql_mode: a comma-separated list of modes such as TRADITIONAL,NO_ZERO_DATE")
+	}
+	if strings.Trim(o.TimeZone, "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789_/+:-") != "" {
+		return o, invalid("option time_zone: use an offset such as +00:00, SYSTEM or a named zone")
+	}
+	return o, nil
+}
+
+// apply sets the options on the session connection. Charset and collation
+// are checked names, the other values are bound.
+func (o mysqlOptions) apply(ctx context.Context, conn *sql.Conn) error {
+	exec := func(stmt string, args ...any) error {
+		if _, err := conn.ExecContext(ctx, stmt, args...); err != nil {
+			return fmt.Errorf("session options: %s: %w", stmt, err)
+		}
+		return nil
+	}
+	var err error
+	switch {
+	case o.Charset != "" && o.Collation != "":
+		err = exec("SET NAMES " + o.Charset + " COLLATE " + o.Collation)
+	case o.Charset != "":
+		err = exec("SET NAMES " + o.Charset)
+	case o.Collation != "":
+		err = exec("SET SESSION collation_connection = ?", o.Collation)
+	}
+	if err == nil && o.SQLMode != nil {
+		err = exec("SET SESSION sql_mode = ?", *o.SQLMode)
+	}
+	if err == nil && o.TimeZone != "" {
+		err = exec("SET SESSION time_zone = ?", o.TimeZone)
+	}
+	return err
+}
+
+// Open pins a single connection: locks, warnings and transactions are per
+// session. The pool keeps a second connection free for KILL QUERY.
+func (mysqlEngine) Open(ctx context.Context, t Target, log Logger) (Session, error) {
+	opts, err := mysqlSessionOptions(t)
+	if err != nil {
+		return nil, err
+	}
+	cfg := mysql.Config{
+		User:                 t.Username,
+		Passwd:               t.Password,
+		Net:                  "tcp",
+		Addr:                 net.JoinHostPort(t.Host, fmt.Sprintf("%d", t.Port)),
+		DBName:               t.DBName,
+		AllowNativePasswords: true,
+		Params:               map[string]string{},
+	}
+	// A tenant database replaces the target's; a missing one fails the
+	// handshake.
+	if t.Tenant != "" {
+		cfg.DBName = t.Tenant
+	}
+	tlsCfg, err := t.TLS.Config(t.Host)
+	if err != nil {
+		return nil, err
+	}
+	if tlsCfg != nil {
+		// The driver takes TLS configs by registered name; each target has
+		// one, replaced on every connect so edits apply.
+		cfg.TLSConfig = "migrate-hub-" + t.ID.String()
+		if err := mysql.RegisterTLSConfig(cfg.TLSConfig, tlsCfg); err != nil {
+			return nil, err
+		}
+	}
+	tun, err := openTunnel(ctx, t.Tunnel, log)
+	if err != nil {
+		return nil, err
+	}
+	if tun != nil {
+		cfg.Net = mysqlTunnelNet
+	}
+	connector, err := mysql.NewConnector(&cfg)
+	if err != nil {
+		tun.close()
+		return nil, err
+	}
+	if tun != nil {
+		connector = tunnelConnector{Connector: connector, tun: tun}
+	}
+	db := sql.OpenDB(connector)
+	db.SetConnMaxLifetime(time.Minute)
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		db.Close()
+		tun.close()
+		return nil, err
+	}
+	s := &mysqlSession{db: db, conn: conn, dbName: cfg.DBName, lockName: mysqlLockName(t), log: log, tunnel: tun}
+	if err := conn.QueryRowContext(ctx, `SELECT CONNECTION_ID()`).Scan(&s.connID); err != nil {
+		s.Close(ctx)
+		return nil, err
+	}
+	if err := opts.apply(ctx, conn); err != nil {
+		s.Close(ctx)
+		return nil, err
+	}
+	logOptions(log, opts)
+	return s, nil
+}
+
+// mysqlLockName names the GET_LOCK lock of a target, per tenant for fan-out
+// items. Lock names are limited to 64 characters, so tenants are hashed.
+func mysqlLockName(t Target) string {
+	name := "migrate-hub:" + t.ID.String()
+	if t.Tenant != "" {
+		h := fnv.New32a()
+		h.Write([]byte(t.Tenant))
+		name += fmt.Sprintf(":%08x", h.Sum32())
+	}
+	return name
+}
+
+type mysqlSession struct {
+	db       *sql.DB
+	conn     *sql.Conn
+	connID   uint64
+	dbName   string
+	lockName string
+	log      Logger
+	tunnel   *tunnel
+	// The limits SetTimeouts applied. MySQL has no general statement
+	// timeout, so Exec kills statements that run longer than statementTimeout.
+	lockTimeout      time.Duration
+	statementTimeout time.Duration
+}
+
+type mysqlTx struct {
+	tx *sql.Tx
+}
+
+func (t *mysqlTx) Commit(context.Context) error   { return t.tx.Commit() }
+func (t *mysqlTx) Rollback(context.Context) error { return t.tx.Rollback() }
+
+// mysqlExecer is the session or its open transaction.
+type mysqlExecer interface {
+	ExecContext(context.Context, string, ...any) (sql.Result, error)
+	QueryContext(context.Context, string, ...any) (*sql.Rows, error)
+	QueryRowContext(context.Context, string, ...any) *sql.Row
+}
+
+func (s *mysqlSession) on(tx Tx) mysqlExecer {
+	if t, ok := tx.(*mysqlTx); ok && t != nil {
+		return t.tx
+	}
+	return s.conn
+}
+
+func (s *mysqlSession) Ping(ctx context.Context) error {
+	return s.conn.PingContext(ctx)
+}
+
+func (s *mysqlSession) Version(ctx context.Context) (Version, error) {
+	var version string
+	if err := s.conn.QueryRowContext(ctx, `SELECT VERSION()`).Scan(&version); err != nil {
+		return Version{}, err
+	}
+	v := Version{Name: "MySQL " + version}
+	if strings.Contains(strings.ToLower(version), "mariadb") {
+		v.Name = version
+		v.Warning = v.Name + " is not a tested MySQL server"
+	} else if major, minor, ok := parseMySQLVersion(version); !ok || major < minMySQLMajor || (major == minMySQLMajor && minor < minMySQLMinor) {
+		v.Warning = v.Name + " is older than the supported MySQL 5.7"
+	}
+	return v, nil
+}
+
+func parseMySQLVersion(v string) (int, int, bool) {
+	parts := strings.SplitN(v, ".", 3)
+	if len(parts) < 2 {
+		return 0, 0, false
+	}
+	major, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, 0, false
+	}
+	minor, err := strconv.Atoi(strings.TrimLeftFunc(parts[1], func(r rune) bool { return r < '0' || r > '9' }))
+	if err != nil {
+		return 0, 0, false
+	}
+	return major, minor, true
+}
+
+func (s *mysqlSession) Close(context.Context) error {
+	defer s.tunnel.close()
+	s.conn.Close()
+	return s.db.Close()
+}
+
+func (s *mysqlSession) Lock(ctx context.Context, wait time.Duration) error {
+	var got sql.NullInt64
+	if err := s.conn.QueryRowContext(ctx, `SELECT GET_LOCK(?, ?)`, s.lockName, mysqlSeconds(wait)).Scan(&got); err != nil {
+		return fmt.Errorf("get lock: %w", err)
+	}
+	if got.Int64 != 1 {
+		return ErrLocked
+	}
+	return nil
+}
+
+func (s *mysqlSession) Unlock(ctx context.Context) error {
+	_, err := s.conn.ExecContext(ctx, `SELECT RELEASE_LOCK(?)`, s.lockName)
+	return err
+}
+
+func (s *mysqlSession) LockFree(ctx context.Context) (bool, error) {
+	var free sql.NullInt64
+	if err := s.conn.QueryRowContext(ctx, `SELECT IS_FREE_LOCK(?)`, s.lockName).Scan(&free); err != nil {
+		return false, err
+	}
+	return free.Int64 == 1, nil
+}
+
+func (s *mysqlSession) SetTimeouts(ctx context.Context, lock time.Duration, statement time.Duration) error {
+	s.lockTimeout, s.statementTimeout = lock, statement
+	if lock == 0 {
+		_, err := s.conn.ExecContext(ctx, `SET SESSION lock_wait_timeout = DEFAULT, innodb_lock_wait_timeout = DEFAULT`)
+		return err
+	}
+	secs := mysqlSeconds(lock)
+	if _, err := s.conn.ExecContext(ctx, `SET SESSION lock_wait_timeout = ?, innodb_lock_wait_timeout = ?`, secs, secs); err != nil {
+		return fmt.Errorf("set lock_wait_timeout: %w", err)
+	}
+	return nil
+}
+
+// mysqlSeconds rounds up to whole seconds; MySQL lock timeouts have no finer unit.
+func mysqlSeconds(d time.Duration) int64 {
+	secs := int64((d + time.Second - 1) / time.Second)
+	if secs < 1 {
+		return 1
+	}
+	return secs
+}
+
+// Cancel kills the running query from a side connection.
+func (s *mysqlSession) Cancel(ctx context.Context) error {
+	killCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	_, err := s.db.ExecContext(killCtx, fmt.Sprintf("KILL QUERY %d", s.connID))
+	return err
+}
+
+func (s *mysqlSession) Exec(ctx context.Context, tx Tx, query string) (Result, error) {
+	exec := s.on(tx)
+	var timedOutStmt atomic.Bool
+	var timer *time.Timer
+	killed := make(chan struct{})
+	if s.statementTimeout > 0 {
+		timer = time.AfterFunc(s.statementTimeout, func() {
+			defer close(killed)
+			timedOutStmt.Store(true)
+			s.logInfo("statement timeout reached, killing target query", "connection_id", s.connID)
+			if err := s.Cancel(ctx); err != nil {
+				s.logError("kill query failed", "error", err)
+			}
+		})
+	}
+	res, err := exec.ExecContext(ctx, query)
+	if timer != nil && !timer.Stop() {
+		// The kill is in flight; let it land before the next statement starts.
+		<-killed
+	}
+	defer s.logWarnings(ctx, exec)
+	if err != nil {
+		return Result{}, s.classify(err, timedOutStmt.Load())
+	}
+	rows, _ := res.RowsAffected()
+	return Result{RowsAffected: rows}, nil
+}
+
+// classify marks lock wait timeouts and statements killed by the statement timeout.
+func (s *mysqlSession) classify(err error, statementTimedOut bool) error {
+	if statementTimedOut {
+		return timedOut(ErrStatementTimeout, err)
+	}
+	var myErr *mysql.MySQLError
+	if !errors.As(err, &myErr) {
+		return err
+	}
+	switch myErr.Number {
+	case 1205: // ER_LOCK_WAIT_TIMEOUT, raised for metadata and row lock waits
+		if s.lockTimeout > 0 {
+			return timedOut(ErrLockTimeout, err)
+		}
+	case 3024: // ER_QUERY_TIMEOUT from max_execution_time
+		if s.statementTimeout > 0 {
+			return timedOut(ErrStatementTimeout, err)
+		}
+	}
+	return err
+}
+
+// logWarnings copies SHOW WARNINGS for the last statement into the log.
+func (s *mysqlSession) logWarnings(ctx context.Context, exec mysqlExecer) {
+	if s.log == nil {
+		return
+	}
+	rows, err := exec.QueryContext(ctx, `SHOW WARNINGS`)
+	if err != nil {
+		s.log.Error("show warnings failed", "error", err)
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var level, message string
+		var code int
+		if err := rows.Scan(&level, &code, &message); err != nil {
+			s.log.Error("show warnings failed", "error", err)
+			return
+		}
+		s.log.Info("warning", "level", level, "code", code, "message", message)
+	}
+}
+
+func (s *mysqlSession) logInfo(msg string, args ...any) {
+	if s.log != nil {
+		s.log.Info(msg, args...)
+	}
+}
+
+func (s *mysqlSession) logError(msg string, args ...any) {
+	if s.log != nil {
+		s.log.Error(msg, args...)
+	}
+}
+
+func (s *mysqlSession) Begin(ctx context.Context) (Tx, error) {
+	tx, err := s.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &mysqlTx{tx: tx}, nil
+}
+
+func (s *mysqlSession) Query(ctx context.Context, tx Tx, query string, limit int) (int, [][]any, error) {
+	var q mysqlExecer
+	if t, ok := tx.(*mysqlTx); ok && t != nil {
+		if _, err := t.tx.ExecContext(ctx, `SAVEPOINT migrate_hub_check`); err != nil {
+			return 0, nil, err
+		}
+		defer t.tx.ExecContext(ctx, `ROLLBACK TO SAVEPOINT migrate_hub_check`) // nolint:errcheck
+		q = t.tx
+	} else {
+		roTx, err := s.conn.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+		if err != nil {
+			return 0, nil, err
+		}
+		defer roTx.Rollback() // nolint:errcheck
+		q = roTx
+	}
+
+	rows, err := q.QueryContext(ctx, query)
+	if err != nil {
+		return 0, nil, err
+	}
+	return sqlValues(rows, limit)
+}
+
+func (s *mysqlSession) QueryText(ctx context.Context, query string) ([][]sql.NullString, error) {
+	return sqlText(ctx, s.conn, query)
+}
+
+func (s *mysqlSession) CastText(col string) string {
+	return "CAST(" + col + " AS CHAR)"
+}
+
+// FindTable looks the table up in the current database.
+func (s *mysqlSession) FindTable(ctx context.Context, name string) (string, error) {
+	var table string
+	err := s.conn.QueryRowContext(ctx, "SELECT CONCAT('`', table_name, '`') FROM information_schema.tables WHERE table_schema = DATABASE() AND LOWER(table_name) = LOWER(?) LIMIT 1", name).Scan(&table)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	return table, err
+}
+
+func (s *mysqlSession) Tenants(ctx context.Context, pattern string) ([]string, error) {
+	rows, err := sqlText(ctx, s.conn, `SELECT schema_name FROM information_schema.schemata
+		WHERE schema_name LIKE ? AND schema_name NOT IN ('mysql', 'information_schema', 'performance_schema', 'sys')
+		ORDER BY schema_name`, pattern)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]string, 0, len(rows))
+	for _, row := range rows {
+		out = append(out, row[0].String)
+	}
+	return out, nil
+}
+
+func (s *mysqlSession) LedgerState(ctx context.Context, l Ledger) (LedgerState, error) {
+	var comment string
+	err := s.conn.QueryRowContext(ctx, `
+SELECT table_comment FROM information_schema.tables
+WHERE table_schema = COALESCE(NULLIF(?, ''), DATABASE()) AND table_name = ?
+`, l.Schema, l.Table).Scan(&comment)
+	if errors.Is(err, sql.ErrNoRows) {
+		return LedgerState{}, nil
+	}
+	if err != nil {
+		return LedgerState{}, err
+	}
+	return LedgerState{Exists: true, Version: parseLedgerVersion(comment)}, nil
+}
+
+// EnsureLedger: ADD COLUMN has no IF NOT EXISTS on MySQL, so an upgrade only
+// adds the columns that are missing.
+func (s *mysqlSession) EnsureLedger(ctx context.Context, l Ledger, log Logger) (LedgerState, error) {
+	st, err := s.LedgerState(ctx, l)
+	if err != nil || (st.Exists && st.Version >= LedgerVersion) {
+		return st, err
+	}
+	ident := l.quoted("`")
+	if !st.Exists {
+		defs := make([]string, len(LedgerColumns))
+		for i, c := range LedgerColumns {
+			defs[i] = c.Name + " " + mysqlLedgerTypes[c.Name]
+		}
+		if _, err := s.conn.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS "+ident+" (\n  "+strings.Join(defs, ",\n  ")+"\n)"); err != nil {
+			return st, fmt.Errorf("create ledger %s: %w", l, err)
+		}
+		log.Info("ledger created", "ledger", l.String(), "version", LedgerVersion)
+	} else {
+		existing, err := s.columns(ctx, l)
+		if err != nil {
+			return st, err
+		}
+		for _, c := range LedgerColumns {
+			if c.Since <= st.Version || existing[c.Name] {
+				continue
+			}
+			if _, err := s.conn.ExecContext(ctx, "ALTER TABLE "+ident+" ADD COLUMN "+c.Name+" "+mysqlLedgerTypes[c.Name]); err != nil {
+				return st, fmt.Errorf("upgrade ledger %s: %w", l, err)
+			}
+		}
+		log.Info("ledger upgraded", "ledger", l.String(), "from_version", st.Version, "to_version", LedgerVersion)
+	}
+	if _, err := s.conn.ExecContext(ctx, fmt.Sprintf("ALTER TABLE %s COMMENT = '%s'", ident, ledgerComment())); err != nil {
+		return st, fmt.Errorf("upgrade ledger %s: %w", l, err)
+	}
+	return LedgerState{Exists: true, Version: LedgerVersion}, nil
+}
+
+func (s *mysqlSession) columns(ctx context.Context, l Ledger) (map[string]bool, error) {
+	rows, err := sqlText(ctx, s.conn, `
+SELECT column_name FROM information_schema.columns
+WHERE table_schema = COALESCE(NULLIF(?, ''), DATABASE()) AND table_name = ?
+`, l.Schema, l.Table)
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]bool, len(rows))
+	for _, row := range rows {
+		out[strings.ToLower(row[0].String)] = true
+	}
+	return out, nil
+}
+
+func (s *mysqlSession) LookupLedger(ctx context.Context, l Ledger, st LedgerState, key string) (LedgerEntry, error) {
+	var entry LedgerEntry
+	if !st.Exists {
+		return entry, nil
+	}
+	rolledBack := "false"
+	if st.Version >= 2 {
+		rolledBack = "rolled_back_at IS NOT NULL"
+	}
+	query := "SELECT checksum_up, COALESCE(tool_run_id, ''), " + rolledBack + " FROM " + l.quoted("`") + " WHERE migration_key = ?"
+	err := s.conn.QueryRowContext(ctx, query, key).Scan(&entry.ChecksumUp, &entry.ToolRunID, &entry.RolledBack)
+	if errors.Is(err, sql.ErrNoRows) {
+		return entry, nil
+	}
+	if err != nil {
+		return entry, err
+	}
+	entry.Found = !entry.RolledBack
+	return entry, nil
+}
+
+func (s *mysqlSession) ReadLedger(ctx context.Context, l Ledger, st LedgerState) ([]LedgerRow, error) {
+	if !st.Exists {
+		return nil, nil
+	}
+	query := "SELECT migration_key, checksum_up, COALESCE(tool_run_id, ''), CAST(applied_at AS CHAR) FROM " + l.quoted("`")
+	if st.Version >= 2 {
+		query += " WHERE rolled_back_at IS NULL"
+	}
+	rows, err := s.conn.QueryContext(ctx, query+" ORDER BY migration_key")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []LedgerRow
+	for rows.Next() {
+		var row LedgerRow
+		if err := rows.Scan(&row.Key, &row.ChecksumUp, &row.ToolRunID, &row.AppliedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, row)
+	}
+	return out, rows.Err()
+}
+
+func (s *mysqlSession) RecordApply(ctx context.Context, tx Tx, l Ledger, r LedgerRecord) error {
+	_, err := s.on(tx).ExecContext(ctx, `
+INSERT INTO `+l.quoted("`")+` (migration_key, checksum_up, checksum_down, applied_at, applied_by, tool_run_id,
+  migration_version, run_type, duration_ms, executed_by_email, tool_instance_id, rolled_back_at)
+VALUES (?, ?, ?, NOW(), ?, ?, ?, ?, ?, ?, ?, NULL)
+ON DUPLICATE KEY UPDATE checksum_up = VALUES(checksum_up), checksum_down = VALUES(checksum_down),
+  applied_at = VALUES(applied_at), applied_by = VALUES(applied_by), tool_run_id = VALUES(tool_run_id),
+  migration_version = VALUES(migration_version), run_type = VALUES(run_type), duration_ms = VALUES(duration_ms),
+  executed_by_email = VALUES(executed_by_email), tool_instance_id = VALUES(tool_instance_id), rolled_back_at = NULL
+`, r.Key, r.ChecksumUp, r.ChecksumDown, r.AppliedBy, r.RunID, r.Version, r.RunType, r.Duration.Milliseconds(), r.Email, r.InstanceID)
+	return err
+}
+
+func (s *mysqlSession) RecordRollback(ctx context.Context, tx Tx, l Ledger, key string, runID string) error {
+	_, err := s.on(tx).ExecContext(ctx, `UPDATE `+l.quoted("`")+` SET rolled_back_at = NOW(), tool_run_id = ?, run_type = 'rollback' WHERE migration_key = ? AND rolled_back_at IS NULL`, runID, key)
+	return err
+}
+
+// LedgerAccess compares SHOW GRANTS with the privileges a run needs. Role and
+// wildcard-database grants are not resolved, so missing ones are only unsure.
+func (s *mysqlSession) LedgerAccess(ctx context.Context, l Ledger, st LedgerState) (LedgerAccess, error) {
+	access := LedgerAccess{Readable: true}
+	grants, err := s.grants(ctx)
+	if err != nil {
+		return access, err
+	}
+	needed := []string{"SELECT", "INSERT", "UPDATE"}
+	switch {
+	case !st.Exists:
+		needed = append(needed, "CREATE")
+	case st.Version < LedgerVersion:
+		needed = append(needed, "ALTER")
+	}
+	ledgerDB := l.Schema
+	if ledgerDB == "" {
+		ledgerDB = s.dbName
+	}
+	var missing []string
+	for _, priv := range needed {
+		if !grants.allows(priv, ledgerDB, l.Table) {
+			missing = append(missing, priv)
+		}
+	}
+	if len(missing) > 0 {
+		access.Problem = "SHOW GRANTS does not show " + strings.Join(missing, ", ") + " on the ledger " + l.String()
+		access.Unsure = true
+		return access, nil
+	}
+	access.Detail = upgradeDetail(st)
+	return access, nil
+}
+
+// mysqlGrantSet holds the privileges from SHOW GRANTS keyed by object
+// (`*.*`, `db`.* or `db`.`table`, unquoted and lower-cased).
+type mysqlGrantSet map[string]map[string]bool
+
+func (g mysqlGrantSet) allows(priv string, db string, table string) bool {
+	for _, obj := range []string{"*.*", strings.ToLower(db) + ".*", strings.ToLower(db) + "." + table} {
+		if g[obj]["ALL PRIVILEGES"] || g[obj][priv] {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *mysqlSession) grants(ctx context.Context) (mysqlGrantSet, error) {
+	rows, err := s.conn.QueryContext(ctx, `SHOW GRANTS FOR CURRENT_USER()`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := make(mysqlGrantSet)
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			return nil, err
+		}
+		upper := strings.ToUpper(line)
+		on := strings.Index(upper, " ON ")
+		to := strings.LastIndex(upper, " TO ")
+		if !strings.HasPrefix(upper, "GRANT ") || on < 0 || to < on {
+			continue
+		}
+		obj := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(line[on+4:to]), "`", ""))
+		if out[obj] == nil {
+			out[obj] = make(map[string]bool)
+		}
+		for _, priv := range strings.Split(upper[len("GRANT "):on], ",") {
+			priv = strings.TrimSpace(priv)
+			if priv == "ALL" {
+				priv = "ALL PRIVILEGES"
+			}
+			out[obj][priv] = true
+		}
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,87 @@
+package engine
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// decodeOptions reads the session options of an engine from options_json
+// into dst, a struct with json tags. Other keys are left to the store, which
+// rejects the ones nobody reads.
+func decodeOptions(raw json.RawMessage, dst any) error {
+	if len(raw) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(raw, dst); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			return invalid(fmt.Sprintf("option %s must be %s", typeErr.Field, jsonType(typeErr.Type)))
+		}
+		return invalid("options: " + err.Error())
+	}
+	return nil
+}
+
+// jsonType names a Go type as the JSON users write.
+func jsonType(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Slice:
+		return "a list of " + strings.TrimPrefix(jsonType(t.Elem()), "a ") + "s"
+	case reflect.Pointer:
+		return jsonType(t.Elem())
+	case reflect.String:
+		return "a string"
+	default:
+		return "a " + t.Kind().String()
+	}
+}
+
+// optionKeys lists the json keys of an options struct, in field order.
+func optionKeys(v any) []string {
+	typ := reflect.TypeOf(v)
+	keys := make([]string, 0, typ.NumField())
+	for i := 0; i < typ.NumField(); i++ {
+		name, _, _ := strings.Cut(typ.Field(i).Tag.Get("json"), ",")
+		keys = append(keys, name)
+	}
+	return keys
+}
+
+// checkOptionName checks a charset, collation or similar server name, which
+// ends up in SQL unquoted.
+func checkOptionName(key, value string) error {
+	if value == "" {
+		return nil
+	}
+	for _, r := range value {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_') {
+			return invalid(fmt.Sprintf("option %s: use letters, digits and underscores", key))
+		}
+	}
+	return nil
+}
+
+// logOptions logs the session options that are set, if any.
+func logOptions(log Logger, opts any, args ...any) {
+	if log == nil {
+		return
+	}
+	v := reflect.ValueOf(opts)
+	keys := optionKeys(opts)
+	for i, key := range keys {
+		f := v.Field(i)
+		if f.IsZero() {
+			continue
+		}
+		if f.Kind() == reflect.Pointer {
+			f = f.Elem()
+		}
+		args = append(args, key, f.Interface())
+	}
+	if len(args) > 0 {
+		log.Info("session options", args...)
+	}
+}
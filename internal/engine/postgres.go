@@ -0,0 +1,616 @@
+package engine
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// minPostgresVersionNum is the oldest server the executor is tested against.
+const minPostgresVersionNum = 120000
+
+var postgresLedgerTypes = map[string]string{
+	"migration_key":     "TEXT PRIMARY KEY",
+	"checksum_up":       "TEXT NOT NULL",
+	"checksum_down":     "TEXT",
+	"applied_at":        "TIMESTAMPTZ NOT NULL DEFAULT now()",
+	"applied_by":        "TEXT",
+	"tool_run_id":       "TEXT",
+	"migration_version": "INT",
+	"run_type":          "TEXT",
+	"duration_ms":       "BIGINT",
+	"executed_by_email": "TEXT",
+	"tool_instance_id":  "TEXT",
+	"rolled_back_at":    "TIMESTAMPTZ",
+}
+
+func init() {
+	Register(postgres{})
+}
+
+type postgres struct{}
+
+func (postgres) Name() string             { return "postgres" }
+func (postgres) Dialect() string          { return "postgres" }
+func (postgres) Address(t Target) string  { return serverAddress(t) }
+func (postgres) SessionOptions() []string { return optionKeys(postgresOptions{}) }
+func (postgres) TenantKind() string       { return "schema" }
+
+func (postgres) Validate(t Target) error {
+	if err := validateServer(t); err != nil {
+		return err
+	}
+	_, err := postgresSessionOptions(t)
+	return err
+}
+
+// postgresOptions are the session options of Postgres targets.
+type postgresOptions struct {
+	SearchPath      string `json:"search_path"`
+	Role            string `json:"role"`
+	ApplicationName string `json:"application_name"`
+	// FallbackHosts are tried in order when host is down or does not match
+	// TargetSessionAttrs, as host or host:port (default: the target port).
+	FallbackHosts      []string `json:"fallback_hosts"`
+	TargetSessionAttrs string   `json:"target_session_attrs"`
+}
+
+// postgresSessionAttrs are the target_session_attrs values, as in libpq.
+var postgresSessionAttrs = map[string]pgconn.ValidateConnectFunc{
+	"any":        nil,
+	"read-write": pgconn.ValidateConnectTargetSessionAttrsReadWrite,
+	"read-only":  pgconn.ValidateConnectTargetSessionAttrsReadOnly,
+	"primary":    pgconn.ValidateConnectTargetSessionAttrsPrimary,
+	"standby":    pgconn.ValidateConnectTargetSessionAttrsStandby,
+}
+
+func postgresSessionOptions(t Target) (postgresOptions, error) {
+	var o postgresOptions
+	if err := decodeOptions(t.Options, &o); err != nil {
+		return o, err
+	}
+	if strings.ContainsRune(o.SearchPath+o.Role+o.ApplicationName, 0) {
+		return o, invalid("options must not contain NUL characters")
+	}
+	if len(o.ApplicationName) > 63 {
+		return o, invalid("option application_name: at most 63 bytes")
+	}
+	if _, ok := postgresSessionAttrs[o.TargetSessionAttrs]; !ok && o.TargetSessionAttrs != "" {
+		return o, invalid("option target_session_attrs: use any, read-write, read-only, primary or standby")
+	}
+	for _, h := range o.FallbackHosts {
+		if _, _, err := fallbackHost(h, t.Port); err != nil {
+			return o, err
+		}
+	}
+	return o, nil
+}
+
+// fallbackHost splits host or host:port; port defaults to port.
+func fallbackHost(s string, port int) (string, int, error) {
+	s = strings.TrimSpace(s)
+	host, p, err := net.SplitHostPort(s)
+	if err != nil {
+		// No port, or a bare IPv6 address.
+		host = strings.Trim(s, "[]")
+	} else if port, err = strconv.Atoi(p); err != nil {
+		return "", 0, invalid(fmt.Sprintf("option fallback_hosts: invalid port in %q", s))
+	}
+	if host == "" || port <= 0 || port > 65535 {
+		return "", 0, invalid(fmt.Sprintf("option fallback_hosts: invalid host %q", s))
+	}
+	return host, port, nil
+}
+
+func (postgres) Open(ctx context.Context, t Target, log Logger) (Session, error) {
+	cfg, err := pgx.ParseConfig(postgresDSN(t))
+	if err != nil {
+		return nil, err
+	}
+	// The DSN disables TLS; the target's TLS settings replace it.
+	if cfg.TLSConfig, err = t.TLS.Config(t.Host); err != nil {
+		return nil, err
+	}
+	opts, err := postgresSessionOptions(t)
+	if err != nil {
+		return nil, err
+	}
+	// A tenant schema goes first, so unqualified names and the ledger
+	// resolve there; the search_path option follows it.
+	searchPath := opts.SearchPath
+	if t.Tenant != "" {
+		tenant := pgx.Identifier{t.Tenant}.Sanitize()
+		if searchPath == "" {
+			searchPath = tenant
+		} else {
+			searchPath = tenant + ", " + searchPath
+		}
+	}
+	if searchPath != "" {
+		cfg.RuntimeParams["search_path"] = searchPath
+	}
+	if opts.ApplicationName != "" {
+		cfg.RuntimeParams["application_name"] = opts.ApplicationName
+	}
+	cfg.ValidateConnect = postgresSessionAttrs[opts.TargetSessionAttrs]
+	for _, h := range opts.FallbackHosts {
+		host, port, _ := fallbackHost(h, t.Port)
+		tlsCfg, err := t.TLS.Config(host)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Fallbacks = append(cfg.Fallbacks, &pgconn.FallbackConfig{Host: host, Port: uint16(port), TLSConfig: tlsCfg})
+	}
+	if log != nil {
+		cfg.OnNotice = func(_ *pgconn.PgConn, n *pgconn.Notice) {
+			log.Info("notice", "severity", n.Severity, "code", n.Code, "message", n.Message)
+		}
+	}
+	tun, err := openTunnel(ctx, t.Tunnel, log)
+	if err != nil {
+		return nil, err
+	}
+	if tun != nil {
+		// Cancel requests dial the same way.
+		cfg.DialFunc = tun.dial
+		// The host may only resolve behind the bastion.
+		cfg.LookupFunc = func(_ context.Context, host string) ([]string, error) { return []string{host}, nil }
+	}
+	conn, err := pgx.ConnectConfig(ctx, cfg)
+	if err != nil {
+		tun.close()
+		return nil, err
+	}
+	// SET ROLE instead of a startup parameter, so a missing role or grant is
+	// reported as such.
+	if opts.Role != "" {
+		if _, err := conn.Exec(ctx, "SET ROLE "+pgx.Identifier{opts.Role}.Sanitize()); err != nil {
+			conn.Close(ctx)
+			tun.close()
+			return nil, fmt.Errorf("set role: %w", err)
+		}
+	}
+	// Schemas missing from the search path are skipped silently; a dropped
+	// tenant must not run in the next schema on the path.
+	if t.Tenant != "" {
+		var schema *string
+		err := conn.QueryRow(ctx, `SELECT current_schema()`).Scan(&schema)
+		if err == nil && (schema == nil || *schema != t.Tenant) {
+			err = fmt.Errorf("tenant schema %q not found", t.Tenant)
+		}
+		if err != nil {
+			conn.Close(ctx)
+			tun.close()
+			return nil, err
+		}
+	}
+	if len(opts.FallbackHosts) > 0 {
+		logOptions(log, opts, "server", conn.PgConn().Conn().RemoteAddr().String())
+	} else {
+		logOptions(log, opts)
+	}
+	return &pgSession{conn: conn, lockID: advisoryKey(t.ID, t.Tenant), tunnel: tun, tenant: t.Tenant}, nil
+}
+
+func postgresDSN(t Target) string {
+	return fmt.Sprintf("postgres://%s:%s@%s:%d/%s?sslmode=disable", url.QueryEscape(t.Username), url.QueryEscape(t.Password), t.Host, t.Port, url.PathEscape(t.DBName))
+}
+
+// advisoryKey derives the advisory lock id of a target from its id, mixed
+// with the tenant for fan-out items, so tenants do not wait for each other.
+func advisoryKey(id uuid.UUID, tenant string) int64 {
+	var out int64
+	bytes := id[:]
+	for i := 0; i < 8; i++ {
+		out = (out << 8) | int64(bytes[i])
+	}
+	if tenant != "" {
+		h := fnv.New64a()
+		h.Write([]byte(tenant))
+		out ^= int64(h.Sum64())
+	}
+	return out
+}
+
+type pgSession struct {
+	conn   *pgx.Conn
+	lockID int64
+	tunnel *tunnel
+	// tenant is the schema of a fan-out item; its ledger lives there.
+	tenant string
+	// The limits SetTimeouts applied, to tell timeouts from other errors.
+	lockTimeout      time.Duration
+	statementTimeout time.Duration
+}
+
+type pgTx struct {
+	tx pgx.Tx
+}
+
+func (t *pgTx) Commit(ctx context.Context) error   { return t.tx.Commit(ctx) }
+func (t *pgTx) Rollback(ctx context.Context) error { return t.tx.Rollback(ctx) }
+
+// pgExecer is the session or its open transaction.
+type pgExecer interface {
+	Exec(context.Context, string, ...any) (pgconn.CommandTag, error)
+	Query(context.Context, string, ...any) (pgx.Rows, error)
+	QueryRow(context.Context, string, ...any) pgx.Row
+}
+
+func (s *pgSession) on(tx Tx) pgExecer {
+	if t, ok := tx.(*pgTx); ok && t != nil {
+		return t.tx
+	}
+	return s.conn
+}
+
+func (s *pgSession) Ping(ctx context.Context) error {
+	return s.conn.Ping(ctx)
+}
+
+func (s *pgSession) Version(ctx context.Context) (Version, error) {
+	var num int
+	var version string
+	if err := s.conn.QueryRow(ctx, `SELECT current_setting('server_version_num')::int, current_setting('server_version')`).Scan(&num, &version); err != nil {
+		return Version{}, err
+	}
+	v := Version{Name: "PostgreSQL " + version}
+	if num < minPostgresVersionNum {
+		v.Warning = v.Name + " is older than the supported PostgreSQL 12"
+	}
+	return v, nil
+}
+
+func (s *pgSession) Close(ctx context.Context) error {
+	defer s.tunnel.close()
+	return s.conn.Close(ctx)
+}
+
+func (s *pgSession) Lock(ctx context.Context, wait time.Duration) error {
+	// lock_timeout also bounds advisory lock waits.
+	if _, err := s.conn.Exec(ctx, `SELECT set_config('lock_timeout', $1, false)`, postgresMillis(wait)); err != nil {
+		return fmt.Errorf("lock: %w", err)
+	}
+	if _, err := s.conn.Exec(ctx, `SELECT pg_advisory_lock($1)`, s.lockID); err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "55P03" {
+			return ErrLocked
+		}
+		return fmt.Errorf("lock: %w", err)
+	}
+	return nil
+}
+
+func (s *pgSession) Unlock(ctx context.Context) error {
+	_, err := s.conn.Exec(ctx, `SELECT pg_advisory_unlock($1)`, s.lockID)
+	return err
+}
+
+func (s *pgSession) LockFree(ctx context.Context) (bool, error) {
+	var locked bool
+	if err := s.conn.QueryRow(ctx, `SELECT pg_try_advisory_lock($1)`, s.lockID).Scan(&locked); err != nil {
+		return false, err
+	}
+	if locked {
+		s.conn.Exec(ctx, `SELECT pg_advisory_unlock($1)`, s.lockID) // nolint:errcheck
+	}
+	return locked, nil
+}
+
+func (s *pgSession) SetTimeouts(ctx context.Context, lock time.Duration, statement time.Duration) error {
+	for _, set := range []struct {
+		name  string
+		value time.Duration
+	}{
+		{"lock_timeout", lock},
+		{"statement_timeout", statement},
+	} {
+		if set.value == 0 {
+			if _, err := s.conn.Exec(ctx, "RESET "+set.name); err != nil {
+				return err
+			}
+			continue
+		}
+		if _, err := s.conn.Exec(ctx, `SELECT set_config($1, $2, false)`, set.name, postgresMillis(set.value)); err != nil {
+			return fmt.Errorf("set %s: %w", set.name, err)
+		}
+	}
+	s.lockTimeout, s.statementTimeout = lock, statement
+	return nil
+}
+
+func postgresMillis(d time.Duration) string {
+	return fmt.Sprintf("%dms", d.Milliseconds())
+}
+
+// Cancel sends a cancel request on a side connection.
+func (s *pgSession) Cancel(ctx context.Context) error {
+	cancelCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	return s.conn.PgConn().CancelRequest(cancelCtx)
+}
+
+func (s *pgSession) Exec(ctx context.Context, tx Tx, query string) (Result, error) {
+	tag, err := s.on(tx).Exec(ctx, query)
+	if err != nil {
+		return Result{}, s.classify(err)
+	}
+	return Result{RowsAffected: tag.RowsAffected(), Command: tag.String()}, nil
+}
+
+// classify marks lock_timeout and statement_timeout failures. A canceled
+// statement also fails with 57014; the executor reports those as canceled.
+func (s *pgSession) classify(err error) error {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return err
+	}
+	switch {
+	case pgErr.Code == "55P03" && s.lockTimeout > 0:
+		return timedOut(ErrLockTimeout, err)
+	case pgErr.Code == "57014" && s.statementTimeout > 0:
+		return timedOut(ErrStatementTimeout, err)
+	}
+	return err
+}
+
+func (s *pgSession) Begin(ctx context.Context) (Tx, error) {
+	tx, err := s.conn.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &pgTx{tx: tx}, nil
+}
+
+func (s *pgSession) Query(ctx context.Context, tx Tx, query string, limit int) (int, [][]any, error) {
+	var qtx pgx.Tx
+	var err error
+	if t, ok := tx.(*pgTx); ok && t != nil {
+		qtx, err = t.tx.Begin(ctx)
+	} else {
+		qtx, err = s.conn.BeginTx(ctx, pgx.TxOptions{AccessMode: pgx.ReadOnly})
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+	defer qtx.Rollback(ctx) // nolint:errcheck
+
+	rows, err := qtx.Query(ctx, query)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer rows.Close()
+	columns := len(rows.FieldDescriptions())
+	var values [][]any
+	for len(values) < limit && rows.Next() {
+		v, err := rows.Values()
+		if err != nil {
+			return 0, nil, err
+		}
+		values = append(values, v)
+	}
+	rows.Close()
+	return columns, values, rows.Err()
+}
+
+func (s *pgSession) QueryText(ctx context.Context, query string) ([][]sql.NullString, error) {
+	rows, err := s.conn.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out [][]sql.NullString
+	for rows.Next() {
+		row := make([]sql.NullString, len(rows.FieldDescriptions()))
+		dest := make([]any, len(row))
+		for i := range row {
+			dest[i] = &row[i]
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		out = append(out, row)
+	}
+	return out, rows.Err()
+}
+
+func (s *pgSession) CastText(col string) string {
+	return col + "::text"
+}
+
+// FindTable looks the table up on the search path.
+func (s *pgSession) FindTable(ctx context.Context, name string) (string, error) {
+	var table *string
+	if err := s.conn.QueryRow(ctx, `SELECT to_regclass($1)::text`, strings.ToLower(name)).Scan(&table); err != nil {
+		return "", err
+	}
+	if table == nil {
+		return "", nil
+	}
+	return *table, nil
+}
+
+func (s *pgSession) Tenants(ctx context.Context, pattern string) ([]string, error) {
+	rows, err := s.conn.Query(ctx, `SELECT nspname FROM pg_namespace
+		WHERE nspname LIKE $1 AND nspname NOT LIKE 'pg\_%' AND nspname <> 'information_schema'
+		ORDER BY nspname`, pattern)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, pgx.RowTo[string])
+}
+
+// ledger qualifies the ledger of a tenant session with the tenant schema,
+// so a tenant without a ledger never reads another schema's ledger further
+// along the search path.
+func (s *pgSession) ledger(l Ledger) Ledger {
+	if l.Schema == "" {
+		l.Schema = s.tenant
+	}
+	return l
+}
+
+func (s *pgSession) LedgerState(ctx context.Context, l Ledger) (LedgerState, error) {
+	l = s.ledger(l)
+	var st LedgerState
+	var comment string
+	if err := s.conn.QueryRow(ctx, `
+SELECT c.oid IS NOT NULL, COALESCE(obj_description(c.oid, 'pg_class'), '')
+FROM (SELECT to_regclass($1) AS oid) c
+`, l.quoted(`"`)).Scan(&st.Exists, &comment); err != nil {
+		return st, err
+	}
+	if st.Exists {
+		st.Version = parseLedgerVersion(comment)
+	}
+	return st, nil
+}
+
+func (s *pgSession) EnsureLedger(ctx context.Context, l Ledger, log Logger) (LedgerState, error) {
+	l = s.ledger(l)
+	st, err := s.LedgerState(ctx, l)
+	if err != nil || (st.Exists && st.Version >= LedgerVersion) {
+		return st, err
+	}
+	ident := l.quoted(`"`)
+	if !st.Exists {
+		defs := make([]string, len(LedgerColumns))
+		for i, c := range LedgerColumns {
+			defs[i] = c.Name + " " + postgresLedgerTypes[c.Name]
+		}
+		if _, err := s.conn.Exec(ctx, "CREATE TABLE IF NOT EXISTS "+ident+" (\n  "+strings.Join(defs, ",\n  ")+"\n)"); err != nil {
+			return st, fmt.Errorf("create ledger %s: %w", l, err)
+		}
+		log.Info("ledger created", "ledger", l.String(), "version", LedgerVersion)
+	} else {
+		for _, c := range LedgerColumns {
+			if c.Since <= st.Version {
+				continue
+			}
+			if _, err := s.conn.Exec(ctx, "ALTER TABLE "+ident+" ADD COLUMN IF NOT EXISTS "+c.Name+" "+postgresLedgerTypes[c.Name]); err != nil {
+				return st, fmt.Errorf("upgrade ledger %s: %w", l, err)
+			}
+		}
+		log.Info("ledger upgraded", "ledger", l.String(), "from_version", st.Version, "to_version", LedgerVersion)
+	}
+	if _, err := s.conn.Exec(ctx, fmt.Sprintf("COMMENT ON TABLE %s IS '%s'", ident, ledgerComment())); err != nil {
+		return st, fmt.Errorf("upgrade ledger %s: %w", l, err)
+	}
+	return LedgerState{Exists: true, Version: LedgerVersion}, nil
+}
+
+func (s *pgSession) LookupLedger(ctx context.Context, l Ledger, st LedgerState, key string) (LedgerEntry, error) {
+	l = s.ledger(l)
+	var entry LedgerEntry
+	if !st.Exists {
+		return entry, nil
+	}
+	rolledBack := "false"
+	if st.Version >= 2 {
+		rolledBack = "rolled_back_at IS NOT NULL"
+	}
+	query := "SELECT checksum_up, COALESCE(tool_run_id, ''), " + rolledBack + " FROM " + l.quoted(`"`) + " WHERE migration_key = $1"
+	err := s.conn.QueryRow(ctx, query, key).Scan(&entry.ChecksumUp, &entry.ToolRunID, &entry.RolledBack)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return entry, nil
+	}
+	if err != nil {
+		return entry, err
+	}
+	entry.Found = !entry.RolledBack
+	return entry, nil
+}
+
+func (s *pgSession) ReadLedger(ctx context.Context, l Ledger, st LedgerState) ([]LedgerRow, error) {
+	l = s.ledger(l)
+	if !st.Exists {
+		return nil, nil
+	}
+	query := "SELECT migration_key, checksum_up, COALESCE(tool_run_id, ''), applied_at::text FROM " + l.quoted(`"`)
+	if st.Version >= 2 {
+		query += " WHERE rolled_back_at IS NULL"
+	}
+	rows, err := s.conn.Query(ctx, query+" ORDER BY migration_key")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []LedgerRow
+	for rows.Next() {
+		var row LedgerRow
+		if err := rows.Scan(&row.Key, &row.ChecksumUp, &row.ToolRunID, &row.AppliedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, row)
+	}
+	return out, rows.Err()
+}
+
+func (s *pgSession) RecordApply(ctx context.Context, tx Tx, l Ledger, r LedgerRecord) error {
+	l = s.ledger(l)
+	_, err := s.on(tx).Exec(ctx, `
+INSERT INTO `+l.quoted(`"`)+` (migration_key, checksum_up, checksum_down, applied_at, applied_by, tool_run_id,
+  migration_version, run_type, duration_ms, executed_by_email, tool_instance_id, rolled_back_at)
+VALUES ($1, $2, $3, now(), $4, $5, $6, $7, $8, $9, $10, NULL)
+ON CONFLICT (migration_key) DO UPDATE SET checksum_up = EXCLUDED.checksum_up, checksum_down = EXCLUDED.checksum_down,
+  applied_at = EXCLUDED.applied_at, applied_by = EXCLUDED.applied_by, tool_run_id = EXCLUDED.tool_run_id,
+  migration_version = EXCLUDED.migration_version, run_type = EXCLUDED.run_type, duration_ms = EXCLUDED.duration_ms,
+  executed_by_email = EXCLUDED.executed_by_email, tool_instance_id = EXCLUDED.tool_instance_id, rolled_back_at = NULL
+`, r.Key, r.ChecksumUp, r.ChecksumDown, r.AppliedBy, r.RunID, r.Version, r.RunType, r.Duration.Milliseconds(), r.Email, r.InstanceID)
+	return err
+}
+
+func (s *pgSession) RecordRollback(ctx context.Context, tx Tx, l Ledger, key string, runID string) error {
+	l = s.ledger(l)
+	_, err := s.on(tx).Exec(ctx, `UPDATE `+l.quoted(`"`)+` SET rolled_back_at = now(), tool_run_id = $2, run_type = 'rollback' WHERE migration_key = $1 AND rolled_back_at IS NULL`, key, runID)
+	return err
+}
+
+func (s *pgSession) LedgerAccess(ctx context.Context, l Ledger, st LedgerState) (LedgerAccess, error) {
+	l = s.ledger(l)
+	var access LedgerAccess
+	var schemaExists, canCreate, canWrite, isOwner bool
+	if err := s.conn.QueryRow(ctx, `
+SELECT n.oid IS NOT NULL, COALESCE(has_schema_privilege(n.oid, 'CREATE'), false)
+FROM (SELECT (SELECT oid FROM pg_namespace WHERE nspname = COALESCE(NULLIF($1, ''), current_schema())) AS oid) n
+`, l.Schema).Scan(&schemaExists, &canCreate); err != nil {
+		return access, err
+	}
+	switch {
+	case st.Exists:
+		if err := s.conn.QueryRow(ctx, `
+SELECT has_table_privilege($1, 'SELECT'),
+  has_table_privilege($1, 'INSERT') AND has_table_privilege($1, 'UPDATE'),
+  pg_has_role((SELECT relowner FROM pg_class WHERE oid = to_regclass($1)), 'USAGE')
+`, l.quoted(`"`)).Scan(&access.Readable, &canWrite, &isOwner); err != nil {
+			return access, err
+		}
+		switch {
+		case !access.Readable || !canWrite:
+			access.Problem = "missing SELECT, INSERT or UPDATE on " + l.String()
+		case st.Version < LedgerVersion && !isOwner:
+			// ALTER TABLE and COMMENT ON TABLE need ownership of the ledger.
+			access.Problem = fmt.Sprintf("%s is ledger v%d and must be upgraded to v%d, which needs ownership of the table", l, st.Version, LedgerVersion)
+		default:
+			access.Detail = upgradeDetail(st)
+		}
+	case !schemaExists:
+		access.Problem = "ledger schema of " + l.String() + " does not exist"
+	case !canCreate:
+		access.Problem = l.String() + " does not exist and CREATE on its schema is missing"
+	default:
+		access.Detail = "ledger table " + l.String() + " will be created"
+	}
+	return access, nil
+}
@@ -0,0 +1,569 @@
+package engine
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"modernc.org/sqlite"
+)
+
+var sqliteLedgerTypes = map[string]string{
+	"migration_key":     "TEXT PRIMARY KEY",
+	"checksum_up":       "TEXT NOT NULL",
+	"checksum_down":     "TEXT",
+	"applied_at":        "TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP",
+	"applied_by":        "TEXT",
+	"tool_run_id":       "TEXT",
+	"migration_version": "INTEGER",
+	"run_type":          "TEXT",
+	"duration_ms":       "INTEGER",
+	"executed_by_email": "TEXT",
+	"tool_instance_id":  "TEXT",
+	"rolled_back_at":    "TEXT",
+}
+
+// SQLite result codes; errors carry extended codes, so compare the low byte.
+const (
+	sqliteBusy     = 5
+	sqliteLocked   = 6
+	sqliteReadOnly = 8
+)
+
+// sqliteLockPoll is how often Lock retries a lock file held by another run.
+const sqliteLockPoll = 100 * time.Millisecond
+
+func init() {
+	Register(sqliteEngine{})
+}
+
+// sqliteRoot is the directory SQLite targets must live in; empty refuses
+// every SQLite target.
+var sqliteRoot atomic.Value // string
+
+// SetSQLiteRoot sets the directory SQLite database files must be under.
+// Without it SQLite targets are refused, so API users cannot point the hub
+// at arbitrary files on its host.
+func SetSQLiteRoot(dir string) {
+	if dir != "" {
+		dir = filepath.Clean(dir)
+	}
+	sqliteRoot.Store(dir)
+}
+
+// sqlitePath returns the cleaned path of a database file under the root.
+func sqlitePath(dbname string) (string, error) {
+	root, _ := sqliteRoot.Load().(string)
+	if root == "" {
+		return "", invalid("sqlite targets are disabled; set MIGRATEHUB_SQLITE_ROOT")
+	}
+	path := filepath.Clean(strings.TrimSpace(dbname))
+	if !filepath.IsAbs(path) {
+		return "", invalid("dbname must be the absolute path of the sqlite database file")
+	}
+	if !underDir(root, path) {
+		return "", invalid("dbname must be a file under " + root)
+	}
+	return path, nil
+}
+
+// underDir reports whether path is below dir; both are clean.
+func underDir(dir string, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// sqliteEngine runs on database files on the hub host. The target's DBName
+// is the absolute path of the file under the SQLite root; host, port and
+// credentials are unused.
+type sqliteEngine struct{}
+
+func (sqliteEngine) Name() string             { return "sqlite" }
+func (sqliteEngine) Dialect() string          { return "sqlite" }
+func (sqliteEngine) SessionOptions() []string { return nil }
+func (sqliteEngine) TenantKind() string       { return "" }
+
+func (sqliteEngine) Validate(t Target) error {
+	if _, err := sqlitePath(t.DBName); err != nil {
+		return err
+	}
+	if t.TLS.Mode != "" && t.TLS.Mode != TLSDisable {
+		return invalid("sqlite targets do not use tls")
+	}
+	if t.Tunnel != nil {
+		return invalid("sqlite targets do not use ssh tunnels")
+	}
+	return nil
+}
+
+func (sqliteEngine) Address(t Target) string { return t.DBName }
+
+// Open refuses missing files instead of creating an empty database, and pins
+// a single connection like the other engines. The root is checked again with
+// symlinks resolved, for targets saved before it was set or changed. A tunnel
+// inherited from the db set does not apply to local files and is ignored.
+func (sqliteEngine) Open(ctx context.Context, t Target, log Logger) (Session, error) {
+	path, err := sqlitePath(t.DBName)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(path); err != nil {
+		return nil, err
+	}
+	root, _ := sqliteRoot.Load().(string)
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return nil, err
+	}
+	realPath, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return nil, err
+	}
+	if !underDir(realRoot, realPath) {
+		return nil, invalid("dbname must be a file under " + root)
+	}
+	db, err := sql.Open("sqlite", "file:"+(&url.URL{Path: realPath}).EscapedPath()+"?mode=rw")
+	if err != nil {
+		return nil, err
+	}
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	s := &sqliteSession{db: db, conn: conn, path: realPath, log: log}
+	// Files that are not SQLite databases only fail once the schema is read.
+	var tables int
+	if err := conn.QueryRowContext(ctx, `SELECT count(*) FROM sqlite_master`).Scan(&tables); err != nil {
+		s.Close(ctx)
+		return nil, err
+	}
+	return s, nil
+}
+
+type sqliteSession struct {
+	db   *sql.DB
+	conn *sql.Conn
+	path string
+	log  Logger
+	// lock is the open lock file while the session holds the target lock.
+	lock *os.File
+
+	// The limits SetTimeouts applied. SQLite has no statement timeout, so
+	// statements run on a context that expires after statementTimeout.
+	lockTimeout      time.Duration
+	statementTimeout time.Duration
+
+	mu sync.Mutex
+	// cancel interrupts the statement in flight.
+	cancel context.CancelFunc
+}
+
+// sqliteTx is a transaction on the pinned connection. It begins IMMEDIATE,
+// so writers from other processes are kept out for its whole length.
+type sqliteTx struct {
+	s *sqliteSession
+}
+
+func (t *sqliteTx) Commit(ctx context.Context) error {
+	_, err := t.s.conn.ExecContext(ctx, `COMMIT`)
+	return err
+}
+
+func (t *sqliteTx) Rollback(ctx context.Context) error {
+	_, err := t.s.conn.ExecContext(ctx, `ROLLBACK`)
+	return err
+}
+
+func (s *sqliteSession) Ping(ctx context.Context) error {
+	return s.conn.PingContext(ctx)
+}
+
+func (s *sqliteSession) Version(ctx context.Context) (Version, error) {
+	var version string
+	if err := s.conn.QueryRowContext(ctx, `SELECT sqlite_version()`).Scan(&version); err != nil {
+		return Version{}, err
+	}
+	return Version{Name: "SQLite " + version}, nil
+}
+
+func (s *sqliteSession) Close(ctx context.Context) error {
+	if s.lock != nil {
+		s.Unlock(ctx) // nolint:errcheck
+	}
+	s.conn.Close()
+	return s.db.Close()
+}
+
+// lockPath is the file that holds the per-target migration lock. It sits
+// next to the database, like its journal.
+func (s *sqliteSession) lockPath() string {
+	return s.path + "-migrate-hub.lock"
+}
+
+// Lock takes an exclusive lock on the lock file. The kernel drops it when
+// the process dies, so a crashed run never leaves the target locked.
+func (s *sqliteSession) Lock(ctx context.Context, wait time.Duration) error {
+	f, err := os.OpenFile(s.lockPath(), os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return fmt.Errorf("lock: %w", err)
+	}
+	deadline := time.Now().Add(wait)
+	for {
+		ok, err := tryLockFile(f)
+		if err != nil {
+			f.Close()
+			return fmt.Errorf("lock: %w", err)
+		}
+		if ok {
+			s.lock = f
+			return nil
+		}
+		if time.Now().After(deadline) {
+			f.Close()
+			return ErrLocked
+		}
+		select {
+		case <-ctx.Done():
+			f.Close()
+			return ctx.Err()
+		case <-time.After(sqliteLockPoll):
+		}
+	}
+}
+
+func (s *sqliteSession) Unlock(context.Context) error {
+	if s.lock == nil {
+		return nil
+	}
+	f := s.lock
+	s.lock = nil
+	if err := unlockFile(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (s *sqliteSession) LockFree(context.Context) (bool, error) {
+	f, err := os.OpenFile(s.lockPath(), os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	ok, err := tryLockFile(f)
+	if err != nil || !ok {
+		return false, err
+	}
+	return true, unlockFile(f)
+}
+
+// SetTimeouts maps the lock timeout to busy_timeout, the wait for other
+// connections' database locks; zero fails at once, the SQLite default.
+func (s *sqliteSession) SetTimeouts(ctx context.Context, lock time.Duration, statement time.Duration) error {
+	if _, err := s.conn.ExecContext(ctx, fmt.Sprintf("PRAGMA busy_timeout = %d", lock.Milliseconds())); err != nil {
+		return fmt.Errorf("set busy_timeout: %w", err)
+	}
+	s.lockTimeout, s.statementTimeout = lock, statement
+	return nil
+}
+
+func (s *sqliteSession) Cancel(context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cancel != nil {
+		s.cancel()
+	}
+	return nil
+}
+
+// statement returns the context a statement runs on: canceled by Cancel and
+// after the statement timeout, either of which interrupts the statement.
+func (s *sqliteSession) statement(ctx context.Context) (context.Context, context.CancelFunc) {
+	var cancel context.CancelFunc
+	if s.statementTimeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, s.statementTimeout)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+	s.mu.Lock()
+	s.cancel = cancel
+	s.mu.Unlock()
+	return ctx, func() {
+		s.mu.Lock()
+		s.cancel = nil
+		s.mu.Unlock()
+		cancel()
+	}
+}
+
+// Exec ignores tx: the transaction lives on the pinned connection. An
+// interrupted write inside a transaction makes SQLite roll the transaction
+// back itself.
+func (s *sqliteSession) Exec(ctx context.Context, tx Tx, query string) (Result, error) {
+	stmtCtx, done := s.statement(ctx)
+	defer done()
+	res, err := s.conn.ExecContext(stmtCtx, query)
+	if err != nil {
+		return Result{}, s.classify(stmtCtx, err)
+	}
+	rows, _ := res.RowsAffected()
+	return Result{RowsAffected: rows}, nil
+}
+
+func (s *sqliteSession) classify(stmtCtx context.Context, err error) error {
+	if errors.Is(stmtCtx.Err(), context.DeadlineExceeded) {
+		return timedOut(ErrStatementTimeout, err)
+	}
+	switch sqliteCode(err) {
+	case sqliteBusy, sqliteLocked:
+		if s.lockTimeout > 0 {
+			return timedOut(ErrLockTimeout, err)
+		}
+	}
+	return err
+}
+
+func sqliteCode(err error) int {
+	var e *sqlite.Error
+	if !errors.As(err, &e) {
+		return 0
+	}
+	return e.Code() & 0xff
+}
+
+func (s *sqliteSession) Begin(ctx context.Context) (Tx, error) {
+	if _, err := s.conn.ExecContext(ctx, `BEGIN IMMEDIATE`); err != nil {
+		return nil, s.classify(ctx, err)
+	}
+	return &sqliteTx{s: s}, nil
+}
+
+// Query runs with query_only set, SQLite's closest thing to a read-only
+// transaction.
+func (s *sqliteSession) Query(ctx context.Context, tx Tx, query string, limit int) (int, [][]any, error) {
+	if tx != nil {
+		if _, err := s.conn.ExecContext(ctx, `SAVEPOINT migrate_hub_check`); err != nil {
+			return 0, nil, err
+		}
+		defer s.conn.ExecContext(ctx, `RELEASE SAVEPOINT migrate_hub_check`)     // nolint:errcheck
+		defer s.conn.ExecContext(ctx, `ROLLBACK TO SAVEPOINT migrate_hub_check`) // nolint:errcheck
+	} else {
+		if _, err := s.conn.ExecContext(ctx, `BEGIN`); err != nil {
+			return 0, nil, err
+		}
+		defer s.conn.ExecContext(ctx, `ROLLBACK`) // nolint:errcheck
+	}
+	if _, err := s.conn.ExecContext(ctx, `PRAGMA query_only = ON`); err != nil {
+		return 0, nil, err
+	}
+	defer s.conn.ExecContext(ctx, `PRAGMA query_only = OFF`) // nolint:errcheck
+
+	stmtCtx, done := s.statement(ctx)
+	defer done()
+	rows, err := s.conn.QueryContext(stmtCtx, query)
+	if err != nil {
+		return 0, nil, s.classify(stmtCtx, err)
+	}
+	return sqlValues(rows, limit)
+}
+
+func (s *sqliteSession) QueryText(ctx context.Context, query string) ([][]sql.NullString, error) {
+	return sqlText(ctx, s.conn, query)
+}
+
+func (s *sqliteSession) CastText(col string) string {
+	return "CAST(" + col + " AS TEXT)"
+}
+
+// FindTable looks the table up in the main database.
+func (s *sqliteSession) FindTable(ctx context.Context, name string) (string, error) {
+	var table string
+	err := s.conn.QueryRowContext(ctx, `SELECT name FROM sqlite_master WHERE type = 'table' AND lower(name) = lower(?) LIMIT 1`, name).Scan(&table)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return `"` + strings.ReplaceAll(table, `"`, `""`) + `"`, nil
+}
+
+// Tenants fails: a SQLite file has no schemas or databases to fan out to.
+func (s *sqliteSession) Tenants(context.Context, string) ([]string, error) {
+	return nil, errors.New("sqlite targets have no tenants")
+}
+
+// schema is the attached database holding the ledger; Ledger.Schema names
+// one, main by default.
+func sqliteSchema(l Ledger) string {
+	if l.Schema == "" {
+		return "main"
+	}
+	return l.Schema
+}
+
+// LedgerState: SQLite tables have no comment, so the ledger version is the
+// newest one whose columns all exist.
+func (s *sqliteSession) LedgerState(ctx context.Context, l Ledger) (LedgerState, error) {
+	cols, err := s.columns(ctx, l)
+	if err != nil || len(cols) == 0 {
+		return LedgerState{}, err
+	}
+	st := LedgerState{Exists: true, Version: 1}
+	for v := 2; v <= LedgerVersion; v++ {
+		for _, c := range LedgerColumns {
+			if c.Since <= v && !cols[c.Name] {
+				return st, nil
+			}
+		}
+		st.Version = v
+	}
+	return st, nil
+}
+
+func (s *sqliteSession) columns(ctx context.Context, l Ledger) (map[string]bool, error) {
+	rows, err := sqlText(ctx, s.conn, `SELECT name FROM pragma_table_info(?, ?)`, l.Table, sqliteSchema(l))
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]bool, len(rows))
+	for _, row := range rows {
+		out[strings.ToLower(row[0].String)] = true
+	}
+	return out, nil
+}
+
+func (s *sqliteSession) EnsureLedger(ctx context.Context, l Ledger, log Logger) (LedgerState, error) {
+	st, err := s.LedgerState(ctx, l)
+	if err != nil || (st.Exists && st.Version >= LedgerVersion) {
+		return st, err
+	}
+	ident := l.quoted(`"`)
+	if !st.Exists {
+		defs := make([]string, len(LedgerColumns))
+		for i, c := range LedgerColumns {
+			defs[i] = c.Name + " " + sqliteLedgerTypes[c.Name]
+		}
+		if _, err := s.conn.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS "+ident+" (\n  "+strings.Join(defs, ",\n  ")+"\n)"); err != nil {
+			return st, fmt.Errorf("create ledger %s: %w", l, err)
+		}
+		log.Info("ledger created", "ledger", l.String(), "version", LedgerVersion)
+		return LedgerState{Exists: true, Version: LedgerVersion}, nil
+	}
+	existing, err := s.columns(ctx, l)
+	if err != nil {
+		return st, err
+	}
+	for _, c := range LedgerColumns {
+		if existing[c.Name] {
+			continue
+		}
+		// ADD COLUMN cannot add a NOT NULL column without a default.
+		def := strings.TrimSuffix(sqliteLedgerTypes[c.Name], " NOT NULL")
+		if _, err := s.conn.ExecContext(ctx, "ALTER TABLE "+ident+" ADD COLUMN "+c.Name+" "+def); err != nil {
+			return st, fmt.Errorf("upgrade ledger %s: %w", l, err)
+		}
+	}
+	log.Info("ledger upgraded", "ledger", l.String(), "from_version", st.Version, "to_version", LedgerVersion)
+	return LedgerState{Exists: true, Version: LedgerVersion}, nil
+}
+
+func (s *sqliteSession) LookupLedger(ctx context.Context, l Ledger, st LedgerState, key string) (LedgerEntry, error) {
+	var entry LedgerEntry
+	if !st.Exists {
+		return entry, nil
+	}
+	rolledBack := "false"
+	if st.Version >= 2 {
+		rolledBack = "rolled_back_at IS NOT NULL"
+	}
+	query := "SELECT checksum_up, COALESCE(tool_run_id, ''), " + rolledBack + " FROM " + l.quoted(`"`) + " WHERE migration_key = ?"
+	err := s.conn.QueryRowContext(ctx, query, key).Scan(&entry.ChecksumUp, &entry.ToolRunID, &entry.RolledBack)
+	if errors.Is(err, sql.ErrNoRows) {
+		return entry, nil
+	}
+	if err != nil {
+		return entry, err
+	}
+	entry.Found = !entry.RolledBack
+	return entry, nil
+}
+
+func (s *sqliteSession) ReadLedger(ctx context.Context, l Ledger, st LedgerState) ([]LedgerRow, error) {
+	if !st.Exists {
+		return nil, nil
+	}
+	query := "SELECT migration_key, checksum_up, COALESCE(tool_run_id, ''), CAST(applied_at AS TEXT) FROM " + l.quoted(`"`)
+	if st.Version >= 2 {
+		query += " WHERE rolled_back_at IS NULL"
+	}
+	rows, err := s.conn.QueryContext(ctx, query+" ORDER BY migration_key")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []LedgerRow
+	for rows.Next() {
+		var row LedgerRow
+		if err := rows.Scan(&row.Key, &row.ChecksumUp, &row.ToolRunID, &row.AppliedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, row)
+	}
+	return out, rows.Err()
+}
+
+func (s *sqliteSession) RecordApply(ctx context.Context, tx Tx, l Ledger, r LedgerRecord) error {
+	_, err := s.conn.ExecContext(ctx, `
+INSERT INTO `+l.quoted(`"`)+` (migration_key, checksum_up, checksum_down, applied_at, applied_by, tool_run_id,
+  migration_version, run_type, duration_ms, executed_by_email, tool_instance_id, rolled_back_at)
+VALUES (?, ?, ?, CURRENT_TIMESTAMP, ?, ?, ?, ?, ?, ?, ?, NULL)
+ON CONFLICT (migration_key) DO UPDATE SET checksum_up = excluded.checksum_up, checksum_down = excluded.checksum_down,
+  applied_at = excluded.applied_at, applied_by = excluded.applied_by, tool_run_id = excluded.tool_run_id,
+  migration_version = excluded.migration_version, run_type = excluded.run_type, duration_ms = excluded.duration_ms,
+  executed_by_email = excluded.executed_by_email, tool_instance_id = excluded.tool_instance_id, rolled_back_at = NULL
+`, r.Key, r.ChecksumUp, r.ChecksumDown, r.AppliedBy, r.RunID, r.Version, r.RunType, r.Duration.Milliseconds(), r.Email, r.InstanceID)
+	return err
+}
+
+func (s *sqliteSession) RecordRollback(ctx context.Context, tx Tx, l Ledger, key string, runID string) error {
+	_, err := s.conn.ExecContext(ctx, `UPDATE `+l.quoted(`"`)+` SET rolled_back_at = CURRENT_TIMESTAMP, tool_run_id = ?, run_type = 'rollback' WHERE migration_key = ? AND rolled_back_at IS NULL`, runID, key)
+	return err
+}
+
+// LedgerAccess: SQLite has no privileges, only file permissions. Taking the
+// write lock and releasing it at once shows whether the file is writable.
+func (s *sqliteSession) LedgerAccess(ctx context.Context, l Ledger, st LedgerState) (LedgerAccess, error) {
+	access := LedgerAccess{Readable: true}
+	_, err := s.conn.ExecContext(ctx, `BEGIN IMMEDIATE`)
+	if err == nil {
+		_, err = s.conn.ExecContext(ctx, `ROLLBACK`)
+	}
+	switch code := sqliteCode(err); {
+	case err == nil:
+	case code == sqliteReadOnly:
+		access.Problem = "database file " + s.path + " is read-only"
+		return access, nil
+	case code == sqliteBusy || code == sqliteLocked:
+		access.Problem = "database file " + s.path + " is being written; write access not confirmed"
+		access.Unsure = true
+		return access, nil
+	default:
+		return access, err
+	}
+	if !st.Exists {
+		access.Detail = "ledger table " + l.String() + " will be created"
+		return access, nil
+	}
+	access.Detail = upgradeDetail(st)
+	return access, nil
+}
@@ -0,0 +1,14 @@
+//go:build !unix
+
+package engine
+
+import (
+	"errors"
+	"os"
+)
+
+var errFileLockUnsupported = errors.New("sqlite targets need file locks, which this platform lacks")
+
+func tryLockFile(*os.File) (bool, error) { return false, errFileLockUnsupported }
+
+func unlockFile(*os.File) error { return errFileLockUnsupported }
@@ -0,0 +1,69 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSQLiteRoot(t *testing.T) {
+	root := t.TempDir()
+	outside := t.TempDir()
+	for _, dir := range []string{root, outside} {
+		if err := os.WriteFile(filepath.Join(dir, "app.db"), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	eng := sqliteEngine{}
+
+	SetSQLiteRoot("")
+	t.Cleanup(func() { SetSQLiteRoot("") })
+	if err := eng.Validate(Target{DBName: filepath.Join(root, "app.db")}); !errors.Is(err, ErrInvalidTarget) {
+		t.Fatalf("Validate without root: %v, want invalid target", err)
+	}
+
+	SetSQLiteRoot(root)
+	tests := []struct {
+		dbname string
+		ok     bool
+	}{
+		{filepath.Join(root, "app.db"), true},
+		{filepath.Join(root, "sub", "..", "app.db"), true},
+		{filepath.Join(root, "..", filepath.Base(outside), "app.db"), false},
+		{root + "/../" + filepath.Base(outside) + "/app.db", false},
+		{filepath.Join(outside, "app.db"), false},
+		{root, false},
+		{"app.db", false},
+	}
+	for _, tt := range tests {
+		err := eng.Validate(Target{DBName: tt.dbname})
+		if tt.ok && err != nil {
+			t.Errorf("Validate(%q): %v", tt.dbname, err)
+		}
+		if !tt.ok && !errors.Is(err, ErrInvalidTarget) {
+			t.Errorf("Validate(%q): %v, want invalid target", tt.dbname, err)
+		}
+	}
+
+	// A symlink under the root that leads out of it is refused on open.
+	link := filepath.Join(root, "link.db")
+	if err := os.Symlink(filepath.Join(outside, "app.db"), link); err != nil {
+		t.Fatal(err)
+	}
+	if err := eng.Validate(Target{DBName: link}); err != nil {
+		t.Fatalf("Validate(%q): %v", link, err)
+	}
+	if sess, err := eng.Open(context.Background(), Target{DBName: link}, nil); !errors.Is(err, ErrInvalidTarget) {
+		if err == nil {
+			sess.Close(context.Background())
+		}
+		t.Fatalf("Open(%q): %v, want invalid target", link, err)
+	}
+	sess, err := eng.Open(context.Background(), Target{DBName: filepath.Join(root, "app.db")}, nil)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	sess.Close(context.Background())
+}
@@ -0,0 +1,93 @@
+package engine
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+)
+
+// TLS modes, named and meant as in libpq's sslmode.
+const (
+	TLSDisable    = "disable"
+	TLSRequire    = "require"
+	TLSVerifyCA   = "verify-ca"
+	TLSVerifyFull = "verify-full"
+)
+
+// TLSModes lists the TLS modes in order of strictness.
+var TLSModes = []string{TLSDisable, TLSRequire, TLSVerifyCA, TLSVerifyFull}
+
+// TLS is how a session secures its connection to a network target. CA, Cert
+// and Key are PEM.
+type TLS struct {
+	// Mode is one of TLSModes; empty is TLSDisable.
+	Mode string
+	// ServerName is the name verify-full checks the certificate against;
+	// empty uses the target host.
+	ServerName string
+	// CA is the bundle verify-ca and verify-full trust; empty uses the system
+	// roots.
+	CA []byte
+	// Cert and Key are the client certificate, sent when the server asks.
+	Cert []byte
+	Key  []byte
+}
+
+// Config returns the client TLS config for host, or nil when TLS is disabled.
+// require encrypts without checking the server certificate; verify-ca checks
+// it was issued by the CA; verify-full also checks the server name.
+func (t TLS) Config(host string) (*tls.Config, error) {
+	if t.Mode == "" || t.Mode == TLSDisable {
+		return nil, nil
+	}
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if len(t.Cert) > 0 || len(t.Key) > 0 {
+		if len(t.Cert) == 0 || len(t.Key) == 0 {
+			return nil, invalid("tls client certificate and key must be set together")
+		}
+		pair, err := tls.X509KeyPair(t.Cert, t.Key)
+		if err != nil {
+			return nil, invalid("tls client certificate: " + err.Error())
+		}
+		cfg.Certificates = []tls.Certificate{pair}
+	}
+	var roots *x509.CertPool
+	if len(t.CA) > 0 {
+		roots = x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(t.CA) {
+			return nil, invalid("tls ca: no PEM certificates found")
+		}
+	}
+	switch t.Mode {
+	case TLSRequire:
+		cfg.InsecureSkipVerify = true
+	case TLSVerifyCA:
+		// crypto/tls always checks the name, so the chain is verified here.
+		cfg.InsecureSkipVerify = true
+		cfg.VerifyConnection = func(cs tls.ConnectionState) error {
+			return verifyChain(cs, roots)
+		}
+	case TLSVerifyFull:
+		cfg.RootCAs = roots
+		cfg.ServerName = t.ServerName
+		if cfg.ServerName == "" {
+			cfg.ServerName = host
+		}
+	default:
+		return nil, invalid(fmt.Sprintf("invalid tls mode %q", t.Mode))
+	}
+	return cfg, nil
+}
+
+func verifyChain(cs tls.ConnectionState, roots *x509.CertPool) error {
+	if len(cs.PeerCertificates) == 0 {
+		return errors.New("tls: server sent no certificate")
+	}
+	opts := x509.VerifyOptions{Roots: roots, Intermediates: x509.NewCertPool()}
+	for _, cert := range cs.PeerCertificates[1:] {
+		opts.Intermediates.AddCert(cert)
+	}
+	_, err := cs.PeerCertificates[0].Verify(opts)
+	return err
+}
@@ -0,0 +1,164 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// tunnelDialTimeout bounds connecting to the bastion when ctx has no deadline.
+const tunnelDialTimeout = 15 * time.Second
+
+// Tunnel is an SSH bastion a target is reached through. Engines that dial
+// over the network open it with the session and close it with the session.
+type Tunnel struct {
+	Host string
+	Port int
+	User string
+	// HostKey pins the bastion's public key, as in authorized_keys or a
+	// known_hosts / ssh-keyscan line.
+	HostKey string
+	// Key is a PEM private key without passphrase; Password is tried when
+	// the key is missing or refused.
+	Key      []byte
+	Password string
+}
+
+// Address is the bastion as user@host:port.
+func (t *Tunnel) Address() string {
+	return t.User + "@" + net.JoinHostPort(t.Host, strconv.Itoa(t.Port))
+}
+
+// Validate checks the tunnel settings; a nil tunnel is valid.
+func (t *Tunnel) Validate() error {
+	if t == nil {
+		return nil
+	}
+	if strings.TrimSpace(t.Host) == "" || strings.TrimSpace(t.User) == "" {
+		return invalid("ssh host and user required")
+	}
+	if t.Port <= 0 {
+		return invalid("ssh port must be positive")
+	}
+	_, _, err := t.clientConfig()
+	return err
+}
+
+func (t *Tunnel) clientConfig() (*ssh.ClientConfig, ssh.PublicKey, error) {
+	hostKey, err := parseHostKey(t.HostKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	var auth []ssh.AuthMethod
+	if len(t.Key) > 0 {
+		signer, err := ssh.ParsePrivateKey(t.Key)
+		if err != nil {
+			return nil, nil, invalid("ssh private key: " + err.Error())
+		}
+		auth = append(auth, ssh.PublicKeys(signer))
+	}
+	if t.Password != "" {
+		auth = append(auth, ssh.Password(t.Password))
+	}
+	if len(auth) == 0 {
+		return nil, nil, invalid("ssh private key or password required")
+	}
+	algos := []string{hostKey.Type()}
+	if hostKey.Type() == ssh.KeyAlgoRSA {
+		algos = []string{ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSASHA256, ssh.KeyAlgoRSA}
+	}
+	return &ssh.ClientConfig{
+		User:              t.User,
+		Auth:              auth,
+		HostKeyCallback:   ssh.FixedHostKey(hostKey),
+		HostKeyAlgorithms: algos,
+		Timeout:           tunnelDialTimeout,
+	}, hostKey, nil
+}
+
+// parseHostKey reads an authorized_keys line or a known_hosts line, which is
+// what ssh-keyscan prints.
+func parseHostKey(s string) (ssh.PublicKey, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, invalid("ssh host key required (see ssh-keyscan)")
+	}
+	if key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(s)); err == nil {
+		return key, nil
+	}
+	_, _, key, _, _, err := ssh.ParseKnownHosts([]byte(s))
+	if err != nil {
+		return nil, invalid("ssh host key: " + err.Error())
+	}
+	return key, nil
+}
+
+// tunnel is an open connection to a bastion.
+type tunnel struct {
+	client *ssh.Client
+	addr   string
+	log    Logger
+}
+
+// openTunnel connects to the bastion of t, or returns nil when t has none.
+func openTunnel(ctx context.Context, t *Tunnel, log Logger) (*tunnel, error) {
+	if t == nil {
+		return nil, nil
+	}
+	cfg, hostKey, err := t.clientConfig()
+	if err != nil {
+		return nil, err
+	}
+	hostPort := net.JoinHostPort(t.Host, strconv.Itoa(t.Port))
+	d := net.Dialer{Timeout: tunnelDialTimeout}
+	raw, err := d.DialContext(ctx, "tcp", hostPort)
+	if err != nil {
+		return nil, fmt.Errorf("ssh tunnel %s: %w", t.Address(), err)
+	}
+	// The handshake does not watch ctx; bound it by the deadline instead.
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(tunnelDialTimeout)
+	}
+	raw.SetDeadline(deadline) // nolint:errcheck
+	conn, chans, reqs, err := ssh.NewClientConn(raw, hostPort, cfg)
+	if err != nil {
+		raw.Close()
+		return nil, fmt.Errorf("ssh tunnel %s: %w", t.Address(), err)
+	}
+	raw.SetDeadline(time.Time{}) // nolint:errcheck
+	tun := &tunnel{client: ssh.NewClient(conn, chans, reqs), addr: t.Address(), log: log}
+	if log != nil {
+		log.Info("ssh tunnel opened", "bastion", tun.addr, "host_key", ssh.FingerprintSHA256(hostKey))
+	}
+	return tun, nil
+}
+
+// dial opens a connection from the bastion to addr.
+func (t *tunnel) dial(ctx context.Context, network string, addr string) (net.Conn, error) {
+	conn, err := t.client.DialContext(ctx, network, addr)
+	if err != nil {
+		return nil, fmt.Errorf("ssh tunnel %s: dial %s: %w", t.addr, addr, err)
+	}
+	return conn, nil
+}
+
+// close ends the tunnel; it is safe on a nil tunnel.
+func (t *tunnel) close() {
+	if t == nil {
+		return
+	}
+	err := t.client.Close()
+	if t.log != nil {
+		if err != nil {
+			t.log.Error("ssh tunnel close failed", "bastion", t.addr, "error", err)
+			return
+		}
+		t.log.Info("ssh tunnel closed", "bastion", t.addr)
+	}
+}
@@ -0,0 +1,248 @@
+package engine
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// testBastion is an in-process SSH server that accepts one password and
+// forwards direct-tcpip channels.
+type testBastion struct {
+	ln      net.Listener
+	hostKey ssh.PublicKey
+}
+
+func newTestBastion(t *testing.T, password string) *testBastion {
+	t.Helper()
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &ssh.ServerConfig{
+		PasswordCallback: func(_ ssh.ConnMetadata, pass []byte) (*ssh.Permissions, error) {
+			if string(pass) != password {
+				return nil, io.EOF
+			}
+			return nil, nil
+		},
+	}
+	cfg.AddHostKey(signer)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go serveBastionConn(conn, cfg)
+		}
+	}()
+	return &testBastion{ln: ln, hostKey: signer.PublicKey()}
+}
+
+func serveBastionConn(conn net.Conn, cfg *ssh.ServerConfig) {
+	_, chans, reqs, err := ssh.NewServerConn(conn, cfg)
+	if err != nil {
+		conn.Close()
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+	for nc := range chans {
+		if nc.ChannelType() != "direct-tcpip" {
+			nc.Reject(ssh.UnknownChannelType, "unsupported")
+			continue
+		}
+		var dest struct {
+			Host     string
+			Port     uint32
+			OrigHost string
+			OrigPort uint32
+		}
+		if err := ssh.Unmarshal(nc.ExtraData(), &dest); err != nil {
+			nc.Reject(ssh.ConnectionFailed, err.Error())
+			continue
+		}
+		up, err := net.Dial("tcp", net.JoinHostPort(dest.Host, strconv.Itoa(int(dest.Port))))
+		if err != nil {
+			nc.Reject(ssh.ConnectionFailed, err.Error())
+			continue
+		}
+		ch, chReqs, err := nc.Accept()
+		if err != nil {
+			up.Close()
+			continue
+		}
+		go ssh.DiscardRequests(chReqs)
+		go func() {
+			io.Copy(ch, up)
+			ch.Close()
+		}()
+		go func() {
+			io.Copy(up, ch)
+			up.Close()
+		}()
+	}
+}
+
+func (b *testBastion) tunnel(hostKey ssh.PublicKey, password string) *Tunnel {
+	addr := b.ln.Addr().(*net.TCPAddr)
+	return &Tunnel{
+		Host:     addr.IP.String(),
+		Port:     addr.Port,
+		User:     "migrate",
+		HostKey:  string(ssh.MarshalAuthorizedKey(hostKey)),
+		Password: password,
+	}
+}
+
+// startEcho starts a TCP server that writes back what it reads.
+func startEcho(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				io.Copy(conn, conn)
+				conn.Close()
+			}()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func TestTunnelDial(t *testing.T) {
+	bastion := newTestBastion(t, "secret")
+	echo := startEcho(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tun, err := openTunnel(ctx, bastion.tunnel(bastion.hostKey, "secret"), nil)
+	if err != nil {
+		t.Fatalf("openTunnel: %v", err)
+	}
+	defer tun.close()
+
+	conn, err := tun.dial(ctx, "tcp", echo)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte("ping")); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	buf := make([]byte, 4)
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if string(buf) != "ping" {
+		t.Fatalf("read %q, want ping", buf)
+	}
+}
+
+func TestTunnelHostKeyMismatch(t *testing.T) {
+	bastion := newTestBastion(t, "secret")
+	other, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := ssh.NewPublicKey(other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tun, err := openTunnel(ctx, bastion.tunnel(otherKey, "secret"), nil)
+	if err == nil {
+		tun.close()
+		t.Fatal("openTunnel: want host key mismatch")
+	}
+	if !strings.Contains(err.Error(), "host key mismatch") {
+		t.Fatalf("openTunnel: %v, want host key mismatch", err)
+	}
+}
+
+func TestTunnelAuthRejected(t *testing.T) {
+	bastion := newTestBastion(t, "secret")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tun, err := openTunnel(ctx, bastion.tunnel(bastion.hostKey, "wrong"), nil)
+	if err == nil {
+		tun.close()
+		t.Fatal("openTunnel: want authentication failure")
+	}
+}
+
+func TestTunnelDialRefused(t *testing.T) {
+	bastion := newTestBastion(t, "secret")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tun, err := openTunnel(ctx, bastion.tunnel(bastion.hostKey, "secret"), nil)
+	if err != nil {
+		t.Fatalf("openTunnel: %v", err)
+	}
+	defer tun.close()
+
+	// A closed port behind the bastion.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+	if conn, err := tun.dial(ctx, "tcp", addr); err == nil {
+		conn.Close()
+		t.Fatal("dial: want error")
+	}
+}
+
+func TestParseHostKey(t *testing.T) {
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	line := strings.TrimSpace(string(ssh.MarshalAuthorizedKey(key)))
+	for _, s := range []string{line, "bastion.example.com " + line} {
+		got, err := parseHostKey(s)
+		if err != nil {
+			t.Fatalf("parseHostKey(%q): %v", s, err)
+		}
+		if ssh.FingerprintSHA256(got) != ssh.FingerprintSHA256(key) {
+			t.Fatalf("parseHostKey(%q): wrong key", s)
+		}
+	}
+	if _, err := parseHostKey("not a key"); err == nil {
+		t.Fatal("parseHostKey: want error")
+	}
+}
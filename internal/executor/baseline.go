@@ -0,0 +1,64 @@
+package executor
+
+import (
+	"context"
+	"log/slog"
+
+	"db_inner_migrator_syncer/internal/engine"
+	"db_inner_migrator_syncer/internal/store"
+)
+
+// baselineItem marks the migration applied on the target without executing
+// it: under the target lock it checks the ledger like an apply, runs the
+// run's verification query if it has one and writes the ledger row with the
+// migration's checksum. Nothing else on the target changes.
+func (e *Executor) baselineItem(ctx context.Context, run store.Run, mig store.Migration, target *store.DBTarget, conn engine.Target, eng engine.Engine, timeouts store.Timeouts, log *slog.Logger) error {
+	ledger, err := store.TargetLedger(target.Options)
+	if err != nil {
+		return err
+	}
+	record, err := e.ledgerRecord(ctx, run, mig)
+	if err != nil {
+		return err
+	}
+	verify := run.Verify()
+	log.Info("baseline: recording the migration without executing sql_up", "verify", verify != nil)
+	logTimeouts(log, timeouts)
+
+	sess, connCtx, closeSession, err := openSession(ctx, target, conn, eng, log)
+	if err != nil {
+		return err
+	}
+	defer closeSession()
+	unlock, err := lockTarget(connCtx, sess, timeouts, log)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	state, err := sess.EnsureLedger(connCtx, ledger, log)
+	if err != nil {
+		return err
+	}
+	entry, err := sess.LookupLedger(connCtx, ledger, state, mig.Key)
+	if err != nil {
+		return err
+	}
+	log.Info("ledger checked", "ledger", ledger.String(), "ledger_version", state.Version, "migration_key", mig.Key, "recorded", entry.Found, "checksum_up", entry.ChecksumUp)
+	if err := checkLedger(run, entry.Found, entry.ChecksumUp); err != nil {
+		return err
+	}
+	if verify != nil {
+		if err := runCheck(connCtx, sess, nil, eng.Dialect(), verify, log); err != nil {
+			return err
+		}
+	}
+	if ctx.Err() != nil {
+		return context.Cause(ctx)
+	}
+	if err := sess.RecordApply(connCtx, nil, ledger, record); err != nil {
+		return err
+	}
+	log.Info("ledger row recorded (baseline)", "migration_key", mig.Key)
+	return nil
+}
@@ -0,0 +1,177 @@
+package executor
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"db_inner_migrator_syncer/internal/engine"
+	"db_inner_migrator_syncer/internal/store"
+)
+
+// checkRowLimit is how many rows of a check are read; enough to tell one row
+// from several and to quote the first violation.
+const checkRowLimit = 2
+
+// maxCheckValueLen caps a value quoted in a check failure.
+const maxCheckValueLen = 200
+
+// runCheck runs a precheck, postcheck or verification query. Inside the
+// migration transaction (tx set) it runs in a savepoint, otherwise in a
+// read-only transaction; both are rolled back, so the check cannot change the
+// target.
+func runCheck(ctx context.Context, sess engine.Session, tx engine.Tx, dialect string, check *store.Check, log *slog.Logger) error {
+	stmt, err := store.CheckStatement(dialect, check.SQL)
+	if err != nil {
+		return fmt.Errorf("%s: %w", check.Phase, err)
+	}
+	start := time.Now()
+	columns, values, err := sess.Query(ctx, tx, stmt.SQL, checkRowLimit)
+	if err != nil {
+		return fmt.Errorf("%s query: %w", check.Phase, err)
+	}
+	return checkResult(check, columns, values, time.Since(start), log)
+}
+
+// checkResult logs the outcome of a check and returns ErrPrecheckFailed,
+// ErrPostcheckFailed or ErrVerifyFailed with the reason when the rows do not
+// satisfy it.
+func checkResult(check *store.Check, columns int, rows [][]any, d time.Duration, log *slog.Logger) error {
+	reason := evalCheck(check.Expect, columns, rows)
+	if reason == "" {
+		log.Info(check.Phase+" passed", "expect", check.Expect, "duration", d)
+		return nil
+	}
+	log.Error(check.Phase+" failed", "expect", check.Expect, "duration", d, "reason", reason)
+	failed := store.ErrPrecheckFailed
+	switch check.Phase {
+	case store.CheckPost:
+		failed = store.ErrPostcheckFailed
+	case store.CheckVerify:
+		failed = store.ErrVerifyFailed
+	}
+	return fmt.Errorf("%w: %s", failed, reason)
+}
+
+// postcheckFailure notes on a failed postcheck that a script run without a
+// transaction could not be undone.
+func postcheckFailure(txMode string, err error) error {
+	if txMode == "no_transaction" {
+		return fmt.Errorf("%w (no_transaction: the statements stay applied and the ledger was not written)", err)
+	}
+	return err
+}
+
+// evalCheck returns why rows do not satisfy expect, or "" when they do.
+func evalCheck(expect string, columns int, rows [][]any) string {
+	if expect == store.CheckExpectNoRows {
+		switch len(rows) {
+		case 0:
+			return ""
+		case 1:
+			return "expected no rows, got " + formatRow(rows[0])
+		default:
+			return "expected no rows, got " + formatRow(rows[0]) + " and more"
+		}
+	}
+	switch {
+	case len(rows) == 0:
+		return "expected one true value, got no rows"
+	case len(rows) > 1:
+		return "expected one true value, got more than one row"
+	case columns != 1:
+		return fmt.Sprintf("expected one true value, got %d columns", columns)
+	case !truthy(rows[0][0]):
+		return "expected a true value, got " + formatValue(rows[0][0])
+	}
+	return ""
+}
+
+// truthy treats true, non-zero numbers and strings such as 't', 'yes' or '1'
+// as true. MySQL returns every value as text, so strings are parsed too.
+func truthy(v any) bool {
+	switch x := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return x
+	case int64:
+		return x != 0
+	case int32:
+		return x != 0
+	case int16:
+		return x != 0
+	case int8:
+		return x != 0
+	case int:
+		return x != 0
+	case uint64:
+		return x != 0
+	case float64:
+		return x != 0
+	case float32:
+		return x != 0
+	case []byte:
+		return truthyString(string(x))
+	case string:
+		return truthyString(x)
+	case driver.Valuer:
+		// pgtype values such as numeric.
+		dv, err := x.Value()
+		if err != nil {
+			return false
+		}
+		if _, ok := dv.(driver.Valuer); ok {
+			return false
+		}
+		return truthy(dv)
+	default:
+		return truthyString(fmt.Sprint(x))
+	}
+}
+
+func truthyString(s string) bool {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return f != 0
+	}
+	switch s {
+	case "t", "true", "y", "yes", "on":
+		return true
+	}
+	return false
+}
+
+func formatRow(row []any) string {
+	parts := make([]string, len(row))
+	for i, v := range row {
+		parts[i] = formatValue(v)
+	}
+	return "(" + strings.Join(parts, ", ") + ")"
+}
+
+func formatValue(v any) string {
+	var s string
+	switch x := v.(type) {
+	case nil:
+		return "NULL"
+	case []byte:
+		s = string(x)
+	case driver.Valuer:
+		dv, err := x.Value()
+		if err != nil || dv == nil {
+			return "NULL"
+		}
+		s = fmt.Sprint(dv)
+	default:
+		s = fmt.Sprint(x)
+	}
+	if len(s) > maxCheckValueLen {
+		s = s[:maxCheckValueLen] + "..."
+	}
+	return s
+}
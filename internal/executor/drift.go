@@ -0,0 +1,179 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"db_inner_migrator_syncer/internal/audit"
+	"db_inner_migrator_syncer/internal/engine"
+	"db_inner_migrator_syncer/internal/store"
+)
+
+// syncTargetTimeout bounds reading the ledger of one target.
+const syncTargetTimeout = 30 * time.Second
+
+// syncParallel is how many target ledgers a sync reads at once.
+const syncParallel = 4
+
+// SyncTarget reads the ledger of a target, compares it with the hub and
+// stores the result. Drift that the previous sync did not show is logged and
+// audited as target_drift_detected; drift going away as target_drift_resolved.
+// A ledger that cannot be read is stored as status error.
+func (e *Executor) SyncTarget(ctx context.Context, targetID uuid.UUID, actorID *uuid.UUID) (*store.TargetSync, error) {
+	if _, err := store.ClaimTargetSync(ctx, e.pool, targetID, 0); err != nil {
+		return nil, err
+	}
+	return e.syncTarget(ctx, targetID, actorID)
+}
+
+// SyncTargets syncs several targets, syncParallel at a time, and returns the
+// results in order. A target that could not be synced at all has a nil result.
+func (e *Executor) SyncTargets(ctx context.Context, targetIDs []uuid.UUID, actorID *uuid.UUID) []*store.TargetSync {
+	out := make([]*store.TargetSync, len(targetIDs))
+	sem := make(chan struct{}, syncParallel)
+	var wg sync.WaitGroup
+	for i, id := range targetIDs {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, id uuid.UUID) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			res, err := e.SyncTarget(ctx, id, actorID)
+			if err != nil {
+				e.logger.Error("target sync failed", "db_target_id", id, "error", err)
+				return
+			}
+			out[i] = res
+		}(i, id)
+	}
+	wg.Wait()
+	return out
+}
+
+// SyncDueTargets syncs the active targets not synced within every, skipping
+// targets another server claimed meanwhile. It returns how many were synced.
+func (e *Executor) SyncDueTargets(ctx context.Context, every time.Duration) (int, error) {
+	ids, err := store.ListDueTargetSyncs(ctx, e.pool, every)
+	if err != nil {
+		return 0, err
+	}
+	synced := 0
+	for _, id := range ids {
+		if ctx.Err() != nil {
+			return synced, nil
+		}
+		claimed, err := store.ClaimTargetSync(ctx, e.pool, id, every)
+		if err != nil {
+			return synced, err
+		}
+		if !claimed {
+			continue
+		}
+		if _, err := e.syncTarget(ctx, id, nil); err != nil {
+			e.logger.Error("target sync failed", "db_target_id", id, "error", err)
+			continue
+		}
+		synced++
+	}
+	return synced, nil
+}
+
+func (e *Executor) syncTarget(ctx context.Context, targetID uuid.UUID, actorID *uuid.UUID) (*store.TargetSync, error) {
+	target, conn, err := e.loadTarget(ctx, targetID)
+	if err != nil {
+		return nil, err
+	}
+	result := &store.TargetSync{DBTargetID: targetID}
+	ledger, err := store.TargetLedger(target.Options)
+	if err == nil && target.Fanout() {
+		err = fmt.Errorf("drift detection: %w", store.ErrFanoutUnsupported)
+	}
+	if err == nil {
+		result.Ledger = ledger.String()
+		var rows []store.LedgerRow
+		readCtx, cancel := context.WithTimeout(ctx, syncTargetTimeout)
+		rows, err = readTargetLedger(readCtx, target, conn, ledger)
+		cancel()
+		if err == nil {
+			result.Entries, err = store.ClassifyLedger(ctx, e.pool, target, rows)
+		}
+	}
+	if err != nil {
+		msg := err.Error()
+		result.Status = store.TargetSyncError
+		result.Error = &msg
+	}
+	appeared, resolved, err := store.SaveTargetSync(ctx, e.pool, result)
+	if err != nil {
+		return nil, err
+	}
+
+	name := target.Address()
+	switch {
+	case result.Status == store.TargetSyncError:
+		e.logger.Error("target sync failed", "db_target_id", targetID, "target", name, "error", *result.Error)
+	case len(appeared) > 0:
+		drift := make(map[string]string, len(appeared))
+		keys := make([]string, 0, len(appeared))
+		for _, entry := range appeared {
+			drift[entry.Key] = entry.State
+			keys = append(keys, entry.Key+"="+entry.State)
+		}
+		e.logger.Error("target drift detected", "db_target_id", targetID, "target", name, "ledger", result.Ledger, "drift_count", result.DriftCount, "new", strings.Join(keys, ","))
+		_ = audit.LogEvent(ctx, e.pool, e.logger, audit.Event{
+			ActorID:    actorID,
+			Action:     "target_drift_detected",
+			EntityType: "db_target",
+			EntityID:   &target.ID,
+			Payload: map[string]any{
+				"db_set_id":   target.DBSetID,
+				"ledger":      result.Ledger,
+				"drift_count": result.DriftCount,
+				"new_drift":   drift,
+			},
+		})
+	case resolved:
+		e.logger.Info("target drift resolved", "db_target_id", targetID, "target", name)
+		_ = audit.LogEvent(ctx, e.pool, e.logger, audit.Event{
+			ActorID:    actorID,
+			Action:     "target_drift_resolved",
+			EntityType: "db_target",
+			EntityID:   &target.ID,
+			Payload: map[string]any{
+				"db_set_id": target.DBSetID,
+				"ledger":    result.Ledger,
+			},
+		})
+	default:
+		e.logger.Info("target synced", "db_target_id", targetID, "target", name, "status", result.Status, "drift_count", result.DriftCount)
+	}
+	return result, nil
+}
+
+// readTargetLedger reads the ledger rows of a target on its own session; a
+// missing ledger table has no rows.
+func readTargetLedger(ctx context.Context, target *store.DBTarget, conn engine.Target, ledger store.Ledger) ([]store.LedgerRow, error) {
+	if !target.IsActive {
+		return nil, errors.New("target disabled")
+	}
+	eng, err := engine.Lookup(target.Engine)
+	if err != nil {
+		return nil, err
+	}
+	sess, err := eng.Open(ctx, conn, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer sess.Close(context.WithoutCancel(ctx)) // nolint:errcheck
+	state, err := sess.LedgerState(ctx, ledger)
+	if err != nil {
+		return nil, err
+	}
+	return sess.ReadLedger(ctx, ledger, state)
+}
@@ -2,21 +2,20 @@ package executor
 
 import (
 	"context"
-	"database/sql"
 	"errors"
 	"fmt"
-	"net"
-	"net/url"
+	"log/slog"
 	"strings"
+	"sync"
 	"time"
 
-	"github.com/go-sql-driver/mysql"
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
-	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 
-	"db_inner_migrator_syncer/internal/secret"
+	"db_inner_migrator_syncer/internal/audit"
+	"db_inner_migrator_syncer/internal/engine"
+	"db_inner_migrator_syncer/internal/sqlscript"
 	"db_inner_migrator_syncer/internal/store"
 )
 
@@ -25,97 +24,292 @@ type Logger interface {
 	Error(msg string, args ...any)
 }
 
+// heartbeatInterval is how often a running run records a heartbeat and checks
+// the tool DB for a cancel request made through another server instance.
+const heartbeatInterval = time.Second
+
+// Options holds executor settings that come from the server configuration.
+type Options struct {
+	// RetryApprovalValidity is how long after approval a failed run may be retried.
+	RetryApprovalValidity time.Duration
+	// InstanceID names this server in the ledger rows it writes.
+	InstanceID string
+}
+
 type Executor struct {
 	pool      *pgxpool.Pool
 	secretKey []byte
 	logger    Logger
+	opts      Options
+	wake      chan struct{}
+
+	mu     sync.Mutex
+	active map[uuid.UUID]context.CancelCauseFunc
 }
 
-func New(pool *pgxpool.Pool, secretKey []byte, logger Logger) *Executor {
-	return &Executor{pool: pool, secretKey: secretKey, logger: logger}
+func New(pool *pgxpool.Pool, secretKey []byte, logger Logger, opts Options) *Executor {
+	return &Executor{
+		pool:      pool,
+		secretKey: secretKey,
+		logger:    logger,
+		opts:      opts,
+		wake:      make(chan struct{}, 1),
+		active:    make(map[uuid.UUID]context.CancelCauseFunc),
+	}
 }
 
-func (e *Executor) ExecuteRun(ctx context.Context, projectID uuid.UUID, runID uuid.UUID, actorID uuid.UUID) (*store.RunWithItems, error) {
-	run, err := store.GetRunWithItems(ctx, e.pool, projectID, runID)
+// QueueRun moves an approved run to queued and wakes an idle worker.
+// acceptWarnings are the pre-flight warning ids execution may proceed with.
+func (e *Executor) QueueRun(ctx context.Context, projectID uuid.UUID, runID uuid.UUID, actorID uuid.UUID, acceptWarnings []string) (*store.Run, error) {
+	run, err := store.QueueRun(ctx, e.pool, projectID, runID, actorID, acceptWarnings)
 	if err != nil {
 		return nil, err
 	}
-	if run.Status != "approved" {
-		return nil, errors.New("run must be approved before execution")
+	e.notify()
+	return run, nil
+}
+
+// RequestDryRun creates a dry run, which needs no approval, and wakes an idle worker.
+func (e *Executor) RequestDryRun(ctx context.Context, input store.RequestRunInput) (*store.RunWithItems, error) {
+	input.RunType = "dry_run"
+	run, err := e.RequestRun(ctx, input)
+	if err != nil {
+		return nil, err
 	}
+	e.notify()
+	return run, nil
+}
 
-	mig, err := store.GetMigration(ctx, e.pool, projectID, run.MigrationID)
+// RetryRun re-queues the failed and canceled items of a run under its original
+// approval and wakes an idle worker.
+func (e *Executor) RetryRun(ctx context.Context, projectID uuid.UUID, runID uuid.UUID, actorID uuid.UUID, acceptWarnings []string) (*store.RunWithItems, error) {
+	run, err := store.RetryRun(ctx, e.pool, projectID, runID, actorID, e.opts.RetryApprovalValidity, acceptWarnings)
 	if err != nil {
 		return nil, err
 	}
-	if mig.ChecksumUp != run.ChecksumUpAtRequest || !equalNullable(mig.ChecksumDown, run.ChecksumDownAtRequest) {
-		return nil, store.ErrChecksumMismatch
+	e.notify()
+	return run, nil
+}
+
+// ContinueRun re-queues a run paused at a manual wave gate and wakes an idle worker.
+func (e *Executor) ContinueRun(ctx context.Context, projectID uuid.UUID, runID uuid.UUID) (*store.Run, error) {
+	run, err := store.ContinueRun(ctx, e.pool, projectID, runID)
+	if err != nil {
+		return nil, err
+	}
+	e.notify()
+	return run, nil
+}
+
+func (e *Executor) notify() {
+	select {
+	case e.wake <- struct{}{}:
+	default:
 	}
+}
 
-	now := time.Now().UTC()
-	if _, err := e.pool.Exec(ctx, `
-UPDATE runs
-SET status = 'running', executed_by = $1, started_at = $2
-WHERE id = $3
-`, actorID, now, run.ID); err != nil {
+// CancelRun cancels an approved or queued run, or asks a running run to stop.
+// A run executing in this process is interrupted right away; runs on other
+// instances notice the request within heartbeatInterval.
+func (e *Executor) CancelRun(ctx context.Context, projectID uuid.UUID, runID uuid.UUID, actorID uuid.UUID, reason string) (*store.Run, error) {
+	run, err := store.CancelRun(ctx, e.pool, projectID, runID, actorID, reason)
+	if err != nil {
 		return nil, err
 	}
+	if run.Status == "running" {
+		e.mu.Lock()
+		cancel := e.active[run.ID]
+		e.mu.Unlock()
+		if cancel != nil {
+			cancel(cancelCause(run.CancelReason))
+		}
+	}
+	return run, nil
+}
 
-	run.Status = "running"
-	run.StartedAt = &now
-	run.ExecutedBy = &actorID
+func cancelCause(reason *string) error {
+	if reason == nil || *reason == "" {
+		return store.ErrRunCanceled
+	}
+	return fmt.Errorf("%w: %s", store.ErrRunCanceled, *reason)
+}
 
-	var firstErr error
-	for i := range run.Items {
-		item := &run.Items[i]
-		if item.Status != "queued" {
+// watchRun sends heartbeats for a running run and cancels the run context once
+// a cancel request shows up in the tool DB.
+func (e *Executor) watchRun(ctx context.Context, runID uuid.UUID, cancel context.CancelCauseFunc) {
+	ticker := time.NewTicker(heartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		requested, reason, err := store.HeartbeatRun(ctx, e.pool, runID)
+		if err != nil {
+			if ctx.Err() == nil {
+				e.logger.Error("run heartbeat failed", "run_id", runID, "error", err)
+			}
 			continue
 		}
-		if err := e.updateRunItemStatus(ctx, item.ID, "running", nil, nil); err != nil {
-			firstErr = err
-			break
+		if requested {
+			cancel(cancelCause(&reason))
+			return
 		}
-		item.Status = "running"
-		start := time.Now().UTC()
-		item.StartedAt = &start
-
-		err := e.executeItem(ctx, run.Run, *item, *mig)
-		if errors.Is(err, store.ErrAlreadyApplied) {
-			end := time.Now().UTC()
-			msg := "already applied, skipped"
-			_ = e.updateRunItemStatus(ctx, item.ID, "skipped", &msg, &end)
-			item.Status = "skipped"
-			item.FinishedAt = &end
-			item.Error = nil
+	}
+}
+
+// ExecuteRun processes the items of a run that a worker has already claimed.
+// Items run wave by wave; within a wave up to run.MaxParallel items run at
+// once. Once the run failure policy trips, no new items start and the
+// remaining queued items are canceled. A wave with failed or canceled items
+// stops the rollout; between waves the run gate may soak or pause the run.
+func (e *Executor) ExecuteRun(ctx context.Context, projectID uuid.UUID, runID uuid.UUID) (*store.RunWithItems, error) {
+	run, err := store.GetRunWithItems(ctx, e.pool, projectID, runID)
+	if err != nil {
+		return nil, err
+	}
+	if run.Status != "running" {
+		return nil, errors.New("run must be claimed before execution")
+	}
+
+	mig, err := store.GetMigration(ctx, e.pool, projectID, run.MigrationID)
+	if err != nil {
+		return e.failRun(ctx, run, err)
+	}
+	if mig.ChecksumUp != run.ChecksumUpAtRequest || !equalNullable(mig.ChecksumDown, run.ChecksumDownAtRequest) {
+		return e.failRun(ctx, run, store.ErrChecksumMismatch)
+	}
+	if run.RollsBack() && (mig.SQLDown == nil || strings.TrimSpace(*mig.SQLDown) == "") {
+		return e.failRun(ctx, run, store.ErrRollbackMissingSQL)
+	}
+
+	policy, err := store.ParseFailurePolicy(run.FailurePolicy)
+	if err != nil {
+		return e.failRun(ctx, run, err)
+	}
+	gate, err := store.ParseWaveGate(run.WaveGate)
+	if err != nil {
+		return e.failRun(ctx, run, err)
+	}
+	limit := run.MaxParallel
+	if limit < 1 {
+		limit = 1
+	}
+
+	runCtx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	e.mu.Lock()
+	e.active[run.ID] = cancel
+	e.mu.Unlock()
+	defer func() {
+		e.mu.Lock()
+		delete(e.active, run.ID)
+		e.mu.Unlock()
+	}()
+	go e.watchRun(runCtx, run.ID, cancel)
+	// Bookkeeping writes must still land after the run context is canceled.
+	dbCtx := context.WithoutCancel(ctx)
+
+	if blocking, err := e.preflightQueued(runCtx, dbCtx, run, mig); err != nil {
+		return e.failRun(dbCtx, run, err)
+	} else if len(blocking) > 0 && runCtx.Err() == nil {
+		cause := fmt.Errorf("%w: %s", store.ErrPreflightFailed, strings.Join(blocking, "; "))
+		e.cancelQueuedItems(dbCtx, run, "canceled: "+cause.Error())
+		return e.failRun(dbCtx, run, cause)
+	}
+
+	waves := runWaves(run.Items)
+	var (
+		stopped  bool
+		waveErr  error
+		firstErr error
+		ranWave  int
+	)
+	for i, wave := range waves {
+		if !hasQueuedItems(run.Items, wave) {
 			continue
 		}
-		if err != nil {
-			firstErr = err
-			end := time.Now().UTC()
-			msg := err.Error()
-			_ = e.updateRunItemStatus(ctx, item.ID, "failed", &msg, &end)
-			item.Status = "failed"
-			item.Error = &msg
-			item.FinishedAt = &end
+		// The gate sits between two waves of this execution; continuing a
+		// paused run passes it.
+		if ranWave > 0 && runCtx.Err() == nil {
+			if gate.Manual {
+				return e.pauseRun(dbCtx, runCtx, run, ranWave, wave)
+			}
+			if gate.Soak > 0 {
+				e.logger.Info("wave soak started", "run_id", run.ID, "wave", ranWave, "next_wave", wave, "soak", gate.Soak.String())
+				select {
+				case <-runCtx.Done():
+				case <-time.After(gate.Soak):
+				}
+			}
+		}
+		if runCtx.Err() != nil {
 			break
 		}
-		end := time.Now().UTC()
-		_ = e.updateRunItemStatus(ctx, item.ID, "executed", nil, &end)
-		item.Status = "executed"
-		item.FinishedAt = &end
+		stopped, firstErr = e.runWave(runCtx, run, mig, wave, policy, limit)
+		ranWave = wave
+		failed, canceled := countWaveItems(run.Items, wave, "failed"), countWaveItems(run.Items, wave, "canceled")
+		if stopped || failed > 0 || canceled > 0 || runCtx.Err() != nil {
+			if len(waves) > 1 && runCtx.Err() == nil {
+				waveErr = fmt.Errorf("wave %d of %d failed; rollout stopped", wave, len(waves))
+			}
+			break
+		}
+		if i < len(waves)-1 {
+			e.logger.Info("wave completed", "run_id", run.ID, "wave", wave, "waves", len(waves))
+			_ = audit.LogEvent(dbCtx, e.pool, e.logger, audit.Event{
+				ActorID:    run.ExecutedBy,
+				Action:     "run_wave_completed",
+				EntityType: "run",
+				EntityID:   &run.ID,
+				Payload: map[string]any{
+					"wave":      wave,
+					"waves":     len(waves),
+					"wave_gate": gate.String(),
+				},
+			})
+		}
 	}
 
-	finish := time.Now().UTC()
-	if firstErr != nil {
-		_, _ = e.pool.Exec(ctx, `
-UPDATE runs SET status = 'failed', finished_at = $1 WHERE id = $2
-`, finish, run.ID)
-		run.Status = "failed"
-		run.FinishedAt = &finish
-		return run, firstErr
+	if cause := context.Cause(runCtx); errors.Is(cause, store.ErrRunCanceled) {
+		e.cancelQueuedItems(dbCtx, run, "canceled: "+cause.Error())
+		return e.finishCanceledRun(dbCtx, run, cause)
+	}
+	switch {
+	case stopped:
+		e.cancelQueuedItems(dbCtx, run, "canceled: run stopped by failure policy "+policy.String())
+	case waveErr != nil:
+		e.cancelQueuedItems(dbCtx, run, "canceled: "+waveErr.Error())
+	case runCtx.Err() != nil:
+		e.cancelQueuedItems(dbCtx, run, "canceled: executor stopped before the item started")
 	}
 
-	_, _ = e.pool.Exec(ctx, `
+	var failed, canceled int
+	for _, item := range run.Items {
+		switch item.Status {
+		case "failed":
+			failed++
+		case "canceled":
+			canceled++
+		}
+	}
+	if failed > 0 || canceled > 0 {
+		cause := firstErr
+		if cause == nil {
+			cause = errors.New("run has failed or canceled items")
+		}
+		if failed+canceled > 1 {
+			cause = fmt.Errorf("%d failed, %d canceled of %d items; first error: %w", failed, canceled, len(run.Items), cause)
+		}
+		if waveErr != nil {
+			cause = fmt.Errorf("%v: %w", waveErr, cause)
+		}
+		return e.failRun(dbCtx, run, cause)
+	}
+
+	finish := time.Now().UTC()
+	_, _ = e.pool.Exec(dbCtx, `
 UPDATE runs SET status = 'executed', finished_at = $1 WHERE id = $2
 `, finish, run.ID)
 	run.Status = "executed"
@@ -123,218 +317,601 @@ UPDATE runs SET status = 'executed', finished_at = $1 WHERE id = $2
 	return run, nil
 }
 
-func (e *Executor) executeItem(ctx context.Context, run store.Run, item store.RunItem, mig store.Migration) error {
-	target, encPwd, err := store.GetDBTarget(ctx, e.pool, item.DBTargetID)
+// runWave executes the queued items of one wave, up to limit at once, and
+// reports whether the failure policy stopped it and the first item error.
+func (e *Executor) runWave(ctx context.Context, run *store.RunWithItems, mig *store.Migration, wave int, policy store.FailurePolicy, limit int) (bool, error) {
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		failures int
+		stopped  bool
+		firstErr error
+	)
+	sem := make(chan struct{}, limit)
+	for i := range run.Items {
+		item := &run.Items[i]
+		if item.Status != "queued" || item.Wave != wave {
+			continue
+		}
+		sem <- struct{}{}
+		mu.Lock()
+		stop := stopped
+		mu.Unlock()
+		if stop || ctx.Err() != nil {
+			<-sem
+			break
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			if err := e.runItem(ctx, &run.Run, item, mig); err != nil {
+				mu.Lock()
+				failures++
+				if firstErr == nil {
+					firstErr = err
+				}
+				if policy.ShouldStop(failures) {
+					stopped = true
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	return stopped, firstErr
+}
+
+// pauseRun stops the run at a manual gate after wave done; continuing the run
+// re-queues it and execution resumes with wave next. A cancel requested
+// meanwhile wins over the pause.
+func (e *Executor) pauseRun(ctx context.Context, runCtx context.Context, run *store.RunWithItems, done int, next int) (*store.RunWithItems, error) {
+	paused, err := store.PauseRun(ctx, e.pool, run.ID)
 	if err != nil {
+		return e.failRun(ctx, run, err)
+	}
+	if !paused {
+		_, reason, _ := store.HeartbeatRun(ctx, e.pool, run.ID)
+		cause := cancelCause(&reason)
+		e.cancelQueuedItems(ctx, run, "canceled: "+cause.Error())
+		return e.finishCanceledRun(ctx, run, cause)
+	}
+	e.logger.Info("run paused", "run_id", run.ID, "wave", done, "next_wave", next)
+	_ = audit.LogEvent(ctx, e.pool, e.logger, audit.Event{
+		ActorID:    run.ExecutedBy,
+		Action:     "run_paused",
+		EntityType: "run",
+		EntityID:   &run.ID,
+		Payload: map[string]any{
+			"wave":      done,
+			"next_wave": next,
+		},
+	})
+	run.Status = "paused"
+	run.HeartbeatAt = nil
+	return run, nil
+}
+
+// runWaves returns the distinct waves of the items in order.
+func runWaves(items []store.RunItem) []int {
+	var waves []int
+	for _, item := range items {
+		if len(waves) == 0 || waves[len(waves)-1] != item.Wave {
+			waves = append(waves, item.Wave)
+		}
+	}
+	return waves
+}
+
+func hasQueuedItems(items []store.RunItem, wave int) bool {
+	return countWaveItems(items, wave, "queued") > 0
+}
+
+func countWaveItems(items []store.RunItem, wave int, status string) int {
+	n := 0
+	for _, item := range items {
+		if item.Wave == wave && item.Status == status {
+			n++
+		}
+	}
+	return n
+}
+
+// preflightQueued checks the targets of the queued items before any of them
+// starts and returns what blocks execution. Dry runs change nothing, so only
+// failed checks block them.
+func (e *Executor) preflightQueued(ctx context.Context, dbCtx context.Context, run *store.RunWithItems, mig *store.Migration) ([]string, error) {
+	var queued []store.RunItem
+	for _, item := range run.Items {
+		if item.Status == "queued" {
+			queued = append(queued, item)
+		}
+	}
+	report := e.preflight(ctx, &run.Run, mig, queued)
+	if err := store.SavePreflight(dbCtx, e.pool, report); err != nil {
+		return nil, err
+	}
+	run.Preflight = report
+	accepted := run.AcceptedWarnings
+	if run.RunType == "dry_run" {
+		accepted = report.Warnings
+	}
+	blocking := report.Blocking(accepted)
+	e.logger.Info("pre-flight finished", "run_id", run.ID, "status", report.Status, "targets", len(report.Targets), "blocking", len(blocking))
+	return blocking, nil
+}
+
+// runItem executes one queued item and records its final status. It returns
+// an error only when the item failed; skipped items count as success.
+func (e *Executor) runItem(ctx context.Context, run *store.Run, item *store.RunItem, mig *store.Migration) error {
+	dbCtx := context.WithoutCancel(ctx)
+	if err := e.updateRunItemStatus(dbCtx, item.ID, "running", nil, nil, nil); err != nil {
 		return err
 	}
-	if !target.IsActive {
-		return errors.New("target disabled")
+	item.Status = "running"
+	start := time.Now().UTC()
+	item.StartedAt = &start
+
+	itemLog := e.newItemLogger(dbCtx, item.ID)
+	itemLog.Info("item started", "run_id", run.ID, "run_type", run.RunType, "db_target_id", item.DBTargetID, "migration_key", mig.Key)
+	if item.Tenant != "" {
+		itemLog.Info("fan-out tenant", "tenant", item.Tenant)
 	}
-	password, err := secret.Decrypt(e.secretKey, encPwd)
+	var res itemResult
+	err := e.executeItem(ctx, *run, *item, *mig, &res, itemLog)
+	end := time.Now().UTC()
+	item.FinishedAt = &end
 	if err != nil {
-		return fmt.Errorf("decrypt password: %w", err)
+		if cause := context.Cause(ctx); errors.Is(cause, store.ErrRunCanceled) {
+			err = cause
+		}
 	}
-
-	switch strings.ToLower(target.Engine) {
-	case "postgres":
-		return e.execPostgres(ctx, run, item, mig, target, string(password))
-	case "mysql":
-		return e.execMySQL(ctx, run, item, mig, target, string(password))
+	switch {
+	case errors.Is(err, store.ErrRunCanceled):
+		itemLog.Info("item canceled", "reason", err.Error())
+		msg := "canceled: " + err.Error()
+		_ = e.updateRunItemStatus(dbCtx, item.ID, "canceled", &msg, nil, &end)
+		item.Status = "canceled"
+		item.Error = &msg
+		return err
+	case errors.Is(err, store.ErrAlreadyApplied):
+		itemLog.Info("item skipped", "reason", "already applied")
+		msg := "already applied, skipped"
+		_ = e.updateRunItemStatus(dbCtx, item.ID, "skipped", &msg, nil, &end)
+		item.Status = "skipped"
+		item.Error = nil
+		return nil
+	case errors.Is(err, store.ErrNotApplied):
+		itemLog.Info("item skipped", "reason", "not applied")
+		msg := "not applied, skipped"
+		_ = e.updateRunItemStatus(dbCtx, item.ID, "skipped", &msg, nil, &end)
+		item.Status = "skipped"
+		item.Error = &msg
+		return nil
+	case errors.Is(err, store.ErrPrecheckFailed):
+		itemLog.Info("item skipped", "reason", err.Error())
+		msg := err.Error()
+		_ = e.updateRunItemStatus(dbCtx, item.ID, "skipped", &msg, nil, &end)
+		item.Status = "skipped"
+		item.Error = &msg
+		return nil
+	case errors.Is(err, store.ErrNotDryRunnable):
+		itemLog.Info("item skipped", "reason", err.Error())
+		msg := err.Error()
+		_ = e.updateRunItemStatus(dbCtx, item.ID, "skipped", &msg, nil, &end)
+		item.Status = "skipped"
+		item.Error = &msg
+		return nil
+	case err != nil:
+		code := errorCode(err)
+		itemLog.Error("item failed", "error", err, "retryable", code != nil)
+		msg := err.Error()
+		_ = e.updateRunItemStatus(dbCtx, item.ID, "failed", &msg, code, &end)
+		item.Status = "failed"
+		item.Error = &msg
+		item.ErrorCode = code
+		return err
 	default:
-		return store.ErrDBTargetBadEngine
+		itemLog.Info("item executed", "rows_affected", res.rowsAffected)
+		_ = e.updateRunItemStatus(dbCtx, item.ID, "executed", nil, nil, &end)
+		_, _ = e.pool.Exec(dbCtx, `UPDATE run_items SET rows_affected = $2 WHERE id = $1`, item.ID, res.rowsAffected)
+		item.Status = "executed"
+		item.RowsAffected = &res.rowsAffected
+		return nil
 	}
 }
 
-func (e *Executor) execPostgres(ctx context.Context, run store.Run, item store.RunItem, mig store.Migration, target *store.DBTarget, password string) error {
-	dsn := fmt.Sprintf("postgres://%s:%s@%s:%d/%s?sslmode=disable", url.QueryEscape(target.Username), url.QueryEscape(password), target.Host, target.Port, url.PathEscape(target.DBName))
-	conn, err := pgx.Connect(ctx, dsn)
-	if err != nil {
-		return err
+// cancelQueuedItems marks items that were never started as canceled.
+func (e *Executor) cancelQueuedItems(ctx context.Context, run *store.RunWithItems, reason string) {
+	end := time.Now().UTC()
+	for i := range run.Items {
+		item := &run.Items[i]
+		if item.Status != "queued" {
+			continue
+		}
+		msg := reason
+		_, _ = e.pool.Exec(ctx, `
+UPDATE run_items SET status = 'canceled', error = $2, finished_at = $3
+WHERE id = $1 AND status = 'queued'
+`, item.ID, msg, end)
+		item.Status = "canceled"
+		item.Error = &msg
+		item.FinishedAt = &end
 	}
-	defer conn.Close(ctx)
+}
 
-	lockID := advisoryKey(target.ID)
-	if _, err := conn.Exec(ctx, `SELECT pg_advisory_lock($1)`, lockID); err != nil {
-		return fmt.Errorf("lock: %w", err)
+// finishCanceledRun marks a run canceled after a cancel request interrupted it.
+func (e *Executor) finishCanceledRun(ctx context.Context, run *store.RunWithItems, cause error) (*store.RunWithItems, error) {
+	finish := time.Now().UTC()
+	if err := e.pool.QueryRow(ctx, `
+UPDATE runs SET status = 'canceled', finished_at = $1 WHERE id = $2
+RETURNING cancel_requested_at, cancel_requested_by, cancel_reason
+`, finish, run.ID).Scan(&run.CancelRequestedAt, &run.CancelRequestedBy, &run.CancelReason); err != nil {
+		e.logger.Error("mark run canceled failed", "run_id", run.ID, "error", err)
 	}
-	defer conn.Exec(ctx, `SELECT pg_advisory_unlock($1)`, lockID) // nolint:errcheck
+	run.Status = "canceled"
+	run.FinishedAt = &finish
+	return run, cause
+}
 
-	if err := ensureTargetMigrationsTablePg(ctx, conn); err != nil {
+func (e *Executor) failRun(ctx context.Context, run *store.RunWithItems, cause error) (*store.RunWithItems, error) {
+	// Items that never started are canceled, so a retry picks them up.
+	e.cancelQueuedItems(ctx, run, "canceled: "+cause.Error())
+	finish := time.Now().UTC()
+	_, _ = e.pool.Exec(ctx, `
+UPDATE runs SET status = 'failed', finished_at = $1 WHERE id = $2
+`, finish, run.ID)
+	run.Status = "failed"
+	run.FinishedAt = &finish
+	return run, cause
+}
+
+func (e *Executor) executeItem(ctx context.Context, run store.Run, item store.RunItem, mig store.Migration, res *itemResult, log *slog.Logger) error {
+	target, conn, err := e.loadTarget(ctx, item.DBTargetID)
+	if err != nil {
 		return err
 	}
+	if !target.IsActive {
+		return errors.New("target disabled")
+	}
+	conn.Tenant = item.Tenant
+	return e.executeTarget(ctx, run, mig, target, conn, res, log)
+}
 
-	var existingChecksum string
-	err = conn.QueryRow(ctx, `SELECT checksum_up FROM migrate_hub_migrations WHERE migration_key = $1`, mig.Key).Scan(&existingChecksum)
-	if err == nil {
-		if existingChecksum == mig.ChecksumUp {
-			return store.ErrAlreadyApplied
-		}
-		return errors.New("migration already applied with different checksum")
+// executeTarget runs the migration of an item on its loaded target: it splits
+// the script, resolves the transaction mode and executes it under the target
+// lock, checking and writing the ledger.
+func (e *Executor) executeTarget(ctx context.Context, run store.Run, mig store.Migration, target *store.DBTarget, conn engine.Target, res *itemResult, log *slog.Logger) error {
+	eng, err := engine.Lookup(target.Engine)
+	if err != nil {
+		return err
 	}
-	if !errors.Is(err, pgx.ErrNoRows) {
+	timeouts, err := store.ResolveTimeouts(target, &mig)
+	if err != nil {
 		return err
 	}
-
-	appliedBy := ""
-	if run.ExecutedBy != nil {
-		appliedBy = run.ExecutedBy.String()
+	if run.RunType == "baseline" {
+		return e.baselineItem(ctx, run, mig, target, conn, eng, timeouts, log)
 	}
 
-	applyFn := func(exec interface {
-		Exec(context.Context, string, ...any) (pgconn.CommandTag, error)
-	}) error {
-		if _, err := exec.Exec(ctx, mig.SQLUp); err != nil {
-			return err
-		}
-		_, err := exec.Exec(ctx, `
-INSERT INTO migrate_hub_migrations (migration_key, checksum_up, checksum_down, applied_at, applied_by, tool_run_id)
-VALUES ($1, $2, $3, now(), $4, $5)
-`, mig.Key, mig.ChecksumUp, mig.ChecksumDown, appliedBy, run.ID.String())
+	script := mig.SQLUp
+	if run.RollsBack() {
+		script = *mig.SQLDown
+	}
+	dialect := eng.Dialect()
+	stmts, err := sqlscript.Split(dialect, script)
+	if err != nil {
 		return err
 	}
-
-	switch mig.TransactionMode {
-	case "no_transaction":
-		return applyFn(conn)
-	case "single_transaction", "auto":
-		tx, err := conn.Begin(ctx)
+	if len(stmts) == 0 {
+		return errors.New("script contains no statements")
+	}
+	log.Info("script split", "statements", len(stmts))
+	ledger, err := store.TargetLedger(target.Options)
+	if err != nil {
+		return err
+	}
+	record, err := e.ledgerRecord(ctx, run, mig)
+	if err != nil {
+		return err
+	}
+	plan := execPlan{stmts: stmts, dialect: dialect, timeouts: timeouts, ledger: ledger, record: record, result: res}
+	if !run.RollsBack() {
+		plan.precheck, plan.postcheck = mig.Checks()
+	}
+	if run.RunType == "dry_run" {
+		// A dry run is only evidence if the transaction really undoes every statement.
+		if stmt, reason, ok := sqlscript.FirstTransactionBlocker(dialect, stmts); ok {
+			return fmt.Errorf("%w: statement %d of %d (line %d): %s", store.ErrNotDryRunnable, stmt.Index, len(stmts), stmt.Line, reason)
+		}
+		log.Info("dry run: executing in a transaction that is always rolled back", "dry_run_of", *run.DryRunOf)
+		plan.txMode = "single_transaction"
+		plan.dryRun = true
+	} else {
+		plan.txMode, err = resolveTxMode(dialect, mig.TransactionMode, stmts, log)
 		if err != nil {
 			return err
 		}
-		if err := applyFn(tx); err != nil {
-			tx.Rollback(ctx) // nolint:errcheck
-			return err
+	}
+	logTimeouts(log, timeouts)
+
+	return e.execItem(ctx, run, mig, target, conn, eng, plan, log)
+}
+
+// execPlan is what an item executes: the split script, the transaction mode
+// resolved for the target engine and the session timeouts. Dry runs leave the
+// ledger alone and roll the transaction back. Checks only run around sql_up.
+type execPlan struct {
+	stmts     []sqlscript.Statement
+	dialect   string
+	txMode    string
+	timeouts  store.Timeouts
+	dryRun    bool
+	precheck  *store.Check
+	postcheck *store.Check
+	result    *itemResult
+
+	ledger engine.Ledger
+	record engine.LedgerRecord
+}
+
+// ledgerRecord prepares the ledger row an apply writes; the duration is set
+// once the statements ran.
+func (e *Executor) ledgerRecord(ctx context.Context, run store.Run, mig store.Migration) (engine.LedgerRecord, error) {
+	r := engine.LedgerRecord{
+		Key:          mig.Key,
+		ChecksumUp:   mig.ChecksumUp,
+		ChecksumDown: mig.ChecksumDown,
+		RunID:        run.ID.String(),
+		RunType:      run.RunType,
+		Version:      mig.Version,
+		InstanceID:   e.opts.InstanceID,
+	}
+	if run.ExecutedBy != nil {
+		r.AppliedBy = run.ExecutedBy.String()
+		if err := e.pool.QueryRow(ctx, `SELECT email FROM users WHERE id = $1`, *run.ExecutedBy).Scan(&r.Email); err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			return r, err
 		}
-		return tx.Commit(ctx)
-	default:
-		return store.ErrTxModeInvalid
 	}
+	return r, nil
+}
+
+// itemResult collects what the statements of an item did.
+type itemResult struct {
+	rowsAffected int64
 }
 
-func (e *Executor) execMySQL(ctx context.Context, run store.Run, item store.RunItem, mig store.Migration, target *store.DBTarget, password string) error {
-	cfg := mysql.Config{
-		User:                 target.Username,
-		Passwd:               password,
-		Net:                  "tcp",
-		Addr:                 net.JoinHostPort(target.Host, fmt.Sprintf("%d", target.Port)),
-		DBName:               target.DBName,
-		AllowNativePasswords: true,
-		Params:               map[string]string{},
+// resolveTxMode turns the migration transaction mode into single_transaction or
+// no_transaction for the target dialect. auto uses one transaction unless a
+// statement cannot run in one (Postgres) or commits implicitly (MySQL).
+func resolveTxMode(dialect string, mode string, stmts []sqlscript.Statement, log *slog.Logger) (string, error) {
+	switch mode {
+	case "auto":
+		if stmt, reason, ok := sqlscript.FirstTransactionBlocker(dialect, stmts); ok {
+			log.Info("transaction mode resolved", "requested", mode, "mode", "no_transaction", "statement", stmt.Index, "line", stmt.Line, "reason", reason)
+			return "no_transaction", nil
+		}
+		log.Info("transaction mode resolved", "requested", mode, "mode", "single_transaction")
+		return "single_transaction", nil
+	case "single_transaction", "no_transaction":
+		if err := store.CheckTxMode(dialect, mode, stmts); err != nil {
+			return "", err
+		}
+		log.Info("transaction mode resolved", "requested", mode, "mode", mode)
+		return mode, nil
+	default:
+		return "", store.ErrTxModeInvalid
 	}
-	db, err := sql.Open("mysql", cfg.FormatDSN())
+}
+
+// openSession connects to the target with its engine. Target statements run
+// on the returned context, which is never canceled; canceling ctx interrupts
+// the statement in flight instead (Postgres cancel request, MySQL KILL QUERY),
+// so the session stays usable for rolling back the open transaction and
+// releasing the lock. close ends the session.
+func openSession(ctx context.Context, target *store.DBTarget, conn engine.Target, eng engine.Engine, log *slog.Logger) (engine.Session, context.Context, func(), error) {
+	log.Info("connecting", "engine", eng.Name(), "target", target.Address(), "tls", target.TLS.String())
+	sess, err := eng.Open(ctx, conn, log)
 	if err != nil {
-		return err
+		return nil, nil, nil, err
 	}
-	defer db.Close()
-	db.SetConnMaxLifetime(time.Minute)
+	connCtx := context.WithoutCancel(ctx)
+	stopCancel := context.AfterFunc(ctx, func() {
+		log.Info("cancel requested, interrupting the target statement", "reason", context.Cause(ctx).Error())
+		if err := sess.Cancel(connCtx); err != nil {
+			log.Error("cancel request failed", "error", err)
+		}
+	})
+	log.Info("connected")
+	return sess, connCtx, func() {
+		stopCancel()
+		sess.Close(connCtx) // nolint:errcheck
+	}, nil
+}
 
-	lockName := "migrate-hub:" + target.ID.String()
-	var got int
-	if err := db.QueryRowContext(ctx, `SELECT GET_LOCK(?, 10)`, lockName).Scan(&got); err != nil {
-		return fmt.Errorf("get lock: %w", err)
-	}
-	if got != 1 {
-		return errors.New("could not acquire lock")
+// lockTarget takes the per-target migration lock and sets the session
+// timeouts. unlock releases the lock.
+func lockTarget(ctx context.Context, sess engine.Session, timeouts store.Timeouts, log *slog.Logger) (func(), error) {
+	log.Info("acquiring lock", "timeout", timeouts.AdvisoryLock.String())
+	lockStart := time.Now()
+	if err := sess.Lock(ctx, timeouts.AdvisoryLock); err != nil {
+		return nil, lockError(err, timeouts)
+	}
+	log.Info("lock acquired", "wait", time.Since(lockStart))
+	unlock := func() {
+		sess.Unlock(ctx) // nolint:errcheck
+		log.Info("lock released")
+	}
+	if err := sess.SetTimeouts(ctx, timeouts.Lock, timeouts.Statement); err != nil {
+		unlock()
+		return nil, err
 	}
-	defer db.ExecContext(ctx, `SELECT RELEASE_LOCK(?)`, lockName) // nolint:errcheck
+	return unlock, nil
+}
 
-	if err := ensureTargetMigrationsTableMySQL(ctx, db); err != nil {
+func (e *Executor) execItem(ctx context.Context, run store.Run, mig store.Migration, target *store.DBTarget, conn engine.Target, eng engine.Engine, plan execPlan, log *slog.Logger) error {
+	stmts := plan.stmts
+	sess, connCtx, closeSession, err := openSession(ctx, target, conn, eng, log)
+	if err != nil {
 		return err
 	}
+	defer closeSession()
+	unlock, err := lockTarget(connCtx, sess, plan.timeouts, log)
+	if err != nil {
+		return err
+	}
+	defer unlock()
 
-	var existingChecksum string
-	err = db.QueryRowContext(ctx, `SELECT checksum_up FROM migrate_hub_migrations WHERE migration_key = ?`, mig.Key).Scan(&existingChecksum)
-	if err == nil {
-		if existingChecksum == mig.ChecksumUp {
-			return store.ErrAlreadyApplied
-		}
-		return errors.New("migration already applied with different checksum")
+	var ledger engine.LedgerState
+	if plan.dryRun {
+		// A dry run must not create or upgrade the ledger; a missing one records nothing.
+		ledger, err = sess.LedgerState(connCtx, plan.ledger)
+	} else {
+		ledger, err = sess.EnsureLedger(connCtx, plan.ledger, log)
+	}
+	if err != nil {
+		return err
 	}
-	if !errors.Is(err, sql.ErrNoRows) {
+	entry, err := sess.LookupLedger(connCtx, plan.ledger, ledger, mig.Key)
+	if err != nil {
 		return err
 	}
-
-	appliedBy := ""
-	if run.ExecutedBy != nil {
-		appliedBy = run.ExecutedBy.String()
+	log.Info("ledger checked", "ledger", plan.ledger.String(), "ledger_version", ledger.Version, "migration_key", mig.Key, "recorded", entry.Found, "checksum_up", entry.ChecksumUp)
+	if err := checkLedger(run, entry.Found, entry.ChecksumUp); err != nil {
+		return err
+	}
+	if plan.precheck != nil {
+		if err := runCheck(connCtx, sess, nil, plan.dialect, plan.precheck, log); err != nil {
+			return err
+		}
 	}
 
-	applyFn := func(exec interface {
-		ExecContext(context.Context, string, ...any) (sql.Result, error)
-	}) error {
-		if _, err := exec.ExecContext(ctx, mig.SQLUp); err != nil {
+	applyFn := func(tx engine.Tx) error {
+		applyStart := time.Now()
+		for _, stmt := range stmts {
+			if ctx.Err() != nil {
+				return context.Cause(ctx)
+			}
+			start := time.Now()
+			res, err := sess.Exec(connCtx, tx, stmt.SQL)
+			if err != nil {
+				log.Error("statement failed", "index", stmt.Index, "line", stmt.Line, "duration", time.Since(start), "error", err)
+				return statementError(stmt, len(stmts), classifyError(err, plan.timeouts))
+			}
+			args := []any{"index", stmt.Index, "line", stmt.Line, "duration", time.Since(start), "rows_affected", res.RowsAffected}
+			if res.Command != "" {
+				args = append(args, "command", res.Command)
+			}
+			log.Info("statement executed", args...)
+			plan.result.rowsAffected += res.RowsAffected
+		}
+		if plan.postcheck != nil {
+			if err := runCheck(connCtx, sess, tx, plan.dialect, plan.postcheck, log); err != nil {
+				return postcheckFailure(plan.txMode, err)
+			}
+		}
+
+		if plan.dryRun {
+			log.Info("ledger left unchanged (dry run)", "migration_key", mig.Key)
+			return nil
+		}
+		if run.RunType == "rollback" {
+			if err := sess.RecordRollback(connCtx, tx, plan.ledger, mig.Key, run.ID.String()); err != nil {
+				return err
+			}
+			log.Info("ledger row marked rolled back", "migration_key", mig.Key)
+			return nil
+		}
+		record := plan.record
+		record.Duration = time.Since(applyStart)
+		if err := sess.RecordApply(connCtx, tx, plan.ledger, record); err != nil {
 			return err
 		}
-		_, err := exec.ExecContext(ctx, `
-INSERT INTO migrate_hub_migrations (migration_key, checksum_up, checksum_down, applied_at, applied_by, tool_run_id)
-VALUES (?, ?, ?, NOW(), ?, ?)
-`, mig.Key, mig.ChecksumUp, mig.ChecksumDown, appliedBy, run.ID.String())
-		return err
+		log.Info("ledger row recorded", "migration_key", mig.Key)
+		return nil
 	}
 
-	switch mig.TransactionMode {
+	switch plan.txMode {
 	case "no_transaction":
-		return applyFn(db)
-	case "single_transaction", "auto":
-		tx, err := db.BeginTx(ctx, nil)
+		log.Info("executing without transaction")
+		return applyFn(nil)
+	case "single_transaction":
+		tx, err := sess.Begin(connCtx)
 		if err != nil {
 			return err
 		}
+		log.Info("transaction started")
 		if err := applyFn(tx); err != nil {
-			tx.Rollback() // nolint:errcheck
+			tx.Rollback(connCtx) // nolint:errcheck
+			log.Info("transaction rolled back")
+			return err
+		}
+		if plan.dryRun {
+			if err := tx.Rollback(connCtx); err != nil {
+				return err
+			}
+			log.Info("transaction rolled back (dry run)", "rows_affected", plan.result.rowsAffected)
+			return nil
+		}
+		if err := tx.Commit(connCtx); err != nil {
 			return err
 		}
-		return tx.Commit()
+		log.Info("transaction committed")
+		return nil
 	default:
 		return store.ErrTxModeInvalid
 	}
 }
 
-func ensureTargetMigrationsTablePg(ctx context.Context, conn *pgx.Conn) error {
-	_, err := conn.Exec(ctx, `
-CREATE TABLE IF NOT EXISTS migrate_hub_migrations (
-  migration_key TEXT PRIMARY KEY,
-  checksum_up TEXT NOT NULL,
-  checksum_down TEXT,
-  applied_at TIMESTAMPTZ NOT NULL DEFAULT now(),
-  applied_by TEXT,
-  tool_run_id TEXT
-)
-`)
-	return err
+// loadTarget returns the target and what its engine connects with.
+func (e *Executor) loadTarget(ctx context.Context, targetID uuid.UUID) (*store.DBTarget, engine.Target, error) {
+	target, enc, err := store.GetDBTarget(ctx, e.pool, targetID)
+	if err != nil {
+		return nil, engine.Target{}, err
+	}
+	conn, err := target.EngineTarget(e.secretKey, enc)
+	if err != nil {
+		return nil, engine.Target{}, err
+	}
+	return target, conn, nil
 }
 
-func ensureTargetMigrationsTableMySQL(ctx context.Context, db *sql.DB) error {
-	_, err := db.ExecContext(ctx, `
-CREATE TABLE IF NOT EXISTS migrate_hub_migrations (
-  migration_key VARCHAR(255) PRIMARY KEY,
-  checksum_up TEXT NOT NULL,
-  checksum_down TEXT,
-  applied_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
-  applied_by VARCHAR(255),
-  tool_run_id VARCHAR(255)
-)`)
-	return err
+// statementError names the failing statement so the run item error points at it.
+func statementError(stmt sqlscript.Statement, total int, err error) error {
+	return fmt.Errorf("statement %d of %d (line %d): %w", stmt.Index, total, stmt.Line, err)
+}
+
+// checkLedger decides whether the target ledger state allows the run to proceed.
+// Apply runs skip keys already recorded with the same checksum; rollback runs
+// require the key to be recorded with the checksum that was approved. Dry runs
+// follow the rules of the run type they try.
+func checkLedger(run store.Run, applied bool, existingChecksum string) error {
+	if run.RollsBack() {
+		if !applied {
+			return store.ErrNotApplied
+		}
+		if existingChecksum != run.ChecksumUpAtRequest {
+			return errors.New("migration applied with different checksum; refusing rollback")
+		}
+		return nil
+	}
+	if !applied {
+		return nil
+	}
+	if existingChecksum == run.ChecksumUpAtRequest {
+		return store.ErrAlreadyApplied
+	}
+	return errors.New("migration already applied with different checksum")
 }
 
-func (e *Executor) updateRunItemStatus(ctx context.Context, itemID uuid.UUID, status string, errMsg *string, finishedAt *time.Time) error {
+func (e *Executor) updateRunItemStatus(ctx context.Context, itemID uuid.UUID, status string, errMsg *string, errCode *string, finishedAt *time.Time) error {
 	_, err := e.pool.Exec(ctx, `
-UPDATE run_items SET status = COALESCE($2, status), error = COALESCE($3, error), finished_at = COALESCE($4, finished_at), started_at = COALESCE(started_at, now())
+UPDATE run_items SET status = COALESCE($2, status), error = COALESCE($3, error), error_code = COALESCE($4, error_code), finished_at = COALESCE($5, finished_at), started_at = COALESCE(started_at, now())
 WHERE id = $1
-`, itemID, status, errMsg, finishedAt)
+`, itemID, status, errMsg, errCode, finishedAt)
 	return err
 }
 
-func advisoryKey(id uuid.UUID) int64 {
-	var out int64
-	bytes := id[:]
-	for i := 0; i < 8; i++ {
-		out = (out << 8) | int64(bytes[i])
-	}
-	return out
-}
-
 func equalNullable(a *string, b *string) bool {
 	if a == nil && b == nil {
 		return true
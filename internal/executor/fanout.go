@@ -0,0 +1,138 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"db_inner_migrator_syncer/internal/audit"
+	"db_inner_migrator_syncer/internal/engine"
+	"db_inner_migrator_syncer/internal/store"
+)
+
+// tenantDiscoveryTimeout bounds listing the tenants of all fan-out targets of
+// a db set. Discovery runs inside run requests and approvals, so the answer
+// is written before the server write timeout (30s).
+const tenantDiscoveryTimeout = 20 * time.Second
+
+// RequestRun creates a run. The fan-out targets of the db set are expanded
+// to the tenants found on their servers now; runs that need approval are
+// expanded again when approved.
+func (e *Executor) RequestRun(ctx context.Context, input store.RequestRunInput) (*store.RunWithItems, error) {
+	set, err := store.GetDBSet(ctx, e.pool, input.DBSetID)
+	if err != nil {
+		return nil, err
+	}
+	if set.ProjectID != input.ProjectID {
+		return nil, store.ErrDBSetNotFound
+	}
+	if input.Tenants, err = e.fanoutTenants(ctx, input.DBSetID); err != nil {
+		return nil, err
+	}
+	return store.RequestRun(ctx, e.pool, input)
+}
+
+// ApproveRun approves a run. Its fan-out targets are expanded again first, so
+// the tenant list is frozen at approval time: execution, retries and recovery
+// keep it, and tenants created later are left to the next run. When the list
+// changed since the run was reviewed, the items are replaced, the change is
+// audited as run_tenants_changed and the approval is refused, so the new list
+// is reviewed before it is approved.
+func (e *Executor) ApproveRun(ctx context.Context, input store.ApprovalDecisionInput) (*store.Run, error) {
+	run, err := store.GetRunWithItems(ctx, e.pool, input.ProjectID, input.RunID)
+	if err != nil {
+		return nil, err
+	}
+	if run.Status != "awaiting_approval" {
+		return nil, store.ErrRunInvalidStatus
+	}
+	if input.Tenants, err = e.fanoutTenants(ctx, run.DBSetID); err != nil {
+		return nil, err
+	}
+	approved, err := store.ApproveRun(ctx, e.pool, input)
+	var changed *store.TenantsChangedError
+	if errors.As(err, &changed) {
+		e.logger.Info("run tenants changed before approval", "run_id", input.RunID, "before", len(changed.Before), "after", len(changed.After))
+		_ = audit.LogEvent(ctx, e.pool, e.logger, audit.Event{
+			ActorID:    &input.ActorID,
+			Action:     "run_tenants_changed",
+			EntityType: "run",
+			EntityID:   &input.RunID,
+			Payload: map[string]any{
+				"db_set_id":      run.DBSetID,
+				"tenants_before": changed.Before,
+				"tenants_after":  changed.After,
+			},
+		})
+	}
+	return approved, err
+}
+
+// fanoutTenants discovers the tenants of the active fan-out targets of a
+// db set, keyed by target id. The targets are listed in parallel, all within
+// tenantDiscoveryTimeout.
+func (e *Executor) fanoutTenants(ctx context.Context, dbSetID uuid.UUID) (map[uuid.UUID][]string, error) {
+	targets, err := store.ListDBTargetsBySet(ctx, e.pool, dbSetID)
+	if err != nil {
+		return nil, err
+	}
+	var fanout []store.DBTarget
+	for _, t := range targets {
+		if t.IsActive && t.Fanout() {
+			fanout = append(fanout, t)
+		}
+	}
+	ctx, cancel := context.WithTimeoutCause(ctx, tenantDiscoveryTimeout, errTenantDiscoveryDeadline)
+	defer cancel()
+	found := make([][]string, len(fanout))
+	errs := make([]error, len(fanout))
+	var wg sync.WaitGroup
+	for i := range fanout {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			found[i], errs[i] = e.discoverTenants(ctx, fanout[i].ID)
+			if errs[i] != nil && ctx.Err() != nil {
+				errs[i] = context.Cause(ctx)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	out := make(map[uuid.UUID][]string, len(fanout))
+	for i, t := range fanout {
+		if errs[i] != nil {
+			return nil, fmt.Errorf("%w: %s: %v", store.ErrTenantDiscovery, t.Address(), errs[i])
+		}
+		e.logger.Info("tenants discovered", "db_target_id", t.ID, "pattern", t.FanoutPattern, "tenants", len(found[i]))
+		out[t.ID] = found[i]
+	}
+	return out, nil
+}
+
+// errTenantDiscoveryDeadline is the cause of a discovery cut short by
+// tenantDiscoveryTimeout.
+var errTenantDiscoveryDeadline = errors.New("tenant discovery time limit reached")
+
+// discoverTenants lists the schemas or databases matching the pattern of a
+// fan-out target.
+func (e *Executor) discoverTenants(ctx context.Context, targetID uuid.UUID) ([]string, error) {
+	target, conn, err := e.loadTarget(ctx, targetID)
+	if err != nil {
+		return nil, err
+	}
+	eng, err := engine.Lookup(target.Engine)
+	if err != nil {
+		return nil, err
+	}
+	sess, err := eng.Open(ctx, conn, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer sess.Close(context.WithoutCancel(ctx)) // nolint:errcheck
+	return sess.Tenants(ctx, target.FanoutPattern)
+}
@@ -0,0 +1,444 @@
+package executor
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"db_inner_migrator_syncer/internal/audit"
+	"db_inner_migrator_syncer/internal/engine"
+	"db_inner_migrator_syncer/internal/store"
+)
+
+// importTimeout bounds reading and seeding the ledgers of one import.
+const importTimeout = 2 * time.Minute
+
+// PreviewImport reads the ledger table of another migration tool on a target
+// and returns what an import would do. Nothing is written.
+func (e *Executor) PreviewImport(ctx context.Context, targetID uuid.UUID, source string, bodies map[string]store.ImportBody) (*store.ImportPlan, error) {
+	return e.runImport(ctx, targetID, source, bodies, nil)
+}
+
+// CommitImport imports the ledger of another migration tool: it creates the
+// missing migrations in the project and seeds the target ledger so they are
+// never executed there. previewHash must match the plan, built again under
+// the target lock, so the import does what the preview showed.
+func (e *Executor) CommitImport(ctx context.Context, targetID uuid.UUID, source string, bodies map[string]store.ImportBody, previewHash string, actorID uuid.UUID) (*store.ImportPlan, error) {
+	id := uuid.New()
+	plan, err := e.runImport(ctx, targetID, source, bodies, &importCommit{id: id, previewHash: previewHash, actorID: actorID})
+	if err != nil {
+		e.logger.Error("ledger import failed", "db_target_id", targetID, "source", source, "error", err)
+		_ = audit.LogEvent(ctx, e.pool, e.logger, audit.Event{
+			ActorID:    &actorID,
+			Action:     "ledger_import_failed",
+			EntityType: "db_target",
+			EntityID:   &targetID,
+			Payload:    map[string]any{"source": source, "error": err.Error()},
+		})
+		return nil, err
+	}
+	e.logger.Info("ledger imported", "db_target_id", targetID, "import_id", id, "source", source, "created", plan.Create, "seeded", plan.Create+plan.Seed)
+	_ = audit.LogEvent(ctx, e.pool, e.logger, audit.Event{
+		ActorID:    &actorID,
+		Action:     "ledger_import_committed",
+		EntityType: "db_target",
+		EntityID:   &targetID,
+		Payload: map[string]any{
+			"import_id":    id,
+			"source":       plan.Source,
+			"source_table": plan.Table,
+			"ledger":       plan.Ledger,
+			"created":      plan.Create,
+			"seeded":       plan.Create + plan.Seed,
+			"recorded":     plan.Recorded,
+			"skipped":      plan.Skip,
+		},
+	})
+	return plan, nil
+}
+
+// importCommit is set when an import writes.
+type importCommit struct {
+	id          uuid.UUID
+	previewHash string
+	actorID     uuid.UUID
+}
+
+func (e *Executor) runImport(ctx context.Context, targetID uuid.UUID, source string, bodies map[string]store.ImportBody, commit *importCommit) (*store.ImportPlan, error) {
+	source, err := store.NormalizeImportSource(source)
+	if err != nil {
+		return nil, err
+	}
+	target, conn, err := e.loadTarget(ctx, targetID)
+	if err != nil {
+		return nil, err
+	}
+	if !target.IsActive {
+		return nil, store.ErrDBTargetInactive
+	}
+	if target.Fanout() {
+		return nil, fmt.Errorf("ledger import: %w", store.ErrFanoutUnsupported)
+	}
+	set, err := store.GetDBSet(ctx, e.pool, target.DBSetID)
+	if err != nil {
+		return nil, err
+	}
+	eng, err := engine.Lookup(target.Engine)
+	if err != nil {
+		return nil, err
+	}
+	ledger, err := store.TargetLedger(target.Options)
+	if err != nil {
+		return nil, err
+	}
+	plan := &store.ImportPlan{DBTargetID: target.ID, Source: source, Table: store.ImportSourceTables[source], Ledger: ledger.String()}
+	imp := &ledgerImport{e: e, projectID: set.ProjectID, plan: plan, bodies: bodies, commit: commit}
+
+	ctx, cancel := context.WithTimeout(ctx, importTimeout)
+	defer cancel()
+	if err := imp.run(ctx, eng, target, conn, ledger); err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+// ledgerImport is one import on an open target session.
+type ledgerImport struct {
+	e         *Executor
+	projectID uuid.UUID
+	plan      *store.ImportPlan
+	bodies    map[string]store.ImportBody
+	commit    *importCommit
+	// tx holds the created migrations and the import record until the
+	// target ledger is seeded.
+	tx pgx.Tx
+}
+
+func (imp *ledgerImport) run(ctx context.Context, eng engine.Engine, target *store.DBTarget, conn engine.Target, ledger store.Ledger) error {
+	sess, err := eng.Open(ctx, conn, nil)
+	if err != nil {
+		return err
+	}
+	defer sess.Close(context.WithoutCancel(ctx)) // nolint:errcheck
+	if imp.commit != nil {
+		timeouts, err := store.ResolveTimeouts(target, &store.Migration{})
+		if err != nil {
+			return err
+		}
+		if err := sess.Lock(ctx, timeouts.AdvisoryLock); err != nil {
+			return lockError(err, timeouts)
+		}
+		defer sess.Unlock(context.WithoutCancel(ctx)) // nolint:errcheck
+	}
+
+	state, err := sess.LedgerState(ctx, ledger)
+	if err != nil {
+		return err
+	}
+	rows, err := sess.ReadLedger(ctx, ledger, state)
+	if err != nil {
+		return err
+	}
+	if imp.commit != nil {
+		imp.tx, err = imp.e.pool.Begin(ctx)
+		if err != nil {
+			return err
+		}
+		defer imp.tx.Rollback(context.WithoutCancel(ctx)) // nolint:errcheck
+	}
+	records, err := imp.prepare(ctx, importReader{sess: sess}, rows)
+	if err != nil || imp.commit == nil {
+		return err
+	}
+
+	if _, err := sess.EnsureLedger(ctx, ledger, imp.e.logger); err != nil {
+		return err
+	}
+	tx, err := sess.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(context.WithoutCancel(ctx)) // nolint:errcheck
+	for _, r := range records {
+		if err := sess.RecordApply(ctx, tx, ledger, r); err != nil {
+			return fmt.Errorf("seed ledger %s: %w", r.Key, err)
+		}
+	}
+	if err := imp.save(ctx); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+	// The project keeps the migrations only once their ledger rows exist.
+	return imp.tx.Commit(context.WithoutCancel(ctx))
+}
+
+// prepare reads the source table and builds the plan. On commit it checks the
+// plan against the preview, creates the new migrations and returns the ledger
+// rows to write.
+func (imp *ledgerImport) prepare(ctx context.Context, src importReader, ledgerRows []store.LedgerRow) ([]engine.LedgerRecord, error) {
+	plan := imp.plan
+	entries, err := src.read(ctx, plan.Source)
+	if err != nil {
+		return nil, err
+	}
+	recorded := make(map[string]bool, len(ledgerRows))
+	for _, row := range ledgerRows {
+		recorded[row.Key] = true
+	}
+	if err := store.BuildImportPlan(ctx, imp.e.pool, imp.projectID, plan, entries, recorded, imp.bodies); err != nil {
+		return nil, err
+	}
+	if imp.commit == nil {
+		return nil, nil
+	}
+	if imp.commit.previewHash != plan.Hash {
+		return nil, store.ErrImportPreviewStale
+	}
+	if plan.Create+plan.Seed == 0 {
+		return nil, store.ErrImportNothing
+	}
+
+	created, err := store.CreateImportedMigrations(ctx, imp.tx, imp.projectID, plan, imp.bodies, imp.commit.actorID)
+	if err != nil {
+		return nil, err
+	}
+	migs, err := store.ListMigrations(ctx, imp.e.pool, imp.projectID, "")
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[uuid.UUID]store.Migration, len(migs)+len(created))
+	for _, m := range append(migs, created...) {
+		byID[m.ID] = m
+	}
+	var email string
+	if err := imp.e.pool.QueryRow(ctx, `SELECT email FROM users WHERE id = $1`, imp.commit.actorID).Scan(&email); err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return nil, err
+	}
+	var records []engine.LedgerRecord
+	for _, entry := range plan.Entries {
+		if entry.Action != store.ImportCreate && entry.Action != store.ImportSeed {
+			continue
+		}
+		m, ok := byID[*entry.MigrationID]
+		if !ok {
+			return nil, fmt.Errorf("%s: %w", entry.Key, store.ErrMigrationNotFound)
+		}
+		records = append(records, engine.LedgerRecord{
+			Key:          m.Key,
+			ChecksumUp:   m.ChecksumUp,
+			ChecksumDown: m.ChecksumDown,
+			AppliedBy:    imp.commit.actorID.String(),
+			RunID:        imp.commit.id.String(),
+			RunType:      "import",
+			Version:      m.Version,
+			Email:        email,
+			InstanceID:   imp.e.opts.InstanceID,
+		})
+	}
+	return records, nil
+}
+
+// save records the import in the tool DB transaction, committed with the
+// created migrations after the target ledger.
+func (imp *ledgerImport) save(ctx context.Context) error {
+	id := imp.commit.id
+	imp.plan.ID = &id
+	return store.SaveLedgerImport(ctx, imp.tx, imp.projectID, imp.plan, imp.commit.actorID)
+}
+
+// importReader runs read-only queries on the target and returns every column
+// as text, so each source is parsed the same way on every engine.
+type importReader struct {
+	sess engine.Session
+}
+
+func (r importReader) query(ctx context.Context, query string) ([][]sql.NullString, error) {
+	return r.sess.QueryText(ctx, query)
+}
+
+// text casts a column to text.
+func (r importReader) text(col string) string {
+	return r.sess.CastText(col)
+}
+
+// table returns the quoted name of a source table on the target.
+func (r importReader) table(ctx context.Context, name string) (string, error) {
+	table, err := r.sess.FindTable(ctx, name)
+	if err != nil {
+		return "", err
+	}
+	if table == "" {
+		return "", fmt.Errorf("%s: %w", name, store.ErrImportSourceMissing)
+	}
+	return table, nil
+}
+
+// read returns the entries of a source ledger in the order they were applied.
+func (r importReader) read(ctx context.Context, source string) ([]store.ImportSourceEntry, error) {
+	table, err := r.table(ctx, store.ImportSourceTables[source])
+	if err != nil {
+		return nil, err
+	}
+	switch source {
+	case store.ImportFlyway:
+		return r.readFlyway(ctx, table)
+	case store.ImportGolangMigrate:
+		return r.readGolangMigrate(ctx, table)
+	case store.ImportGoose:
+		return r.readGoose(ctx, table)
+	case store.ImportLiquibase:
+		return r.readLiquibase(ctx, table)
+	}
+	return nil, store.ErrImportSourceInvalid
+}
+
+// readFlyway keys versioned migrations by their script name without .sql.
+// Undone versions, failed rows and repeatable migrations are skipped.
+func (r importReader) readFlyway(ctx context.Context, table string) ([]store.ImportSourceEntry, error) {
+	rows, err := r.query(ctx, `
+SELECT version, description, type, script, `+r.text("checksum")+`, `+r.text("installed_on")+`,
+  CASE WHEN success THEN 'true' ELSE 'false' END
+FROM `+table+` ORDER BY installed_rank`)
+	if err != nil {
+		return nil, err
+	}
+	var out []store.ImportSourceEntry
+	byVersion := make(map[string]int)
+	for _, row := range rows {
+		version, kind, script := row[0], strings.ToUpper(row[2].String), row[3].String
+		entry := store.ImportSourceEntry{
+			Key:       strings.TrimSuffix(script, ".sql"),
+			Name:      row[1].String,
+			Detail:    script,
+			Checksum:  row[4].String,
+			AppliedAt: row[5].String,
+		}
+		if version.Valid {
+			entry.Detail = "V" + version.String + " " + script
+		}
+		switch {
+		case strings.HasPrefix(kind, "UNDO_"):
+			if i, ok := byVersion[version.String]; ok && row[6].String == "true" {
+				out[i].SkipReason = "undone by " + script
+			}
+			continue
+		case kind == "SCHEMA" || kind == "DELETE":
+			continue
+		case kind == "BASELINE":
+			entry.SkipReason = "baseline marker; earlier versions were not run by Flyway"
+		case !version.Valid:
+			entry.SkipReason = "repeatable migration"
+		case row[6].String != "true":
+			entry.SkipReason = "failed"
+		}
+		if entry.Name == "" {
+			entry.Name = entry.Key
+		}
+		if i, ok := byVersion[version.String]; ok && version.Valid && out[i].SkipReason != "" {
+			out[i] = entry // applied again after an undo or a failure
+			continue
+		}
+		if version.Valid {
+			byVersion[version.String] = len(out)
+		}
+		out = append(out, entry)
+	}
+	return out, nil
+}
+
+// readGolangMigrate returns the one version golang-migrate records; the
+// versions before it leave no trace.
+func (r importReader) readGolangMigrate(ctx context.Context, table string) ([]store.ImportSourceEntry, error) {
+	rows, err := r.query(ctx, `SELECT `+r.text("version")+`, CASE WHEN dirty THEN 'true' ELSE 'false' END FROM `+table)
+	if err != nil {
+		return nil, err
+	}
+	var out []store.ImportSourceEntry
+	for _, row := range rows {
+		entry := store.ImportSourceEntry{
+			Key:    "migrate_" + row[0].String,
+			Name:   "golang-migrate version " + row[0].String,
+			Detail: "version " + row[0].String + " (earlier versions are not recorded)",
+		}
+		if row[1].String == "true" {
+			entry.SkipReason = "dirty: the migration failed part way"
+		}
+		out = append(out, entry)
+	}
+	return out, nil
+}
+
+// readGoose keys versions by their latest row; goose appends a row with
+// is_applied false when a version is rolled back.
+func (r importReader) readGoose(ctx context.Context, table string) ([]store.ImportSourceEntry, error) {
+	rows, err := r.query(ctx, `
+SELECT `+r.text("version_id")+`, CASE WHEN is_applied THEN 'true' ELSE 'false' END, `+r.text("tstamp")+`
+FROM `+table+` ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	var out []store.ImportSourceEntry
+	byVersion := make(map[string]int)
+	for _, row := range rows {
+		version := row[0].String
+		if version == "0" {
+			continue // the row goose writes when it creates the table
+		}
+		entry := store.ImportSourceEntry{
+			Key:       "goose_" + version,
+			Name:      "goose version " + version,
+			Detail:    "version " + version,
+			AppliedAt: row[2].String,
+		}
+		if row[1].String != "true" {
+			entry.SkipReason = "rolled back"
+		}
+		if i, ok := byVersion[version]; ok {
+			out[i] = entry
+			continue
+		}
+		byVersion[version] = len(out)
+		out = append(out, entry)
+	}
+	return out, nil
+}
+
+// readLiquibase keys changesets by file, id and author, as Liquibase
+// identifies them.
+func (r importReader) readLiquibase(ctx context.Context, table string) ([]store.ImportSourceEntry, error) {
+	rows, err := r.query(ctx, `
+SELECT id, author, filename, `+r.text("dateexecuted")+`, exectype, md5sum, description
+FROM `+table+` ORDER BY orderexecuted`)
+	if err != nil {
+		return nil, err
+	}
+	var out []store.ImportSourceEntry
+	for _, row := range rows {
+		id, author, file := row[0].String, row[1].String, row[2].String
+		entry := store.ImportSourceEntry{
+			Key:       file + "::" + id + "::" + author,
+			Name:      row[6].String,
+			Detail:    fmt.Sprintf("changeset %s by %s in %s", id, author, file),
+			Checksum:  row[5].String,
+			AppliedAt: row[3].String,
+		}
+		if entry.Name == "" {
+			entry.Name = id
+		}
+		switch kind := strings.ToUpper(row[4].String); kind {
+		case "EXECUTED", "RERAN", "MARK_RAN":
+		default:
+			entry.SkipReason = "exectype " + kind
+		}
+		out = append(out, entry)
+	}
+	return out, nil
+}
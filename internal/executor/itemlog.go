@@ -0,0 +1,47 @@
+package executor
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// itemLogWriter appends each slog record to run_items.log as soon as it is written,
+// so a partially executed item still shows how far it got.
+type itemLogWriter struct {
+	ctx    context.Context
+	pool   *pgxpool.Pool
+	itemID uuid.UUID
+	logger Logger
+}
+
+func (w *itemLogWriter) Write(p []byte) (int, error) {
+	if _, err := w.pool.Exec(w.ctx, `
+UPDATE run_items SET log = COALESCE(log, '') || $2 WHERE id = $1
+`, w.itemID, string(p)); err != nil {
+		w.logger.Error("append run item log failed", "run_item_id", w.itemID, "error", err)
+	}
+	return len(p), nil
+}
+
+// newItemLogger returns a logger whose records are stored on the run item.
+// Writes use a context detached from cancellation so the final lines of a
+// canceled item are not lost.
+func (e *Executor) newItemLogger(ctx context.Context, itemID uuid.UUID) *slog.Logger {
+	w := &itemLogWriter{
+		ctx:    context.WithoutCancel(ctx),
+		pool:   e.pool,
+		itemID: itemID,
+		logger: e.logger,
+	}
+	return slog.New(slog.NewTextHandler(w, &slog.HandlerOptions{
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey && len(groups) == 0 {
+				return slog.String(slog.TimeKey, a.Value.Time().UTC().Format("2006-01-02T15:04:05.000Z"))
+			}
+			return a
+		},
+	}))
+}
@@ -0,0 +1,215 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"db_inner_migrator_syncer/internal/engine"
+	"db_inner_migrator_syncer/internal/store"
+)
+
+// preflightTargetTimeout bounds all checks on one target.
+const preflightTargetTimeout = 30 * time.Second
+
+// preflightRequestTimeout bounds a pre-flight requested from the API or UI, so
+// the report is written before the server write timeout (30s).
+const preflightRequestTimeout = 20 * time.Second
+
+// Preflight checks every target of a run without changing anything and stores
+// the report on the run. It may be called in any run status. Targets not
+// checked within preflightRequestTimeout are reported as warnings.
+func (e *Executor) Preflight(ctx context.Context, projectID uuid.UUID, runID uuid.UUID) (*store.PreflightReport, error) {
+	run, err := store.GetRunWithItems(ctx, e.pool, projectID, runID)
+	if err != nil {
+		return nil, err
+	}
+	mig, err := store.GetMigration(ctx, e.pool, projectID, run.MigrationID)
+	if err != nil {
+		return nil, err
+	}
+	checkCtx, cancel := context.WithTimeoutCause(ctx, preflightRequestTimeout, errPreflightDeadline)
+	defer cancel()
+	report := e.preflight(checkCtx, &run.Run, mig, run.Items)
+	if err := store.SavePreflight(ctx, e.pool, report); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// errPreflightDeadline is the cause of a pre-flight cut short by preflightRequestTimeout.
+var errPreflightDeadline = errors.New("pre-flight time limit reached")
+
+// preflight checks the targets of items, up to run.MaxParallel at once. Once
+// ctx ends, the targets not checked yet are reported as warnings.
+func (e *Executor) preflight(ctx context.Context, run *store.Run, mig *store.Migration, items []store.RunItem) *store.PreflightReport {
+	report := &store.PreflightReport{
+		RunID:     run.ID,
+		CheckedAt: time.Now().UTC(),
+		Targets:   make([]store.PreflightResult, len(items)),
+	}
+	limit := run.MaxParallel
+	if limit < 1 {
+		limit = 1
+	}
+	sem := make(chan struct{}, limit)
+	var wg sync.WaitGroup
+	for i := range items {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			report.Targets[i] = uncheckedTarget(ctx, items[i], store.PreflightResult{})
+			continue
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			targetCtx, cancel := context.WithTimeout(ctx, preflightTargetTimeout)
+			defer cancel()
+			res := e.preflightTarget(targetCtx, run, mig, items[i])
+			if ctx.Err() != nil {
+				// The checks were cut short; their errors say nothing about the target.
+				res = uncheckedTarget(ctx, items[i], res)
+			}
+			report.Targets[i] = res
+		}(i)
+	}
+	wg.Wait()
+	report.Finish()
+	return report
+}
+
+// uncheckedTarget is the result of an item whose target was not checked
+// before ctx ended. It keeps the address and engine from partial, if known.
+func uncheckedTarget(ctx context.Context, item store.RunItem, partial store.PreflightResult) store.PreflightResult {
+	res := store.PreflightResult{DBTargetID: item.DBTargetID, Target: partial.Target, Engine: partial.Engine, Tenant: item.Tenant}
+	if res.Target == "" {
+		res.Target = item.DBTargetID.String()
+		if item.Tenant != "" {
+			res.Target += " (" + item.Tenant + ")"
+		}
+	}
+	res.Add(store.PreflightTarget, store.PreflightWarn, "not checked: "+context.Cause(ctx).Error()+"; execution checks it again")
+	return res
+}
+
+// preflightTarget checks the target of an item, in the item's tenant on a
+// fan-out target.
+func (e *Executor) preflightTarget(ctx context.Context, run *store.Run, mig *store.Migration, item store.RunItem) store.PreflightResult {
+	res := store.PreflightResult{DBTargetID: item.DBTargetID, Target: item.DBTargetID.String(), Tenant: item.Tenant}
+	target, enc, err := store.GetDBTarget(ctx, e.pool, item.DBTargetID)
+	if err != nil {
+		res.Add(store.PreflightTarget, store.PreflightFail, err.Error())
+		return res
+	}
+	res.Target = target.Address()
+	if item.Tenant != "" {
+		res.Target += " (" + item.Tenant + ")"
+	}
+	res.Engine = target.Engine
+	if !target.IsActive {
+		res.Add(store.PreflightTarget, store.PreflightFail, "target disabled")
+		return res
+	}
+	eng, err := engine.Lookup(target.Engine)
+	if err != nil {
+		res.Add(store.PreflightTarget, store.PreflightFail, err.Error())
+		return res
+	}
+	ledger, err := store.TargetLedger(target.Options)
+	if err != nil {
+		res.Add(store.PreflightTarget, store.PreflightFail, err.Error())
+		return res
+	}
+	res.Add(store.PreflightTarget, store.PreflightPass, "")
+	conn, err := target.EngineTarget(e.secretKey, enc)
+	if err != nil {
+		res.Add(store.PreflightCredentials, store.PreflightFail, err.Error())
+		return res
+	}
+	conn.Tenant = item.Tenant
+
+	preflightSession(ctx, run, mig, eng, conn, ledger, &res)
+	return res
+}
+
+func preflightSession(ctx context.Context, run *store.Run, mig *store.Migration, eng engine.Engine, target engine.Target, ledger store.Ledger, res *store.PreflightResult) {
+	sess, err := eng.Open(ctx, target, nil)
+	if err != nil {
+		res.Add(store.PreflightCredentials, store.PreflightFail, err.Error())
+		return
+	}
+	defer sess.Close(context.WithoutCancel(ctx)) // nolint:errcheck
+	res.Add(store.PreflightCredentials, store.PreflightPass, "")
+
+	version, err := sess.Version(ctx)
+	if err != nil {
+		res.Add(store.PreflightVersion, store.PreflightFail, err.Error())
+		return
+	}
+	res.Version = version.Name
+	if version.Warning != "" {
+		res.Add(store.PreflightVersion, store.PreflightWarn, version.Warning)
+	} else {
+		res.Add(store.PreflightVersion, store.PreflightPass, res.Version)
+	}
+
+	state, err := sess.LedgerState(ctx, ledger)
+	if err != nil {
+		res.Add(store.PreflightPrivileges, store.PreflightFail, err.Error())
+		return
+	}
+	access, err := sess.LedgerAccess(ctx, ledger, state)
+	switch {
+	case err != nil:
+		res.Add(store.PreflightPrivileges, store.PreflightFail, err.Error())
+		return
+	case access.Problem != "" && access.Unsure:
+		// Privileges the engine cannot resolve, such as role grants, are not a hard failure.
+		res.Add(store.PreflightPrivileges, store.PreflightWarn, access.Problem)
+	case access.Problem != "":
+		res.Add(store.PreflightPrivileges, store.PreflightFail, access.Problem)
+	default:
+		res.Add(store.PreflightPrivileges, store.PreflightPass, access.Detail)
+	}
+
+	if free, err := sess.LockFree(ctx); err != nil {
+		res.Add(store.PreflightLock, store.PreflightFail, err.Error())
+	} else if !free {
+		res.Add(store.PreflightLock, store.PreflightWarn, engine.ErrLocked.Error())
+	} else {
+		res.Add(store.PreflightLock, store.PreflightPass, "")
+	}
+
+	var entry engine.LedgerEntry
+	if access.Readable {
+		entry, err = sess.LookupLedger(ctx, ledger, state, mig.Key)
+		if err != nil {
+			res.Add(store.PreflightLedger, store.PreflightFail, err.Error())
+			return
+		}
+	}
+	addLedgerCheck(res, *run, entry.Found, entry.ChecksumUp)
+}
+
+// addLedgerCheck reports what the item will do given the ledger state: items
+// that will be skipped are warnings, checksum conflicts are failures.
+func addLedgerCheck(res *store.PreflightResult, run store.Run, applied bool, checksum string) {
+	err := checkLedger(run, applied, checksum)
+	switch {
+	case err == nil && applied:
+		res.Add(store.PreflightLedger, store.PreflightPass, "recorded with the requested checksum")
+	case err == nil:
+		res.Add(store.PreflightLedger, store.PreflightPass, "not recorded")
+	case errors.Is(err, store.ErrAlreadyApplied):
+		res.Add(store.PreflightLedger, store.PreflightWarn, "already applied; the item will be skipped")
+	case errors.Is(err, store.ErrNotApplied):
+		res.Add(store.PreflightLedger, store.PreflightWarn, "not applied; the item will be skipped")
+	default:
+		res.Add(store.PreflightLedger, store.PreflightFail, err.Error())
+	}
+}
@@ -0,0 +1,193 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"db_inner_migrator_syncer/internal/audit"
+	"db_inner_migrator_syncer/internal/engine"
+	"db_inner_migrator_syncer/internal/store"
+)
+
+// interruptedMessage is the item error when the target ledger cannot prove the outcome.
+const interruptedMessage = "interrupted, manual check required"
+
+// RecoverStaleRuns reconciles runs left in running by an executor that stopped
+// sending heartbeats, e.g. after a crash. Each interrupted item is resolved from
+// the target ledger and the run is finalized. It returns how many runs were recovered.
+func (e *Executor) RecoverStaleRuns(ctx context.Context, staleAfter time.Duration) (int, error) {
+	runs, err := store.ListStaleRunningRuns(ctx, e.pool, staleAfter)
+	if err != nil {
+		return 0, err
+	}
+	recovered := 0
+	for _, run := range runs {
+		claimed, err := store.ClaimStaleRun(ctx, e.pool, run.ID, staleAfter)
+		if err != nil {
+			return recovered, err
+		}
+		if !claimed {
+			continue
+		}
+		if err := e.recoverRun(ctx, run.ProjectID, run.ID); err != nil {
+			e.logger.Error("recover run failed", "run_id", run.ID, "error", err)
+			continue
+		}
+		recovered++
+	}
+	return recovered, nil
+}
+
+func (e *Executor) recoverRun(ctx context.Context, projectID uuid.UUID, runID uuid.UUID) error {
+	run, err := store.GetRunWithItems(ctx, e.pool, projectID, runID)
+	if err != nil {
+		return err
+	}
+	e.logger.Info("recovering stale run", "run_id", run.ID)
+
+	mig, err := store.GetMigration(ctx, e.pool, projectID, run.MigrationID)
+	if err != nil && !errors.Is(err, store.ErrMigrationNotFound) {
+		return err
+	}
+
+	for i := range run.Items {
+		item := &run.Items[i]
+		from := item.Status
+		var status, msg string
+		switch from {
+		case "queued":
+			status, msg = "canceled", "canceled: run interrupted before the item started"
+		case "running":
+			status, msg = e.resolveInterruptedItem(ctx, run.Run, *item, mig)
+		default:
+			continue
+		}
+
+		end := time.Now().UTC()
+		ct, err := e.pool.Exec(ctx, `
+UPDATE run_items SET status = $2, error = $3, finished_at = $4
+WHERE id = $1 AND status = $5
+`, item.ID, status, msg, end, from)
+		if err != nil {
+			return err
+		}
+		if ct.RowsAffected() == 0 {
+			continue
+		}
+		item.Status = status
+		item.Error = &msg
+		item.FinishedAt = &end
+
+		e.newItemLogger(ctx, item.ID).Info("item recovered", "from", from, "to", status, "reason", msg)
+		_ = audit.LogEvent(ctx, e.pool, e.logger, audit.Event{
+			Action:     "run_item_recovered",
+			EntityType: "run_item",
+			EntityID:   &item.ID,
+			Payload: map[string]any{
+				"run_id":       run.ID,
+				"db_target_id": item.DBTargetID,
+				"from":         from,
+				"to":           status,
+				"reason":       msg,
+			},
+		})
+	}
+
+	final := "executed"
+	counts := map[string]int{}
+	for _, item := range run.Items {
+		counts[item.Status]++
+		if item.Status == "failed" || item.Status == "canceled" {
+			final = "failed"
+		}
+	}
+	// A cancel request only counts when it kept an item from running.
+	if run.CancelRequestedAt != nil && counts["canceled"] > 0 {
+		final = "canceled"
+	}
+	ct, err := e.pool.Exec(ctx, `
+UPDATE runs SET status = $2, finished_at = now() WHERE id = $1 AND status = 'running'
+`, run.ID, final)
+	if err != nil {
+		return err
+	}
+	if ct.RowsAffected() == 0 {
+		return nil
+	}
+
+	e.logger.Info("stale run recovered", "run_id", run.ID, "status", final)
+	_ = audit.LogEvent(ctx, e.pool, e.logger, audit.Event{
+		Action:     "run_recovered",
+		EntityType: "run",
+		EntityID:   &run.ID,
+		Payload: map[string]any{
+			"from":  "running",
+			"to":    final,
+			"items": counts,
+		},
+	})
+	return nil
+}
+
+// resolveInterruptedItem decides the final status of an item that was running
+// when its executor died, using the migration key and tool_run_id in the ledger.
+func (e *Executor) resolveInterruptedItem(ctx context.Context, run store.Run, item store.RunItem, mig *store.Migration) (string, string) {
+	if mig == nil {
+		return "failed", interruptedMessage + " (migration not found)"
+	}
+	if run.RunType == "dry_run" {
+		// The dry run transaction died with its session; nothing reached the target.
+		return "failed", "interrupted; the dry run transaction was rolled back, nothing was applied"
+	}
+	target, conn, err := e.loadTarget(ctx, item.DBTargetID)
+	if err != nil {
+		return "failed", fmt.Sprintf("%s (ledger check failed: %v)", interruptedMessage, err)
+	}
+	conn.Tenant = item.Tenant
+	entry, err := lookupLedger(ctx, target, conn, mig.Key)
+	if err != nil {
+		return "failed", fmt.Sprintf("%s (ledger check failed: %v)", interruptedMessage, err)
+	}
+
+	if run.RunType == "rollback" {
+		if entry.RolledBack && entry.ToolRunID == run.ID.String() {
+			return "executed", "recovered: ledger row marked rolled back by this rollback"
+		}
+		return "failed", interruptedMessage
+	}
+	switch {
+	case entry.Found && entry.ToolRunID == run.ID.String():
+		return "executed", "recovered: ledger shows the migration applied by this run"
+	case entry.Found && entry.ChecksumUp == run.ChecksumUpAtRequest:
+		return "skipped", "already applied, skipped (recovered)"
+	default:
+		return "failed", interruptedMessage
+	}
+}
+
+// lookupLedger reads the ledger row of key on a target, opening its own
+// session. A missing ledger table means the key was never recorded.
+func lookupLedger(ctx context.Context, target *store.DBTarget, conn engine.Target, key string) (engine.LedgerEntry, error) {
+	ledger, err := store.TargetLedger(target.Options)
+	if err != nil {
+		return engine.LedgerEntry{}, err
+	}
+	eng, err := engine.Lookup(target.Engine)
+	if err != nil {
+		return engine.LedgerEntry{}, err
+	}
+	sess, err := eng.Open(ctx, conn, nil)
+	if err != nil {
+		return engine.LedgerEntry{}, err
+	}
+	defer sess.Close(ctx) // nolint:errcheck
+	state, err := sess.LedgerState(ctx, ledger)
+	if err != nil {
+		return engine.LedgerEntry{}, err
+	}
+	return sess.LookupLedger(ctx, ledger, state, key)
+}
@@ -0,0 +1,60 @@
+package executor
+
+import (
+	"context"
+
+	"db_inner_migrator_syncer/internal/audit"
+	"db_inner_migrator_syncer/internal/store"
+)
+
+// StartDueScheduledRuns queues approved runs whose scheduled time has come, as
+// if the scheduling user had pressed Execute. Runs that may no longer start
+// (window missed, checksum changed, approval invalidated) keep their approval,
+// lose their schedule and are reported through a run_schedule_skipped audit
+// event. It returns how many runs were queued.
+func (e *Executor) StartDueScheduledRuns(ctx context.Context) (int, error) {
+	runs, err := store.ListDueScheduledRuns(ctx, e.pool)
+	if err != nil {
+		return 0, err
+	}
+	started := 0
+	for _, due := range runs {
+		run, reason, err := store.StartScheduledRun(ctx, e.pool, due.ID)
+		if err != nil {
+			return started, err
+		}
+		if run == nil {
+			continue
+		}
+		if reason != "" {
+			e.logger.Error("scheduled run skipped", "run_id", run.ID, "reason", reason)
+			_ = audit.LogEvent(ctx, e.pool, e.logger, audit.Event{
+				ActorID:    run.ScheduledBy,
+				Action:     "run_schedule_skipped",
+				EntityType: "run",
+				EntityID:   &run.ID,
+				Payload: map[string]any{
+					"reason":        reason,
+					"scheduled_for": due.ScheduledFor,
+				},
+			})
+			continue
+		}
+		e.logger.Info("scheduled run queued", "run_id", run.ID, "scheduled_for", due.ScheduledFor)
+		_ = audit.LogEvent(ctx, e.pool, e.logger, audit.Event{
+			ActorID:    run.ScheduledBy,
+			Action:     "run_schedule_started",
+			EntityType: "run",
+			EntityID:   &run.ID,
+			Payload: map[string]any{
+				"scheduled_for":   due.ScheduledFor,
+				"scheduled_until": due.ScheduledUntil,
+			},
+		})
+		started++
+	}
+	if started > 0 {
+		e.notify()
+	}
+	return started, nil
+}
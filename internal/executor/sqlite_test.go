@@ -0,0 +1,236 @@
+package executor
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"db_inner_migrator_syncer/internal/engine"
+	"db_inner_migrator_syncer/internal/store"
+)
+
+// sqliteTarget is a database file in a test directory.
+type sqliteTarget struct {
+	path   string
+	target *store.DBTarget
+	conn   engine.Target
+}
+
+func newSQLiteTarget(t *testing.T) *sqliteTarget {
+	t.Helper()
+	dir := t.TempDir()
+	engine.SetSQLiteRoot(dir)
+	t.Cleanup(func() { engine.SetSQLiteRoot("") })
+	path := filepath.Join(dir, "app.db")
+	// An empty file is an empty SQLite database.
+	if err := os.WriteFile(path, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	options := json.RawMessage(`{}`)
+	return &sqliteTarget{
+		path:   path,
+		target: &store.DBTarget{ID: uuid.New(), Engine: "sqlite", DBName: path, IsActive: true, Options: options},
+		conn:   engine.Target{DBName: path, Options: options},
+	}
+}
+
+// execute runs mig on the target as a run of runType and returns the run id.
+func (st *sqliteTarget) execute(t *testing.T, runType string, mig store.Migration) (uuid.UUID, error) {
+	t.Helper()
+	e := &Executor{}
+	run := store.Run{ID: uuid.New(), RunType: runType, ChecksumUpAtRequest: mig.ChecksumUp}
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	var res itemResult
+	return run.ID, e.executeTarget(context.Background(), run, mig, st.target, st.conn, &res, log)
+}
+
+// db opens the file directly, beside the executor's session.
+func (st *sqliteTarget) db(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite", st.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func (st *sqliteTarget) hasTable(t *testing.T, name string) bool {
+	t.Helper()
+	var n int
+	if err := st.db(t).QueryRow(`SELECT count(*) FROM sqlite_master WHERE type = 'table' AND name = ?`, name).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	return n == 1
+}
+
+// ledgerRow is the ledger row of key: the run that wrote it last and
+// whether it is marked rolled back. found is false without a row.
+func (st *sqliteTarget) ledgerRow(t *testing.T, key string) (runID string, rolledBack bool, found bool) {
+	t.Helper()
+	if !st.hasTable(t, "migrate_hub_migrations") {
+		return "", false, false
+	}
+	err := st.db(t).QueryRow(`SELECT tool_run_id, rolled_back_at IS NOT NULL FROM migrate_hub_migrations WHERE migration_key = ?`, key).Scan(&runID, &rolledBack)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", false, false
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return runID, rolledBack, true
+}
+
+func testMigration(mode string, up string, down string) store.Migration {
+	return store.Migration{
+		ID:              uuid.New(),
+		Key:             "20260101_001_accounts",
+		SQLUp:           up,
+		SQLDown:         &down,
+		ChecksumUp:      "up-checksum",
+		Version:         1,
+		TransactionMode: mode,
+	}
+}
+
+func TestSQLiteApplyAndRollback(t *testing.T) {
+	st := newSQLiteTarget(t)
+	mig := testMigration("auto",
+		"CREATE TABLE accounts (id INTEGER PRIMARY KEY);\nINSERT INTO accounts (id) VALUES (1);",
+		"DROP TABLE accounts;")
+
+	applyID, err := st.execute(t, "apply", mig)
+	if err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	if !st.hasTable(t, "accounts") {
+		t.Fatal("apply: accounts not created")
+	}
+	if runID, rolledBack, found := st.ledgerRow(t, mig.Key); !found || rolledBack || runID != applyID.String() {
+		t.Fatalf("apply: ledger row run=%q rolled_back=%v found=%v", runID, rolledBack, found)
+	}
+
+	if _, err := st.execute(t, "apply", mig); !errors.Is(err, store.ErrAlreadyApplied) {
+		t.Fatalf("second apply: %v, want already applied", err)
+	}
+
+	rollbackID, err := st.execute(t, "rollback", mig)
+	if err != nil {
+		t.Fatalf("rollback: %v", err)
+	}
+	if st.hasTable(t, "accounts") {
+		t.Fatal("rollback: accounts not dropped")
+	}
+	if runID, rolledBack, found := st.ledgerRow(t, mig.Key); !found || !rolledBack || runID != rollbackID.String() {
+		t.Fatalf("rollback: ledger row run=%q rolled_back=%v found=%v", runID, rolledBack, found)
+	}
+	// Recovery reads the same row through the session.
+	entry, err := lookupLedger(context.Background(), st.target, st.conn, mig.Key)
+	if err != nil {
+		t.Fatalf("lookupLedger: %v", err)
+	}
+	if entry.Found || !entry.RolledBack || entry.ToolRunID != rollbackID.String() {
+		t.Fatalf("lookupLedger: %+v", entry)
+	}
+
+	if _, err := st.execute(t, "rollback", mig); !errors.Is(err, store.ErrNotApplied) {
+		t.Fatalf("second rollback: %v, want not applied", err)
+	}
+
+	reapplyID, err := st.execute(t, "apply", mig)
+	if err != nil {
+		t.Fatalf("reapply: %v", err)
+	}
+	if runID, rolledBack, found := st.ledgerRow(t, mig.Key); !found || rolledBack || runID != reapplyID.String() {
+		t.Fatalf("reapply: ledger row run=%q rolled_back=%v found=%v", runID, rolledBack, found)
+	}
+}
+
+func TestSQLiteTransactionModes(t *testing.T) {
+	const failing = "CREATE TABLE accounts (id INTEGER PRIMARY KEY);\nINSERT INTO missing (id) VALUES (1);"
+	tests := []struct {
+		name string
+		mode string
+		up   string
+		// ok is whether the script succeeds; kept whether accounts exists afterwards.
+		ok   bool
+		kept bool
+	}{
+		{name: "auto rolls back", mode: "auto", up: failing, kept: false},
+		{name: "single_transaction rolls back", mode: "single_transaction", up: failing, kept: false},
+		{name: "no_transaction keeps statements", mode: "no_transaction", up: failing, kept: true},
+		{name: "auto without transaction", mode: "auto", up: "CREATE TABLE accounts (id INTEGER PRIMARY KEY);\nVACUUM;", ok: true, kept: true},
+		{name: "single_transaction", mode: "single_transaction", up: "CREATE TABLE accounts (id INTEGER PRIMARY KEY);", ok: true, kept: true},
+		{name: "no_transaction", mode: "no_transaction", up: "CREATE TABLE accounts (id INTEGER PRIMARY KEY);\nVACUUM;", ok: true, kept: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := newSQLiteTarget(t)
+			mig := testMigration(tt.mode, tt.up, "DROP TABLE accounts;")
+			_, err := st.execute(t, "apply", mig)
+			if tt.ok && err != nil {
+				t.Fatalf("apply: %v", err)
+			}
+			if !tt.ok && err == nil {
+				t.Fatal("apply: want error")
+			}
+			if got := st.hasTable(t, "accounts"); got != tt.kept {
+				t.Fatalf("accounts exists = %v, want %v", got, tt.kept)
+			}
+			if _, _, found := st.ledgerRow(t, mig.Key); found != tt.ok {
+				t.Fatalf("ledger row found = %v, want %v", found, tt.ok)
+			}
+		})
+	}
+}
+
+func TestSQLiteLockContention(t *testing.T) {
+	st := newSQLiteTarget(t)
+	wait := "200ms"
+	mig := testMigration("auto", "CREATE TABLE accounts (id INTEGER PRIMARY KEY);", "DROP TABLE accounts;")
+	mig.AdvisoryLockTimeout = &wait
+
+	eng, err := engine.Lookup("sqlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	holder, err := eng.Open(ctx, st.conn, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer holder.Close(ctx)
+	if err := holder.Lock(ctx, time.Second); err != nil {
+		t.Fatalf("lock: %v", err)
+	}
+	if _, err := os.Stat(st.path + "-migrate-hub.lock"); err != nil {
+		t.Fatalf("lock file: %v", err)
+	}
+
+	_, err = st.execute(t, "apply", mig)
+	if code := errorCode(err); code == nil || *code != store.TimeoutAdvisoryLock {
+		t.Fatalf("apply while locked: %v, want advisory lock timeout", err)
+	}
+	if st.hasTable(t, "accounts") {
+		t.Fatal("apply while locked: accounts created")
+	}
+
+	if err := holder.Unlock(ctx); err != nil {
+		t.Fatalf("unlock: %v", err)
+	}
+	if _, err := st.execute(t, "apply", mig); err != nil {
+		t.Fatalf("apply after unlock: %v", err)
+	}
+	if !st.hasTable(t, "accounts") {
+		t.Fatal("apply after unlock: accounts not created")
+	}
+}
@@ -0,0 +1,66 @@
+package executor
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"db_inner_migrator_syncer/internal/engine"
+	"db_inner_migrator_syncer/internal/store"
+)
+
+// timeoutError marks a failure caused by one of the configured time limits.
+// Its code is stored in run_items.error_code; such items are safe to retry.
+type timeoutError struct {
+	code  string
+	limit time.Duration
+	err   error
+}
+
+func (e *timeoutError) Error() string {
+	return fmt.Sprintf("%s exceeded (%s, retryable): %v", e.code, e.limit, e.err)
+}
+
+func (e *timeoutError) Unwrap() error { return e.err }
+
+// errorCode returns the run item error code for err, or nil when it has none.
+func errorCode(err error) *string {
+	var te *timeoutError
+	if errors.As(err, &te) {
+		return &te.code
+	}
+	return nil
+}
+
+// lockError turns a failed lock attempt into an advisory_lock timeout when
+// another run keeps the target lock.
+func lockError(err error, t store.Timeouts) error {
+	if errors.Is(err, engine.ErrLocked) {
+		return &timeoutError{code: store.TimeoutAdvisoryLock, limit: t.AdvisoryLock, err: err}
+	}
+	return err
+}
+
+// classifyError wraps statements the engine stopped at the lock or statement
+// timeout. A canceled run can look the same; runItem reports those as canceled.
+func classifyError(err error, t store.Timeouts) error {
+	switch {
+	case errors.Is(err, engine.ErrLockTimeout):
+		return &timeoutError{code: store.TimeoutLock, limit: t.Lock, err: err}
+	case errors.Is(err, engine.ErrStatementTimeout):
+		return &timeoutError{code: store.TimeoutStatement, limit: t.Statement, err: err}
+	}
+	return err
+}
+
+func logTimeouts(log *slog.Logger, t store.Timeouts) {
+	log.Info("session timeouts", store.TimeoutAdvisoryLock, t.AdvisoryLock.String(), store.TimeoutLock, durationOrDefault(t.Lock), store.TimeoutStatement, durationOrDefault(t.Statement))
+}
+
+func durationOrDefault(d time.Duration) string {
+	if d == 0 {
+		return "server default"
+	}
+	return d.String()
+}
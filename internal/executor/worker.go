@@ -0,0 +1,230 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"db_inner_migrator_syncer/internal/audit"
+	"db_inner_migrator_syncer/internal/store"
+)
+
+// WorkerPool claims queued runs from the tool DB and executes them in the background.
+type WorkerPool struct {
+	exec *Executor
+	cfg  WorkerPoolConfig
+}
+
+type WorkerPoolConfig struct {
+	Workers      int
+	PollInterval time.Duration
+	DrainTimeout time.Duration
+	// RecoveryInterval is how often stale running runs are reconciled; StaleAfter
+	// is how long a running run may go without a heartbeat before it is recovered.
+	RecoveryInterval time.Duration
+	StaleAfter       time.Duration
+	// ScheduleInterval is how often due scheduled runs are queued.
+	ScheduleInterval time.Duration
+	// DriftInterval is how often each target ledger is synced; 0 disables the sync loop.
+	DriftInterval time.Duration
+}
+
+func NewWorkerPool(exec *Executor, cfg WorkerPoolConfig) *WorkerPool {
+	if cfg.Workers < 1 {
+		cfg.Workers = 1
+	}
+	if cfg.ScheduleInterval <= 0 {
+		cfg.ScheduleInterval = 30 * time.Second
+	}
+	return &WorkerPool{exec: exec, cfg: cfg}
+}
+
+// Run blocks until ctx is canceled. Workers then stop claiming new runs and in-flight
+// runs are given drainTimeout to finish before their target statements are canceled.
+func (p *WorkerPool) Run(ctx context.Context) {
+	execCtx, cancelExec := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancelExec()
+
+	var wg sync.WaitGroup
+	for i := 0; i < p.cfg.Workers; i++ {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			p.loop(ctx, execCtx, id)
+		}(i + 1)
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		p.recoverLoop(ctx)
+	}()
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		p.scheduleLoop(ctx)
+	}()
+	if p.cfg.DriftInterval > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.driftLoop(ctx)
+		}()
+	}
+	p.exec.logger.Info("executor workers started", "workers", p.cfg.Workers)
+
+	<-ctx.Done()
+	p.exec.logger.Info("executor workers draining", "timeout", p.cfg.DrainTimeout.String())
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(p.cfg.DrainTimeout):
+		p.exec.logger.Error("executor drain timed out, canceling in-flight runs")
+		cancelExec()
+		<-done
+	}
+	p.exec.logger.Info("executor workers stopped")
+}
+
+// recoverLoop reconciles stale running runs at startup and then periodically.
+func (p *WorkerPool) recoverLoop(ctx context.Context) {
+	ticker := time.NewTicker(p.cfg.RecoveryInterval)
+	defer ticker.Stop()
+	for {
+		n, err := p.exec.RecoverStaleRuns(ctx, p.cfg.StaleAfter)
+		if err != nil && ctx.Err() == nil {
+			p.exec.logger.Error("recover stale runs failed", "error", err)
+		}
+		if n > 0 {
+			p.exec.logger.Info("stale runs recovered", "count", n)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// scheduleLoop queues scheduled runs once their time has come.
+func (p *WorkerPool) scheduleLoop(ctx context.Context) {
+	ticker := time.NewTicker(p.cfg.ScheduleInterval)
+	defer ticker.Stop()
+	for {
+		if _, err := p.exec.StartDueScheduledRuns(ctx); err != nil && ctx.Err() == nil {
+			p.exec.logger.Error("start scheduled runs failed", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// driftLoop syncs target ledgers that were not synced within DriftInterval.
+// It looks for due targets at least every minute, so a target is synced about
+// DriftInterval after its previous sync on any server.
+func (p *WorkerPool) driftLoop(ctx context.Context) {
+	ticker := time.NewTicker(min(p.cfg.DriftInterval, time.Minute))
+	defer ticker.Stop()
+	for {
+		if _, err := p.exec.SyncDueTargets(ctx, p.cfg.DriftInterval); err != nil && ctx.Err() == nil {
+			p.exec.logger.Error("sync target ledgers failed", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (p *WorkerPool) loop(ctx context.Context, execCtx context.Context, id int) {
+	ticker := time.NewTicker(p.cfg.PollInterval)
+	defer ticker.Stop()
+	for {
+		for ctx.Err() == nil {
+			claimed, err := p.executeNext(execCtx, id)
+			if err != nil {
+				p.exec.logger.Error("claim queued run failed", "worker", id, "error", err)
+				break
+			}
+			if !claimed {
+				break
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-p.exec.wake:
+		}
+	}
+}
+
+func (p *WorkerPool) executeNext(ctx context.Context, id int) (bool, error) {
+	claimed, err := store.ClaimQueuedRun(ctx, p.exec.pool)
+	if err != nil {
+		return false, err
+	}
+	if claimed == nil {
+		return false, nil
+	}
+	p.exec.logger.Info("run claimed", "worker", id, "run_id", claimed.ID)
+
+	run, err := p.exec.ExecuteRun(ctx, claimed.ProjectID, claimed.ID)
+	if errors.Is(err, store.ErrRunCanceled) {
+		p.exec.logger.Info("run canceled", "worker", id, "run_id", claimed.ID, "reason", err.Error())
+		reason := ""
+		if run.CancelReason != nil {
+			reason = *run.CancelReason
+		}
+		_ = audit.LogEvent(ctx, p.exec.pool, p.exec.logger, audit.Event{
+			ActorID:    run.CancelRequestedBy,
+			Action:     "run_canceled",
+			EntityType: "run",
+			EntityID:   &claimed.ID,
+			Payload: map[string]any{
+				"reason":          reason,
+				"previous_status": "running",
+			},
+		})
+		return true, nil
+	}
+	if err != nil {
+		p.exec.logger.Error("run execution failed", "worker", id, "run_id", claimed.ID, "error", err)
+		_ = audit.LogEvent(ctx, p.exec.pool, p.exec.logger, audit.Event{
+			ActorID:    claimed.ExecutedBy,
+			Action:     "run_execute_failed",
+			EntityType: "run",
+			EntityID:   &claimed.ID,
+			Payload: map[string]any{
+				"error": err.Error(),
+			},
+		})
+		return true, nil
+	}
+
+	if run.Status == "paused" {
+		// ExecuteRun recorded run_paused; the run waits for a continue.
+		return true, nil
+	}
+	p.exec.logger.Info("run executed", "worker", id, "run_id", run.ID, "status", run.Status)
+	_ = audit.LogEvent(ctx, p.exec.pool, p.exec.logger, audit.Event{
+		ActorID:    claimed.ExecutedBy,
+		Action:     "run_executed",
+		EntityType: "run",
+		EntityID:   &run.ID,
+		Payload: map[string]any{
+			"status":   run.Status,
+			"run_type": run.RunType,
+		},
+	})
+	return true, nil
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"errors"
 	"net/http"
+	"strings"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
@@ -11,6 +12,7 @@ import (
 
 	"db_inner_migrator_syncer/internal/audit"
 	"db_inner_migrator_syncer/internal/auth"
+	"db_inner_migrator_syncer/internal/executor"
 	"db_inner_migrator_syncer/internal/store"
 )
 
@@ -19,14 +21,16 @@ type DBInventoryHandler struct {
 	logger    requestLogger
 	sessions  *auth.SessionManager
 	secretKey []byte
+	executor  *executor.Executor
 }
 
-func NewDBInventoryHandler(pool *pgxpool.Pool, logger requestLogger, sessions *auth.SessionManager, secretKey []byte) *DBInventoryHandler {
+func NewDBInventoryHandler(pool *pgxpool.Pool, logger requestLogger, sessions *auth.SessionManager, secretKey []byte, executor *executor.Executor) *DBInventoryHandler {
 	return &DBInventoryHandler{
 		pool:      pool,
 		logger:    logger,
 		sessions:  sessions,
 		secretKey: secretKey,
+		executor:  executor,
 	}
 }
 
@@ -52,8 +56,14 @@ func (h *DBInventoryHandler) ListDBSets(w http.ResponseWriter, r *http.Request)
 }
 
 type createDBSetRequest struct {
-	Env  string `json:"env"`
-	Name string `json:"name"`
+	Env           string `json:"env"`
+	Name          string `json:"name"`
+	MaxParallel   int    `json:"max_parallel"`
+	FailurePolicy string `json:"failure_policy"`
+	WavePlan      string `json:"wave_plan"`
+	WaveGate      string `json:"wave_gate"`
+	// MaintenanceWindow such as "mon-fri 02:00-04:00 UTC"; runs can be scheduled into it.
+	MaintenanceWindow string `json:"maintenance_window"`
 }
 
 func (h *DBInventoryHandler) CreateDBSet(w http.ResponseWriter, r *http.Request) {
@@ -73,9 +83,22 @@ func (h *DBInventoryHandler) CreateDBSet(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	set, err := store.CreateDBSet(r.Context(), h.pool, projectID, req.Env, req.Name, user.ID)
+	set, err := store.CreateDBSet(r.Context(), h.pool, store.CreateDBSetInput{
+		ProjectID:     projectID,
+		Env:           req.Env,
+		Name:          req.Name,
+		MaxParallel:   req.MaxParallel,
+		FailurePolicy: req.FailurePolicy,
+		WavePlan:      req.WavePlan,
+		WaveGate:      req.WaveGate,
+		CreatedBy:     user.ID,
+
+		MaintenanceWindow: req.MaintenanceWindow,
+	})
 	if err != nil {
-		if errors.Is(err, store.ErrEnvInvalid) || errors.Is(err, store.ErrDBSetNameEmpty) {
+		if errors.Is(err, store.ErrEnvInvalid) || errors.Is(err, store.ErrDBSetNameEmpty) ||
+			errors.Is(err, store.ErrMaxParallelInvalid) || errors.Is(err, store.ErrFailurePolicyInvalid) ||
+			errors.Is(err, store.ErrWavePlanInvalid) || errors.Is(err, store.ErrWaveGateInvalid) || errors.Is(err, store.ErrMaintenanceWindowInvalid) {
 			writeError(w, http.StatusBadRequest, "validation_error", err.Error())
 			return
 		}
@@ -90,8 +113,14 @@ func (h *DBInventoryHandler) CreateDBSet(w http.ResponseWriter, r *http.Request)
 		EntityType: "db_set",
 		EntityID:   &set.ID,
 		Payload: map[string]any{
-			"name": set.Name,
-			"env":  set.Env,
+			"name":           set.Name,
+			"env":            set.Env,
+			"max_parallel":   set.MaxParallel,
+			"failure_policy": set.FailurePolicy,
+			"wave_plan":      set.WavePlan,
+			"wave_gate":      set.WaveGate,
+
+			"maintenance_window": set.MaintenanceWindow,
 		},
 	})
 
@@ -160,7 +189,21 @@ type createTargetRequest struct {
 	DBName   string         `json:"dbname"`
 	Username string         `json:"username"`
 	Password string         `json:"password"`
+	TLS      targetTLS      `json:"tls"`
 	Options  map[string]any `json:"options"`
+	Priority *int           `json:"priority"`
+	// FanoutPattern makes a fan-out target: a LIKE pattern of the schemas
+	// (postgres) or databases (mysql) it expands to.
+	FanoutPattern string `json:"fanout_pattern"`
+}
+
+// targetTLS is the TLS setup of a target; ca, cert and key are PEM.
+type targetTLS struct {
+	Mode       string `json:"mode"`
+	ServerName string `json:"server_name"`
+	CA         string `json:"ca"`
+	Cert       string `json:"cert"`
+	Key        string `json:"key"`
 }
 
 func (h *DBInventoryHandler) ListTargets(w http.ResponseWriter, r *http.Request) {
@@ -240,21 +283,21 @@ func (h *DBInventoryHandler) CreateTarget(w http.ResponseWriter, r *http.Request
 	}
 
 	target, err := store.CreateDBTarget(r.Context(), h.pool, h.secretKey, store.CreateTargetInput{
-		DBSetID:  setID,
-		Engine:   req.Engine,
-		Host:     req.Host,
-		Port:     req.Port,
-		DBName:   req.DBName,
-		Username: req.Username,
-		Password: req.Password,
-		Options:  req.Options,
+		DBSetID:       setID,
+		Engine:        req.Engine,
+		Host:          req.Host,
+		Port:          req.Port,
+		DBName:        req.DBName,
+		Username:      req.Username,
+		Password:      req.Password,
+		TLS:           store.TLSInput(req.TLS),
+		Options:       req.Options,
+		Priority:      req.Priority,
+		FanoutPattern: req.FanoutPattern,
 	})
 	if err != nil {
-		if errors.Is(err, store.ErrDBTargetBadEngine) || errors.Is(err, store.ErrDBTargetInactive) {
-			writeError(w, http.StatusBadRequest, "validation_error", err.Error())
-			return
-		}
-		if err.Error() == "host, dbname, username required" || err.Error() == "port must be positive" {
+		if errors.Is(err, store.ErrDBTargetBadEngine) || errors.Is(err, store.ErrDBTargetInactive) || errors.Is(err, store.ErrTimeoutInvalid) ||
+			errors.Is(err, store.ErrDBTargetPriority) || errors.Is(err, store.ErrLedgerNameInvalid) || errors.Is(err, store.ErrDBTargetInvalid) {
 			writeError(w, http.StatusBadRequest, "validation_error", err.Error())
 			return
 		}
@@ -273,6 +316,9 @@ func (h *DBInventoryHandler) CreateTarget(w http.ResponseWriter, r *http.Request
 			"engine":    target.Engine,
 			"host":      target.Host,
 			"port":      target.Port,
+			"priority":  target.Priority,
+			"tls":       target.TLS.String(),
+			"fanout":    target.FanoutPattern,
 		},
 	})
 
@@ -443,6 +489,334 @@ func (h *DBInventoryHandler) TestConnection(w http.ResponseWriter, r *http.Reque
 	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
 }
 
+// GetTargetSync returns the latest ledger sync of a target.
+func (h *DBInventoryHandler) GetTargetSync(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "unauthorized", "authentication required")
+		return
+	}
+	target, ok := h.projectTarget(w, r, user)
+	if !ok {
+		return
+	}
+	sync, err := store.GetTargetSync(r.Context(), h.pool, target.ID)
+	if err != nil {
+		if errors.Is(err, store.ErrTargetSyncNotFound) {
+			writeError(w, http.StatusNotFound, "not_found", err.Error())
+			return
+		}
+		h.logger.Error("get target sync failed", "error", err)
+		writeError(w, http.StatusInternalServerError, "lookup_failed", "failed to fetch target sync")
+		return
+	}
+	writeJSON(w, http.StatusOK, sync)
+}
+
+// SyncTarget reads the target ledger now and returns the stored comparison.
+func (h *DBInventoryHandler) SyncTarget(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "unauthorized", "authentication required")
+		return
+	}
+	target, ok := h.projectTarget(w, r, user)
+	if !ok {
+		return
+	}
+	sync, err := h.executor.SyncTarget(r.Context(), target.ID, &user.ID)
+	if err != nil {
+		h.logger.Error("sync target failed", "error", err)
+		writeError(w, http.StatusInternalServerError, "sync_failed", "failed to sync target ledger")
+		return
+	}
+	writeJSON(w, http.StatusOK, sync)
+}
+
+type importLedgerRequest struct {
+	Source      string                      `json:"source"`
+	Bodies      map[string]store.ImportBody `json:"bodies"`
+	PreviewHash string                      `json:"preview_hash"`
+}
+
+// PreviewImport reads another tool's ledger table on the target and returns
+// the import plan without writing anything.
+func (h *DBInventoryHandler) PreviewImport(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "unauthorized", "authentication required")
+		return
+	}
+	target, ok := h.projectTarget(w, r, user)
+	if !ok {
+		return
+	}
+	var req importLedgerRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_body", "invalid json body")
+		return
+	}
+	plan, err := h.executor.PreviewImport(r.Context(), target.ID, req.Source, req.Bodies)
+	if err != nil {
+		h.writeImportError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, plan)
+}
+
+// CommitImport creates the migrations of a previewed import and seeds the
+// target ledger with them.
+func (h *DBInventoryHandler) CommitImport(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "unauthorized", "authentication required")
+		return
+	}
+	target, ok := h.projectTarget(w, r, user)
+	if !ok {
+		return
+	}
+	var req importLedgerRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_body", "invalid json body")
+		return
+	}
+	if strings.TrimSpace(req.PreviewHash) == "" {
+		writeError(w, http.StatusBadRequest, "validation_error", "preview_hash required; preview the import first")
+		return
+	}
+	plan, err := h.executor.CommitImport(r.Context(), target.ID, req.Source, req.Bodies, req.PreviewHash, user.ID)
+	if err != nil {
+		h.writeImportError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, plan)
+}
+
+func (h *DBInventoryHandler) writeImportError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, store.ErrImportSourceInvalid) || errors.Is(err, store.ErrImportSourceMissing) || errors.Is(err, store.ErrImportNothing) ||
+		errors.Is(err, store.ErrDBTargetInactive) || errors.Is(err, store.ErrLedgerNameInvalid) || errors.Is(err, store.ErrMigrationSQLMissing) ||
+		errors.Is(err, store.ErrFanoutUnsupported):
+		writeError(w, http.StatusBadRequest, "validation_error", err.Error())
+	case errors.Is(err, store.ErrImportPreviewStale):
+		writeError(w, http.StatusConflict, "preview_stale", err.Error())
+	default:
+		h.logger.Error("ledger import failed", "error", err)
+		writeError(w, http.StatusInternalServerError, "import_failed", "ledger import failed: "+err.Error())
+	}
+}
+
+// sshRequest sets an SSH tunnel; private_key (PEM) and password keep the
+// stored ones when blank.
+type sshRequest struct {
+	Host       string `json:"host"`
+	Port       int    `json:"port"`
+	User       string `json:"user"`
+	HostKey    string `json:"host_key"`
+	PrivateKey string `json:"private_key"`
+	Password   string `json:"password"`
+}
+
+func (req sshRequest) input() store.SSHInput {
+	return store.SSHInput{Host: req.Host, Port: req.Port, User: req.User, HostKey: req.HostKey, Key: req.PrivateKey, Password: req.Password}
+}
+
+func (h *DBInventoryHandler) SetDBSetSSH(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "unauthorized", "authentication required")
+		return
+	}
+	set, ok := h.projectDBSet(w, r, user)
+	if !ok {
+		return
+	}
+	var req sshRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_body", "invalid json body")
+		return
+	}
+	tunnel, err := store.SetDBSetSSH(r.Context(), h.pool, h.secretKey, set.ID, req.input())
+	if err != nil {
+		h.writeSSHError(w, err, "db set not found")
+		return
+	}
+
+	_ = audit.LogEvent(r.Context(), h.pool, h.logger, audit.Event{
+		ActorID:    &user.ID,
+		Action:     "db_set_ssh_set",
+		EntityType: "db_set",
+		EntityID:   &set.ID,
+		Payload: map[string]any{
+			"bastion":  tunnel.String(),
+			"host_key": tunnel.HostKey,
+		},
+	})
+
+	writeJSON(w, http.StatusOK, tunnel)
+}
+
+func (h *DBInventoryHandler) RemoveDBSetSSH(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "unauthorized", "authentication required")
+		return
+	}
+	set, ok := h.projectDBSet(w, r, user)
+	if !ok {
+		return
+	}
+	if err := store.RemoveDBSetSSH(r.Context(), h.pool, set.ID); err != nil {
+		h.writeSSHError(w, err, "db set not found")
+		return
+	}
+
+	_ = audit.LogEvent(r.Context(), h.pool, h.logger, audit.Event{
+		ActorID:    &user.ID,
+		Action:     "db_set_ssh_removed",
+		EntityType: "db_set",
+		EntityID:   &set.ID,
+	})
+
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+func (h *DBInventoryHandler) SetTargetSSH(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "unauthorized", "authentication required")
+		return
+	}
+	target, ok := h.projectTarget(w, r, user)
+	if !ok {
+		return
+	}
+	var req sshRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_body", "invalid json body")
+		return
+	}
+	tunnel, err := store.SetDBTargetSSH(r.Context(), h.pool, h.secretKey, target.ID, req.input())
+	if err != nil {
+		h.writeSSHError(w, err, "db target not found")
+		return
+	}
+
+	_ = audit.LogEvent(r.Context(), h.pool, h.logger, audit.Event{
+		ActorID:    &user.ID,
+		Action:     "db_target_ssh_set",
+		EntityType: "db_target",
+		EntityID:   &target.ID,
+		Payload: map[string]any{
+			"db_set_id": target.DBSetID,
+			"bastion":   tunnel.String(),
+			"host_key":  tunnel.HostKey,
+		},
+	})
+
+	writeJSON(w, http.StatusOK, tunnel)
+}
+
+func (h *DBInventoryHandler) RemoveTargetSSH(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "unauthorized", "authentication required")
+		return
+	}
+	target, ok := h.projectTarget(w, r, user)
+	if !ok {
+		return
+	}
+	if err := store.RemoveDBTargetSSH(r.Context(), h.pool, target.ID); err != nil {
+		h.writeSSHError(w, err, "db target not found")
+		return
+	}
+
+	_ = audit.LogEvent(r.Context(), h.pool, h.logger, audit.Event{
+		ActorID:    &user.ID,
+		Action:     "db_target_ssh_removed",
+		EntityType: "db_target",
+		EntityID:   &target.ID,
+		Payload: map[string]any{
+			"db_set_id": target.DBSetID,
+		},
+	})
+
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+func (h *DBInventoryHandler) writeSSHError(w http.ResponseWriter, err error, notFound string) {
+	switch {
+	case errors.Is(err, store.ErrDBSetNotFound), errors.Is(err, store.ErrDBTargetNotFound):
+		writeError(w, http.StatusNotFound, "not_found", notFound)
+	case errors.Is(err, store.ErrDBTargetInvalid):
+		writeError(w, http.StatusBadRequest, "validation_error", err.Error())
+	default:
+		h.logger.Error("set ssh tunnel failed", "error", err)
+		writeError(w, http.StatusInternalServerError, "update_failed", "failed to update ssh tunnel")
+	}
+}
+
+// projectDBSet loads the db set named in the URL if it belongs to the user's
+// project, writing the error response otherwise.
+func (h *DBInventoryHandler) projectDBSet(w http.ResponseWriter, r *http.Request, user *auth.User) (*store.DBSet, bool) {
+	projectID, ok := requireProject(w, user)
+	if !ok {
+		return nil, false
+	}
+	setID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_id", "invalid db set id")
+		return nil, false
+	}
+	set, err := store.GetDBSet(r.Context(), h.pool, setID)
+	if err != nil {
+		if errors.Is(err, store.ErrDBSetNotFound) {
+			writeError(w, http.StatusNotFound, "not_found", "db set not found")
+			return nil, false
+		}
+		h.logger.Error("get db set failed", "error", err)
+		writeError(w, http.StatusInternalServerError, "lookup_failed", "failed to fetch db set")
+		return nil, false
+	}
+	if set.ProjectID != projectID {
+		writeError(w, http.StatusNotFound, "not_found", "db set not found")
+		return nil, false
+	}
+	return set, true
+}
+
+// projectTarget loads the target named in the URL if it belongs to the user's
+// project, writing the error response otherwise.
+func (h *DBInventoryHandler) projectTarget(w http.ResponseWriter, r *http.Request, user *auth.User) (*store.DBTarget, bool) {
+	projectID, ok := requireProject(w, user)
+	if !ok {
+		return nil, false
+	}
+	targetID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_id", "invalid target id")
+		return nil, false
+	}
+	target, _, err := store.GetDBTarget(r.Context(), h.pool, targetID)
+	if err != nil {
+		if errors.Is(err, store.ErrDBTargetNotFound) {
+			writeError(w, http.StatusNotFound, "not_found", "db target not found")
+			return nil, false
+		}
+		h.logger.Error("get target failed", "error", err)
+		writeError(w, http.StatusInternalServerError, "lookup_failed", "failed to fetch db target")
+		return nil, false
+	}
+	set, err := store.GetDBSet(r.Context(), h.pool, target.DBSetID)
+	if err != nil || set.ProjectID != projectID {
+		writeError(w, http.StatusNotFound, "not_found", "db target not found")
+		return nil, false
+	}
+	return target, true
+}
+
 func requireProject(w http.ResponseWriter, user *auth.User) (uuid.UUID, bool) {
 	if user == nil || user.ProjectID == nil {
 		writeError(w, http.StatusBadRequest, "project_required", "select a project first")
@@ -34,6 +34,16 @@ type createMigrationRequest struct {
 	SQLUp           string  `json:"sql_up"`
 	SQLDown         *string `json:"sql_down"`
 	TransactionMode string  `json:"transaction_mode"`
+	FailurePolicy   string  `json:"failure_policy"`
+
+	AdvisoryLockTimeout string `json:"advisory_lock_timeout"`
+	LockTimeout         string `json:"lock_timeout"`
+	StatementTimeout    string `json:"statement_timeout"`
+
+	PrecheckSQL     string `json:"precheck_sql"`
+	PrecheckExpect  string `json:"precheck_expect"`
+	PostcheckSQL    string `json:"postcheck_sql"`
+	PostcheckExpect string `json:"postcheck_expect"`
 }
 
 type updateMigrationRequest struct {
@@ -43,6 +53,16 @@ type updateMigrationRequest struct {
 	SQLUp           *string `json:"sql_up"`
 	SQLDown         *string `json:"sql_down"`
 	TransactionMode *string `json:"transaction_mode"`
+	FailurePolicy   *string `json:"failure_policy"`
+
+	AdvisoryLockTimeout *string `json:"advisory_lock_timeout"`
+	LockTimeout         *string `json:"lock_timeout"`
+	StatementTimeout    *string `json:"statement_timeout"`
+
+	PrecheckSQL     *string `json:"precheck_sql"`
+	PrecheckExpect  *string `json:"precheck_expect"`
+	PostcheckSQL    *string `json:"postcheck_sql"`
+	PostcheckExpect *string `json:"postcheck_expect"`
 }
 
 func (h *MigrationHandler) List(w http.ResponseWriter, r *http.Request) {
@@ -118,13 +138,25 @@ func (h *MigrationHandler) Create(w http.ResponseWriter, r *http.Request) {
 		SQLUp:           req.SQLUp,
 		SQLDown:         req.SQLDown,
 		TransactionMode: req.TransactionMode,
-		CreatedBy:       user.ID,
+		FailurePolicy:   req.FailurePolicy,
+
+		AdvisoryLockTimeout: req.AdvisoryLockTimeout,
+		LockTimeout:         req.LockTimeout,
+		StatementTimeout:    req.StatementTimeout,
+		CreatedBy:           user.ID,
+
+		PrecheckSQL:     req.PrecheckSQL,
+		PrecheckExpect:  req.PrecheckExpect,
+		PostcheckSQL:    req.PostcheckSQL,
+		PostcheckExpect: req.PostcheckExpect,
 	})
 	if err != nil {
 		if errors.Is(err, store.ErrMigrationKeyEmpty) ||
 			errors.Is(err, store.ErrMigrationNameEmpty) ||
 			errors.Is(err, store.ErrMigrationSQLMissing) ||
-			errors.Is(err, store.ErrTxModeInvalid) {
+			errors.Is(err, store.ErrTxModeInvalid) || errors.Is(err, store.ErrTxModeImplicitCommit) ||
+			errors.Is(err, store.ErrFailurePolicyInvalid) || errors.Is(err, store.ErrTimeoutInvalid) ||
+			errors.Is(err, store.ErrCheckInvalid) || errors.Is(err, store.ErrCheckExpectInvalid) {
 			writeError(w, http.StatusBadRequest, "validation_error", err.Error())
 			return
 		}
@@ -179,6 +211,16 @@ func (h *MigrationHandler) Update(w http.ResponseWriter, r *http.Request) {
 		SQLUp:           req.SQLUp,
 		SQLDown:         req.SQLDown,
 		TransactionMode: req.TransactionMode,
+		FailurePolicy:   req.FailurePolicy,
+
+		AdvisoryLockTimeout: req.AdvisoryLockTimeout,
+		LockTimeout:         req.LockTimeout,
+		StatementTimeout:    req.StatementTimeout,
+
+		PrecheckSQL:     req.PrecheckSQL,
+		PrecheckExpect:  req.PrecheckExpect,
+		PostcheckSQL:    req.PostcheckSQL,
+		PostcheckExpect: req.PostcheckExpect,
 	})
 	if err != nil {
 		if errors.Is(err, store.ErrMigrationNotFound) {
@@ -186,7 +228,10 @@ func (h *MigrationHandler) Update(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 		if errors.Is(err, store.ErrMigrationNameEmpty) ||
-			errors.Is(err, store.ErrMigrationSQLMissing) || errors.Is(err, store.ErrTxModeInvalid) {
+			errors.Is(err, store.ErrMigrationSQLMissing) || errors.Is(err, store.ErrTxModeInvalid) ||
+			errors.Is(err, store.ErrTxModeImplicitCommit) ||
+			errors.Is(err, store.ErrFailurePolicyInvalid) || errors.Is(err, store.ErrTimeoutInvalid) ||
+			errors.Is(err, store.ErrCheckInvalid) || errors.Is(err, store.ErrCheckExpectInvalid) {
 			writeError(w, http.StatusBadRequest, "validation_error", err.Error())
 			return
 		}
@@ -1,9 +1,13 @@
 package httpserver
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
+	"io"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
@@ -26,14 +30,54 @@ func NewRunHandler(pool *pgxpool.Pool, logger requestLogger, executor *executor.
 }
 
 type requestApprovalRequest struct {
-	Env     string `json:"env"`
-	DBSetID string `json:"db_set_id"`
+	Env         string `json:"env"`
+	DBSetID     string `json:"db_set_id"`
+	MaxParallel int    `json:"max_parallel"`
+	WavePlan    string `json:"wave_plan"`
+	WaveGate    string `json:"wave_gate"`
+}
+
+type requestBaselineRequest struct {
+	requestApprovalRequest
+	VerifySQL    string `json:"verify_sql"`
+	VerifyExpect string `json:"verify_expect"`
+}
+
+type requestDryRunRequest struct {
+	Env         string `json:"env"`
+	DBSetID     string `json:"db_set_id"`
+	MaxParallel int    `json:"max_parallel"`
+	DryRunOf    string `json:"dry_run_of"`
 }
 
 type decisionRequest struct {
 	Comment string `json:"comment"`
 }
 
+type cancelRequest struct {
+	Reason string `json:"reason"`
+}
+
+// executeRequest is the optional body of execute and retry.
+type executeRequest struct {
+	AcceptWarnings []string `json:"accept_warnings"`
+}
+
+// scheduleRequest sets either a fixed start time or the next maintenance window.
+type scheduleRequest struct {
+	ScheduledFor      *time.Time `json:"scheduled_for"`
+	MaintenanceWindow bool       `json:"maintenance_window"`
+	AcceptWarnings    []string   `json:"accept_warnings"`
+}
+
+// decodeOptionalBody decodes a JSON body that may be empty.
+func decodeOptionalBody(r *http.Request, dst any) error {
+	if err := json.NewDecoder(r.Body).Decode(dst); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	return nil
+}
+
 func (h *RunHandler) Get(w http.ResponseWriter, r *http.Request) {
 	user, ok := auth.UserFromContext(r.Context())
 	if !ok {
@@ -112,16 +156,20 @@ func (h *RunHandler) RequestApproval(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	run, err := store.RequestRun(r.Context(), h.pool, store.RequestRunInput{
+	run, err := h.executor.RequestRun(r.Context(), store.RequestRunInput{
 		ProjectID:   projectID,
 		MigrationID: migrationID,
 		DBSetID:     dbSetID,
 		Env:         req.Env,
 		RequestedBy: user.ID,
+		MaxParallel: req.MaxParallel,
+		WavePlan:    req.WavePlan,
+		WaveGate:    req.WaveGate,
 		RunType:     "apply",
 	})
 	if err != nil {
-		if errors.Is(err, store.ErrRunEnvInvalid) || errors.Is(err, store.ErrRunNoTargets) {
+		if errors.Is(err, store.ErrRunEnvInvalid) || errors.Is(err, store.ErrRunNoTargets) || errors.Is(err, store.ErrMaxParallelInvalid) ||
+			errors.Is(err, store.ErrTxModeImplicitCommit) || errors.Is(err, store.ErrWavePlanInvalid) || errors.Is(err, store.ErrWaveGateInvalid) {
 			writeError(w, http.StatusBadRequest, "validation_error", err.Error())
 			return
 		}
@@ -129,6 +177,10 @@ func (h *RunHandler) RequestApproval(w http.ResponseWriter, r *http.Request) {
 			writeError(w, http.StatusNotFound, "not_found", err.Error())
 			return
 		}
+		if errors.Is(err, store.ErrTenantDiscovery) {
+			writeError(w, http.StatusBadGateway, "tenant_discovery_failed", err.Error())
+			return
+		}
 		h.logger.Error("request run failed", "error", err)
 		writeError(w, http.StatusInternalServerError, "request_failed", "failed to request approval")
 		return
@@ -140,9 +192,13 @@ func (h *RunHandler) RequestApproval(w http.ResponseWriter, r *http.Request) {
 		EntityType: "run",
 		EntityID:   &run.ID,
 		Payload: map[string]any{
-			"migration_id": run.MigrationID,
-			"env":          run.Env,
-			"db_set_id":    run.DBSetID,
+			"migration_id":   run.MigrationID,
+			"env":            run.Env,
+			"db_set_id":      run.DBSetID,
+			"max_parallel":   run.MaxParallel,
+			"failure_policy": run.FailurePolicy,
+			"wave_plan":      run.WavePlan,
+			"wave_gate":      run.WaveGate,
 		},
 	})
 
@@ -175,16 +231,20 @@ func (h *RunHandler) RequestRollback(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	run, err := store.RequestRun(r.Context(), h.pool, store.RequestRunInput{
+	run, err := h.executor.RequestRun(r.Context(), store.RequestRunInput{
 		ProjectID:   projectID,
 		MigrationID: migrationID,
 		DBSetID:     dbSetID,
 		Env:         req.Env,
 		RequestedBy: user.ID,
+		MaxParallel: req.MaxParallel,
+		WavePlan:    req.WavePlan,
+		WaveGate:    req.WaveGate,
 		RunType:     "rollback",
 	})
 	if err != nil {
-		if errors.Is(err, store.ErrRunEnvInvalid) || errors.Is(err, store.ErrRunNoTargets) || errors.Is(err, store.ErrRollbackMissingSQL) {
+		if errors.Is(err, store.ErrRunEnvInvalid) || errors.Is(err, store.ErrRunNoTargets) || errors.Is(err, store.ErrMaxParallelInvalid) || errors.Is(err, store.ErrRollbackMissingSQL) ||
+			errors.Is(err, store.ErrTxModeImplicitCommit) || errors.Is(err, store.ErrWavePlanInvalid) || errors.Is(err, store.ErrWaveGateInvalid) {
 			writeError(w, http.StatusBadRequest, "validation_error", err.Error())
 			return
 		}
@@ -192,6 +252,10 @@ func (h *RunHandler) RequestRollback(w http.ResponseWriter, r *http.Request) {
 			writeError(w, http.StatusNotFound, "not_found", err.Error())
 			return
 		}
+		if errors.Is(err, store.ErrTenantDiscovery) {
+			writeError(w, http.StatusBadGateway, "tenant_discovery_failed", err.Error())
+			return
+		}
 		h.logger.Error("request rollback failed", "error", err)
 		writeError(w, http.StatusInternalServerError, "request_failed", "failed to request rollback")
 		return
@@ -202,14 +266,174 @@ func (h *RunHandler) RequestRollback(w http.ResponseWriter, r *http.Request) {
 		Action:     "rollback_requested",
 		EntityType: "run",
 		EntityID:   &run.ID,
+		Payload: map[string]any{
+			"migration_id":   run.MigrationID,
+			"env":            run.Env,
+			"db_set_id":      run.DBSetID,
+			"max_parallel":   run.MaxParallel,
+			"failure_policy": run.FailurePolicy,
+			"wave_plan":      run.WavePlan,
+			"wave_gate":      run.WaveGate,
+		},
+	})
+
+	writeJSON(w, http.StatusCreated, run)
+}
+
+// RequestBaseline requests a baseline run: once approved and executed it writes
+// the ledger row of the migration on every target without running sql_up, for
+// changes that were applied by hand.
+func (h *RunHandler) RequestBaseline(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "unauthorized", "authentication required")
+		return
+	}
+	projectID, ok := requireProject(w, user)
+	if !ok {
+		return
+	}
+	migrationID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_id", "invalid migration id")
+		return
+	}
+	var req requestBaselineRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_body", "invalid json body")
+		return
+	}
+	dbSetID, err := uuid.Parse(req.DBSetID)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_db_set_id", "invalid db set id")
+		return
+	}
+
+	run, err := h.executor.RequestRun(r.Context(), store.RequestRunInput{
+		ProjectID:    projectID,
+		MigrationID:  migrationID,
+		DBSetID:      dbSetID,
+		Env:          req.Env,
+		RequestedBy:  user.ID,
+		MaxParallel:  req.MaxParallel,
+		WavePlan:     req.WavePlan,
+		WaveGate:     req.WaveGate,
+		RunType:      "baseline",
+		VerifySQL:    req.VerifySQL,
+		VerifyExpect: req.VerifyExpect,
+	})
+	if err != nil {
+		if errors.Is(err, store.ErrRunEnvInvalid) || errors.Is(err, store.ErrRunNoTargets) || errors.Is(err, store.ErrMaxParallelInvalid) ||
+			errors.Is(err, store.ErrWavePlanInvalid) || errors.Is(err, store.ErrWaveGateInvalid) ||
+			errors.Is(err, store.ErrVerifyInvalid) || errors.Is(err, store.ErrCheckExpectInvalid) {
+			writeError(w, http.StatusBadRequest, "validation_error", err.Error())
+			return
+		}
+		if errors.Is(err, store.ErrDBSetNotFound) || errors.Is(err, store.ErrMigrationNotFound) {
+			writeError(w, http.StatusNotFound, "not_found", err.Error())
+			return
+		}
+		if errors.Is(err, store.ErrTenantDiscovery) {
+			writeError(w, http.StatusBadGateway, "tenant_discovery_failed", err.Error())
+			return
+		}
+		h.logger.Error("request baseline failed", "error", err)
+		writeError(w, http.StatusInternalServerError, "request_failed", "failed to request baseline")
+		return
+	}
+
+	_ = audit.LogEvent(r.Context(), h.pool, h.logger, audit.Event{
+		ActorID:    &user.ID,
+		Action:     "baseline_requested",
+		EntityType: "run",
+		EntityID:   &run.ID,
+		Payload: map[string]any{
+			"migration_id":   run.MigrationID,
+			"env":            run.Env,
+			"db_set_id":      run.DBSetID,
+			"max_parallel":   run.MaxParallel,
+			"failure_policy": run.FailurePolicy,
+			"wave_plan":      run.WavePlan,
+			"wave_gate":      run.WaveGate,
+			"verify_sql":     run.VerifySQL,
+			"verify_expect":  run.VerifyExpect,
+		},
+	})
+
+	writeJSON(w, http.StatusCreated, run)
+}
+
+// RequestDryRun queues a dry run of the migration on every active target of the
+// db set. Dry runs roll back and leave the ledger alone, so no approval is needed.
+func (h *RunHandler) RequestDryRun(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "unauthorized", "authentication required")
+		return
+	}
+	projectID, ok := requireProject(w, user)
+	if !ok {
+		return
+	}
+	migrationID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_id", "invalid migration id")
+		return
+	}
+	var req requestDryRunRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_body", "invalid json body")
+		return
+	}
+	dbSetID, err := uuid.Parse(req.DBSetID)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_db_set_id", "invalid db set id")
+		return
+	}
+
+	run, err := h.executor.RequestDryRun(r.Context(), store.RequestRunInput{
+		ProjectID:   projectID,
+		MigrationID: migrationID,
+		DBSetID:     dbSetID,
+		Env:         req.Env,
+		RequestedBy: user.ID,
+		MaxParallel: req.MaxParallel,
+		DryRunOf:    req.DryRunOf,
+	})
+	if err != nil {
+		if errors.Is(err, store.ErrRunEnvInvalid) || errors.Is(err, store.ErrRunNoTargets) || errors.Is(err, store.ErrMaxParallelInvalid) || errors.Is(err, store.ErrRollbackMissingSQL) ||
+			errors.Is(err, store.ErrDryRunOfInvalid) {
+			writeError(w, http.StatusBadRequest, "validation_error", err.Error())
+			return
+		}
+		if errors.Is(err, store.ErrDBSetNotFound) || errors.Is(err, store.ErrMigrationNotFound) {
+			writeError(w, http.StatusNotFound, "not_found", err.Error())
+			return
+		}
+		if errors.Is(err, store.ErrTenantDiscovery) {
+			writeError(w, http.StatusBadGateway, "tenant_discovery_failed", err.Error())
+			return
+		}
+		h.logger.Error("request dry run failed", "error", err)
+		writeError(w, http.StatusInternalServerError, "request_failed", "failed to request dry run")
+		return
+	}
+
+	_ = audit.LogEvent(r.Context(), h.pool, h.logger, audit.Event{
+		ActorID:    &user.ID,
+		Action:     "dry_run_queued",
+		EntityType: "run",
+		EntityID:   &run.ID,
 		Payload: map[string]any{
 			"migration_id": run.MigrationID,
 			"env":          run.Env,
 			"db_set_id":    run.DBSetID,
+			"dry_run_of":   run.DryRunOf,
+			"max_parallel": run.MaxParallel,
 		},
 	})
 
-	writeJSON(w, http.StatusCreated, run)
+	writeJSON(w, http.StatusAccepted, run)
 }
 
 func (h *RunHandler) Approve(w http.ResponseWriter, r *http.Request) {
@@ -235,8 +459,13 @@ func (h *RunHandler) Execute(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusBadRequest, "invalid_id", "invalid run id")
 		return
 	}
+	var req executeRequest
+	if err := decodeOptionalBody(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_body", "invalid json body")
+		return
+	}
 
-	run, err := h.executor.ExecuteRun(r.Context(), projectID, runID, user.ID)
+	run, err := h.executor.QueueRun(r.Context(), projectID, runID, user.ID, req.AcceptWarnings)
 	if err != nil {
 		if errors.Is(err, store.ErrRunNotFound) {
 			writeError(w, http.StatusNotFound, "not_found", "run not found")
@@ -246,32 +475,386 @@ func (h *RunHandler) Execute(w http.ResponseWriter, r *http.Request) {
 			writeError(w, http.StatusConflict, "checksum_mismatch", err.Error())
 			return
 		}
-		writeError(w, http.StatusBadRequest, "execution_failed", err.Error())
-		_ = audit.LogEvent(r.Context(), h.pool, h.logger, audit.Event{
-			ActorID:    &user.ID,
-			Action:     "run_execute_failed",
-			EntityType: "run",
-			EntityID:   &runID,
-			Payload: map[string]any{
-				"error": err.Error(),
-			},
-		})
+		if errors.Is(err, store.ErrRunNotApproved) {
+			writeError(w, http.StatusBadRequest, "invalid_status", err.Error())
+			return
+		}
+		h.logger.Error("queue run failed", "error", err)
+		writeError(w, http.StatusInternalServerError, "queue_failed", "failed to queue run")
+		return
+	}
+
+	_ = audit.LogEvent(r.Context(), h.pool, h.logger, audit.Event{
+		ActorID:    &user.ID,
+		Action:     "run_queued",
+		EntityType: "run",
+		EntityID:   &run.ID,
+		Payload: map[string]any{
+			"status":            run.Status,
+			"accepted_warnings": run.AcceptedWarnings,
+		},
+	})
+
+	writeJSON(w, http.StatusAccepted, run)
+}
+
+// Preflight checks every target of the run without changing anything and
+// returns the report, which is also stored on the run.
+func (h *RunHandler) Preflight(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "unauthorized", "authentication required")
+		return
+	}
+	projectID, ok := requireProject(w, user)
+	if !ok {
+		return
+	}
+	runID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_id", "invalid run id")
+		return
+	}
+
+	report, err := h.executor.Preflight(r.Context(), projectID, runID)
+	if err != nil {
+		if errors.Is(err, store.ErrRunNotFound) || errors.Is(err, store.ErrMigrationNotFound) {
+			writeError(w, http.StatusNotFound, "not_found", err.Error())
+			return
+		}
+		h.logger.Error("preflight failed", "error", err)
+		writeError(w, http.StatusInternalServerError, "preflight_failed", "failed to run pre-flight checks")
+		return
+	}
+
+	_ = audit.LogEvent(r.Context(), h.pool, h.logger, audit.Event{
+		ActorID:    &user.ID,
+		Action:     "run_preflight",
+		EntityType: "run",
+		EntityID:   &runID,
+		Payload: map[string]any{
+			"status":   report.Status,
+			"warnings": report.Warnings,
+		},
+	})
+
+	writeJSON(w, http.StatusOK, report)
+}
+
+// Cancel cancels an approved or queued run, or requests a running run to stop.
+// A running run is still "running" in the response; it becomes "canceled" once
+// the executor has interrupted its in-flight statements.
+func (h *RunHandler) Cancel(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "unauthorized", "authentication required")
+		return
+	}
+	projectID, ok := requireProject(w, user)
+	if !ok {
+		return
+	}
+	runID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_id", "invalid run id")
+		return
+	}
+	var req cancelRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_body", "invalid json body")
+		return
+	}
+
+	run, err := h.executor.CancelRun(r.Context(), projectID, runID, user.ID, req.Reason)
+	if err != nil {
+		if errors.Is(err, store.ErrRunNotFound) {
+			writeError(w, http.StatusNotFound, "not_found", "run not found")
+			return
+		}
+		if errors.Is(err, store.ErrCancelReasonEmpty) {
+			writeError(w, http.StatusBadRequest, "validation_error", err.Error())
+			return
+		}
+		if errors.Is(err, store.ErrRunNotCancelable) {
+			writeError(w, http.StatusBadRequest, "invalid_status", err.Error())
+			return
+		}
+		h.logger.Error("cancel run failed", "error", err)
+		writeError(w, http.StatusInternalServerError, "cancel_failed", "failed to cancel run")
 		return
 	}
 
+	action := "run_canceled"
+	status := http.StatusOK
+	if run.Status == "running" {
+		action = "run_cancel_requested"
+		status = http.StatusAccepted
+	}
 	_ = audit.LogEvent(r.Context(), h.pool, h.logger, audit.Event{
 		ActorID:    &user.ID,
-		Action:     "run_executed",
+		Action:     action,
 		EntityType: "run",
 		EntityID:   &run.ID,
 		Payload: map[string]any{
+			"reason": strings.TrimSpace(req.Reason),
 			"status": run.Status,
 		},
 	})
 
+	writeJSON(w, status, run)
+}
+
+// Retry re-queues the failed and canceled items of a failed or canceled run
+// under the original approval. Items that executed or were skipped are kept.
+func (h *RunHandler) Retry(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "unauthorized", "authentication required")
+		return
+	}
+	projectID, ok := requireProject(w, user)
+	if !ok {
+		return
+	}
+	runID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_id", "invalid run id")
+		return
+	}
+	var req executeRequest
+	if err := decodeOptionalBody(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_body", "invalid json body")
+		return
+	}
+
+	run, err := h.executor.RetryRun(r.Context(), projectID, runID, user.ID, req.AcceptWarnings)
+	if err != nil {
+		if errors.Is(err, store.ErrRunNotFound) {
+			writeError(w, http.StatusNotFound, "not_found", "run not found")
+			return
+		}
+		if errors.Is(err, store.ErrChecksumMismatch) {
+			writeError(w, http.StatusConflict, "checksum_mismatch", err.Error())
+			return
+		}
+		if errors.Is(err, store.ErrApprovalExpired) {
+			writeError(w, http.StatusConflict, "approval_expired", err.Error())
+			return
+		}
+		if errors.Is(err, store.ErrRunNotRetryable) || errors.Is(err, store.ErrRunNothingToRetry) {
+			writeError(w, http.StatusBadRequest, "invalid_status", err.Error())
+			return
+		}
+		h.logger.Error("retry run failed", "error", err)
+		writeError(w, http.StatusInternalServerError, "retry_failed", "failed to retry run")
+		return
+	}
+
+	_ = audit.LogEvent(r.Context(), h.pool, h.logger, audit.Event{
+		ActorID:    &user.ID,
+		Action:     "run_retry_queued",
+		EntityType: "run",
+		EntityID:   &run.ID,
+		Payload:    retryAuditPayload(run),
+	})
+
+	writeJSON(w, http.StatusAccepted, run)
+}
+
+// Schedule attaches a start time to an approved run; the scheduler queues it
+// at that time unless its checksums or approval changed in the meantime.
+func (h *RunHandler) Schedule(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "unauthorized", "authentication required")
+		return
+	}
+	projectID, ok := requireProject(w, user)
+	if !ok {
+		return
+	}
+	runID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_id", "invalid run id")
+		return
+	}
+	var req scheduleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_body", "invalid json body")
+		return
+	}
+
+	run, err := store.ScheduleRun(r.Context(), h.pool, store.ScheduleRunInput{
+		ProjectID:      projectID,
+		RunID:          runID,
+		ActorID:        user.ID,
+		ScheduledFor:   req.ScheduledFor,
+		UseWindow:      req.MaintenanceWindow,
+		AcceptWarnings: req.AcceptWarnings,
+	})
+	if err != nil {
+		if errors.Is(err, store.ErrRunNotFound) {
+			writeError(w, http.StatusNotFound, "not_found", "run not found")
+			return
+		}
+		if errors.Is(err, store.ErrChecksumMismatch) {
+			writeError(w, http.StatusConflict, "checksum_mismatch", err.Error())
+			return
+		}
+		if errors.Is(err, store.ErrRunNotApproved) {
+			writeError(w, http.StatusBadRequest, "invalid_status", err.Error())
+			return
+		}
+		if errors.Is(err, store.ErrScheduleInvalid) || errors.Is(err, store.ErrNoMaintenanceWindow) || errors.Is(err, store.ErrMaintenanceWindowInvalid) {
+			writeError(w, http.StatusBadRequest, "validation_error", err.Error())
+			return
+		}
+		h.logger.Error("schedule run failed", "error", err)
+		writeError(w, http.StatusInternalServerError, "schedule_failed", "failed to schedule run")
+		return
+	}
+
+	_ = audit.LogEvent(r.Context(), h.pool, h.logger, audit.Event{
+		ActorID:    &user.ID,
+		Action:     "run_scheduled",
+		EntityType: "run",
+		EntityID:   &run.ID,
+		Payload: map[string]any{
+			"scheduled_for":   run.ScheduledFor,
+			"scheduled_until": run.ScheduledUntil,
+		},
+	})
+
+	writeJSON(w, http.StatusOK, run)
+}
+
+// Unschedule removes the start time of a scheduled run; the run stays approved.
+func (h *RunHandler) Unschedule(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "unauthorized", "authentication required")
+		return
+	}
+	projectID, ok := requireProject(w, user)
+	if !ok {
+		return
+	}
+	runID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_id", "invalid run id")
+		return
+	}
+
+	run, err := store.UnscheduleRun(r.Context(), h.pool, projectID, runID)
+	if err != nil {
+		if errors.Is(err, store.ErrRunNotFound) {
+			writeError(w, http.StatusNotFound, "not_found", "run not found")
+			return
+		}
+		if errors.Is(err, store.ErrRunNotScheduled) {
+			writeError(w, http.StatusBadRequest, "invalid_status", err.Error())
+			return
+		}
+		h.logger.Error("unschedule run failed", "error", err)
+		writeError(w, http.StatusInternalServerError, "unschedule_failed", "failed to unschedule run")
+		return
+	}
+
+	_ = audit.LogEvent(r.Context(), h.pool, h.logger, audit.Event{
+		ActorID:    &user.ID,
+		Action:     "run_unscheduled",
+		EntityType: "run",
+		EntityID:   &run.ID,
+	})
+
 	writeJSON(w, http.StatusOK, run)
 }
 
+// Continue re-queues a run paused at a manual wave gate so its next wave starts.
+func (h *RunHandler) Continue(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "unauthorized", "authentication required")
+		return
+	}
+	projectID, ok := requireProject(w, user)
+	if !ok {
+		return
+	}
+	runID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_id", "invalid run id")
+		return
+	}
+
+	run, err := h.executor.ContinueRun(r.Context(), projectID, runID)
+	if err != nil {
+		if errors.Is(err, store.ErrRunNotFound) {
+			writeError(w, http.StatusNotFound, "not_found", "run not found")
+			return
+		}
+		if errors.Is(err, store.ErrRunNotPaused) {
+			writeError(w, http.StatusBadRequest, "invalid_status", err.Error())
+			return
+		}
+		h.logger.Error("continue run failed", "error", err)
+		writeError(w, http.StatusInternalServerError, "continue_failed", "failed to continue run")
+		return
+	}
+
+	_ = audit.LogEvent(r.Context(), h.pool, h.logger, audit.Event{
+		ActorID:    &user.ID,
+		Action:     "run_continued",
+		EntityType: "run",
+		EntityID:   &run.ID,
+		Payload: map[string]any{
+			"status": run.Status,
+		},
+	})
+
+	writeJSON(w, http.StatusAccepted, run)
+}
+
+// retryAuditPayload lists the items a retry re-queued and their new attempt numbers.
+func retryAuditPayload(run *store.RunWithItems) map[string]any {
+	items := make([]map[string]any, 0, len(run.Items))
+	for _, item := range run.Items {
+		if item.Status != "queued" {
+			continue
+		}
+		entry := map[string]any{
+			"run_item_id":  item.ID,
+			"db_target_id": item.DBTargetID,
+			"attempt":      item.Attempt,
+		}
+		if item.Tenant != "" {
+			entry["tenant"] = item.Tenant
+		}
+		items = append(items, entry)
+	}
+	return map[string]any{
+		"status": run.Status,
+		"items":  items,
+	}
+}
+
+// decisionAuditPayload describes an approval decision. Approvals record the
+// fan-out tenants of the run, the list execution is held to.
+func decisionAuditPayload(ctx context.Context, pool *pgxpool.Pool, run *store.Run, decision string, comment string) map[string]any {
+	payload := map[string]any{
+		"migration_id": run.MigrationID,
+		"env":          run.Env,
+		"db_set_id":    run.DBSetID,
+		"comment":      comment,
+	}
+	if decision == "approved" {
+		if tenants, err := store.ListRunTenants(ctx, pool, run.ID); err == nil && len(tenants) > 0 {
+			payload["tenants"] = tenants
+		}
+	}
+	return payload
+}
+
 func (h *RunHandler) handleDecision(w http.ResponseWriter, r *http.Request, decision string) {
 	user, ok := auth.UserFromContext(r.Context())
 	if !ok {
@@ -305,7 +888,7 @@ func (h *RunHandler) handleDecision(w http.ResponseWriter, r *http.Request, deci
 	var run *store.Run
 	switch decision {
 	case "approved":
-		run, err = store.ApproveRun(r.Context(), h.pool, input)
+		run, err = h.executor.ApproveRun(r.Context(), input)
 	case "denied":
 		run, err = store.DenyRun(r.Context(), h.pool, input)
 	default:
@@ -325,6 +908,18 @@ func (h *RunHandler) handleDecision(w http.ResponseWriter, r *http.Request, deci
 			writeError(w, http.StatusConflict, "checksum_mismatch", err.Error())
 			return
 		}
+		if errors.Is(err, store.ErrRunNoTargets) {
+			writeError(w, http.StatusBadRequest, "validation_error", err.Error())
+			return
+		}
+		if errors.Is(err, store.ErrTenantDiscovery) {
+			writeError(w, http.StatusBadGateway, "tenant_discovery_failed", err.Error())
+			return
+		}
+		if errors.Is(err, store.ErrRunTenantsChanged) {
+			writeError(w, http.StatusConflict, "tenants_changed", err.Error())
+			return
+		}
 		h.logger.Error("run decision failed", "error", err)
 		writeError(w, http.StatusInternalServerError, "decision_failed", "failed to process decision")
 		return
@@ -335,12 +930,7 @@ func (h *RunHandler) handleDecision(w http.ResponseWriter, r *http.Request, deci
 		Action:     "run_" + decision,
 		EntityType: "run",
 		EntityID:   &run.ID,
-		Payload: map[string]any{
-			"migration_id": run.MigrationID,
-			"env":          run.Env,
-			"db_set_id":    run.DBSetID,
-			"comment":      req.Comment,
-		},
+		Payload:    decisionAuditPayload(r.Context(), h.pool, run, decision, req.Comment),
 	})
 
 	writeJSON(w, http.StatusOK, run)
@@ -114,6 +114,7 @@ func (s *Server) routes() http.Handler {
 			authenticated.Get("/db-sets", s.dbHandler.ListDBSets)
 			authenticated.Get("/db-sets/{id}/targets", s.dbHandler.ListTargets)
 			authenticated.Get("/targets/{id}", s.dbHandler.GetTarget)
+			authenticated.Get("/targets/{id}/sync", s.dbHandler.GetTargetSync)
 			authenticated.Get("/migrations", s.migrationHandler.List)
 			authenticated.Get("/migrations/{id}", s.migrationHandler.Get)
 			authenticated.Get("/runs/{id}", s.runHandler.Get)
@@ -135,6 +136,8 @@ func (s *Server) routes() http.Handler {
 			authenticated.Route("/db-sets", func(ds chi.Router) {
 				ds.With(authMiddleware.RequireRoles(rbac.RoleUser, rbac.RoleManager, rbac.RoleAdmin)).Post("/", s.dbHandler.CreateDBSet)
 				ds.With(authMiddleware.RequireRoles(rbac.RoleAdmin)).Post("/{id}/disable", s.dbHandler.DisableDBSet)
+				ds.With(authMiddleware.RequireRoles(rbac.RoleManager, rbac.RoleAdmin)).Put("/{id}/ssh", s.dbHandler.SetDBSetSSH)
+				ds.With(authMiddleware.RequireRoles(rbac.RoleManager, rbac.RoleAdmin)).Delete("/{id}/ssh", s.dbHandler.RemoveDBSetSSH)
 				ds.Route("/{id}/targets", func(tr chi.Router) {
 					tr.With(authMiddleware.RequireRoles(rbac.RoleUser, rbac.RoleManager, rbac.RoleAdmin)).Post("/", s.dbHandler.CreateTarget)
 				})
@@ -142,7 +145,12 @@ func (s *Server) routes() http.Handler {
 
 			authenticated.Route("/targets", func(tr chi.Router) {
 				tr.With(authMiddleware.RequireRoles(rbac.RoleUser, rbac.RoleManager, rbac.RoleAdmin)).Post("/{id}/test-connection", s.dbHandler.TestConnection)
+				tr.With(authMiddleware.RequireRoles(rbac.RoleUser, rbac.RoleManager, rbac.RoleAdmin)).Post("/{id}/sync", s.dbHandler.SyncTarget)
+				tr.With(authMiddleware.RequireRoles(rbac.RoleManager, rbac.RoleAdmin)).Post("/{id}/import/preview", s.dbHandler.PreviewImport)
+				tr.With(authMiddleware.RequireRoles(rbac.RoleManager, rbac.RoleAdmin)).Post("/{id}/import", s.dbHandler.CommitImport)
 				tr.With(authMiddleware.RequireRoles(rbac.RoleAdmin)).Post("/{id}/disable", s.dbHandler.DisableTarget)
+				tr.With(authMiddleware.RequireRoles(rbac.RoleManager, rbac.RoleAdmin)).Put("/{id}/ssh", s.dbHandler.SetTargetSSH)
+				tr.With(authMiddleware.RequireRoles(rbac.RoleManager, rbac.RoleAdmin)).Delete("/{id}/ssh", s.dbHandler.RemoveTargetSSH)
 			})
 
 			authenticated.Route("/migrations", func(mg chi.Router) {
@@ -150,12 +158,20 @@ func (s *Server) routes() http.Handler {
 				mg.With(authMiddleware.RequireRoles(rbac.RoleUser, rbac.RoleManager, rbac.RoleAdmin)).Patch("/{id}", s.migrationHandler.Update)
 				mg.With(authMiddleware.RequireRoles(rbac.RoleUser, rbac.RoleManager, rbac.RoleAdmin)).Post("/{id}/request-approval", s.runHandler.RequestApproval)
 				mg.With(authMiddleware.RequireRoles(rbac.RoleUser, rbac.RoleManager, rbac.RoleAdmin)).Post("/{id}/request-rollback", s.runHandler.RequestRollback)
+				mg.With(authMiddleware.RequireRoles(rbac.RoleUser, rbac.RoleManager, rbac.RoleAdmin)).Post("/{id}/request-baseline", s.runHandler.RequestBaseline)
+				mg.With(authMiddleware.RequireRoles(rbac.RoleUser, rbac.RoleManager, rbac.RoleAdmin)).Post("/{id}/request-dry-run", s.runHandler.RequestDryRun)
 			})
 
 			authenticated.Route("/runs", func(rn chi.Router) {
 				rn.With(authMiddleware.RequireRoles(rbac.RoleManager, rbac.RoleAdmin)).Post("/{id}/approve", s.runHandler.Approve)
 				rn.With(authMiddleware.RequireRoles(rbac.RoleManager, rbac.RoleAdmin)).Post("/{id}/deny", s.runHandler.Deny)
 				rn.With(authMiddleware.RequireRoles(rbac.RoleUser, rbac.RoleManager, rbac.RoleAdmin)).Post("/{id}/execute", s.runHandler.Execute)
+				rn.With(authMiddleware.RequireRoles(rbac.RoleUser, rbac.RoleManager, rbac.RoleAdmin)).Post("/{id}/cancel", s.runHandler.Cancel)
+				rn.With(authMiddleware.RequireRoles(rbac.RoleUser, rbac.RoleManager, rbac.RoleAdmin)).Post("/{id}/retry", s.runHandler.Retry)
+				rn.With(authMiddleware.RequireRoles(rbac.RoleUser, rbac.RoleManager, rbac.RoleAdmin)).Post("/{id}/continue", s.runHandler.Continue)
+				rn.With(authMiddleware.RequireRoles(rbac.RoleUser, rbac.RoleManager, rbac.RoleAdmin)).Post("/{id}/schedule", s.runHandler.Schedule)
+				rn.With(authMiddleware.RequireRoles(rbac.RoleUser, rbac.RoleManager, rbac.RoleAdmin)).Post("/{id}/unschedule", s.runHandler.Unschedule)
+				rn.With(authMiddleware.RequireRoles(rbac.RoleUser, rbac.RoleManager, rbac.RoleAdmin)).Post("/{id}/preflight", s.runHandler.Preflight)
 			})
 		})
 	})
@@ -173,6 +189,7 @@ func (s *Server) routes() http.Handler {
 			authed.Post("/projects/select", s.uiHandler.SelectProject)
 
 			authed.Get("/targets", s.uiHandler.TargetMigrations)
+			authed.Post("/targets/sync", s.uiHandler.SyncTargets)
 
 			authed.Get("/users", s.uiHandler.Users)
 			authed.Post("/users", s.uiHandler.CreateUser)
@@ -184,10 +201,17 @@ func (s *Server) routes() http.Handler {
 			authed.Get("/db-sets/{id}", s.uiHandler.DBSetDetail)
 			authed.Post("/db-sets/{id}/disable", s.uiHandler.DisableDBSet)
 			authed.Post("/db-sets/{id}/targets", s.uiHandler.AddTarget)
+			authed.Post("/db-sets/{id}/ssh", s.uiHandler.SetDBSetSSH)
+			authed.Post("/db-sets/{id}/ssh/remove", s.uiHandler.RemoveDBSetSSH)
 
 			authed.Post("/targets/{id}/edit", s.uiHandler.EditTarget)
 			authed.Post("/targets/{id}/disable", s.uiHandler.DisableTarget)
+			authed.Post("/targets/{id}/ssh", s.uiHandler.SetTargetSSH)
+			authed.Post("/targets/{id}/ssh/remove", s.uiHandler.RemoveTargetSSH)
 			authed.Post("/targets/{id}/test-connection", s.uiHandler.TestTarget)
+			authed.Post("/targets/{id}/sync", s.uiHandler.SyncTarget)
+			authed.Get("/targets/{id}/import", s.uiHandler.ImportPreview)
+			authed.Post("/targets/{id}/import", s.uiHandler.ImportCommit)
 
 			authed.Get("/migrations", s.uiHandler.MigrationsList)
 			authed.Get("/migrations/new", s.uiHandler.MigrationNew)
@@ -196,6 +220,8 @@ func (s *Server) routes() http.Handler {
 			authed.Post("/migrations/{id}/edit", s.uiHandler.MigrationUpdate)
 			authed.Post("/migrations/{id}/request-approval", s.uiHandler.RequestApproval)
 			authed.Post("/migrations/{id}/request-rollback", s.uiHandler.RequestRollback)
+			authed.Post("/migrations/{id}/request-baseline", s.uiHandler.RequestBaseline)
+			authed.Post("/migrations/{id}/request-dry-run", s.uiHandler.RequestDryRun)
 
 			authed.Get("/approvals", s.uiHandler.Approvals)
 			authed.Post("/runs/{id}/approve", s.uiHandler.ApproveRun)
@@ -204,6 +230,12 @@ func (s *Server) routes() http.Handler {
 			authed.Get("/runs", s.uiHandler.Runs)
 			authed.Get("/runs/{id}", s.uiHandler.RunDetail)
 			authed.Post("/runs/{id}/execute", s.uiHandler.ExecuteRun)
+			authed.Post("/runs/{id}/cancel", s.uiHandler.CancelRun)
+			authed.Post("/runs/{id}/retry", s.uiHandler.RetryRun)
+			authed.Post("/runs/{id}/continue", s.uiHandler.ContinueRun)
+			authed.Post("/runs/{id}/schedule", s.uiHandler.ScheduleRun)
+			authed.Post("/runs/{id}/unschedule", s.uiHandler.UnscheduleRun)
+			authed.Post("/runs/{id}/preflight", s.uiHandler.PreflightRun)
 			authed.Get("/runs/{id}/items/{item_id}/logs", s.uiHandler.RunItemLogs)
 
 			authed.Post("/logout", s.uiHandler.Logout)
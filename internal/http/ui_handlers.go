@@ -3,10 +3,10 @@ package httpserver
 import (
 	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
+	"reflect"
 	"strconv"
 	"strings"
 	"time"
@@ -87,12 +87,16 @@ func (h *UIHandler) Dashboard(w http.ResponseWriter, r *http.Request) {
 	running, _ := store.CountRunsByStatus(r.Context(), h.pool, projectID, "running")
 	failed, _ := store.CountFailedRunsSince(r.Context(), h.pool, projectID, time.Now().Add(-24*time.Hour))
 	recent, _ := store.ListRecentRuns(r.Context(), h.pool, projectID, 20)
+	scheduled, _ := store.ListScheduledRuns(r.Context(), h.pool, projectID, time.Now().Add(-7*24*time.Hour))
+	drift, _ := store.ListTargetDrift(r.Context(), h.pool, projectID)
 
 	data.Page = dashboardPage{
 		PendingCount: pending,
 		RunningCount: running,
 		FailedCount:  failed,
 		RecentRuns:   recent,
+		Scheduled:    scheduled,
+		Drift:        drift,
 	}
 	h.renderer.Render(w, data)
 }
@@ -358,8 +362,24 @@ func (h *UIHandler) CreateDBSet(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	env := r.FormValue("env")
-	name := r.FormValue("name")
-	set, err := store.CreateDBSet(r.Context(), h.pool, *user.ProjectID, env, name, user.ID)
+	maxParallel, err := parseOptionalInt(r.FormValue("max_parallel"))
+	if err != nil {
+		h.setFlash(w, r, "error", store.ErrMaxParallelInvalid.Error())
+		http.Redirect(w, r, "/ui/db-sets?env="+url.QueryEscape(env), http.StatusSeeOther)
+		return
+	}
+	set, err := store.CreateDBSet(r.Context(), h.pool, store.CreateDBSetInput{
+		ProjectID:     *user.ProjectID,
+		Env:           env,
+		Name:          r.FormValue("name"),
+		MaxParallel:   maxParallel,
+		FailurePolicy: r.FormValue("failure_policy"),
+		WavePlan:      r.FormValue("wave_plan"),
+		WaveGate:      r.FormValue("wave_gate"),
+		CreatedBy:     user.ID,
+
+		MaintenanceWindow: r.FormValue("maintenance_window"),
+	})
 	if err != nil {
 		h.setFlash(w, r, "error", err.Error())
 		http.Redirect(w, r, "/ui/db-sets?env="+url.QueryEscape(env), http.StatusSeeOther)
@@ -371,8 +391,14 @@ func (h *UIHandler) CreateDBSet(w http.ResponseWriter, r *http.Request) {
 		EntityType: "db_set",
 		EntityID:   &set.ID,
 		Payload: map[string]any{
-			"name": set.Name,
-			"env":  set.Env,
+			"name":           set.Name,
+			"env":            set.Env,
+			"max_parallel":   set.MaxParallel,
+			"failure_policy": set.FailurePolicy,
+			"wave_plan":      set.WavePlan,
+			"wave_gate":      set.WaveGate,
+
+			"maintenance_window": set.MaintenanceWindow,
 		},
 	})
 	h.setFlash(w, r, "success", "DB set created.")
@@ -406,9 +432,10 @@ func (h *UIHandler) DBSetDetail(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	data.Page = dbSetDetailPage{
-		DBSet:   *set,
-		Targets: targets,
-		IsAdmin: user.Role == rbac.RoleAdmin,
+		DBSet:     *set,
+		Targets:   targets,
+		IsAdmin:   user.Role == rbac.RoleAdmin,
+		IsManager: user.Role == rbac.RoleManager || user.Role == rbac.RoleAdmin,
 	}
 	h.renderer.Render(w, data)
 }
@@ -434,6 +461,7 @@ func (h *UIHandler) TargetMigrations(w http.ResponseWriter, r *http.Request) {
 		envFilter = ""
 	}
 	onlyMissing := r.URL.Query().Get("only_missing") == "1"
+	onlyDrift := r.URL.Query().Get("only_drift") == "1"
 
 	migs, err := store.ListMigrations(r.Context(), h.pool, *user.ProjectID, "")
 	if err != nil {
@@ -450,6 +478,11 @@ func (h *UIHandler) TargetMigrations(w http.ResponseWriter, r *http.Request) {
 		h.renderError(w, r, http.StatusInternalServerError, "Failed to load run history.")
 		return
 	}
+	syncs, err := store.ListTargetSyncs(r.Context(), h.pool, *user.ProjectID)
+	if err != nil {
+		h.renderError(w, r, http.StatusInternalServerError, "Failed to load ledger syncs.")
+		return
+	}
 
 	var targets []targetMigrationView
 	for _, set := range sets {
@@ -464,6 +497,14 @@ func (h *UIHandler) TargetMigrations(w http.ResponseWriter, r *http.Request) {
 				Target:    target,
 				MaskedDSN: maskedDSN(target),
 			}
+			if sync, ok := syncs[target.ID]; ok {
+				view.Sync = &sync
+				for _, entry := range sync.Entries {
+					if entry.State == store.DriftUnknown {
+						view.Unknown = append(view.Unknown, entry)
+					}
+				}
+			}
 			for _, mig := range migs {
 				status := compareStatus{Label: "missing"}
 				if byMig, ok := latestByTarget[target.ID]; ok {
@@ -472,7 +513,7 @@ func (h *UIHandler) TargetMigrations(w http.ResponseWriter, r *http.Request) {
 					}
 				}
 				switch status.Label {
-				case "applied":
+				case "applied", "baselined":
 					view.AppliedCount++
 				case "missing":
 					view.MissingCount++
@@ -487,12 +528,20 @@ func (h *UIHandler) TargetMigrations(w http.ResponseWriter, r *http.Request) {
 				case "running":
 					view.RunningCount++
 				}
+				var ledger *store.DriftEntry
+				if view.Sync != nil {
+					ledger = view.Sync.Entry(mig.Key)
+				}
 				if onlyMissing && status.Label != "missing" {
 					continue
 				}
+				if onlyDrift && (ledger == nil || !store.IsDrift(ledger.State)) {
+					continue
+				}
 				view.Rows = append(view.Rows, targetMigrationRow{
 					Migration: mig,
 					Status:    status,
+					Ledger:    ledger,
 				})
 			}
 			targets = append(targets, view)
@@ -502,11 +551,351 @@ func (h *UIHandler) TargetMigrations(w http.ResponseWriter, r *http.Request) {
 	data.Page = targetsPage{
 		Env:         env,
 		OnlyMissing: onlyMissing,
+		OnlyDrift:   onlyDrift,
 		Targets:     targets,
 	}
 	h.renderer.Render(w, data)
 }
 
+// SyncTargets reads the ledgers of all active targets in the selected env now.
+func (h *UIHandler) SyncTargets(w http.ResponseWriter, r *http.Request) {
+	user := mustUser(r)
+	if user == nil {
+		return
+	}
+	if user.ProjectID == nil {
+		h.setFlash(w, r, "error", "Select a project first.")
+		http.Redirect(w, r, "/ui/projects", http.StatusSeeOther)
+		return
+	}
+	env := strings.TrimSpace(r.FormValue("env"))
+	if env == "" {
+		env = "stg"
+	}
+	envFilter := env
+	if env == "all" {
+		envFilter = ""
+	}
+	back := "/ui/targets?env=" + url.QueryEscape(env)
+	sets, err := store.ListDBSets(r.Context(), h.pool, *user.ProjectID, envFilter)
+	if err != nil {
+		h.renderError(w, r, http.StatusInternalServerError, "Failed to load db sets.")
+		return
+	}
+	var ids []uuid.UUID
+	for _, set := range sets {
+		if !set.IsActive {
+			continue
+		}
+		tgts, err := store.ListDBTargetsBySet(r.Context(), h.pool, set.ID)
+		if err != nil {
+			h.renderError(w, r, http.StatusInternalServerError, "Failed to load db targets.")
+			return
+		}
+		for _, target := range tgts {
+			// Fan-out targets have no single ledger to sync.
+			if target.IsActive && !target.Fanout() {
+				ids = append(ids, target.ID)
+			}
+		}
+	}
+	var drifted, failed int
+	for _, res := range h.executor.SyncTargets(r.Context(), ids, &user.ID) {
+		switch {
+		case res == nil || res.Status == store.TargetSyncError:
+			failed++
+		case res.Status == store.TargetSyncDrift:
+			drifted++
+		}
+	}
+	kind := "success"
+	if drifted > 0 || failed > 0 {
+		kind = "error"
+	}
+	h.setFlash(w, r, kind, fmt.Sprintf("Synced %d target ledgers: %d with drift, %d failed.", len(ids), drifted, failed))
+	http.Redirect(w, r, back, http.StatusSeeOther)
+}
+
+// SyncTarget reads the ledger of one target now.
+func (h *UIHandler) SyncTarget(w http.ResponseWriter, r *http.Request) {
+	user := mustUser(r)
+	if user == nil {
+		return
+	}
+	if user.ProjectID == nil {
+		h.setFlash(w, r, "error", "Select a project first.")
+		http.Redirect(w, r, "/ui/projects", http.StatusSeeOther)
+		return
+	}
+	back := "/ui/targets?env=" + url.QueryEscape(r.FormValue("env"))
+	targetID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		h.setFlash(w, r, "error", "Invalid target id.")
+		http.Redirect(w, r, back, http.StatusSeeOther)
+		return
+	}
+	target, _, err := store.GetDBTarget(r.Context(), h.pool, targetID)
+	if err != nil {
+		h.renderError(w, r, http.StatusNotFound, "Target not found.")
+		return
+	}
+	set, err := store.GetDBSet(r.Context(), h.pool, target.DBSetID)
+	if err != nil || set.ProjectID != *user.ProjectID {
+		h.renderError(w, r, http.StatusNotFound, "Target not found.")
+		return
+	}
+	res, err := h.executor.SyncTarget(r.Context(), targetID, &user.ID)
+	switch {
+	case err != nil:
+		h.setFlash(w, r, "error", err.Error())
+	case res.Status == store.TargetSyncError:
+		h.setFlash(w, r, "error", "Ledger sync failed: "+*res.Error)
+	case res.Status == store.TargetSyncDrift:
+		h.setFlash(w, r, "error", fmt.Sprintf("Ledger synced: %d keys drifted.", res.DriftCount))
+	default:
+		h.setFlash(w, r, "success", "Ledger synced: in sync.")
+	}
+	http.Redirect(w, r, back, http.StatusSeeOther)
+}
+
+// ImportPreview shows what importing another tool's ledger table into the
+// target would do; with no source selected it only shows the source picker.
+func (h *UIHandler) ImportPreview(w http.ResponseWriter, r *http.Request) {
+	user := mustUser(r)
+	data, _ := h.baseData(w, r)
+	if user == nil {
+		return
+	}
+	target, set, ok := h.managedTarget(w, r, user)
+	if !ok {
+		return
+	}
+	page := targetImportPage{Target: *target, DBSet: *set, Sources: store.ImportSources, Source: r.URL.Query().Get("source")}
+	if page.Source != "" {
+		plan, err := h.executor.PreviewImport(r.Context(), target.ID, page.Source, nil)
+		if err != nil {
+			page.Error = err.Error()
+		} else {
+			page.Plan = plan
+			page.Importable = plan.Create + plan.Seed
+		}
+	}
+	data.Page = page
+	h.renderer.Render(w, data)
+}
+
+// ImportCommit commits the previewed import.
+func (h *UIHandler) ImportCommit(w http.ResponseWriter, r *http.Request) {
+	user := mustUser(r)
+	if user == nil {
+		return
+	}
+	target, _, ok := h.managedTarget(w, r, user)
+	if !ok {
+		return
+	}
+	source := r.FormValue("source")
+	plan, err := h.executor.CommitImport(r.Context(), target.ID, source, nil, r.FormValue("preview_hash"), user.ID)
+	if err != nil {
+		h.setFlash(w, r, "error", "Import failed: "+err.Error())
+		http.Redirect(w, r, "/ui/targets/"+target.ID.String()+"/import?source="+url.QueryEscape(source), http.StatusSeeOther)
+		return
+	}
+	h.setFlash(w, r, "success", fmt.Sprintf("Ledger imported: %d migrations created, %d ledger rows seeded.", plan.Create, plan.Create+plan.Seed))
+	http.Redirect(w, r, "/ui/db-sets/"+target.DBSetID.String(), http.StatusSeeOther)
+}
+
+// managedTarget loads the target of an import page or tunnel form for a
+// manager or admin.
+func (h *UIHandler) managedTarget(w http.ResponseWriter, r *http.Request, user *auth.User) (*store.DBTarget, *store.DBSet, bool) {
+	if user.Role != rbac.RoleManager && user.Role != rbac.RoleAdmin {
+		h.renderError(w, r, http.StatusForbidden, "Manager or admin role required.")
+		return nil, nil, false
+	}
+	if user.ProjectID == nil {
+		h.setFlash(w, r, "error", "Select a project first.")
+		http.Redirect(w, r, "/ui/projects", http.StatusSeeOther)
+		return nil, nil, false
+	}
+	targetID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		h.renderError(w, r, http.StatusBadRequest, "Invalid target id.")
+		return nil, nil, false
+	}
+	target, _, err := store.GetDBTarget(r.Context(), h.pool, targetID)
+	if err != nil {
+		h.renderError(w, r, http.StatusNotFound, "Target not found.")
+		return nil, nil, false
+	}
+	set, err := store.GetDBSet(r.Context(), h.pool, target.DBSetID)
+	if err != nil || set.ProjectID != *user.ProjectID {
+		h.renderError(w, r, http.StatusNotFound, "Target not found.")
+		return nil, nil, false
+	}
+	return target, set, true
+}
+
+// managedDBSet loads the db set of a tunnel form for a manager or admin.
+func (h *UIHandler) managedDBSet(w http.ResponseWriter, r *http.Request, user *auth.User) (*store.DBSet, bool) {
+	if user.Role != rbac.RoleManager && user.Role != rbac.RoleAdmin {
+		h.renderError(w, r, http.StatusForbidden, "Manager or admin role required.")
+		return nil, false
+	}
+	if user.ProjectID == nil {
+		h.setFlash(w, r, "error", "Select a project first.")
+		http.Redirect(w, r, "/ui/projects", http.StatusSeeOther)
+		return nil, false
+	}
+	setID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		h.renderError(w, r, http.StatusBadRequest, "Invalid db set id.")
+		return nil, false
+	}
+	set, err := store.GetDBSet(r.Context(), h.pool, setID)
+	if err != nil || set.ProjectID != *user.ProjectID {
+		h.renderError(w, r, http.StatusNotFound, "DB set not found.")
+		return nil, false
+	}
+	return set, true
+}
+
+func (h *UIHandler) SetDBSetSSH(w http.ResponseWriter, r *http.Request) {
+	user := mustUser(r)
+	if user == nil {
+		return
+	}
+	set, ok := h.managedDBSet(w, r, user)
+	if !ok {
+		return
+	}
+	input, err := sshInputFromForm(r)
+	if err == nil {
+		var tunnel *store.SSHTunnel
+		if tunnel, err = store.SetDBSetSSH(r.Context(), h.pool, h.secretKey, set.ID, input); err == nil {
+			_ = audit.LogEvent(r.Context(), h.pool, h.logger, audit.Event{
+				ActorID:    &user.ID,
+				Action:     "db_set_ssh_set",
+				EntityType: "db_set",
+				EntityID:   &set.ID,
+				Payload: map[string]any{
+					"bastion":  tunnel.String(),
+					"host_key": tunnel.HostKey,
+				},
+			})
+		}
+	}
+	if err != nil {
+		h.setFlash(w, r, "error", err.Error())
+	} else {
+		h.setFlash(w, r, "success", "SSH tunnel saved.")
+	}
+	http.Redirect(w, r, "/ui/db-sets/"+set.ID.String(), http.StatusSeeOther)
+}
+
+func (h *UIHandler) RemoveDBSetSSH(w http.ResponseWriter, r *http.Request) {
+	user := mustUser(r)
+	if user == nil {
+		return
+	}
+	set, ok := h.managedDBSet(w, r, user)
+	if !ok {
+		return
+	}
+	if err := store.RemoveDBSetSSH(r.Context(), h.pool, set.ID); err != nil {
+		h.setFlash(w, r, "error", "Failed to remove ssh tunnel.")
+		http.Redirect(w, r, "/ui/db-sets/"+set.ID.String(), http.StatusSeeOther)
+		return
+	}
+	_ = audit.LogEvent(r.Context(), h.pool, h.logger, audit.Event{
+		ActorID:    &user.ID,
+		Action:     "db_set_ssh_removed",
+		EntityType: "db_set",
+		EntityID:   &set.ID,
+	})
+	h.setFlash(w, r, "success", "SSH tunnel removed.")
+	http.Redirect(w, r, "/ui/db-sets/"+set.ID.String(), http.StatusSeeOther)
+}
+
+func (h *UIHandler) SetTargetSSH(w http.ResponseWriter, r *http.Request) {
+	user := mustUser(r)
+	if user == nil {
+		return
+	}
+	target, _, ok := h.managedTarget(w, r, user)
+	if !ok {
+		return
+	}
+	input, err := sshInputFromForm(r)
+	if err == nil {
+		var tunnel *store.SSHTunnel
+		if tunnel, err = store.SetDBTargetSSH(r.Context(), h.pool, h.secretKey, target.ID, input); err == nil {
+			_ = audit.LogEvent(r.Context(), h.pool, h.logger, audit.Event{
+				ActorID:    &user.ID,
+				Action:     "db_target_ssh_set",
+				EntityType: "db_target",
+				EntityID:   &target.ID,
+				Payload: map[string]any{
+					"db_set_id": target.DBSetID,
+					"bastion":   tunnel.String(),
+					"host_key":  tunnel.HostKey,
+				},
+			})
+		}
+	}
+	if err != nil {
+		h.setFlash(w, r, "error", err.Error())
+	} else {
+		h.setFlash(w, r, "success", "SSH tunnel saved.")
+	}
+	http.Redirect(w, r, "/ui/db-sets/"+target.DBSetID.String(), http.StatusSeeOther)
+}
+
+func (h *UIHandler) RemoveTargetSSH(w http.ResponseWriter, r *http.Request) {
+	user := mustUser(r)
+	if user == nil {
+		return
+	}
+	target, _, ok := h.managedTarget(w, r, user)
+	if !ok {
+		return
+	}
+	if err := store.RemoveDBTargetSSH(r.Context(), h.pool, target.ID); err != nil {
+		h.setFlash(w, r, "error", "Failed to remove ssh tunnel.")
+		http.Redirect(w, r, "/ui/db-sets/"+target.DBSetID.String(), http.StatusSeeOther)
+		return
+	}
+	_ = audit.LogEvent(r.Context(), h.pool, h.logger, audit.Event{
+		ActorID:    &user.ID,
+		Action:     "db_target_ssh_removed",
+		EntityType: "db_target",
+		EntityID:   &target.ID,
+		Payload: map[string]any{
+			"db_set_id": target.DBSetID,
+		},
+	})
+	h.setFlash(w, r, "success", "SSH tunnel removed; the target uses its db set's tunnel, if any.")
+	http.Redirect(w, r, "/ui/db-sets/"+target.DBSetID.String(), http.StatusSeeOther)
+}
+
+// sshInputFromForm reads the fields of the ssh_fields partial.
+func sshInputFromForm(r *http.Request) (store.SSHInput, error) {
+	input := store.SSHInput{
+		Host:     r.FormValue("ssh_host"),
+		User:     r.FormValue("ssh_user"),
+		HostKey:  r.FormValue("ssh_host_key"),
+		Key:      r.FormValue("ssh_private_key"),
+		Password: r.FormValue("ssh_password"),
+	}
+	if portStr := strings.TrimSpace(r.FormValue("ssh_port")); portStr != "" {
+		port, err := strconv.Atoi(portStr)
+		if err != nil {
+			return input, fmt.Errorf("ssh port must be a number")
+		}
+		input.Port = port
+	}
+	return input, nil
+}
+
 func (h *UIHandler) AddTarget(w http.ResponseWriter, r *http.Request) {
 	user := mustUser(r)
 	if user == nil {
@@ -538,6 +927,12 @@ func (h *UIHandler) AddTarget(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 	}
+	priority, err := parseOptionalIntPtr(r.FormValue("priority"))
+	if err != nil {
+		h.setFlash(w, r, "error", "Priority must be a number.")
+		http.Redirect(w, r, "/ui/db-sets/"+setID.String(), http.StatusSeeOther)
+		return
+	}
 
 	target, err := store.CreateDBTarget(r.Context(), h.pool, h.secretKey, store.CreateTargetInput{
 		DBSetID:  setID,
@@ -547,7 +942,16 @@ func (h *UIHandler) AddTarget(w http.ResponseWriter, r *http.Request) {
 		DBName:   r.FormValue("dbname"),
 		Username: r.FormValue("username"),
 		Password: r.FormValue("password"),
-		Options:  options,
+		TLS: store.TLSInput{
+			Mode:       r.FormValue("tls_mode"),
+			ServerName: r.FormValue("tls_server_name"),
+			CA:         r.FormValue("tls_ca"),
+			Cert:       r.FormValue("tls_cert"),
+			Key:        r.FormValue("tls_key"),
+		},
+		Options:       options,
+		Priority:      priority,
+		FanoutPattern: r.FormValue("fanout_pattern"),
 	})
 	if err != nil {
 		h.setFlash(w, r, "error", err.Error())
@@ -565,6 +969,9 @@ func (h *UIHandler) AddTarget(w http.ResponseWriter, r *http.Request) {
 			"engine":    target.Engine,
 			"host":      target.Host,
 			"port":      target.Port,
+			"priority":  target.Priority,
+			"tls":       target.TLS.String(),
+			"fanout":    target.FanoutPattern,
 		},
 	})
 	h.setFlash(w, r, "success", "DB target created.")
@@ -606,24 +1013,48 @@ func (h *UIHandler) EditTarget(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 	}
+	// The edit form posts the stored options back; unchanged options are left
+	// out, so they are not checked again.
+	var stored map[string]any
+	if options != nil && json.Unmarshal(target.Options, &stored) == nil && reflect.DeepEqual(options, stored) {
+		options = nil
+	}
 	var portPtr *int
 	if portStr := strings.TrimSpace(r.FormValue("port")); portStr != "" {
 		if p, err := strconv.Atoi(portStr); err == nil {
 			portPtr = &p
 		}
 	}
+	priority, err := parseOptionalIntPtr(r.FormValue("priority"))
+	if err != nil {
+		h.setFlash(w, r, "error", "Priority must be a number.")
+		http.Redirect(w, r, "/ui/db-sets/"+target.DBSetID.String(), http.StatusSeeOther)
+		return
+	}
 	host := strings.TrimSpace(r.FormValue("host"))
 	dbname := strings.TrimSpace(r.FormValue("dbname"))
 	username := strings.TrimSpace(r.FormValue("username"))
 	password := r.FormValue("password")
+	// The edit form always posts the server name and fan-out pattern, so
+	// blank clears them.
+	tlsServerName := strings.TrimSpace(r.FormValue("tls_server_name"))
+	fanoutPattern := strings.TrimSpace(r.FormValue("fanout_pattern"))
 
 	updated, err := store.UpdateDBTarget(r.Context(), h.pool, h.secretKey, targetID, store.UpdateTargetInput{
-		Host:     strPtr(host),
-		Port:     portPtr,
-		DBName:   strPtr(dbname),
-		Username: strPtr(username),
-		Password: strPtr(password),
-		Options:  options,
+		Host:          strPtr(host),
+		Port:          portPtr,
+		DBName:        strPtr(dbname),
+		Username:      strPtr(username),
+		Password:      strPtr(password),
+		TLSMode:       strPtr(r.FormValue("tls_mode")),
+		TLSServerName: &tlsServerName,
+		TLSCA:         strPtr(r.FormValue("tls_ca")),
+		TLSCert:       strPtr(r.FormValue("tls_cert")),
+		TLSKey:        strPtr(r.FormValue("tls_key")),
+		TLSClearFiles: r.FormValue("tls_clear_files") == "on",
+		Options:       options,
+		Priority:      priority,
+		FanoutPattern: &fanoutPattern,
 	})
 	if err != nil {
 		h.setFlash(w, r, "error", err.Error())
@@ -640,6 +1071,9 @@ func (h *UIHandler) EditTarget(w http.ResponseWriter, r *http.Request) {
 			"engine":    updated.Engine,
 			"host":      updated.Host,
 			"port":      updated.Port,
+			"priority":  updated.Priority,
+			"tls":       updated.TLS.String(),
+			"fanout":    updated.FanoutPattern,
 		},
 	})
 	h.setFlash(w, r, "success", "DB target updated.")
@@ -885,7 +1319,17 @@ func (h *UIHandler) MigrationCreate(w http.ResponseWriter, r *http.Request) {
 		SQLUp:           r.FormValue("sql_up"),
 		SQLDown:         sqlDownPtr,
 		TransactionMode: r.FormValue("transaction_mode"),
-		CreatedBy:       user.ID,
+		FailurePolicy:   r.FormValue("failure_policy"),
+
+		AdvisoryLockTimeout: r.FormValue("advisory_lock_timeout"),
+		LockTimeout:         r.FormValue("lock_timeout"),
+		StatementTimeout:    r.FormValue("statement_timeout"),
+		CreatedBy:           user.ID,
+
+		PrecheckSQL:     r.FormValue("precheck_sql"),
+		PrecheckExpect:  r.FormValue("precheck_expect"),
+		PostcheckSQL:    r.FormValue("postcheck_sql"),
+		PostcheckExpect: r.FormValue("postcheck_expect"),
 	})
 	if err != nil {
 		h.setFlash(w, r, "error", err.Error())
@@ -971,6 +1415,16 @@ func (h *UIHandler) MigrationUpdate(w http.ResponseWriter, r *http.Request) {
 		SQLUp:           stringPtr(r.FormValue("sql_up")),
 		SQLDown:         sqlDownPtr,
 		TransactionMode: stringPtr(r.FormValue("transaction_mode")),
+		FailurePolicy:   stringPtr(r.FormValue("failure_policy")),
+
+		AdvisoryLockTimeout: stringPtr(r.FormValue("advisory_lock_timeout")),
+		LockTimeout:         stringPtr(r.FormValue("lock_timeout")),
+		StatementTimeout:    stringPtr(r.FormValue("statement_timeout")),
+
+		PrecheckSQL:     stringPtr(r.FormValue("precheck_sql")),
+		PrecheckExpect:  stringPtr(r.FormValue("precheck_expect")),
+		PostcheckSQL:    stringPtr(r.FormValue("postcheck_sql")),
+		PostcheckExpect: stringPtr(r.FormValue("postcheck_expect")),
 	})
 	if err != nil {
 		h.setFlash(w, r, "error", err.Error())
@@ -1011,9 +1465,12 @@ func (h *UIHandler) RequestRollback(w http.ResponseWriter, r *http.Request) {
 	h.requestRun(w, r, "rollback")
 }
 
-func (h *UIHandler) Approvals(w http.ResponseWriter, r *http.Request) {
+func (h *UIHandler) RequestBaseline(w http.ResponseWriter, r *http.Request) {
+	h.requestRun(w, r, "baseline")
+}
+
+func (h *UIHandler) RequestDryRun(w http.ResponseWriter, r *http.Request) {
 	user := mustUser(r)
-	data, _ := h.baseData(w, r)
 	if user == nil {
 		return
 	}
@@ -1022,24 +1479,98 @@ func (h *UIHandler) Approvals(w http.ResponseWriter, r *http.Request) {
 		http.Redirect(w, r, "/ui/projects", http.StatusSeeOther)
 		return
 	}
-	if user.Role != rbac.RoleManager && user.Role != rbac.RoleAdmin {
-		h.renderError(w, r, http.StatusForbidden, "Manager or admin role required.")
+	migrationID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		h.setFlash(w, r, "error", "Invalid migration id.")
+		http.Redirect(w, r, "/ui/migrations", http.StatusSeeOther)
 		return
 	}
-	env := r.URL.Query().Get("env")
-	runs, err := store.ListPendingApprovals(r.Context(), h.pool, *user.ProjectID, env)
+	dbSetID, err := uuid.Parse(r.FormValue("db_set_id"))
 	if err != nil {
-		h.renderError(w, r, http.StatusInternalServerError, "Failed to list approvals.")
+		h.setFlash(w, r, "error", "Invalid db set id.")
+		http.Redirect(w, r, "/ui/migrations/"+migrationID.String(), http.StatusSeeOther)
 		return
 	}
-	data.Page = approvalsPage{Env: env, Runs: runs}
-	h.renderer.Render(w, data)
-}
-
-func (h *UIHandler) Runs(w http.ResponseWriter, r *http.Request) {
-	user := mustUser(r)
-	data, _ := h.baseData(w, r)
-	if user == nil {
+	maxParallel, err := parseOptionalInt(r.FormValue("max_parallel"))
+	if err != nil {
+		h.setFlash(w, r, "error", store.ErrMaxParallelInvalid.Error())
+		http.Redirect(w, r, "/ui/migrations/"+migrationID.String(), http.StatusSeeOther)
+		return
+	}
+	run, err := h.executor.RequestDryRun(r.Context(), store.RequestRunInput{
+		ProjectID:   *user.ProjectID,
+		MigrationID: migrationID,
+		DBSetID:     dbSetID,
+		Env:         r.FormValue("env"),
+		RequestedBy: user.ID,
+		MaxParallel: maxParallel,
+		DryRunOf:    r.FormValue("dry_run_of"),
+	})
+	if err != nil {
+		h.setFlash(w, r, "error", err.Error())
+		http.Redirect(w, r, "/ui/migrations/"+migrationID.String(), http.StatusSeeOther)
+		return
+	}
+	_ = audit.LogEvent(r.Context(), h.pool, h.logger, audit.Event{
+		ActorID:    &user.ID,
+		Action:     "dry_run_queued",
+		EntityType: "run",
+		EntityID:   &run.ID,
+		Payload: map[string]any{
+			"migration_id": run.MigrationID,
+			"env":          run.Env,
+			"db_set_id":    run.DBSetID,
+			"dry_run_of":   run.DryRunOf,
+			"max_parallel": run.MaxParallel,
+		},
+	})
+	h.setFlash(w, r, "success", "Dry run queued.")
+	http.Redirect(w, r, "/ui/runs/"+run.ID.String(), http.StatusSeeOther)
+}
+
+func (h *UIHandler) Approvals(w http.ResponseWriter, r *http.Request) {
+	user := mustUser(r)
+	data, _ := h.baseData(w, r)
+	if user == nil {
+		return
+	}
+	if user.ProjectID == nil {
+		h.setFlash(w, r, "error", "Select a project first.")
+		http.Redirect(w, r, "/ui/projects", http.StatusSeeOther)
+		return
+	}
+	if user.Role != rbac.RoleManager && user.Role != rbac.RoleAdmin {
+		h.renderError(w, r, http.StatusForbidden, "Manager or admin role required.")
+		return
+	}
+	env := r.URL.Query().Get("env")
+	runs, err := store.ListPendingApprovals(r.Context(), h.pool, *user.ProjectID, env)
+	if err != nil {
+		h.renderError(w, r, http.StatusInternalServerError, "Failed to list approvals.")
+		return
+	}
+	runIDs := make([]uuid.UUID, 0, len(runs))
+	for _, run := range runs {
+		runIDs = append(runIDs, run.ID)
+	}
+	dryRuns, err := store.LatestDryRuns(r.Context(), h.pool, *user.ProjectID, runIDs)
+	if err != nil {
+		h.renderError(w, r, http.StatusInternalServerError, "Failed to load dry run results.")
+		return
+	}
+	checks, err := store.ListRunChecks(r.Context(), h.pool, *user.ProjectID, runIDs)
+	if err != nil {
+		h.renderError(w, r, http.StatusInternalServerError, "Failed to load migration checks.")
+		return
+	}
+	data.Page = approvalsPage{Env: env, Runs: runs, DryRuns: dryRuns, Checks: checks}
+	h.renderer.Render(w, data)
+}
+
+func (h *UIHandler) Runs(w http.ResponseWriter, r *http.Request) {
+	user := mustUser(r)
+	data, _ := h.baseData(w, r)
+	if user == nil {
 		return
 	}
 	if user.ProjectID == nil {
@@ -1145,31 +1676,263 @@ func (h *UIHandler) ExecuteRun(w http.ResponseWriter, r *http.Request) {
 		http.Redirect(w, r, "/ui/runs", http.StatusSeeOther)
 		return
 	}
-	run, err := h.callRunExecute(r, *user.ProjectID, runID, user.ID)
+	_ = r.ParseForm()
+	run, err := h.executor.QueueRun(r.Context(), *user.ProjectID, runID, user.ID, r.PostForm["accept_warnings"])
+	if err != nil {
+		h.setFlash(w, r, "error", err.Error())
+		http.Redirect(w, r, "/ui/runs/"+runID.String(), http.StatusSeeOther)
+		return
+	}
+	_ = audit.LogEvent(r.Context(), h.pool, h.logger, audit.Event{
+		ActorID:    &user.ID,
+		Action:     "run_queued",
+		EntityType: "run",
+		EntityID:   &run.ID,
+		Payload: map[string]any{
+			"status":            run.Status,
+			"accepted_warnings": run.AcceptedWarnings,
+		},
+	})
+	h.setFlash(w, r, "success", "Run queued for execution.")
+	http.Redirect(w, r, "/ui/runs/"+runID.String(), http.StatusSeeOther)
+}
+
+func (h *UIHandler) CancelRun(w http.ResponseWriter, r *http.Request) {
+	user := mustUser(r)
+	if user == nil {
+		return
+	}
+	if user.ProjectID == nil {
+		h.setFlash(w, r, "error", "Select a project first.")
+		http.Redirect(w, r, "/ui/projects", http.StatusSeeOther)
+		return
+	}
+	runID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		h.setFlash(w, r, "error", "Invalid run id.")
+		http.Redirect(w, r, "/ui/runs", http.StatusSeeOther)
+		return
+	}
+	reason := strings.TrimSpace(r.FormValue("reason"))
+	run, err := h.executor.CancelRun(r.Context(), *user.ProjectID, runID, user.ID, reason)
+	if err != nil {
+		h.setFlash(w, r, "error", err.Error())
+		http.Redirect(w, r, "/ui/runs/"+runID.String(), http.StatusSeeOther)
+		return
+	}
+	action := "run_canceled"
+	message := "Run canceled."
+	if run.Status == "running" {
+		action = "run_cancel_requested"
+		message = "Cancel requested; in-flight statements are being interrupted."
+	}
+	_ = audit.LogEvent(r.Context(), h.pool, h.logger, audit.Event{
+		ActorID:    &user.ID,
+		Action:     action,
+		EntityType: "run",
+		EntityID:   &run.ID,
+		Payload: map[string]any{
+			"reason": reason,
+			"status": run.Status,
+		},
+	})
+	h.setFlash(w, r, "success", message)
+	http.Redirect(w, r, "/ui/runs/"+runID.String(), http.StatusSeeOther)
+}
+
+func (h *UIHandler) RetryRun(w http.ResponseWriter, r *http.Request) {
+	user := mustUser(r)
+	if user == nil {
+		return
+	}
+	if user.ProjectID == nil {
+		h.setFlash(w, r, "error", "Select a project first.")
+		http.Redirect(w, r, "/ui/projects", http.StatusSeeOther)
+		return
+	}
+	runID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		h.setFlash(w, r, "error", "Invalid run id.")
+		http.Redirect(w, r, "/ui/runs", http.StatusSeeOther)
+		return
+	}
+	_ = r.ParseForm()
+	run, err := h.executor.RetryRun(r.Context(), *user.ProjectID, runID, user.ID, r.PostForm["accept_warnings"])
+	if err != nil {
+		h.setFlash(w, r, "error", err.Error())
+		http.Redirect(w, r, "/ui/runs/"+runID.String(), http.StatusSeeOther)
+		return
+	}
+	_ = audit.LogEvent(r.Context(), h.pool, h.logger, audit.Event{
+		ActorID:    &user.ID,
+		Action:     "run_retry_queued",
+		EntityType: "run",
+		EntityID:   &run.ID,
+		Payload:    retryAuditPayload(run),
+	})
+	h.setFlash(w, r, "success", "Failed and canceled items queued for retry.")
+	http.Redirect(w, r, "/ui/runs/"+runID.String(), http.StatusSeeOther)
+}
+
+func (h *UIHandler) ContinueRun(w http.ResponseWriter, r *http.Request) {
+	user := mustUser(r)
+	if user == nil {
+		return
+	}
+	if user.ProjectID == nil {
+		h.setFlash(w, r, "error", "Select a project first.")
+		http.Redirect(w, r, "/ui/projects", http.StatusSeeOther)
+		return
+	}
+	runID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		h.setFlash(w, r, "error", "Invalid run id.")
+		http.Redirect(w, r, "/ui/runs", http.StatusSeeOther)
+		return
+	}
+	run, err := h.executor.ContinueRun(r.Context(), *user.ProjectID, runID)
 	if err != nil {
-		_ = audit.LogEvent(r.Context(), h.pool, h.logger, audit.Event{
-			ActorID:    &user.ID,
-			Action:     "run_execute_failed",
-			EntityType: "run",
-			EntityID:   &runID,
-			Payload: map[string]any{
-				"error": err.Error(),
-			},
-		})
 		h.setFlash(w, r, "error", err.Error())
 		http.Redirect(w, r, "/ui/runs/"+runID.String(), http.StatusSeeOther)
 		return
 	}
 	_ = audit.LogEvent(r.Context(), h.pool, h.logger, audit.Event{
 		ActorID:    &user.ID,
-		Action:     "run_executed",
+		Action:     "run_continued",
 		EntityType: "run",
 		EntityID:   &run.ID,
 		Payload: map[string]any{
 			"status": run.Status,
 		},
 	})
-	h.setFlash(w, r, "success", "Run execution started.")
+	h.setFlash(w, r, "success", "Run continued; the next wave is queued.")
+	http.Redirect(w, r, "/ui/runs/"+runID.String(), http.StatusSeeOther)
+}
+
+// ScheduleRun schedules an approved run at a UTC time from the form, or in the
+// next maintenance window of its db set when maintenance_window is set.
+func (h *UIHandler) ScheduleRun(w http.ResponseWriter, r *http.Request) {
+	user := mustUser(r)
+	if user == nil {
+		return
+	}
+	if user.ProjectID == nil {
+		h.setFlash(w, r, "error", "Select a project first.")
+		http.Redirect(w, r, "/ui/projects", http.StatusSeeOther)
+		return
+	}
+	runID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		h.setFlash(w, r, "error", "Invalid run id.")
+		http.Redirect(w, r, "/ui/runs", http.StatusSeeOther)
+		return
+	}
+	_ = r.ParseForm()
+	input := store.ScheduleRunInput{
+		ProjectID:      *user.ProjectID,
+		RunID:          runID,
+		ActorID:        user.ID,
+		UseWindow:      r.PostForm.Get("maintenance_window") != "",
+		AcceptWarnings: r.PostForm["accept_warnings"],
+	}
+	if raw := strings.TrimSpace(r.PostForm.Get("scheduled_for")); raw != "" && !input.UseWindow {
+		at, err := time.ParseInLocation("2006-01-02T15:04", raw, time.UTC)
+		if err != nil {
+			h.setFlash(w, r, "error", "Scheduled time must look like 2006-01-02T15:04 (UTC).")
+			http.Redirect(w, r, "/ui/runs/"+runID.String(), http.StatusSeeOther)
+			return
+		}
+		input.ScheduledFor = &at
+	}
+	run, err := store.ScheduleRun(r.Context(), h.pool, input)
+	if err != nil {
+		h.setFlash(w, r, "error", err.Error())
+		http.Redirect(w, r, "/ui/runs/"+runID.String(), http.StatusSeeOther)
+		return
+	}
+	_ = audit.LogEvent(r.Context(), h.pool, h.logger, audit.Event{
+		ActorID:    &user.ID,
+		Action:     "run_scheduled",
+		EntityType: "run",
+		EntityID:   &run.ID,
+		Payload: map[string]any{
+			"scheduled_for":   run.ScheduledFor,
+			"scheduled_until": run.ScheduledUntil,
+		},
+	})
+	h.setFlash(w, r, "success", "Run scheduled for "+run.ScheduledFor.Format("2006-01-02 15:04 MST")+".")
+	http.Redirect(w, r, "/ui/runs/"+runID.String(), http.StatusSeeOther)
+}
+
+func (h *UIHandler) UnscheduleRun(w http.ResponseWriter, r *http.Request) {
+	user := mustUser(r)
+	if user == nil {
+		return
+	}
+	if user.ProjectID == nil {
+		h.setFlash(w, r, "error", "Select a project first.")
+		http.Redirect(w, r, "/ui/projects", http.StatusSeeOther)
+		return
+	}
+	runID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		h.setFlash(w, r, "error", "Invalid run id.")
+		http.Redirect(w, r, "/ui/runs", http.StatusSeeOther)
+		return
+	}
+	run, err := store.UnscheduleRun(r.Context(), h.pool, *user.ProjectID, runID)
+	if err != nil {
+		h.setFlash(w, r, "error", err.Error())
+		http.Redirect(w, r, "/ui/runs/"+runID.String(), http.StatusSeeOther)
+		return
+	}
+	_ = audit.LogEvent(r.Context(), h.pool, h.logger, audit.Event{
+		ActorID:    &user.ID,
+		Action:     "run_unscheduled",
+		EntityType: "run",
+		EntityID:   &run.ID,
+	})
+	h.setFlash(w, r, "success", "Schedule removed; the run stays approved.")
+	http.Redirect(w, r, "/ui/runs/"+runID.String(), http.StatusSeeOther)
+}
+
+func (h *UIHandler) PreflightRun(w http.ResponseWriter, r *http.Request) {
+	user := mustUser(r)
+	if user == nil {
+		return
+	}
+	if user.ProjectID == nil {
+		h.setFlash(w, r, "error", "Select a project first.")
+		http.Redirect(w, r, "/ui/projects", http.StatusSeeOther)
+		return
+	}
+	runID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		h.setFlash(w, r, "error", "Invalid run id.")
+		http.Redirect(w, r, "/ui/runs", http.StatusSeeOther)
+		return
+	}
+	report, err := h.executor.Preflight(r.Context(), *user.ProjectID, runID)
+	if err != nil {
+		h.setFlash(w, r, "error", err.Error())
+		http.Redirect(w, r, "/ui/runs/"+runID.String(), http.StatusSeeOther)
+		return
+	}
+	_ = audit.LogEvent(r.Context(), h.pool, h.logger, audit.Event{
+		ActorID:    &user.ID,
+		Action:     "run_preflight",
+		EntityType: "run",
+		EntityID:   &runID,
+		Payload: map[string]any{
+			"status":   report.Status,
+			"warnings": report.Warnings,
+		},
+	})
+	kind := "success"
+	if report.Status != store.PreflightPass {
+		kind = "error"
+	}
+	h.setFlash(w, r, kind, "Pre-flight finished: "+report.Status+".")
 	http.Redirect(w, r, "/ui/runs/"+runID.String(), http.StatusSeeOther)
 }
 
@@ -1283,13 +2046,25 @@ func (h *UIHandler) requestRun(w http.ResponseWriter, r *http.Request, runType s
 		http.Redirect(w, r, "/ui/migrations/"+migrationID.String(), http.StatusSeeOther)
 		return
 	}
-	run, err := store.RequestRun(r.Context(), h.pool, store.RequestRunInput{
+	maxParallel, err := parseOptionalInt(r.FormValue("max_parallel"))
+	if err != nil {
+		h.setFlash(w, r, "error", store.ErrMaxParallelInvalid.Error())
+		http.Redirect(w, r, "/ui/migrations/"+migrationID.String(), http.StatusSeeOther)
+		return
+	}
+	run, err := h.executor.RequestRun(r.Context(), store.RequestRunInput{
 		ProjectID:   *user.ProjectID,
 		MigrationID: migrationID,
 		DBSetID:     dbSetID,
 		Env:         env,
 		RequestedBy: user.ID,
 		RunType:     runType,
+		MaxParallel: maxParallel,
+		WavePlan:    r.FormValue("wave_plan"),
+		WaveGate:    r.FormValue("wave_gate"),
+
+		VerifySQL:    r.FormValue("verify_sql"),
+		VerifyExpect: r.FormValue("verify_expect"),
 	})
 	if err != nil {
 		h.setFlash(w, r, "error", err.Error())
@@ -1297,19 +2072,29 @@ func (h *UIHandler) requestRun(w http.ResponseWriter, r *http.Request, runType s
 		return
 	}
 	action := "run_requested"
-	if runType == "rollback" {
+	payload := map[string]any{
+		"migration_id":   run.MigrationID,
+		"env":            run.Env,
+		"db_set_id":      run.DBSetID,
+		"max_parallel":   run.MaxParallel,
+		"failure_policy": run.FailurePolicy,
+		"wave_plan":      run.WavePlan,
+		"wave_gate":      run.WaveGate,
+	}
+	switch runType {
+	case "rollback":
 		action = "rollback_requested"
+	case "baseline":
+		action = "baseline_requested"
+		payload["verify_sql"] = run.VerifySQL
+		payload["verify_expect"] = run.VerifyExpect
 	}
 	_ = audit.LogEvent(r.Context(), h.pool, h.logger, audit.Event{
 		ActorID:    &user.ID,
 		Action:     action,
 		EntityType: "run",
 		EntityID:   &run.ID,
-		Payload: map[string]any{
-			"migration_id": run.MigrationID,
-			"env":          run.Env,
-			"db_set_id":    run.DBSetID,
-		},
+		Payload:    payload,
 	})
 	h.setFlash(w, r, "success", "Approval requested.")
 	http.Redirect(w, r, "/ui/migrations/"+migrationID.String(), http.StatusSeeOther)
@@ -1345,7 +2130,7 @@ func (h *UIHandler) runDecision(w http.ResponseWriter, r *http.Request, decision
 	}
 	var run *store.Run
 	if decision == "approved" {
-		run, err = store.ApproveRun(r.Context(), h.pool, input)
+		run, err = h.executor.ApproveRun(r.Context(), input)
 	} else {
 		run, err = store.DenyRun(r.Context(), h.pool, input)
 	}
@@ -1359,28 +2144,12 @@ func (h *UIHandler) runDecision(w http.ResponseWriter, r *http.Request, decision
 		Action:     "run_" + decision,
 		EntityType: "run",
 		EntityID:   &run.ID,
-		Payload: map[string]any{
-			"migration_id": run.MigrationID,
-			"env":          run.Env,
-			"db_set_id":    run.DBSetID,
-			"comment":      comment,
-		},
+		Payload:    decisionAuditPayload(r.Context(), h.pool, run, decision, comment),
 	})
 	h.setFlash(w, r, "success", "Run "+decision+".")
 	http.Redirect(w, r, "/ui/approvals", http.StatusSeeOther)
 }
 
-func (h *UIHandler) callRunExecute(r *http.Request, projectID uuid.UUID, runID uuid.UUID, actorID uuid.UUID) (*store.RunWithItems, error) {
-	run, err := store.GetRunWithItems(r.Context(), h.pool, projectID, runID)
-	if err != nil {
-		return nil, err
-	}
-	if run.Status != "approved" {
-		return nil, errors.New("run is not approved")
-	}
-	return h.executor.ExecuteRun(r.Context(), projectID, runID, actorID)
-}
-
 func mustUser(r *http.Request) *auth.User {
 	user, _ := auth.UserFromContext(r.Context())
 	return user
@@ -1396,6 +2165,8 @@ func templateNameFromPath(path string) string {
 		return "projects"
 	case path == "/ui/targets":
 		return "targets"
+	case strings.HasPrefix(path, "/ui/targets/") && strings.HasSuffix(path, "/import"):
+		return "target_import"
 	case path == "/ui/users":
 		return "users"
 	case strings.HasPrefix(path, "/ui/db-sets/") && path != "/ui/db-sets":
@@ -1444,9 +2215,12 @@ func deriveEnvStatuses(m store.Migration, runs map[string]store.Run) map[string]
 		case "awaiting_approval":
 			label = "awaiting_approve"
 		case "executed":
-			if run.RunType == "rollback" {
+			switch run.RunType {
+			case "rollback":
 				label = "rollbacked"
-			} else {
+			case "baseline":
+				label = "baselined"
+			default:
 				label = "executed"
 			}
 		}
@@ -1465,6 +2239,28 @@ func (r migrationRow) HasPendingApproval() bool {
 	return false
 }
 
+// parseOptionalInt returns 0 for an empty form value.
+func parseOptionalInt(s string) (int, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+	return strconv.Atoi(s)
+}
+
+// parseOptionalIntPtr returns nil for an empty form value.
+func parseOptionalIntPtr(s string) (*int, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return nil, err
+	}
+	return &n, nil
+}
+
 func stringPtr(s string) *string {
 	return &s
 }
@@ -1485,16 +2281,10 @@ func coalesceLog(log *string) string {
 }
 
 func maskedDSN(target store.DBTarget) string {
-	engine := strings.ToLower(target.Engine)
-	host := target.Host
-	port := target.Port
-	db := target.DBName
-	switch engine {
-	case "mysql":
-		return fmt.Sprintf("mysql://***:***@%s:%d/%s", host, port, db)
-	default:
-		return fmt.Sprintf("postgres://***:***@%s:%d/%s", host, port, db)
+	if target.Username == "" {
+		return fmt.Sprintf("%s://%s", strings.ToLower(target.Engine), target.Address())
 	}
+	return fmt.Sprintf("%s://***:***@%s", strings.ToLower(target.Engine), target.Address())
 }
 
 func compareStatusFromLatest(item store.LatestRunItem) compareStatus {
@@ -1511,6 +2301,10 @@ func compareStatusFromLatest(item store.LatestRunItem) compareStatus {
 			status.Label = "rollbacked"
 			return status
 		}
+		if item.RunType == "baseline" && item.ItemStatus == "executed" {
+			status.Label = "baselined"
+			return status
+		}
 		if item.ItemStatus == "executed" || item.ItemStatus == "skipped" {
 			status.Label = "applied"
 			return status
@@ -1587,6 +2381,10 @@ type dashboardPage struct {
 	FailedCount  int
 	RecentRuns   []store.RunSummary
 	NeedsProject bool
+	// Scheduled lists upcoming scheduled runs and recently skipped schedules.
+	Scheduled []store.RunSummary
+	// Drift lists targets whose latest ledger sync found drift or failed.
+	Drift []store.TargetDrift
 }
 
 type projectsPage struct {
@@ -1628,14 +2426,26 @@ type dbSetsPage struct {
 }
 
 type dbSetDetailPage struct {
-	DBSet   store.DBSet
-	Targets []store.DBTarget
-	IsAdmin bool
+	DBSet     store.DBSet
+	Targets   []store.DBTarget
+	IsAdmin   bool
+	IsManager bool
+}
+
+type targetImportPage struct {
+	Target     store.DBTarget
+	DBSet      store.DBSet
+	Sources    []string
+	Source     string
+	Plan       *store.ImportPlan
+	Importable int
+	Error      string
 }
 
 type targetsPage struct {
 	Env         string
 	OnlyMissing bool
+	OnlyDrift   bool
 	Targets     []targetMigrationView
 }
 
@@ -1671,6 +2481,10 @@ type migrationDetailPage struct {
 type approvalsPage struct {
 	Env  string
 	Runs []store.RunSummary
+	// DryRuns holds the latest matching dry run per pending run id.
+	DryRuns map[uuid.UUID]*store.DryRunResult
+	// Checks holds the precheck/postcheck of the migration per pending run id.
+	Checks map[uuid.UUID][]store.Check
 }
 
 type runsPage struct {
@@ -1704,11 +2518,18 @@ type targetMigrationView struct {
 	RunningCount    int
 	FailedCount     int
 	Rows            []targetMigrationRow
+
+	// Sync is the latest ledger sync, nil before the first; Unknown lists the
+	// ledger keys the project does not have.
+	Sync    *store.TargetSync
+	Unknown []store.DriftEntry
 }
 
 type targetMigrationRow struct {
 	Migration store.Migration
 	Status    compareStatus
+	// Ledger is the key as found in the target ledger by the latest sync.
+	Ledger *store.DriftEntry
 }
 
 type compareStatus struct {
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"db_inner_migrator_syncer/internal/auth"
+	"db_inner_migrator_syncer/internal/engine"
 	"db_inner_migrator_syncer/internal/store"
 	"db_inner_migrator_syncer/web"
 )
@@ -42,7 +43,11 @@ func NewTemplateRenderer() *TemplateRenderer {
 		"formatDate": func(t time.Time) string {
 			return t.Format("2006-01-02")
 		},
-		"eq": func(a, b any) bool { return a == b },
+		"eq":          func(a, b any) bool { return a == b },
+		"coalesceLog": coalesceLog,
+		"engines":     engine.Names,
+		"tlsModes":    func() []string { return engine.TLSModes },
+		"optionKeys":  store.TargetOptionKeys,
 		"hasRole": func(user *auth.User, role string) bool {
 			if user == nil {
 				return false
@@ -0,0 +1,190 @@
+package sqlscript
+
+import (
+	"strings"
+)
+
+// TransactionBlocker reports why a statement cannot run atomically inside a
+// transaction on the given engine, or "" when it can. On Postgres these are
+// statements rejected inside a transaction block; on MySQL, statements that
+// commit the open transaction implicitly (DDL, account management, locking);
+// on SQLite, statements that fail or do nothing inside a transaction.
+// Statements that manage transactions themselves are reported on all engines.
+func TransactionBlocker(engine string, sql string) string {
+	dialect := strings.ToLower(strings.TrimSpace(engine))
+	w := leadingWords(sql, dialect == "mysql", 8)
+	if len(w) == 0 {
+		return ""
+	}
+	if reason := transactionControl(w); reason != "" {
+		return reason
+	}
+	switch dialect {
+	case "mysql":
+		return mysqlImplicitCommit(w)
+	case "sqlite":
+		return sqliteNonTransactional(w)
+	}
+	return postgresNonTransactional(w)
+}
+
+// FirstTransactionBlocker returns the first statement that TransactionBlocker
+// reports, with the reason.
+func FirstTransactionBlocker(engine string, stmts []Statement) (Statement, string, bool) {
+	for _, stmt := range stmts {
+		if reason := TransactionBlocker(engine, stmt.SQL); reason != "" {
+			return stmt, reason, true
+		}
+	}
+	return Statement{}, "", false
+}
+
+// IsQuery reports whether a statement starts like a query (SELECT, WITH,
+// VALUES, TABLE or SHOW) rather than a write or DDL statement.
+func IsQuery(engine string, sql string) bool {
+	w := leadingWords(sql, strings.EqualFold(strings.TrimSpace(engine), "mysql"), 1)
+	if len(w) == 0 {
+		return false
+	}
+	switch w[0] {
+	case "SELECT", "WITH", "VALUES", "TABLE", "SHOW":
+		return true
+	}
+	return false
+}
+
+func transactionControl(w []string) string {
+	switch {
+	case w[0] == "BEGIN" || w[0] == "COMMIT" || w[0] == "ROLLBACK" || w[0] == "END":
+		return w[0] + " manages the transaction itself"
+	case w[0] == "START" && at(w, 1) == "TRANSACTION":
+		return "START TRANSACTION manages the transaction itself"
+	}
+	return ""
+}
+
+func postgresNonTransactional(w []string) string {
+	const suffix = " cannot run inside a transaction block"
+	switch w[0] {
+	case "VACUUM":
+		return "VACUUM" + suffix
+	case "REINDEX":
+		if contains(w, "CONCURRENTLY") {
+			return "REINDEX CONCURRENTLY" + suffix
+		}
+		if contains(w, "DATABASE") || contains(w, "SYSTEM") {
+			return "REINDEX DATABASE/SYSTEM" + suffix
+		}
+	case "CREATE":
+		i := 1
+		if at(w, i) == "UNIQUE" {
+			i++
+		}
+		if at(w, i) == "INDEX" && at(w, i+1) == "CONCURRENTLY" {
+			return "CREATE INDEX CONCURRENTLY" + suffix
+		}
+		switch at(w, 1) {
+		case "DATABASE", "TABLESPACE", "SUBSCRIPTION":
+			return "CREATE " + w[1] + suffix
+		}
+	case "DROP":
+		if at(w, 1) == "INDEX" && at(w, 2) == "CONCURRENTLY" {
+			return "DROP INDEX CONCURRENTLY" + suffix
+		}
+		switch at(w, 1) {
+		case "DATABASE", "TABLESPACE", "SUBSCRIPTION":
+			return "DROP " + w[1] + suffix
+		}
+	case "ALTER":
+		if at(w, 1) == "SYSTEM" {
+			return "ALTER SYSTEM" + suffix
+		}
+		if at(w, 1) == "TYPE" && containsSeq(w, "ADD", "VALUE") {
+			return "ALTER TYPE ... ADD VALUE" + suffix
+		}
+	case "DISCARD":
+		if at(w, 1) == "ALL" {
+			return "DISCARD ALL" + suffix
+		}
+	}
+	return ""
+}
+
+func sqliteNonTransactional(w []string) string {
+	switch w[0] {
+	case "VACUUM":
+		return "VACUUM cannot run inside a transaction"
+	case "ATTACH", "DETACH":
+		return w[0] + " cannot run inside a transaction"
+	case "PRAGMA":
+		// PRAGMA [schema.]name; the schema is a separate word.
+		switch {
+		case at(w, 1) == "FOREIGN_KEYS" || at(w, 2) == "FOREIGN_KEYS":
+			return "PRAGMA foreign_keys has no effect inside a transaction"
+		case at(w, 1) == "JOURNAL_MODE" || at(w, 2) == "JOURNAL_MODE":
+			return "PRAGMA journal_mode cannot switch to or from WAL inside a transaction"
+		}
+	}
+	return ""
+}
+
+// mysqlDDLObjects name the object in reasons such as "CREATE TABLE causes an implicit commit".
+var mysqlDDLObjects = map[string]bool{
+	"DATABASE": true, "SCHEMA": true, "EVENT": true, "FUNCTION": true, "INDEX": true,
+	"PROCEDURE": true, "SERVER": true, "TABLE": true, "TABLESPACE": true, "TRIGGER": true,
+	"VIEW": true, "USER": true, "ROLE": true, "LOGFILE": true, "INSTANCE": true, "RESOURCE": true,
+}
+
+func mysqlImplicitCommit(w []string) string {
+	const suffix = " causes an implicit commit"
+	switch w[0] {
+	case "CREATE", "ALTER", "DROP":
+		// Every CREATE/ALTER/DROP is DDL except temporary tables and prepared statements.
+		if at(w, 1) == "TEMPORARY" || (w[0] == "DROP" && at(w, 1) == "PREPARE") {
+			return ""
+		}
+		for _, word := range w[1:] {
+			if mysqlDDLObjects[word] {
+				return w[0] + " " + word + suffix
+			}
+		}
+		return w[0] + suffix
+	case "RENAME", "TRUNCATE", "GRANT", "REVOKE", "ANALYZE", "OPTIMIZE", "REPAIR", "FLUSH", "RESET", "INSTALL", "UNINSTALL":
+		return w[0] + suffix
+	case "LOCK", "UNLOCK":
+		if at(w, 1) == "TABLES" || at(w, 1) == "TABLE" || at(w, 1) == "INSTANCE" {
+			return w[0] + " " + w[1] + suffix
+		}
+	case "CHECK", "CACHE":
+		if at(w, 1) == "TABLE" || at(w, 1) == "INDEX" {
+			return w[0] + " " + w[1] + suffix
+		}
+	case "LOAD":
+		if at(w, 1) == "INDEX" {
+			return "LOAD INDEX" + suffix
+		}
+	case "SET":
+		if at(w, 1) == "PASSWORD" {
+			return "SET PASSWORD" + suffix
+		}
+	}
+	return ""
+}
+
+func contains(w []string, word string) bool {
+	for _, v := range w {
+		if v == word {
+			return true
+		}
+	}
+	return false
+}
+
+func containsSeq(w []string, a, b string) bool {
+	for i := 0; i+1 < len(w); i++ {
+		if w[i] == a && w[i+1] == b {
+			return true
+		}
+	}
+	return false
+}
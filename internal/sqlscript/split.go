@@ -0,0 +1,425 @@
+package sqlscript
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Statement is a single statement cut from a migration script.
+type Statement struct {
+	Index int    `json:"index"` // 1-based position in the script
+	Line  int    `json:"line"`  // 1-based line where the statement text starts
+	SQL   string `json:"sql"`
+}
+
+// Split cuts a migration script into statements for the given engine.
+//
+// Postgres: single-quoted strings (including E'...' escape strings), quoted
+// identifiers, $$ and $tag$ dollar quotes, -- comments, nested /* */ comments,
+// and BEGIN ATOMIC ... END bodies of CREATE FUNCTION and CREATE PROCEDURE.
+// MySQL: single/double-quoted strings with backslash escapes, backtick
+// identifiers, "-- ", # and /* */ comments, and DELIMITER directives.
+// Executable /*! */ comments are statement text, not comments.
+// SQLite: single-quoted strings, double-quoted, backtick and [bracketed]
+// identifiers, -- and /* */ comments, and CREATE TRIGGER bodies, whose
+// BEGIN ... END holds semicolons.
+//
+// Statements that contain only whitespace or comments are dropped.
+func Split(engine string, script string) ([]Statement, error) {
+	s := &splitter{
+		src:   script,
+		line:  1,
+		delim: ";",
+	}
+	switch strings.ToLower(strings.TrimSpace(engine)) {
+	case "postgres":
+	case "mysql":
+		s.mysql = true
+	case "sqlite":
+		s.sqlite = true
+	default:
+		return nil, fmt.Errorf("sqlscript: unsupported engine %q", engine)
+	}
+	if err := s.run(); err != nil {
+		return nil, err
+	}
+	return s.out, nil
+}
+
+type splitter struct {
+	src    string
+	pos    int
+	line   int
+	mysql  bool
+	sqlite bool
+	delim  string
+	// depth counts the open BEGIN and CASE blocks of a SQLite trigger body
+	// or a Postgres BEGIN ATOMIC body; the delimiter only ends the statement
+	// outside them.
+	depth int
+
+	start      int // offset where the pending statement starts
+	lineStart  int // offset of the current line
+	hasContent bool
+	stmtLine   int
+
+	out []Statement
+}
+
+func (s *splitter) run() error {
+	for s.pos < len(s.src) {
+		c := s.src[s.pos]
+
+		if s.mysql && !s.hasContent && s.atLineIndent() && hasPrefixFold(s.src[s.pos:], "delimiter") {
+			if ok, err := s.delimiterDirective(); err != nil {
+				return err
+			} else if ok {
+				continue
+			}
+		}
+
+		if s.depth == 0 && strings.HasPrefix(s.src[s.pos:], s.delim) {
+			s.emit(s.pos)
+			s.pos += len(s.delim)
+			s.start = s.pos
+			continue
+		}
+
+		switch {
+		case c == '\n':
+			s.newline()
+			continue
+		case c == ' ' || c == '\t' || c == '\r' || c == '\f':
+			s.pos++
+			continue
+		case c == '-' && s.peek(1) == '-' && (!s.mysql || s.peek(2) <= ' '):
+			s.skipLineComment()
+			continue
+		case c == '#' && s.mysql:
+			s.skipLineComment()
+			continue
+		case c == '/' && s.peek(1) == '*':
+			if s.mysql && s.peek(2) == '!' {
+				// Executable comment: MySQL runs its body.
+				s.markContent()
+			}
+			if err := s.skipBlockComment(); err != nil {
+				return err
+			}
+			continue
+		}
+
+		s.markContent()
+		var err error
+		switch {
+		case c == '\'':
+			err = s.skipQuoted('\'', s.mysql || s.isEscapeString())
+		case c == '"':
+			err = s.skipQuoted('"', s.mysql)
+		case c == '`' && (s.mysql || s.sqlite):
+			err = s.skipQuoted('`', false)
+		case c == '[' && s.sqlite:
+			err = s.skipBracketIdent()
+		case c == '$' && !s.mysql && !s.sqlite:
+			err = s.skipDollarQuote()
+		case !s.mysql && isIdentByte(c) && (s.pos == 0 || !isIdentByte(s.src[s.pos-1])):
+			s.blockWord()
+		default:
+			s.pos++
+		}
+		if err != nil {
+			return err
+		}
+	}
+	s.emit(len(s.src))
+	return nil
+}
+
+func (s *splitter) emit(end int) {
+	if s.hasContent {
+		s.out = append(s.out, Statement{
+			Index: len(s.out) + 1,
+			Line:  s.stmtLine,
+			SQL:   strings.TrimSpace(s.src[s.start:end]),
+		})
+	}
+	s.hasContent = false
+	s.depth = 0
+}
+
+func (s *splitter) markContent() {
+	if !s.hasContent {
+		s.hasContent = true
+		s.stmtLine = s.line
+	}
+}
+
+func (s *splitter) newline() {
+	s.pos++
+	s.line++
+	s.lineStart = s.pos
+}
+
+func (s *splitter) peek(n int) byte {
+	if s.pos+n < len(s.src) {
+		return s.src[s.pos+n]
+	}
+	return 0
+}
+
+// atLineIndent reports whether only blanks precede pos on the current line.
+func (s *splitter) atLineIndent() bool {
+	return strings.TrimLeft(s.src[s.lineStart:s.pos], " \t") == ""
+}
+
+func (s *splitter) skipLineComment() {
+	for s.pos < len(s.src) && s.src[s.pos] != '\n' {
+		s.pos++
+	}
+}
+
+func (s *splitter) skipBlockComment() error {
+	startLine := s.line
+	depth := 0
+	for s.pos < len(s.src) {
+		switch {
+		case s.src[s.pos] == '/' && s.peek(1) == '*':
+			// Postgres comments nest; MySQL and SQLite comments end at the first */.
+			if depth == 0 || (!s.mysql && !s.sqlite) {
+				depth++
+			}
+			s.pos += 2
+		case s.src[s.pos] == '*' && s.peek(1) == '/':
+			depth--
+			s.pos += 2
+			if depth == 0 {
+				return nil
+			}
+		case s.src[s.pos] == '\n':
+			s.newline()
+		default:
+			s.pos++
+		}
+	}
+	return fmt.Errorf("sqlscript: unterminated block comment starting at line %d", startLine)
+}
+
+// skipQuoted consumes a quoted string or identifier. A doubled quote is always
+// an escaped quote; backslash escapes are honored when backslash is true.
+func (s *splitter) skipQuoted(quote byte, backslash bool) error {
+	startLine := s.line
+	s.pos++
+	for s.pos < len(s.src) {
+		c := s.src[s.pos]
+		switch {
+		case c == '\\' && backslash:
+			if s.peek(1) == '\n' {
+				s.pos++
+				s.newline()
+				continue
+			}
+			s.pos += 2
+		case c == quote:
+			if s.peek(1) == quote {
+				s.pos += 2
+				continue
+			}
+			s.pos++
+			return nil
+		case c == '\n':
+			s.newline()
+		default:
+			s.pos++
+		}
+	}
+	return fmt.Errorf("sqlscript: unterminated %c quote starting at line %d", quote, startLine)
+}
+
+// skipBracketIdent consumes a SQLite [identifier].
+func (s *splitter) skipBracketIdent() error {
+	startLine := s.line
+	for s.pos < len(s.src) {
+		switch s.src[s.pos] {
+		case ']':
+			s.pos++
+			return nil
+		case '\n':
+			s.newline()
+		default:
+			s.pos++
+		}
+	}
+	return fmt.Errorf("sqlscript: unterminated [ identifier starting at line %d", startLine)
+}
+
+// blockWord consumes a bare word and tracks the bodies that hold semicolons:
+// the BEGIN ... END of a SQLite CREATE TRIGGER and the BEGIN ATOMIC ... END
+// of a Postgres CREATE FUNCTION or PROCEDURE, with the CASE ... END
+// expressions in them.
+func (s *splitter) blockWord() {
+	start := s.pos
+	for s.pos < len(s.src) && isIdentByte(s.src[s.pos]) {
+		s.pos++
+	}
+	switch word := strings.ToUpper(s.src[start:s.pos]); {
+	case word == "BEGIN" && s.depth == 0:
+		w := leadingWords(s.src[s.start:start], false, 4)
+		if at(w, 0) != "CREATE" {
+			return
+		}
+		if s.sqlite {
+			if at(w, 1) == "TRIGGER" || at(w, 2) == "TRIGGER" {
+				s.depth = 1
+			}
+			return
+		}
+		object := at(w, 1)
+		if object == "OR" && at(w, 2) == "REPLACE" {
+			object = at(w, 3)
+		}
+		if (object == "FUNCTION" || object == "PROCEDURE") && s.atomicNext() {
+			s.depth = 1
+		}
+	case word == "CASE" && s.depth > 0:
+		s.depth++
+	case word == "END" && s.depth > 0:
+		s.depth--
+	}
+}
+
+// atomicNext reports whether the word after pos is ATOMIC.
+func (s *splitter) atomicNext() bool {
+	rest := strings.TrimLeft(s.src[s.pos:], " \t\r\n\f")
+	return hasPrefixFold(rest, "atomic") && (len(rest) == len("atomic") || !isIdentByte(rest[len("atomic")]))
+}
+
+// isEscapeString reports whether the quote at pos opens a Postgres E'...' string.
+func (s *splitter) isEscapeString() bool {
+	if s.pos == 0 || (s.src[s.pos-1] != 'E' && s.src[s.pos-1] != 'e') {
+		return false
+	}
+	return s.pos == 1 || !isIdentByte(s.src[s.pos-2])
+}
+
+func (s *splitter) skipDollarQuote() error {
+	if s.pos > 0 && isIdentByte(s.src[s.pos-1]) {
+		s.pos++
+		return nil
+	}
+	end := s.pos + 1
+	for end < len(s.src) && isIdentByte(s.src[end]) && s.src[end] != '$' {
+		end++
+	}
+	tag := s.src[s.pos+1 : end]
+	if end >= len(s.src) || s.src[end] != '$' || (tag != "" && tag[0] >= '0' && tag[0] <= '9') {
+		// Positional parameter ($1) or a stray dollar sign.
+		s.pos++
+		return nil
+	}
+	delim := "$" + tag + "$"
+	startLine := s.line
+	s.pos = end + 1
+	for s.pos < len(s.src) {
+		if strings.HasPrefix(s.src[s.pos:], delim) {
+			s.pos += len(delim)
+			return nil
+		}
+		if s.src[s.pos] == '\n' {
+			s.newline()
+			continue
+		}
+		s.pos++
+	}
+	return fmt.Errorf("sqlscript: unterminated dollar quote %s starting at line %d", delim, startLine)
+}
+
+// delimiterDirective handles a MySQL client "DELIMITER xx" line. It reports
+// false when the word is not followed by a delimiter and is plain SQL.
+func (s *splitter) delimiterDirective() (bool, error) {
+	rest := s.src[s.pos+len("delimiter"):]
+	if rest == "" || (rest[0] != ' ' && rest[0] != '\t') {
+		return false, nil
+	}
+	eol := strings.IndexByte(rest, '\n')
+	lineText := rest
+	if eol >= 0 {
+		lineText = rest[:eol]
+	}
+	delim := strings.TrimSpace(lineText)
+	if delim == "" {
+		return false, fmt.Errorf("sqlscript: empty DELIMITER at line %d", s.line)
+	}
+	if strings.ContainsAny(delim, "'\"`\\") {
+		return false, fmt.Errorf("sqlscript: invalid DELIMITER %q at line %d", delim, s.line)
+	}
+	s.delim = delim
+	s.pos += len("delimiter") + len(lineText)
+	s.start = s.pos
+	if s.pos < len(s.src) {
+		s.newline()
+		s.start = s.pos
+	}
+	return true, nil
+}
+
+func isIdentByte(c byte) bool {
+	return c == '_' || c == '$' || c >= 0x80 ||
+		(c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+func hasPrefixFold(s, prefix string) bool {
+	return len(s) >= len(prefix) && strings.EqualFold(s[:len(prefix)], prefix)
+}
+
+// leadingWords returns up to max upper-cased bare words from the start of a
+// statement, skipping comments, quoted strings and quoted identifiers. The
+// body of a MySQL executable /*! */ comment is read as statement text.
+func leadingWords(sql string, mysql bool, max int) []string {
+	var out []string
+	for i := 0; i < len(sql) && len(out) < max; {
+		c := sql[i]
+		switch {
+		case c == '-' && i+1 < len(sql) && sql[i+1] == '-', c == '#' && mysql:
+			for i < len(sql) && sql[i] != '\n' {
+				i++
+			}
+		case c == '/' && strings.HasPrefix(sql[i:], "/*!") && mysql:
+			// Executable comment: read the words of its body.
+			i += 3
+			for i < len(sql) && sql[i] >= '0' && sql[i] <= '9' {
+				i++
+			}
+		case c == '/' && i+1 < len(sql) && sql[i+1] == '*':
+			end := strings.Index(sql[i+2:], "*/")
+			if end < 0 {
+				return out
+			}
+			i += end + 4
+		case c == '\'' || c == '"' || c == '`':
+			end := strings.IndexByte(sql[i+1:], c)
+			if end < 0 {
+				return out
+			}
+			i += end + 2
+		case c == '$' && !mysql:
+			// Dollar-quoted bodies never hold the leading keywords; stop here.
+			return out
+		case isIdentByte(c) && !(c >= '0' && c <= '9'):
+			start := i
+			for i < len(sql) && isIdentByte(sql[i]) {
+				i++
+			}
+			out = append(out, strings.ToUpper(sql[start:i]))
+		default:
+			i++
+		}
+	}
+	return out
+}
+
+func at(w []string, i int) string {
+	if i < len(w) {
+		return w[i]
+	}
+	return ""
+}
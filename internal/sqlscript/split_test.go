@@ -0,0 +1,154 @@
+package sqlscript
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		name   string
+		engine string
+		script string
+		want   []string
+	}{
+		{
+			name:   "plain statements",
+			engine: "postgres",
+			script: "CREATE TABLE a (id int);\nINSERT INTO a VALUES (1);\n",
+			want:   []string{"CREATE TABLE a (id int)", "INSERT INTO a VALUES (1)"},
+		},
+		{
+			name:   "dollar quote",
+			engine: "postgres",
+			script: "CREATE FUNCTION f() RETURNS int AS $$ SELECT 1; $$ LANGUAGE sql;\nSELECT f();",
+			want:   []string{"CREATE FUNCTION f() RETURNS int AS $$ SELECT 1; $$ LANGUAGE sql", "SELECT f()"},
+		},
+		{
+			name:   "tagged dollar quote",
+			engine: "postgres",
+			script: "DO $body$ BEGIN PERFORM 1; RAISE NOTICE '$$'; END $body$;\nSELECT $1;",
+			want:   []string{"DO $body$ BEGIN PERFORM 1; RAISE NOTICE '$$'; END $body$", "SELECT $1"},
+		},
+		{
+			name:   "nested comment",
+			engine: "postgres",
+			script: "/* outer /* inner; */ still; */ SELECT 1;\n-- only a comment;\n",
+			want:   []string{"/* outer /* inner; */ still; */ SELECT 1"},
+		},
+		{
+			name:   "escape string",
+			engine: "postgres",
+			script: "SELECT E'it\\'s; fine';\nSELECT 'a\\';",
+			want:   []string{"SELECT E'it\\'s; fine'", "SELECT 'a\\'"},
+		},
+		{
+			name:   "begin atomic",
+			engine: "postgres",
+			script: "CREATE OR REPLACE FUNCTION f(x int) RETURNS int LANGUAGE sql\nBEGIN ATOMIC\n  SELECT CASE WHEN x > 0 THEN 1 ELSE 0 END;\n  SELECT 2;\nEND;\nSELECT f(1);",
+			want: []string{
+				"CREATE OR REPLACE FUNCTION f(x int) RETURNS int LANGUAGE sql\nBEGIN ATOMIC\n  SELECT CASE WHEN x > 0 THEN 1 ELSE 0 END;\n  SELECT 2;\nEND",
+				"SELECT f(1)",
+			},
+		},
+		{
+			name:   "transaction begin",
+			engine: "postgres",
+			script: "BEGIN;\nSELECT 1;\nCOMMIT;",
+			want:   []string{"BEGIN", "SELECT 1", "COMMIT"},
+		},
+		{
+			name:   "mysql delimiter",
+			engine: "mysql",
+			script: "DELIMITER $$\nCREATE PROCEDURE p() BEGIN SELECT 1; SELECT 2; END$$\nDELIMITER ;\nCALL p();",
+			want:   []string{"CREATE PROCEDURE p() BEGIN SELECT 1; SELECT 2; END", "CALL p()"},
+		},
+		{
+			name:   "mysql executable comment",
+			engine: "mysql",
+			script: "/*!40101 SET NAMES utf8 */;\n/* plain; comment */;\n# hash comment\nSELECT 'a\\';b';",
+			want:   []string{"/*!40101 SET NAMES utf8 */", "# hash comment\nSELECT 'a\\';b'"},
+		},
+		{
+			name:   "sqlite trigger",
+			engine: "sqlite",
+			script: "CREATE TEMP TRIGGER t AFTER INSERT ON a BEGIN\n  UPDATE b SET n = CASE WHEN n > 0 THEN n + 1 ELSE 1 END;\n  DELETE FROM c;\nEND;\nSELECT [x;y] FROM a;",
+			want: []string{
+				"CREATE TEMP TRIGGER t AFTER INSERT ON a BEGIN\n  UPDATE b SET n = CASE WHEN n > 0 THEN n + 1 ELSE 1 END;\n  DELETE FROM c;\nEND",
+				"SELECT [x;y] FROM a",
+			},
+		},
+		{
+			name:   "sqlite transaction",
+			engine: "sqlite",
+			script: "BEGIN;\nINSERT INTO a VALUES (1);\nEND;",
+			want:   []string{"BEGIN", "INSERT INTO a VALUES (1)", "END"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stmts, err := Split(tt.engine, tt.script)
+			if err != nil {
+				t.Fatalf("Split: %v", err)
+			}
+			var got []string
+			for _, stmt := range stmts {
+				got = append(got, stmt.SQL)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("Split:\n got %q\nwant %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		engine string
+		script string
+	}{
+		{"unterminated dollar quote", "postgres", "SELECT $x$ never closed"},
+		{"unterminated comment", "postgres", "/* /* */ SELECT 1;"},
+		{"unterminated string", "mysql", "SELECT 'open;"},
+		{"empty delimiter", "mysql", "DELIMITER \nSELECT 1;"},
+		{"unsupported engine", "oracle", "SELECT 1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Split(tt.engine, tt.script); err == nil {
+				t.Fatal("Split: want error")
+			}
+		})
+	}
+}
+
+func TestSplitLines(t *testing.T) {
+	stmts, err := Split("postgres", "-- header\n\nSELECT 1;\n\nSELECT\n  2;")
+	if err != nil {
+		t.Fatalf("Split: %v", err)
+	}
+	if len(stmts) != 2 || stmts[0].Line != 3 || stmts[1].Line != 5 || stmts[1].Index != 2 {
+		t.Fatalf("Split: got %+v", stmts)
+	}
+}
+
+func TestTransactionBlocker(t *testing.T) {
+	tests := []struct {
+		engine string
+		sql    string
+		want   bool
+	}{
+		{"postgres", "CREATE OR REPLACE FUNCTION f() RETURNS int LANGUAGE sql BEGIN ATOMIC SELECT 1; END", false},
+		{"postgres", "END", true},
+		{"postgres", "CREATE INDEX CONCURRENTLY i ON a (id)", true},
+		{"mysql", "/*!40101 SET NAMES utf8 */", false},
+		{"mysql", "/*!50003 CREATE TRIGGER t BEFORE INSERT ON a FOR EACH ROW SET @x = 1 */", true},
+		{"sqlite", "PRAGMA main.foreign_keys = ON", true},
+	}
+	for _, tt := range tests {
+		if got := TransactionBlocker(tt.engine, tt.sql) != ""; got != tt.want {
+			t.Errorf("TransactionBlocker(%s, %q) = %v, want %v", tt.engine, tt.sql, got, tt.want)
+		}
+	}
+}
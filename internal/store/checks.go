@@ -0,0 +1,106 @@
+package store
+
+import (
+	"errors"
+	"strings"
+
+	"db_inner_migrator_syncer/internal/engine"
+	"db_inner_migrator_syncer/internal/sqlscript"
+)
+
+const (
+	CheckPre    = "precheck"
+	CheckPost   = "postcheck"
+	CheckVerify = "verify" // baseline runs
+
+	// CheckExpectTrue passes when the query returns one row with one truthy value.
+	CheckExpectTrue = "true"
+	// CheckExpectNoRows passes when the query returns no rows (each row is a violation).
+	CheckExpectNoRows = "no_rows"
+)
+
+var (
+	ErrCheckInvalid       = errors.New("precheck_sql and postcheck_sql must be a single query (SELECT, WITH, VALUES, TABLE or SHOW)")
+	ErrCheckExpectInvalid = errors.New("invalid check expect; use true or no_rows")
+	ErrPrecheckFailed     = errors.New("precheck failed")
+	ErrPostcheckFailed    = errors.New("postcheck failed")
+	ErrVerifyInvalid      = errors.New("verify_sql must be a single query (SELECT, WITH, VALUES, TABLE or SHOW)")
+	ErrVerifyFailed       = errors.New("baseline verification failed")
+)
+
+// Check is an assertion query run on each target around sql_up. The executor
+// always rolls back whatever the query did.
+type Check struct {
+	Phase  string `json:"phase"`
+	SQL    string `json:"sql"`
+	Expect string `json:"expect"`
+}
+
+// Checks returns the precheck and postcheck of the migration, nil when unset.
+func (m *Migration) Checks() (pre *Check, post *Check) {
+	return newCheck(CheckPre, m.PrecheckSQL, m.PrecheckExpect), newCheck(CheckPost, m.PostcheckSQL, m.PostcheckExpect)
+}
+
+// Verify returns the verification query of a baseline run, nil when unset.
+func (r Run) Verify() *Check {
+	return newCheck(CheckVerify, r.VerifySQL, r.VerifyExpect)
+}
+
+func newCheck(phase string, sql *string, expect string) *Check {
+	if sql == nil {
+		return nil
+	}
+	if expect == "" {
+		expect = CheckExpectTrue
+	}
+	return &Check{Phase: phase, SQL: *sql, Expect: expect}
+}
+
+// normalizeCheck trims the query (empty means no check) and validates it. The
+// migration is not bound to an engine, so the query must split as a single
+// query in at least one engine dialect; the executor checks it again per target.
+func normalizeCheck(sql string, expect string) (*string, string, error) {
+	expect = strings.ToLower(strings.TrimSpace(expect))
+	if expect == "" {
+		expect = CheckExpectTrue
+	}
+	if expect != CheckExpectTrue && expect != CheckExpectNoRows {
+		return nil, "", ErrCheckExpectInvalid
+	}
+	sql = strings.TrimSpace(sql)
+	if sql == "" {
+		return nil, CheckExpectTrue, nil
+	}
+	for _, dialect := range engine.Dialects() {
+		if _, err := CheckStatement(dialect, sql); err == nil {
+			return &sql, expect, nil
+		}
+	}
+	return nil, "", ErrCheckInvalid
+}
+
+// CheckStatement returns the single query of a check for the given sqlscript dialect.
+func CheckStatement(dialect string, sql string) (sqlscript.Statement, error) {
+	stmts, err := sqlscript.Split(dialect, sql)
+	if err != nil || len(stmts) != 1 || !sqlscript.IsQuery(dialect, stmts[0].SQL) {
+		return sqlscript.Statement{}, ErrCheckInvalid
+	}
+	return stmts[0], nil
+}
+
+// sqlUpChecksum covers sql_up and the checks run around it. Without checks it is
+// the plain sql_up checksum, so ledger rows written earlier still match.
+func sqlUpChecksum(sqlUp string, pre *Check, post *Check) string {
+	if pre == nil && post == nil {
+		return checksum(sqlUp)
+	}
+	parts := []string{sqlUp}
+	for _, c := range []*Check{pre, post} {
+		if c == nil {
+			parts = append(parts, "", "")
+			continue
+		}
+		parts = append(parts, c.Phase+":"+c.Expect, c.SQL)
+	}
+	return checksum(strings.Join(parts, "\x00"))
+}
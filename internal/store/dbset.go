@@ -19,28 +19,79 @@ var (
 )
 
 type DBSet struct {
-	ID        uuid.UUID `json:"id"`
-	ProjectID uuid.UUID `json:"project_id"`
-	Env       string    `json:"env"`
-	Name      string    `json:"name"`
-	IsActive  bool      `json:"is_active"`
-	CreatedAt time.Time `json:"created_at"`
+	ID            uuid.UUID `json:"id"`
+	ProjectID     uuid.UUID `json:"project_id"`
+	Env           string    `json:"env"`
+	Name          string    `json:"name"`
+	IsActive      bool      `json:"is_active"`
+	MaxParallel   int       `json:"max_parallel"`
+	FailurePolicy string    `json:"failure_policy"`
+	// WavePlan and WaveGate are the rollout defaults for runs on this set.
+	WavePlan string `json:"wave_plan"`
+	WaveGate string `json:"wave_gate"`
+	// MaintenanceWindow is when runs scheduled into the window start, e.g. "mon-fri 02:00-04:00 UTC".
+	MaintenanceWindow string `json:"maintenance_window"`
+	// SSH is the bastion the set's targets connect through, or nil.
+	SSH       *SSHTunnel `json:"ssh,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
 }
 
-func CreateDBSet(ctx context.Context, pool *pgxpool.Pool, projectID uuid.UUID, env string, name string, createdBy uuid.UUID) (*DBSet, error) {
-	env = strings.ToLower(strings.TrimSpace(env))
+type CreateDBSetInput struct {
+	ProjectID     uuid.UUID
+	Env           string
+	Name          string
+	MaxParallel   int    // 0 means 1
+	FailurePolicy string // empty means stop_on_first_failure
+	WavePlan      string // empty runs all targets in one wave
+	WaveGate      string // empty means none
+	// MaintenanceWindow is optional; see ParseMaintenanceWindow.
+	MaintenanceWindow string
+	CreatedBy         uuid.UUID
+}
+
+func CreateDBSet(ctx context.Context, pool *pgxpool.Pool, input CreateDBSetInput) (*DBSet, error) {
+	env := strings.ToLower(strings.TrimSpace(input.Env))
 	if env != "daily" && env != "stg" && env != "prd" {
 		return nil, ErrEnvInvalid
 	}
-	name = strings.TrimSpace(name)
+	name := strings.TrimSpace(input.Name)
 	if name == "" {
 		return nil, ErrDBSetNameEmpty
 	}
+	maxParallel := input.MaxParallel
+	if maxParallel == 0 {
+		maxParallel = 1
+	}
+	if err := validateMaxParallel(maxParallel); err != nil {
+		return nil, err
+	}
+	policy, err := normalizeFailurePolicy(input.FailurePolicy)
+	if err != nil {
+		return nil, err
+	}
+	if policy == "" {
+		policy = FailurePolicyStopOnFirst
+	}
+	wavePlan, err := normalizeWavePlan(input.WavePlan)
+	if err != nil {
+		return nil, err
+	}
+	waveGate, err := normalizeWaveGate(input.WaveGate)
+	if err != nil {
+		return nil, err
+	}
+	if waveGate == "" {
+		waveGate = WaveGateNone
+	}
+	window, err := normalizeMaintenanceWindow(input.MaintenanceWindow)
+	if err != nil {
+		return nil, err
+	}
 	id := uuid.New()
 	if _, err := pool.Exec(ctx, `
-INSERT INTO db_sets (id, project_id, env, name, created_by)
-VALUES ($1, $2, $3, $4, $5)
-`, id, projectID, env, name, createdBy); err != nil {
+INSERT INTO db_sets (id, project_id, env, name, max_parallel, failure_policy, wave_plan, wave_gate, maintenance_window, created_by)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+`, id, input.ProjectID, env, name, maxParallel, policy, wavePlan, waveGate, window, input.CreatedBy); err != nil {
 		var pgErr *pgconn.PgError
 		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
 			return nil, errors.New("db set name already exists for project/env")
@@ -53,12 +104,17 @@ VALUES ($1, $2, $3, $4, $5)
 		return nil, err
 	}
 	return &DBSet{
-		ID:        id,
-		ProjectID: projectID,
-		Env:       env,
-		Name:      name,
-		IsActive:  true,
-		CreatedAt: createdAt,
+		ID:                id,
+		ProjectID:         input.ProjectID,
+		Env:               env,
+		Name:              name,
+		IsActive:          true,
+		MaxParallel:       maxParallel,
+		FailurePolicy:     policy,
+		WavePlan:          wavePlan,
+		WaveGate:          waveGate,
+		MaintenanceWindow: window,
+		CreatedAt:         createdAt,
 	}, nil
 }
 
@@ -68,14 +124,16 @@ func ListDBSets(ctx context.Context, pool *pgxpool.Pool, projectID uuid.UUID, en
 	if envFilter != "" {
 		envFilter = strings.ToLower(envFilter)
 		rows, err = pool.Query(ctx, `
-SELECT id, project_id, env, name, is_active, created_at
+SELECT id, project_id, env, name, is_active, max_parallel, failure_policy, wave_plan, wave_gate, maintenance_window, created_at,
+  `+sshColumns+`
 FROM db_sets
 WHERE project_id = $1 AND env = $2
 ORDER BY name
 `, projectID, envFilter)
 	} else {
 		rows, err = pool.Query(ctx, `
-SELECT id, project_id, env, name, is_active, created_at
+SELECT id, project_id, env, name, is_active, max_parallel, failure_policy, wave_plan, wave_gate, maintenance_window, created_at,
+  `+sshColumns+`
 FROM db_sets
 WHERE project_id = $1
 ORDER BY name
@@ -89,9 +147,11 @@ ORDER BY name
 	var sets []DBSet
 	for rows.Next() {
 		var s DBSet
-		if err := rows.Scan(&s.ID, &s.ProjectID, &s.Env, &s.Name, &s.IsActive, &s.CreatedAt); err != nil {
+		var ssh sshRow
+		if err := rows.Scan(&s.ID, &s.ProjectID, &s.Env, &s.Name, &s.IsActive, &s.MaxParallel, &s.FailurePolicy, &s.WavePlan, &s.WaveGate, &s.MaintenanceWindow, &s.CreatedAt, &ssh.host, &ssh.port, &ssh.user, &ssh.hostKey, &ssh.keyEnc, &ssh.passwordEnc); err != nil {
 			return nil, err
 		}
+		s.SSH = ssh.tunnel()
 		sets = append(sets, s)
 	}
 	return sets, rows.Err()
@@ -99,16 +159,19 @@ ORDER BY name
 
 func GetDBSet(ctx context.Context, pool *pgxpool.Pool, id uuid.UUID) (*DBSet, error) {
 	var s DBSet
+	var ssh sshRow
 	if err := pool.QueryRow(ctx, `
-SELECT id, project_id, env, name, is_active, created_at
+SELECT id, project_id, env, name, is_active, max_parallel, failure_policy, wave_plan, wave_gate, maintenance_window, created_at,
+  `+sshColumns+`
 FROM db_sets
 WHERE id = $1
-`, id).Scan(&s.ID, &s.ProjectID, &s.Env, &s.Name, &s.IsActive, &s.CreatedAt); err != nil {
+`, id).Scan(&s.ID, &s.ProjectID, &s.Env, &s.Name, &s.IsActive, &s.MaxParallel, &s.FailurePolicy, &s.WavePlan, &s.WaveGate, &s.MaintenanceWindow, &s.CreatedAt, &ssh.host, &ssh.port, &ssh.user, &ssh.hostKey, &ssh.keyEnc, &ssh.passwordEnc); err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return nil, ErrDBSetNotFound
 		}
 		return nil, err
 	}
+	s.SSH = ssh.tunnel()
 	return &s, nil
 }
 
@@ -2,40 +2,99 @@ package store
 
 import (
 	"context"
-	"database/sql"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"net"
-	"net/url"
 	"strings"
 	"time"
 
-	"github.com/go-sql-driver/mysql"
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 
+	"db_inner_migrator_syncer/internal/engine"
 	"db_inner_migrator_syncer/internal/secret"
 )
 
 var (
 	ErrDBTargetNotFound  = errors.New("db target not found")
 	ErrDBTargetInactive  = errors.New("db target is inactive")
-	ErrDBTargetBadEngine = errors.New("invalid engine")
+	ErrDBTargetBadEngine = engine.ErrUnknown
+	ErrDBTargetInvalid   = engine.ErrInvalidTarget
+	ErrDBTargetPriority  = errors.New("priority must not be negative")
 )
 
+// DefaultTargetPriority is the priority of targets created without one.
+const DefaultTargetPriority = 100
+
 type DBTarget struct {
-	ID        uuid.UUID       `json:"id"`
-	DBSetID   uuid.UUID       `json:"db_set_id"`
-	Engine    string          `json:"engine"`
-	Host      string          `json:"host"`
-	Port      int             `json:"port"`
-	DBName    string          `json:"dbname"`
-	Username  string          `json:"username"`
-	Options   json.RawMessage `json:"options"`
-	IsActive  bool            `json:"is_active"`
-	CreatedAt time.Time       `json:"created_at"`
+	ID       uuid.UUID `json:"id"`
+	DBSetID  uuid.UUID `json:"db_set_id"`
+	Engine   string    `json:"engine"`
+	Host     string    `json:"host"`
+	Port     int       `json:"port"`
+	DBName   string    `json:"dbname"`
+	Username string    `json:"username"`
+	TLS      TargetTLS `json:"tls"`
+	// SSH is the target's own bastion, or nil to use its db set's.
+	SSH     *SSHTunnel      `json:"ssh,omitempty"`
+	Options json.RawMessage `json:"options"`
+	// FanoutPattern is the LIKE pattern of the schemas or databases a
+	// fan-out target expands to; empty for a plain target.
+	FanoutPattern string `json:"fanout_pattern,omitempty"`
+	IsActive      bool   `json:"is_active"`
+	// Priority orders targets in a rollout, lowest first; the first is the canary.
+	Priority  int       `json:"priority"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TargetTLS is the TLS setup of a target, without the key material.
+type TargetTLS struct {
+	Mode          string `json:"mode"`
+	ServerName    string `json:"server_name,omitempty"`
+	HasCA         bool   `json:"has_ca"`
+	HasClientCert bool   `json:"has_client_cert"`
+}
+
+// Enabled reports whether connections to the target use TLS.
+func (t TargetTLS) Enabled() bool {
+	return t.Mode != "" && t.Mode != engine.TLSDisable
+}
+
+// String describes the TLS setup for the UI, e.g. "verify-full, private CA".
+func (t TargetTLS) String() string {
+	if !t.Enabled() {
+		return engine.TLSDisable
+	}
+	out := t.Mode
+	if t.HasCA {
+		out += ", private CA"
+	}
+	if t.HasClientCert {
+		out += ", client certificate"
+	}
+	return out
+}
+
+// TargetSecrets are the encrypted credentials of a target. The TLS fields
+// are nil when not set.
+type TargetSecrets struct {
+	Password []byte
+	TLSCA    []byte
+	TLSCert  []byte
+	TLSKey   []byte
+	// ssh is the tunnel the target connects through: its own, else its db
+	// set's.
+	ssh sshRow
+}
+
+// TLSInput is the TLS setup of a new target. CA, Cert and Key are PEM.
+type TLSInput struct {
+	Mode       string
+	ServerName string
+	CA         string
+	Cert       string
+	Key        string
 }
 
 type CreateTargetInput struct {
@@ -46,7 +105,11 @@ type CreateTargetInput struct {
 	DBName   string
 	Username string
 	Password string
+	TLS      TLSInput
 	Options  map[string]any
+	Priority *int // nil uses DefaultTargetPriority
+	// FanoutPattern makes the target a fan-out target; see DBTarget.
+	FanoutPattern string
 }
 
 type UpdateTargetInput struct {
@@ -55,25 +118,39 @@ type UpdateTargetInput struct {
 	DBName   *string
 	Username *string
 	Password *string
-	Options  map[string]any
+	// TLSMode and TLSServerName replace the current values when set; an
+	// empty server name clears it.
+	TLSMode       *string
+	TLSServerName *string
+	// TLSCA, TLSCert and TLSKey replace the stored PEM when not blank, like
+	// Password. TLSClearFiles removes the stored ones first.
+	TLSCA         *string
+	TLSCert       *string
+	TLSKey        *string
+	TLSClearFiles bool
+	Options       map[string]any
+	Priority      *int
+	// FanoutPattern replaces the pattern when set; empty makes the target
+	// a plain one.
+	FanoutPattern *string
 }
 
 func CreateDBTarget(ctx context.Context, pool *pgxpool.Pool, key []byte, input CreateTargetInput) (*DBTarget, error) {
-	if err := validateEngine(input.Engine); err != nil {
+	eng, err := engine.Lookup(input.Engine)
+	if err != nil {
 		return nil, err
 	}
-	if input.Port <= 0 {
-		return nil, errors.New("port must be positive")
+	tlsMode := strings.TrimSpace(input.TLS.Mode)
+	if tlsMode == "" {
+		tlsMode = engine.TLSDisable
 	}
-	if strings.TrimSpace(input.Host) == "" || strings.TrimSpace(input.DBName) == "" || strings.TrimSpace(input.Username) == "" {
-		return nil, errors.New("host, dbname, username required")
+	tlsCfg := engine.TLS{
+		Mode:       tlsMode,
+		ServerName: strings.TrimSpace(input.TLS.ServerName),
+		CA:         pemBytes(input.TLS.CA),
+		Cert:       pemBytes(input.TLS.Cert),
+		Key:        pemBytes(input.TLS.Key),
 	}
-	encPwd, err := secret.Encrypt(key, []byte(input.Password))
-	if err != nil {
-		return nil, err
-	}
-
-	id := uuid.New()
 	options := json.RawMessage("{}")
 	if input.Options != nil {
 		body, err := json.Marshal(input.Options)
@@ -82,11 +159,39 @@ func CreateDBTarget(ctx context.Context, pool *pgxpool.Pool, key []byte, input C
 		}
 		options = body
 	}
+	if err := checkOptions(eng, options); err != nil {
+		return nil, err
+	}
+	fanout := strings.TrimSpace(input.FanoutPattern)
+	if err := checkFanout(eng, fanout, options); err != nil {
+		return nil, err
+	}
+	if err := eng.Validate(engine.Target{Host: input.Host, Port: input.Port, DBName: input.DBName, Username: input.Username, TLS: tlsCfg, Options: options}); err != nil {
+		return nil, err
+	}
+	priority := DefaultTargetPriority
+	if input.Priority != nil {
+		priority = *input.Priority
+	}
+	if priority < 0 {
+		return nil, ErrDBTargetPriority
+	}
+	encPwd, err := secret.Encrypt(key, []byte(input.Password))
+	if err != nil {
+		return nil, err
+	}
+	enc, err := encryptTLS(key, tlsCfg)
+	if err != nil {
+		return nil, err
+	}
 
+	id := uuid.New()
 	if _, err := pool.Exec(ctx, `
-INSERT INTO db_targets (id, db_set_id, engine, host, port, dbname, username, password_enc, options_json)
-VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
-`, id, input.DBSetID, strings.ToLower(input.Engine), input.Host, input.Port, input.DBName, input.Username, encPwd, options); err != nil {
+INSERT INTO db_targets (id, db_set_id, engine, host, port, dbname, username, password_enc, options_json, priority,
+  tls_mode, tls_server_name, tls_ca_enc, tls_cert_enc, tls_key_enc, fanout_pattern)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)
+`, id, input.DBSetID, strings.ToLower(input.Engine), input.Host, input.Port, input.DBName, input.Username, encPwd, options, priority,
+		tlsCfg.Mode, tlsCfg.ServerName, enc.TLSCA, enc.TLSCert, enc.TLSKey, fanout); err != nil {
 		return nil, err
 	}
 	var createdAt time.Time
@@ -95,25 +200,29 @@ VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
 	}
 
 	return &DBTarget{
-		ID:        id,
-		DBSetID:   input.DBSetID,
-		Engine:    strings.ToLower(input.Engine),
-		Host:      input.Host,
-		Port:      input.Port,
-		DBName:    input.DBName,
-		Username:  input.Username,
-		Options:   options,
-		IsActive:  true,
-		CreatedAt: createdAt,
+		ID:            id,
+		DBSetID:       input.DBSetID,
+		Engine:        strings.ToLower(input.Engine),
+		Host:          input.Host,
+		Port:          input.Port,
+		DBName:        input.DBName,
+		Username:      input.Username,
+		TLS:           enc.summary(tlsCfg.Mode, tlsCfg.ServerName),
+		Options:       options,
+		FanoutPattern: fanout,
+		IsActive:      true,
+		Priority:      priority,
+		CreatedAt:     createdAt,
 	}, nil
 }
 
 func ListDBTargetsBySet(ctx context.Context, pool *pgxpool.Pool, dbSetID uuid.UUID) ([]DBTarget, error) {
 	rows, err := pool.Query(ctx, `
-SELECT id, db_set_id, engine, host, port, dbname, username, options_json, is_active, created_at
+SELECT id, db_set_id, engine, host, port, dbname, username, options_json, fanout_pattern, is_active, priority, created_at,
+  tls_mode, tls_server_name, tls_ca_enc IS NOT NULL, tls_cert_enc IS NOT NULL, `+sshColumns+`
 FROM db_targets
 WHERE db_set_id = $1
-ORDER BY created_at DESC
+ORDER BY priority, host, port, dbname, id
 `, dbSetID)
 	if err != nil {
 		return nil, err
@@ -123,28 +232,46 @@ ORDER BY created_at DESC
 	var targets []DBTarget
 	for rows.Next() {
 		var t DBTarget
-		if err := rows.Scan(&t.ID, &t.DBSetID, &t.Engine, &t.Host, &t.Port, &t.DBName, &t.Username, &t.Options, &t.IsActive, &t.CreatedAt); err != nil {
+		var ssh sshRow
+		if err := rows.Scan(&t.ID, &t.DBSetID, &t.Engine, &t.Host, &t.Port, &t.DBName, &t.Username, &t.Options, &t.FanoutPattern, &t.IsActive, &t.Priority, &t.CreatedAt,
+			&t.TLS.Mode, &t.TLS.ServerName, &t.TLS.HasCA, &t.TLS.HasClientCert, &ssh.host, &ssh.port, &ssh.user, &ssh.hostKey, &ssh.keyEnc, &ssh.passwordEnc); err != nil {
 			return nil, err
 		}
+		t.SSH = ssh.tunnel()
 		targets = append(targets, t)
 	}
 	return targets, rows.Err()
 }
 
-func GetDBTarget(ctx context.Context, pool *pgxpool.Pool, id uuid.UUID) (*DBTarget, []byte, error) {
+// GetDBTarget returns the target and its encrypted credentials.
+func GetDBTarget(ctx context.Context, pool *pgxpool.Pool, id uuid.UUID) (*DBTarget, TargetSecrets, error) {
 	var t DBTarget
-	var encPwd []byte
+	var enc TargetSecrets
+	var own, set sshRow
 	if err := pool.QueryRow(ctx, `
-SELECT id, db_set_id, engine, host, port, dbname, username, password_enc, options_json, is_active, created_at
-FROM db_targets
-WHERE id = $1
-`, id).Scan(&t.ID, &t.DBSetID, &t.Engine, &t.Host, &t.Port, &t.DBName, &t.Username, &encPwd, &t.Options, &t.IsActive, &t.CreatedAt); err != nil {
+SELECT t.id, t.db_set_id, t.engine, t.host, t.port, t.dbname, t.username, t.password_enc, t.options_json, t.fanout_pattern, t.is_active, t.priority, t.created_at,
+  t.tls_mode, t.tls_server_name, t.tls_ca_enc, t.tls_cert_enc, t.tls_key_enc,
+  t.ssh_host, t.ssh_port, t.ssh_user, t.ssh_host_key, t.ssh_key_enc, t.ssh_password_enc,
+  s.ssh_host, s.ssh_port, s.ssh_user, s.ssh_host_key, s.ssh_key_enc, s.ssh_password_enc
+FROM db_targets t
+JOIN db_sets s ON s.id = t.db_set_id
+WHERE t.id = $1
+`, id).Scan(&t.ID, &t.DBSetID, &t.Engine, &t.Host, &t.Port, &t.DBName, &t.Username, &enc.Password, &t.Options, &t.FanoutPattern, &t.IsActive, &t.Priority, &t.CreatedAt,
+		&t.TLS.Mode, &t.TLS.ServerName, &enc.TLSCA, &enc.TLSCert, &enc.TLSKey,
+		&own.host, &own.port, &own.user, &own.hostKey, &own.keyEnc, &own.passwordEnc,
+		&set.host, &set.port, &set.user, &set.hostKey, &set.keyEnc, &set.passwordEnc); err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
-			return nil, nil, ErrDBTargetNotFound
+			return nil, TargetSecrets{}, ErrDBTargetNotFound
 		}
-		return nil, nil, err
+		return nil, TargetSecrets{}, err
+	}
+	t.TLS = enc.summary(t.TLS.Mode, t.TLS.ServerName)
+	t.SSH = own.tunnel()
+	enc.ssh = own
+	if own.host == "" {
+		enc.ssh = set
 	}
-	return &t, encPwd, nil
+	return &t, enc, nil
 }
 
 func DisableDBTarget(ctx context.Context, pool *pgxpool.Pool, id uuid.UUID) error {
@@ -159,10 +286,15 @@ func DisableDBTarget(ctx context.Context, pool *pgxpool.Pool, id uuid.UUID) erro
 }
 
 func UpdateDBTarget(ctx context.Context, pool *pgxpool.Pool, key []byte, id uuid.UUID, input UpdateTargetInput) (*DBTarget, error) {
-	target, encPwd, err := GetDBTarget(ctx, pool, id)
+	target, enc, err := GetDBTarget(ctx, pool, id)
 	if err != nil {
 		return nil, err
 	}
+	tlsCfg, err := enc.decryptTLS(key)
+	if err != nil {
+		return nil, err
+	}
+	tlsCfg.Mode, tlsCfg.ServerName = target.TLS.Mode, target.TLS.ServerName
 
 	if input.Host != nil && strings.TrimSpace(*input.Host) != "" {
 		target.Host = strings.TrimSpace(*input.Host)
@@ -176,89 +308,217 @@ func UpdateDBTarget(ctx context.Context, pool *pgxpool.Pool, key []byte, id uuid
 	if input.Username != nil && strings.TrimSpace(*input.Username) != "" {
 		target.Username = strings.TrimSpace(*input.Username)
 	}
-
-	newPassword := encPwd
-	if input.Password != nil && strings.TrimSpace(*input.Password) != "" {
-		enc, err := secret.Encrypt(key, []byte(*input.Password))
-		if err != nil {
-			return nil, err
+	if input.Priority != nil {
+		if *input.Priority < 0 {
+			return nil, ErrDBTargetPriority
 		}
-		newPassword = enc
+		target.Priority = *input.Priority
 	}
-
+	if input.TLSMode != nil && strings.TrimSpace(*input.TLSMode) != "" {
+		tlsCfg.Mode = strings.TrimSpace(*input.TLSMode)
+	}
+	if input.TLSServerName != nil {
+		tlsCfg.ServerName = strings.TrimSpace(*input.TLSServerName)
+	}
+	if input.TLSClearFiles {
+		tlsCfg.CA, tlsCfg.Cert, tlsCfg.Key = nil, nil, nil
+	}
+	for _, f := range []struct {
+		input *string
+		dst   *[]byte
+	}{{input.TLSCA, &tlsCfg.CA}, {input.TLSCert, &tlsCfg.Cert}, {input.TLSKey, &tlsCfg.Key}} {
+		if f.input != nil && strings.TrimSpace(*f.input) != "" {
+			*f.dst = pemBytes(*f.input)
+		}
+	}
+	eng, err := engine.Lookup(target.Engine)
+	if err != nil {
+		return nil, err
+	}
+	// Only new options are checked, so a key saved before the check does not
+	// block unrelated edits.
 	options := target.Options
 	if input.Options != nil {
 		body, err := json.Marshal(input.Options)
 		if err != nil {
 			return nil, fmt.Errorf("options marshal: %w", err)
 		}
+		if err := checkOptions(eng, body); err != nil {
+			return nil, err
+		}
 		options = body
 	}
+	if input.FanoutPattern != nil {
+		target.FanoutPattern = strings.TrimSpace(*input.FanoutPattern)
+	}
+	if err := checkFanout(eng, target.FanoutPattern, options); err != nil {
+		return nil, err
+	}
+	check := target.engineTarget()
+	check.TLS = tlsCfg
+	check.Options = options
+	if err := eng.Validate(check); err != nil {
+		return nil, err
+	}
+
+	newPassword := enc.Password
+	if input.Password != nil && strings.TrimSpace(*input.Password) != "" {
+		encPwd, err := secret.Encrypt(key, []byte(*input.Password))
+		if err != nil {
+			return nil, err
+		}
+		newPassword = encPwd
+	}
+	newTLS, err := encryptTLS(key, tlsCfg)
+	if err != nil {
+		return nil, err
+	}
 
 	_, err = pool.Exec(ctx, `
 UPDATE db_targets
-SET host = $1, port = $2, dbname = $3, username = $4, password_enc = $5, options_json = $6
-WHERE id = $7
-`, target.Host, target.Port, target.DBName, target.Username, newPassword, options, id)
+SET host = $1, port = $2, dbname = $3, username = $4, password_enc = $5, options_json = $6, priority = $7,
+  tls_mode = $8, tls_server_name = $9, tls_ca_enc = $10, tls_cert_enc = $11, tls_key_enc = $12, fanout_pattern = $13
+WHERE id = $14
+`, target.Host, target.Port, target.DBName, target.Username, newPassword, options, target.Priority,
+		tlsCfg.Mode, tlsCfg.ServerName, newTLS.TLSCA, newTLS.TLSCert, newTLS.TLSKey, target.FanoutPattern, id)
 	if err != nil {
 		return nil, err
 	}
 
 	target.Options = options
+	target.TLS = newTLS.summary(tlsCfg.Mode, tlsCfg.ServerName)
 	return target, nil
 }
 
 func TestTargetConnection(ctx context.Context, pool *pgxpool.Pool, key []byte, targetID uuid.UUID) error {
-	target, encPwd, err := GetDBTarget(ctx, pool, targetID)
+	target, enc, err := GetDBTarget(ctx, pool, targetID)
 	if err != nil {
 		return err
 	}
 	if !target.IsActive {
 		return ErrDBTargetInactive
 	}
-	password, err := secret.Decrypt(key, encPwd)
+	conn, err := target.EngineTarget(key, enc)
 	if err != nil {
-		return fmt.Errorf("decrypt password: %w", err)
+		return err
+	}
+	eng, err := engine.Lookup(target.Engine)
+	if err != nil {
+		return err
 	}
 
 	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
-	switch strings.ToLower(target.Engine) {
-	case "postgres":
-		connStr := fmt.Sprintf("postgres://%s:%s@%s:%d/%s?sslmode=disable", url.QueryEscape(target.Username), url.QueryEscape(string(password)), target.Host, target.Port, url.PathEscape(target.DBName))
-		conn, err := pgx.Connect(ctx, connStr)
+	sess, err := eng.Open(ctx, conn, nil)
+	if err != nil {
+		return err
+	}
+	defer sess.Close(ctx)
+	return sess.Ping(ctx)
+}
+
+// EngineTarget is what the target's engine needs to connect to it, with the
+// credentials decrypted.
+func (t DBTarget) EngineTarget(key []byte, enc TargetSecrets) (engine.Target, error) {
+	out := t.engineTarget()
+	password, err := secret.Decrypt(key, enc.Password)
+	if err != nil {
+		return out, fmt.Errorf("decrypt password: %w", err)
+	}
+	out.Password = string(password)
+	tlsCfg, err := enc.decryptTLS(key)
+	if err != nil {
+		return out, err
+	}
+	out.TLS.CA, out.TLS.Cert, out.TLS.Key = tlsCfg.CA, tlsCfg.Cert, tlsCfg.Key
+	if enc.ssh.host != "" {
+		if out.Tunnel, err = enc.ssh.engineTunnel(key); err != nil {
+			return out, err
+		}
+	}
+	return out, nil
+}
+
+// engineTarget is the target without credentials.
+func (t DBTarget) engineTarget() engine.Target {
+	return engine.Target{
+		ID:       t.ID,
+		Host:     t.Host,
+		Port:     t.Port,
+		DBName:   t.DBName,
+		Username: t.Username,
+		TLS:      engine.TLS{Mode: t.TLS.Mode, ServerName: t.TLS.ServerName},
+		Options:  t.Options,
+	}
+}
+
+func (s TargetSecrets) summary(mode string, serverName string) TargetTLS {
+	return TargetTLS{Mode: mode, ServerName: serverName, HasCA: s.TLSCA != nil, HasClientCert: s.TLSCert != nil}
+}
+
+// decryptTLS returns the TLS key material; Mode and ServerName are not
+// secret and stay empty.
+func (s TargetSecrets) decryptTLS(key []byte) (engine.TLS, error) {
+	var out engine.TLS
+	for _, f := range []struct {
+		name string
+		enc  []byte
+		dst  *[]byte
+	}{{"ca", s.TLSCA, &out.CA}, {"client certificate", s.TLSCert, &out.Cert}, {"client key", s.TLSKey, &out.Key}} {
+		if f.enc == nil {
+			continue
+		}
+		plain, err := secret.Decrypt(key, f.enc)
 		if err != nil {
-			return err
+			return out, fmt.Errorf("decrypt tls %s: %w", f.name, err)
 		}
-		defer conn.Close(ctx)
-		return conn.Ping(ctx)
-	case "mysql":
-		cfg := mysql.Config{
-			User:                 target.Username,
-			Passwd:               string(password),
-			Net:                  "tcp",
-			Addr:                 net.JoinHostPort(target.Host, fmt.Sprintf("%d", target.Port)),
-			DBName:               target.DBName,
-			AllowNativePasswords: true,
-			Params:               map[string]string{},
+		*f.dst = plain
+	}
+	return out, nil
+}
+
+// encryptTLS encrypts the TLS key material that is set.
+func encryptTLS(key []byte, t engine.TLS) (TargetSecrets, error) {
+	var out TargetSecrets
+	for _, f := range []struct {
+		plain []byte
+		dst   *[]byte
+	}{{t.CA, &out.TLSCA}, {t.Cert, &out.TLSCert}, {t.Key, &out.TLSKey}} {
+		if len(f.plain) == 0 {
+			continue
 		}
-		db, err := sql.Open("mysql", cfg.FormatDSN())
+		enc, err := secret.Encrypt(key, f.plain)
 		if err != nil {
-			return err
+			return out, err
 		}
-		defer db.Close()
-		db.SetConnMaxLifetime(time.Minute)
-		return db.PingContext(ctx)
-	default:
-		return ErrDBTargetBadEngine
+		*f.dst = enc
 	}
+	return out, nil
 }
 
-func validateEngine(engine string) error {
-	engine = strings.ToLower(strings.TrimSpace(engine))
-	if engine != "postgres" && engine != "mysql" {
-		return ErrDBTargetBadEngine
+// pemBytes returns pasted PEM, or nil when blank.
+func pemBytes(s string) []byte {
+	if strings.TrimSpace(s) == "" {
+		return nil
 	}
-	return nil
+	return []byte(strings.TrimSpace(s) + "\n")
+}
+
+// Dialect returns the sqlscript dialect of the target's engine.
+func (t DBTarget) Dialect() (string, error) {
+	eng, err := engine.Lookup(t.Engine)
+	if err != nil {
+		return "", err
+	}
+	return eng.Dialect(), nil
+}
+
+// Address names the target database in logs and reports.
+func (t DBTarget) Address() string {
+	eng, err := engine.Lookup(t.Engine)
+	if err != nil {
+		return fmt.Sprintf("%s:%d/%s", t.Host, t.Port, t.DBName)
+	}
+	return eng.Address(t.engineTarget())
 }
@@ -0,0 +1,431 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"db_inner_migrator_syncer/internal/engine"
+)
+
+var ErrTargetSyncNotFound = errors.New("target ledger not synced yet")
+
+// Drift states of a migration key on a target, comparing the target ledger
+// with the hub migrations and run history.
+const (
+	// DriftInSync: recorded by a hub run or ledger import on this target with the current checksum.
+	DriftInSync = "in_sync"
+	// DriftPending: neither the ledger nor the hub has it applied.
+	DriftPending = "pending"
+	// DriftMissing: the hub applied it on this target but the ledger has no row.
+	DriftMissing = "missing"
+	// DriftOutOfBand: recorded in the ledger without a hub run on this target.
+	DriftOutOfBand = "out_of_band"
+	// DriftChecksumMismatch: recorded with a checksum other than the migration's.
+	DriftChecksumMismatch = "checksum_mismatch"
+	// DriftUnknown: recorded in the ledger under a key the project does not have.
+	DriftUnknown = "unknown"
+)
+
+// Target sync statuses.
+const (
+	TargetSyncOK    = "ok"
+	TargetSyncDrift = "drift"
+	TargetSyncError = "error"
+)
+
+// IsDrift reports whether a drift state means the hub and the target disagree.
+func IsDrift(state string) bool {
+	return state != DriftInSync && state != DriftPending
+}
+
+// LedgerRow is a row of a target ledger that is not marked rolled back.
+type LedgerRow = engine.LedgerRow
+
+// DriftEntry is the state of one migration key in a target sync.
+type DriftEntry struct {
+	Key              string     `json:"key"`
+	MigrationID      *uuid.UUID `json:"migration_id,omitempty"`
+	State            string     `json:"state"`
+	LedgerChecksumUp string     `json:"ledger_checksum_up,omitempty"`
+	ToolRunID        string     `json:"tool_run_id,omitempty"`
+	AppliedAt        string     `json:"applied_at,omitempty"`
+}
+
+// TargetSync is the latest comparison of a target ledger with the hub. A
+// failed sync keeps the entries of the last successful one.
+type TargetSync struct {
+	DBTargetID uuid.UUID    `json:"db_target_id"`
+	CheckedAt  *time.Time   `json:"checked_at,omitempty"`
+	Status     string       `json:"status"`
+	Error      *string      `json:"error,omitempty"`
+	Ledger     string       `json:"ledger"`
+	DriftCount int          `json:"drift_count"`
+	DriftSince *time.Time   `json:"drift_since,omitempty"`
+	Entries    []DriftEntry `json:"entries"`
+}
+
+// Entry returns the entry of a migration key, nil when the sync has none.
+func (s *TargetSync) Entry(key string) *DriftEntry {
+	for i := range s.Entries {
+		if s.Entries[i].Key == key {
+			return &s.Entries[i]
+		}
+	}
+	return nil
+}
+
+// ClassifyLedger compares the ledger rows of a target with the migrations of
+// its project and the hub runs on the target. Entries are sorted by key.
+func ClassifyLedger(ctx context.Context, pool *pgxpool.Pool, target *DBTarget, rows []LedgerRow) ([]DriftEntry, error) {
+	type hubMigration struct {
+		id         uuid.UUID
+		checksumUp string
+	}
+	migs := make(map[string]hubMigration)
+	migRows, err := pool.Query(ctx, `
+SELECT m.id, m.migration_key, m.checksum_up
+FROM migrations m
+JOIN db_sets s ON s.project_id = m.project_id
+WHERE s.id = $1
+`, target.DBSetID)
+	if err != nil {
+		return nil, err
+	}
+	for migRows.Next() {
+		var key string
+		var m hubMigration
+		if err := migRows.Scan(&m.id, &key, &m.checksumUp); err != nil {
+			migRows.Close()
+			return nil, err
+		}
+		migs[key] = m
+	}
+	migRows.Close()
+	if err := migRows.Err(); err != nil {
+		return nil, err
+	}
+
+	// What the hub believes: the last executed apply, baseline or rollback per migration.
+	hubApplied := make(map[uuid.UUID]bool)
+	appliedRows, err := pool.Query(ctx, `
+SELECT DISTINCT ON (r.migration_id) r.migration_id, r.run_type
+FROM run_items ri
+JOIN runs r ON r.id = ri.run_id
+WHERE ri.db_target_id = $1 AND ri.status = 'executed' AND r.run_type IN ('apply', 'baseline', 'rollback')
+ORDER BY r.migration_id, ri.finished_at DESC NULLS LAST
+`, target.ID)
+	if err != nil {
+		return nil, err
+	}
+	for appliedRows.Next() {
+		var migID uuid.UUID
+		var runType string
+		if err := appliedRows.Scan(&migID, &runType); err != nil {
+			appliedRows.Close()
+			return nil, err
+		}
+		hubApplied[migID] = runType != "rollback"
+	}
+	appliedRows.Close()
+	if err := appliedRows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Ledger rows written by a hub run: the tool_run_id executed this migration here.
+	runIDs := make([]string, 0, len(rows))
+	for _, row := range rows {
+		if row.ToolRunID != "" {
+			runIDs = append(runIDs, row.ToolRunID)
+		}
+	}
+	hubRuns := make(map[string]uuid.UUID)
+	runRows, err := pool.Query(ctx, `
+SELECT ri.run_id::text, r.migration_id
+FROM run_items ri
+JOIN runs r ON r.id = ri.run_id
+WHERE ri.db_target_id = $1 AND ri.status = 'executed' AND ri.run_id::text = ANY($2)
+`, target.ID, runIDs)
+	if err != nil {
+		return nil, err
+	}
+	for runRows.Next() {
+		var runID string
+		var migID uuid.UUID
+		if err := runRows.Scan(&runID, &migID); err != nil {
+			runRows.Close()
+			return nil, err
+		}
+		hubRuns[runID] = migID
+	}
+	runRows.Close()
+	if err := runRows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Ledger rows seeded by a ledger import on this target count as hub rows.
+	imports := make(map[string]bool)
+	importRows, err := pool.Query(ctx, `
+SELECT id::text FROM ledger_imports WHERE db_target_id = $1 AND id::text = ANY($2)
+`, target.ID, runIDs)
+	if err != nil {
+		return nil, err
+	}
+	for importRows.Next() {
+		var id string
+		if err := importRows.Scan(&id); err != nil {
+			importRows.Close()
+			return nil, err
+		}
+		imports[id] = true
+	}
+	importRows.Close()
+	if err := importRows.Err(); err != nil {
+		return nil, err
+	}
+
+	entries := make([]DriftEntry, 0, len(migs)+len(rows))
+	recorded := make(map[string]bool, len(rows))
+	for _, row := range rows {
+		recorded[row.Key] = true
+		entry := DriftEntry{Key: row.Key, LedgerChecksumUp: row.ChecksumUp, ToolRunID: row.ToolRunID, AppliedAt: row.AppliedAt}
+		m, ok := migs[row.Key]
+		switch {
+		case !ok:
+			entry.State = DriftUnknown
+		case row.ChecksumUp != m.checksumUp:
+			entry.State = DriftChecksumMismatch
+		case hubRuns[row.ToolRunID] != m.id && !imports[row.ToolRunID]:
+			entry.State = DriftOutOfBand
+		default:
+			entry.State = DriftInSync
+		}
+		if ok {
+			id := m.id
+			entry.MigrationID = &id
+		}
+		entries = append(entries, entry)
+	}
+	for key, m := range migs {
+		if recorded[key] {
+			continue
+		}
+		id := m.id
+		entry := DriftEntry{Key: key, MigrationID: &id, State: DriftPending}
+		if hubApplied[m.id] {
+			entry.State = DriftMissing
+		}
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Key < entries[j].Key })
+	return entries, nil
+}
+
+// ListDueTargetSyncs returns the active targets whose ledger was not claimed
+// for a sync within every. Fan-out targets have no single ledger to sync.
+func ListDueTargetSyncs(ctx context.Context, pool *pgxpool.Pool, every time.Duration) ([]uuid.UUID, error) {
+	rows, err := pool.Query(ctx, `
+SELECT t.id
+FROM db_targets t
+JOIN db_sets s ON s.id = t.db_set_id
+LEFT JOIN target_syncs ts ON ts.db_target_id = t.id
+WHERE t.is_active AND s.is_active AND t.fanout_pattern = ''
+  AND (ts.db_target_id IS NULL OR ts.claimed_at < now() - make_interval(secs => $1))
+ORDER BY ts.claimed_at NULLS FIRST
+`, every.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		out = append(out, id)
+	}
+	return out, rows.Err()
+}
+
+// ClaimTargetSync marks the target as being synced unless another server
+// claimed it within every. On-demand syncs claim with every = 0.
+func ClaimTargetSync(ctx context.Context, pool *pgxpool.Pool, targetID uuid.UUID, every time.Duration) (bool, error) {
+	var id uuid.UUID
+	err := pool.QueryRow(ctx, `
+INSERT INTO target_syncs (db_target_id, claimed_at) VALUES ($1, now())
+ON CONFLICT (db_target_id) DO UPDATE SET claimed_at = now()
+WHERE target_syncs.claimed_at < now() - make_interval(secs => $2)
+RETURNING db_target_id
+`, targetID, every.Seconds()).Scan(&id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// SaveTargetSync stores the result of a sync. For a successful sync it sets
+// the status, drift count and drift start from the entries and returns the
+// drift entries that the previous sync did not have, and whether an earlier
+// drift is now resolved. A failed sync only records the error.
+func SaveTargetSync(ctx context.Context, pool *pgxpool.Pool, sync *TargetSync) ([]DriftEntry, bool, error) {
+	now := time.Now().UTC()
+	sync.CheckedAt = &now
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	defer tx.Rollback(ctx) // nolint:errcheck
+
+	prev, err := scanTargetSync(tx.QueryRow(ctx, `SELECT `+targetSyncColumns+` FROM target_syncs WHERE db_target_id = $1 FOR UPDATE`, sync.DBTargetID))
+	if errors.Is(err, ErrTargetSyncNotFound) {
+		prev = &TargetSync{DBTargetID: sync.DBTargetID}
+	} else if err != nil {
+		return nil, false, err
+	}
+
+	if sync.Status == TargetSyncError {
+		if _, err := tx.Exec(ctx, `
+INSERT INTO target_syncs (db_target_id, checked_at, status, error, ledger) VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT (db_target_id) DO UPDATE SET checked_at = $2, status = $3, error = $4, ledger = $5
+`, sync.DBTargetID, now, sync.Status, sync.Error, sync.Ledger); err != nil {
+			return nil, false, err
+		}
+		return nil, false, tx.Commit(ctx)
+	}
+
+	had := make(map[string]string)
+	for _, e := range prev.Entries {
+		if IsDrift(e.State) {
+			had[e.Key] = e.State
+		}
+	}
+	var appeared []DriftEntry
+	sync.DriftCount = 0
+	for _, e := range sync.Entries {
+		if !IsDrift(e.State) {
+			continue
+		}
+		sync.DriftCount++
+		if had[e.Key] != e.State {
+			appeared = append(appeared, e)
+		}
+	}
+	sync.Status = TargetSyncOK
+	sync.Error = nil
+	sync.DriftSince = nil
+	if sync.DriftCount > 0 {
+		sync.Status = TargetSyncDrift
+		sync.DriftSince = prev.DriftSince
+		if sync.DriftSince == nil {
+			sync.DriftSince = &now
+		}
+	}
+	if sync.Entries == nil {
+		sync.Entries = []DriftEntry{}
+	}
+	if _, err := tx.Exec(ctx, `
+INSERT INTO target_syncs (db_target_id, checked_at, status, error, ledger, drift_count, drift_since, entries)
+VALUES ($1, $2, $3, NULL, $4, $5, $6, $7)
+ON CONFLICT (db_target_id) DO UPDATE SET checked_at = $2, status = $3, error = NULL, ledger = $4,
+  drift_count = $5, drift_since = $6, entries = $7
+`, sync.DBTargetID, now, sync.Status, sync.Ledger, sync.DriftCount, sync.DriftSince, sync.Entries); err != nil {
+		return nil, false, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, false, err
+	}
+	return appeared, prev.DriftCount > 0 && sync.DriftCount == 0, nil
+}
+
+// GetTargetSync returns the latest sync of a target.
+func GetTargetSync(ctx context.Context, pool *pgxpool.Pool, targetID uuid.UUID) (*TargetSync, error) {
+	return scanTargetSync(pool.QueryRow(ctx, `
+SELECT `+targetSyncColumns+` FROM target_syncs WHERE db_target_id = $1 AND status IS NOT NULL
+`, targetID))
+}
+
+// ListTargetSyncs returns the latest finished sync of each target in a project.
+func ListTargetSyncs(ctx context.Context, pool *pgxpool.Pool, projectID uuid.UUID) (map[uuid.UUID]TargetSync, error) {
+	rows, err := pool.Query(ctx, `
+SELECT `+targetSyncColumns+`
+FROM target_syncs
+JOIN db_targets t ON t.id = target_syncs.db_target_id
+JOIN db_sets s ON s.id = t.db_set_id
+WHERE s.project_id = $1 AND target_syncs.status IS NOT NULL
+`, projectID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := make(map[uuid.UUID]TargetSync)
+	for rows.Next() {
+		sync, err := scanTargetSync(rows)
+		if err != nil {
+			return nil, err
+		}
+		out[sync.DBTargetID] = *sync
+	}
+	return out, rows.Err()
+}
+
+// TargetDrift is a target whose latest sync found drift or failed.
+type TargetDrift struct {
+	TargetSync
+	DBSetName string
+	Env       string
+	Target    string
+}
+
+// ListTargetDrift returns the targets of a project whose latest sync found
+// drift or failed, longest drifting first.
+func ListTargetDrift(ctx context.Context, pool *pgxpool.Pool, projectID uuid.UUID) ([]TargetDrift, error) {
+	rows, err := pool.Query(ctx, `
+SELECT `+targetSyncColumns+`, s.name, s.env, t.host || ':' || t.port || '/' || t.dbname
+FROM target_syncs
+JOIN db_targets t ON t.id = target_syncs.db_target_id
+JOIN db_sets s ON s.id = t.db_set_id
+WHERE s.project_id = $1 AND t.is_active AND target_syncs.status IN ('drift', 'error')
+ORDER BY target_syncs.drift_since NULLS LAST, t.host, t.port, t.dbname
+`, projectID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []TargetDrift
+	for rows.Next() {
+		var d TargetDrift
+		var status *string
+		if err := rows.Scan(&d.DBTargetID, &d.CheckedAt, &status, &d.Error, &d.Ledger, &d.DriftCount, &d.DriftSince, &d.Entries, &d.DBSetName, &d.Env, &d.Target); err != nil {
+			return nil, err
+		}
+		if status != nil {
+			d.Status = *status
+		}
+		out = append(out, d)
+	}
+	return out, rows.Err()
+}
+
+const targetSyncColumns = `target_syncs.db_target_id, target_syncs.checked_at, target_syncs.status, target_syncs.error,
+  target_syncs.ledger, target_syncs.drift_count, target_syncs.drift_since, target_syncs.entries`
+
+func scanTargetSync(row pgx.Row) (*TargetSync, error) {
+	var s TargetSync
+	var status *string
+	if err := row.Scan(&s.DBTargetID, &s.CheckedAt, &status, &s.Error, &s.Ledger, &s.DriftCount, &s.DriftSince, &s.Entries); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrTargetSyncNotFound
+		}
+		return nil, err
+	}
+	if status != nil {
+		s.Status = *status
+	}
+	return &s, nil
+}
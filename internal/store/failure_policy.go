@@ -0,0 +1,82 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	FailurePolicyStopOnFirst = "stop_on_first_failure"
+	FailurePolicyContinue    = "continue_on_failure"
+
+	failurePolicyMaxPrefix = "max_failures="
+	maxParallelLimit       = 64
+)
+
+var (
+	ErrFailurePolicyInvalid = errors.New("invalid failure_policy; use stop_on_first_failure, continue_on_failure or max_failures=N")
+	ErrMaxParallelInvalid   = fmt.Errorf("max_parallel must be between 1 and %d", maxParallelLimit)
+)
+
+// FailurePolicy decides when a run stops starting new items after failures.
+type FailurePolicy struct {
+	// MaxFailures is the number of failed items that stops the run; 0 never stops.
+	MaxFailures int
+}
+
+// ParseFailurePolicy parses stop_on_first_failure, continue_on_failure or max_failures=N.
+func ParseFailurePolicy(policy string) (FailurePolicy, error) {
+	policy = strings.ToLower(strings.TrimSpace(policy))
+	switch {
+	case policy == "" || policy == FailurePolicyStopOnFirst:
+		return FailurePolicy{MaxFailures: 1}, nil
+	case policy == FailurePolicyContinue:
+		return FailurePolicy{}, nil
+	case strings.HasPrefix(policy, failurePolicyMaxPrefix):
+		n, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(policy, failurePolicyMaxPrefix)))
+		if err != nil || n < 1 {
+			return FailurePolicy{}, ErrFailurePolicyInvalid
+		}
+		return FailurePolicy{MaxFailures: n}, nil
+	default:
+		return FailurePolicy{}, ErrFailurePolicyInvalid
+	}
+}
+
+// ShouldStop reports whether the run must stop after the given number of failed items.
+func (p FailurePolicy) ShouldStop(failures int) bool {
+	return p.MaxFailures > 0 && failures >= p.MaxFailures
+}
+
+func (p FailurePolicy) String() string {
+	switch p.MaxFailures {
+	case 0:
+		return FailurePolicyContinue
+	case 1:
+		return FailurePolicyStopOnFirst
+	default:
+		return failurePolicyMaxPrefix + strconv.Itoa(p.MaxFailures)
+	}
+}
+
+// normalizeFailurePolicy returns the canonical form of policy. Empty input
+// stays empty so callers can tell "not set" from an explicit policy.
+func normalizeFailurePolicy(policy string) (string, error) {
+	if strings.TrimSpace(policy) == "" {
+		return "", nil
+	}
+	p, err := ParseFailurePolicy(policy)
+	if err != nil {
+		return "", err
+	}
+	return p.String(), nil
+}
+
+func validateMaxParallel(n int) error {
+	if n < 1 || n > maxParallelLimit {
+		return ErrMaxParallelInvalid
+	}
+	return nil
+}
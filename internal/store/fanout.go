@@ -0,0 +1,211 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"db_inner_migrator_syncer/internal/engine"
+)
+
+var (
+	// ErrFanoutUnsupported is returned for features that work on one
+	// database and so not on fan-out targets, such as drift detection and
+	// ledger imports.
+	ErrFanoutUnsupported = errors.New("not supported on fan-out targets")
+	// ErrTenantDiscovery marks a fan-out target whose tenants could not be
+	// listed when a run was requested.
+	ErrTenantDiscovery = errors.New("tenant discovery failed")
+	// ErrRunTenantsChanged refuses an approval whose fan-out tenants are not
+	// the ones the approver reviewed.
+	ErrRunTenantsChanged = errors.New("the tenants of the run changed; review the new list and approve again")
+)
+
+// TenantsChangedError is returned by ApproveRun when the tenants found at
+// approval differ from the run's items. The items were replaced with the new
+// list, which the next approval covers. It matches ErrRunTenantsChanged.
+type TenantsChangedError struct {
+	// Before and After are the tenants as <db_target_id>/<tenant>.
+	Before []string
+	After  []string
+}
+
+func (e *TenantsChangedError) Error() string        { return ErrRunTenantsChanged.Error() }
+func (e *TenantsChangedError) Is(target error) bool { return target == ErrRunTenantsChanged }
+
+// maxFanoutPattern bounds the LIKE pattern of a fan-out target.
+const maxFanoutPattern = 128
+
+// Fanout reports whether the target expands to the tenant schemas or
+// databases matching its pattern when a run is requested.
+func (t DBTarget) Fanout() bool {
+	return t.FanoutPattern != ""
+}
+
+// TenantKind names what the target's engine fans out to, "schema" or
+// "database", or is empty when it cannot.
+func (t DBTarget) TenantKind() string {
+	eng, err := engine.Lookup(t.Engine)
+	if err != nil {
+		return ""
+	}
+	return eng.TenantKind()
+}
+
+// checkFanout checks the fan-out pattern of a target. Each tenant keeps its
+// own ledger, so fan-out targets cannot point the ledger at a fixed schema.
+func checkFanout(eng engine.Engine, pattern string, options []byte) error {
+	if pattern == "" {
+		return nil
+	}
+	if eng.TenantKind() == "" {
+		return fmt.Errorf("%w: %s targets cannot fan out", ErrDBTargetInvalid, eng.Name())
+	}
+	if len(pattern) > maxFanoutPattern || strings.ContainsRune(pattern, 0) {
+		return fmt.Errorf("%w: fan-out pattern must be at most %d bytes", ErrDBTargetInvalid, maxFanoutPattern)
+	}
+	ledger, err := TargetLedger(options)
+	if err != nil {
+		return err
+	}
+	if ledger.Schema != "" {
+		return fmt.Errorf("%w: fan-out targets keep the ledger in each tenant; remove %s", ErrDBTargetInvalid, LedgerOptionSchema)
+	}
+	return nil
+}
+
+// expandTargets lists the run items of a request in rollout order: one per
+// plain target and one per tenant of a fan-out target, in name order.
+// Fan-out targets without tenants get no items.
+func expandTargets(targets []DBTarget, tenants map[uuid.UUID][]string) ([]RunItem, error) {
+	var items []RunItem
+	for _, t := range targets {
+		if !t.Fanout() {
+			items = append(items, RunItem{DBTargetID: t.ID})
+			continue
+		}
+		found, ok := tenants[t.ID]
+		if !ok {
+			return nil, fmt.Errorf("fan-out target %s: tenants not discovered", t.ID)
+		}
+		found = slices.Clone(found)
+		slices.Sort(found)
+		for _, tenant := range slices.Compact(found) {
+			items = append(items, RunItem{DBTargetID: t.ID, Tenant: tenant})
+		}
+	}
+	return items, nil
+}
+
+// refreezeRunItems checks the items of a run awaiting approval against the
+// tenants found at approval, so approval covers the tenants that exist when
+// it is given. The run keeps the targets it was requested for; its fan-out
+// targets are expanded again. When the list differs the items are replaced,
+// the wave plan is applied to the new list, the stored pre-flight report is
+// dropped and the change is returned: the approver reviewed other tenants.
+func refreezeRunItems(ctx context.Context, pool *pgxpool.Pool, tx pgx.Tx, run *Run, tenants map[uuid.UUID][]string) (*TenantsChangedError, error) {
+	var status string
+	if err := tx.QueryRow(ctx, `SELECT status FROM runs WHERE id = $1 FOR UPDATE`, run.ID).Scan(&status); err != nil {
+		return nil, err
+	}
+	if status != "awaiting_approval" {
+		return nil, ErrRunInvalidStatus
+	}
+	rows, err := tx.Query(ctx, `SELECT db_target_id, tenant FROM run_items WHERE run_id = $1 ORDER BY position`, run.ID)
+	if err != nil {
+		return nil, err
+	}
+	current, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (RunItem, error) {
+		var it RunItem
+		err := row.Scan(&it.DBTargetID, &it.Tenant)
+		return it, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	requested := make(map[uuid.UUID]bool, len(current))
+	for _, it := range current {
+		requested[it.DBTargetID] = true
+	}
+
+	targets, err := ListDBTargetsBySet(ctx, pool, run.DBSetID)
+	if err != nil {
+		return nil, err
+	}
+	var keep []DBTarget
+	for _, t := range targets {
+		if _, found := tenants[t.ID]; requested[t.ID] && (found || !t.Fanout()) {
+			keep = append(keep, t)
+		}
+	}
+	items, err := expandTargets(keep, tenants)
+	if err != nil {
+		return nil, err
+	}
+	if len(items) == 0 {
+		return nil, ErrRunNoTargets
+	}
+	if slices.EqualFunc(items, current, func(a, b RunItem) bool {
+		return a.DBTargetID == b.DBTargetID && a.Tenant == b.Tenant
+	}) {
+		return nil, nil
+	}
+
+	sizes, err := ParseWavePlan(run.WavePlan)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM run_items WHERE run_id = $1`, run.ID); err != nil {
+		return nil, err
+	}
+	if err := insertRunItems(ctx, tx, run.ID, items, sizes); err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(ctx, `UPDATE runs SET preflight = NULL WHERE id = $1`, run.ID); err != nil {
+		return nil, err
+	}
+	return &TenantsChangedError{Before: itemTenants(current), After: itemTenants(items)}, nil
+}
+
+// itemTenants lists the tenants of run items as <db_target_id>/<tenant>, like
+// ListRunTenants.
+func itemTenants(items []RunItem) []string {
+	out := []string{}
+	for _, it := range items {
+		if it.Tenant != "" {
+			out = append(out, it.DBTargetID.String()+"/"+it.Tenant)
+		}
+	}
+	return out
+}
+
+// ListRunTenants returns the tenants a run covers, as target id/tenant in
+// rollout order, or nil when it has no fan-out targets. The list is fixed
+// when the run is approved.
+func ListRunTenants(ctx context.Context, pool *pgxpool.Pool, runID uuid.UUID) ([]string, error) {
+	rows, err := pool.Query(ctx, `
+SELECT db_target_id::text || '/' || tenant
+FROM run_items
+WHERE run_id = $1 AND tenant <> ''
+ORDER BY position
+`, runID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []string
+	for rows.Next() {
+		var s string
+		if err := rows.Scan(&s); err != nil {
+			return nil, err
+		}
+		out = append(out, s)
+	}
+	return out, rows.Err()
+}
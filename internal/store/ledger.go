@@ -0,0 +1,64 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"db_inner_migrator_syncer/internal/engine"
+)
+
+// Ledger keys used in target options_json.
+const (
+	LedgerOptionTable  = "ledger_table"
+	LedgerOptionSchema = "ledger_schema"
+
+	// DefaultLedgerTable is the ledger table name when the target sets none.
+	DefaultLedgerTable = "migrate_hub_migrations"
+)
+
+var ErrLedgerNameInvalid = errors.New("invalid ledger_table or ledger_schema; use lower-case letters, digits and underscores (max 63, not starting with a digit)")
+
+var ledgerNamePattern = regexp.MustCompile(`^[a-z_][a-z0-9_]{0,62}$`)
+
+// Ledger locates the migration ledger table on a target.
+type Ledger = engine.Ledger
+
+// TargetLedger reads the ledger location from a target's options_json.
+func TargetLedger(options json.RawMessage) (Ledger, error) {
+	l := Ledger{Table: DefaultLedgerTable}
+	if len(options) == 0 {
+		return l, nil
+	}
+	var raw map[string]any
+	if err := json.Unmarshal(options, &raw); err != nil {
+		return l, fmt.Errorf("options: %w", err)
+	}
+	for _, f := range []struct {
+		key string
+		dst *string
+	}{
+		{LedgerOptionTable, &l.Table},
+		{LedgerOptionSchema, &l.Schema},
+	} {
+		val, ok := raw[f.key]
+		if !ok || val == nil {
+			continue
+		}
+		s, ok := val.(string)
+		if !ok {
+			return l, fmt.Errorf("%s: %w", f.key, ErrLedgerNameInvalid)
+		}
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		if !ledgerNamePattern.MatchString(s) {
+			return l, fmt.Errorf("%s: %w", f.key, ErrLedgerNameInvalid)
+		}
+		*f.dst = s
+	}
+	return l, nil
+}
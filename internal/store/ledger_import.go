@@ -0,0 +1,216 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Ledger tools an import can read, by the table they keep on the target.
+const (
+	ImportFlyway        = "flyway"         // flyway_schema_history
+	ImportGolangMigrate = "golang-migrate" // schema_migrations
+	ImportGoose         = "goose"          // goose_db_version
+	ImportLiquibase     = "liquibase"      // DATABASECHANGELOG
+)
+
+// What an import does with each source entry.
+const (
+	// ImportCreate creates the migration in the project and seeds the target ledger.
+	ImportCreate = "create"
+	// ImportSeed seeds the target ledger with an existing migration of the same key.
+	ImportSeed = "seed"
+	// ImportRecorded: the target ledger already records the key; nothing to do.
+	ImportRecorded = "recorded"
+	// ImportSkip: the source entry is not applied (failed, dirty, undone).
+	ImportSkip = "skip"
+)
+
+var (
+	ErrImportSourceInvalid = errors.New("invalid import source; use flyway, golang-migrate, goose or liquibase")
+	ErrImportSourceMissing = errors.New("source ledger table not found on the target")
+	ErrImportNothing       = errors.New("nothing to import")
+	ErrImportPreviewStale  = errors.New("the import plan changed since the preview; preview again")
+)
+
+// ImportSources lists the import sources in display order.
+var ImportSources = []string{ImportFlyway, ImportGolangMigrate, ImportGoose, ImportLiquibase}
+
+// ImportSourceTables names the ledger table of each import source.
+var ImportSourceTables = map[string]string{
+	ImportFlyway:        "flyway_schema_history",
+	ImportGolangMigrate: "schema_migrations",
+	ImportGoose:         "goose_db_version",
+	ImportLiquibase:     "DATABASECHANGELOG",
+}
+
+// NormalizeImportSource validates an import source name.
+func NormalizeImportSource(source string) (string, error) {
+	source = strings.ToLower(strings.TrimSpace(source))
+	if _, ok := ImportSourceTables[source]; !ok {
+		return "", ErrImportSourceInvalid
+	}
+	return source, nil
+}
+
+// ImportSourceEntry is an applied (or skipped) migration read from another
+// tool's ledger table.
+type ImportSourceEntry struct {
+	Key       string
+	Name      string
+	Detail    string // script, file or version as the tool records it
+	Checksum  string // the tool's own checksum, if it keeps one
+	AppliedAt string
+	// SkipReason is set for entries that are not applied.
+	SkipReason string
+}
+
+// ImportBody is SQL supplied for an imported key; the source tables keep none.
+type ImportBody struct {
+	SQLUp   string  `json:"sql_up"`
+	SQLDown *string `json:"sql_down,omitempty"`
+}
+
+// ImportEntry is one source entry of an import plan.
+type ImportEntry struct {
+	Key            string     `json:"key"`
+	Name           string     `json:"name"`
+	Detail         string     `json:"detail,omitempty"`
+	SourceChecksum string     `json:"source_checksum,omitempty"`
+	AppliedAt      string     `json:"applied_at,omitempty"`
+	Action         string     `json:"action"`
+	Reason         string     `json:"reason,omitempty"`
+	MigrationID    *uuid.UUID `json:"migration_id,omitempty"`
+	HasBody        bool       `json:"has_body"`
+}
+
+// ImportPlan is what an import of one target ledger will do (preview) or did
+// (after commit, with ID set). Hash identifies the plan so a commit can check
+// that it still matches the preview.
+type ImportPlan struct {
+	ID         *uuid.UUID    `json:"id,omitempty"`
+	DBTargetID uuid.UUID     `json:"db_target_id"`
+	Source     string        `json:"source"`
+	Table      string        `json:"table"`
+	Ledger     string        `json:"ledger"`
+	Entries    []ImportEntry `json:"entries"`
+	Hash       string        `json:"preview_hash"`
+	Create     int           `json:"create"`
+	Seed       int           `json:"seed"`
+	Recorded   int           `json:"recorded"`
+	Skip       int           `json:"skip"`
+}
+
+// BuildImportPlan decides the action for each source entry: keys already in
+// the target ledger are recorded, keys the project has are seeded with that
+// migration, the rest are created. bodies supplies SQL for created keys.
+func BuildImportPlan(ctx context.Context, pool *pgxpool.Pool, projectID uuid.UUID, plan *ImportPlan, entries []ImportSourceEntry, recorded map[string]bool, bodies map[string]ImportBody) error {
+	migs, err := ListMigrations(ctx, pool, projectID, "")
+	if err != nil {
+		return err
+	}
+	byKey := make(map[string]Migration, len(migs))
+	for _, m := range migs {
+		byKey[m.Key] = m
+	}
+
+	plan.Entries = plan.Entries[:0]
+	plan.Create, plan.Seed, plan.Recorded, plan.Skip = 0, 0, 0, 0
+	seen := make(map[string]bool, len(entries))
+	hash := []string{plan.Source, plan.Table, plan.Ledger}
+	for _, src := range entries {
+		entry := ImportEntry{Key: src.Key, Name: src.Name, Detail: src.Detail, SourceChecksum: src.Checksum, AppliedAt: src.AppliedAt}
+		_, entry.HasBody = bodies[src.Key]
+		switch {
+		case src.SkipReason != "":
+			entry.Action, entry.Reason = ImportSkip, src.SkipReason
+		case seen[src.Key]:
+			entry.Action, entry.Reason = ImportSkip, "duplicate key"
+		case recorded[src.Key]:
+			entry.Action = ImportRecorded
+		default:
+			if m, ok := byKey[src.Key]; ok {
+				id := m.ID
+				entry.Action, entry.MigrationID = ImportSeed, &id
+				entry.Reason = "migration exists (version " + fmt.Sprint(m.Version) + ")"
+			} else {
+				entry.Action = ImportCreate
+				if !entry.HasBody {
+					entry.Reason = "no SQL body; a placeholder is stored"
+				}
+			}
+		}
+		if entry.Action != ImportSkip {
+			seen[src.Key] = true
+		}
+		switch entry.Action {
+		case ImportCreate:
+			plan.Create++
+		case ImportSeed:
+			plan.Seed++
+		case ImportRecorded:
+			plan.Recorded++
+		case ImportSkip:
+			plan.Skip++
+		}
+		plan.Entries = append(plan.Entries, entry)
+		hash = append(hash, entry.Key, entry.Action, entry.SourceChecksum, fmt.Sprint(entry.HasBody))
+	}
+	plan.Hash = checksum(strings.Join(hash, "\x00"))
+	return nil
+}
+
+// ImportPlaceholderSQL is the sql_up of a created migration without a body. It
+// has no statements, so the migration cannot be applied to other targets
+// until real SQL is added.
+func ImportPlaceholderSQL(plan *ImportPlan, entry ImportEntry) string {
+	return fmt.Sprintf("-- imported from %s (%s)\n-- %s\n-- applied outside migrate-hub; add the SQL before applying it to other targets\n", plan.Table, plan.Source, entry.Detail)
+}
+
+// CreateImportedMigrations creates the migrations of the create entries in tx
+// and fills in their ids. A key created meanwhile by someone else fails the
+// import.
+func CreateImportedMigrations(ctx context.Context, tx pgx.Tx, projectID uuid.UUID, plan *ImportPlan, bodies map[string]ImportBody, createdBy uuid.UUID) ([]Migration, error) {
+	var created []Migration
+	for i := range plan.Entries {
+		entry := &plan.Entries[i]
+		if entry.Action != ImportCreate {
+			continue
+		}
+		input := CreateMigrationInput{
+			ProjectID:       projectID,
+			Key:             entry.Key,
+			Name:            entry.Name,
+			Description:     fmt.Sprintf("Imported from %s on target %s (%s).", plan.Table, plan.DBTargetID, entry.Detail),
+			SQLUp:           ImportPlaceholderSQL(plan, *entry),
+			TransactionMode: "auto",
+			CreatedBy:       createdBy,
+		}
+		if body, ok := bodies[entry.Key]; ok {
+			input.SQLUp, input.SQLDown = body.SQLUp, body.SQLDown
+		}
+		mig, err := createMigration(ctx, tx, input)
+		if err != nil {
+			return created, fmt.Errorf("%s: %w", entry.Key, err)
+		}
+		entry.MigrationID = &mig.ID
+		created = append(created, *mig)
+	}
+	return created, nil
+}
+
+// SaveLedgerImport records an import in tx; its id is the tool_run_id of the
+// ledger rows it seeds.
+func SaveLedgerImport(ctx context.Context, tx pgx.Tx, projectID uuid.UUID, plan *ImportPlan, importedBy uuid.UUID) error {
+	_, err := tx.Exec(ctx, `
+INSERT INTO ledger_imports (id, project_id, db_target_id, source, source_table, ledger, entries, imported_by, imported_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+`, plan.ID, projectID, plan.DBTargetID, plan.Source, plan.Table, plan.Ledger, plan.Entries, importedBy, time.Now().UTC())
+	return err
+}
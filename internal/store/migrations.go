@@ -5,6 +5,7 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"errors"
+	"fmt"
 	"strings"
 	"time"
 
@@ -12,14 +13,18 @@ import (
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
+
+	"db_inner_migrator_syncer/internal/engine"
+	"db_inner_migrator_syncer/internal/sqlscript"
 )
 
 var (
-	ErrMigrationNotFound   = errors.New("migration not found")
-	ErrMigrationKeyEmpty   = errors.New("migration key required")
-	ErrMigrationNameEmpty  = errors.New("migration name required")
-	ErrMigrationSQLMissing = errors.New("migration sql_up required")
-	ErrTxModeInvalid       = errors.New("invalid transaction_mode")
+	ErrMigrationNotFound    = errors.New("migration not found")
+	ErrMigrationKeyEmpty    = errors.New("migration key required")
+	ErrMigrationNameEmpty   = errors.New("migration name required")
+	ErrMigrationSQLMissing  = errors.New("migration sql_up required")
+	ErrTxModeInvalid        = errors.New("invalid transaction_mode")
+	ErrTxModeImplicitCommit = errors.New("single_transaction is not possible on MySQL because the script commits implicitly; use auto or no_transaction")
 )
 
 type Migration struct {
@@ -35,9 +40,20 @@ type Migration struct {
 	ChecksumDown    *string   `json:"checksum_down,omitempty"`
 	Version         int       `json:"version"`
 	TransactionMode string    `json:"transaction_mode"`
-	CreatedBy       uuid.UUID `json:"created_by"`
-	CreatedAt       time.Time `json:"created_at"`
-	UpdatedAt       time.Time `json:"updated_at"`
+	FailurePolicy   *string   `json:"failure_policy,omitempty"`
+	// Timeout overrides; unset values fall back to the target options_json.
+	AdvisoryLockTimeout *string   `json:"advisory_lock_timeout,omitempty"`
+	LockTimeout         *string   `json:"lock_timeout,omitempty"`
+	StatementTimeout    *string   `json:"statement_timeout,omitempty"`
+	CreatedBy           uuid.UUID `json:"created_by"`
+	CreatedAt           time.Time `json:"created_at"`
+	UpdatedAt           time.Time `json:"updated_at"`
+
+	// Assertion queries run around sql_up (see Checks); covered by ChecksumUp.
+	PrecheckSQL     *string `json:"precheck_sql,omitempty"`
+	PrecheckExpect  string  `json:"precheck_expect"`
+	PostcheckSQL    *string `json:"postcheck_sql,omitempty"`
+	PostcheckExpect string  `json:"postcheck_expect"`
 }
 
 type CreateMigrationInput struct {
@@ -49,7 +65,18 @@ type CreateMigrationInput struct {
 	SQLUp           string
 	SQLDown         *string
 	TransactionMode string
-	CreatedBy       uuid.UUID
+	FailurePolicy   string // empty defers to the db set policy
+	// Timeouts as durations (e.g. "30s"); empty defers to the target options.
+	AdvisoryLockTimeout string
+	LockTimeout         string
+	StatementTimeout    string
+	CreatedBy           uuid.UUID
+
+	// Optional assertion queries; expect is true (default) or no_rows.
+	PrecheckSQL     string
+	PrecheckExpect  string
+	PostcheckSQL    string
+	PostcheckExpect string
 }
 
 type UpdateMigrationInput struct {
@@ -59,9 +86,25 @@ type UpdateMigrationInput struct {
 	SQLUp           *string `json:"sql_up"`
 	SQLDown         *string `json:"sql_down"`
 	TransactionMode *string `json:"transaction_mode"`
+	FailurePolicy   *string `json:"failure_policy"` // empty string clears the override
+	// Empty strings clear the timeout overrides.
+	AdvisoryLockTimeout *string `json:"advisory_lock_timeout"`
+	LockTimeout         *string `json:"lock_timeout"`
+	StatementTimeout    *string `json:"statement_timeout"`
+
+	// Empty SQL removes the check.
+	PrecheckSQL     *string `json:"precheck_sql"`
+	PrecheckExpect  *string `json:"precheck_expect"`
+	PostcheckSQL    *string `json:"postcheck_sql"`
+	PostcheckExpect *string `json:"postcheck_expect"`
 }
 
 func CreateMigration(ctx context.Context, pool *pgxpool.Pool, input CreateMigrationInput) (*Migration, error) {
+	return createMigration(ctx, pool, input)
+}
+
+// createMigration creates a migration on the pool or inside a transaction.
+func createMigration(ctx context.Context, db querier, input CreateMigrationInput) (*Migration, error) {
 	if strings.TrimSpace(input.Key) == "" {
 		return nil, ErrMigrationKeyEmpty
 	}
@@ -75,20 +118,47 @@ func CreateMigration(ctx context.Context, pool *pgxpool.Pool, input CreateMigrat
 	if mode == "" {
 		return nil, ErrTxModeInvalid
 	}
+	policy, err := normalizeFailurePolicy(input.FailurePolicy)
+	if err != nil {
+		return nil, err
+	}
+	advisoryLockTimeout, err := normalizeTimeout(TimeoutAdvisoryLock, input.AdvisoryLockTimeout)
+	if err != nil {
+		return nil, err
+	}
+	lockTimeout, err := normalizeTimeout(TimeoutLock, input.LockTimeout)
+	if err != nil {
+		return nil, err
+	}
+	statementTimeout, err := normalizeTimeout(TimeoutStatement, input.StatementTimeout)
+	if err != nil {
+		return nil, err
+	}
+	precheckSQL, precheckExpect, err := normalizeCheck(input.PrecheckSQL, input.PrecheckExpect)
+	if err != nil {
+		return nil, err
+	}
+	postcheckSQL, postcheckExpect, err := normalizeCheck(input.PostcheckSQL, input.PostcheckExpect)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkProjectTxMode(ctx, db, input.ProjectID, mode, input.SQLUp, input.SQLDown); err != nil {
+		return nil, err
+	}
 
 	now := time.Now().UTC()
 	id := uuid.New()
-	checksumUp := checksum(input.SQLUp)
+	checksumUp := sqlUpChecksum(input.SQLUp, newCheck(CheckPre, precheckSQL, precheckExpect), newCheck(CheckPost, postcheckSQL, postcheckExpect))
 	var checksumDown *string
 	if input.SQLDown != nil {
 		down := checksum(*input.SQLDown)
 		checksumDown = &down
 	}
 
-	_, err := pool.Exec(ctx, `
-INSERT INTO migrations (id, project_id, migration_key, name, jira, description, sql_up, sql_down, checksum_up, checksum_down, version, transaction_mode, created_by, created_at, updated_at)
-VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, 1, $11, $12, $13, $13)
-`, id, input.ProjectID, input.Key, input.Name, input.Jira, input.Description, input.SQLUp, input.SQLDown, checksumUp, checksumDown, mode, input.CreatedBy, now)
+	_, err = db.Exec(ctx, `
+INSERT INTO migrations (id, project_id, migration_key, name, jira, description, sql_up, sql_down, checksum_up, checksum_down, version, transaction_mode, failure_policy, advisory_lock_timeout, lock_timeout, statement_timeout, precheck_sql, precheck_expect, postcheck_sql, postcheck_expect, created_by, created_at, updated_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, 1, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $21)
+`, id, input.ProjectID, input.Key, input.Name, input.Jira, input.Description, input.SQLUp, input.SQLDown, checksumUp, checksumDown, mode, nullableString(policy), advisoryLockTimeout, lockTimeout, statementTimeout, precheckSQL, precheckExpect, postcheckSQL, postcheckExpect, input.CreatedBy, now)
 	if err != nil {
 		var pgErr *pgconn.PgError
 		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
@@ -110,25 +180,34 @@ VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, 1, $11, $12, $13, $13)
 		ChecksumDown:    checksumDown,
 		Version:         1,
 		TransactionMode: mode,
-		CreatedBy:       input.CreatedBy,
-		CreatedAt:       now,
-		UpdatedAt:       now,
+		FailurePolicy:   nullableString(policy),
+
+		AdvisoryLockTimeout: advisoryLockTimeout,
+		LockTimeout:         lockTimeout,
+		StatementTimeout:    statementTimeout,
+		PrecheckSQL:         precheckSQL,
+		PrecheckExpect:      precheckExpect,
+		PostcheckSQL:        postcheckSQL,
+		PostcheckExpect:     postcheckExpect,
+		CreatedBy:           input.CreatedBy,
+		CreatedAt:           now,
+		UpdatedAt:           now,
 	}, nil
 }
 
 func GetMigration(ctx context.Context, pool *pgxpool.Pool, projectID uuid.UUID, id uuid.UUID) (*Migration, error) {
-	var m Migration
-	if err := pool.QueryRow(ctx, `
-SELECT id, project_id, migration_key, name, jira, description, sql_up, sql_down, checksum_up, checksum_down, version, transaction_mode, created_by, created_at, updated_at
+	m, err := scanMigration(pool.QueryRow(ctx, `
+SELECT `+migrationColumns+`
 FROM migrations
 WHERE id = $1 AND project_id = $2
-`, id, projectID).Scan(&m.ID, &m.ProjectID, &m.Key, &m.Name, &m.Jira, &m.Description, &m.SQLUp, &m.SQLDown, &m.ChecksumUp, &m.ChecksumDown, &m.Version, &m.TransactionMode, &m.CreatedBy, &m.CreatedAt, &m.UpdatedAt); err != nil {
+`, id, projectID))
+	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return nil, ErrMigrationNotFound
 		}
 		return nil, err
 	}
-	return &m, nil
+	return m, nil
 }
 
 func ListMigrations(ctx context.Context, pool *pgxpool.Pool, projectID uuid.UUID, search string) ([]Migration, error) {
@@ -137,14 +216,14 @@ func ListMigrations(ctx context.Context, pool *pgxpool.Pool, projectID uuid.UUID
 	if search != "" {
 		pattern := "%" + strings.ToLower(search) + "%"
 		rows, err = pool.Query(ctx, `
-SELECT id, project_id, migration_key, name, jira, description, sql_up, sql_down, checksum_up, checksum_down, version, transaction_mode, created_by, created_at, updated_at
+SELECT `+migrationColumns+`
 FROM migrations
 WHERE project_id = $1 AND (LOWER(migration_key) LIKE $2 OR LOWER(name) LIKE $2 OR LOWER(jira) LIKE $2)
 ORDER BY created_at DESC
 `, projectID, pattern)
 	} else {
 		rows, err = pool.Query(ctx, `
-SELECT id, project_id, migration_key, name, jira, description, sql_up, sql_down, checksum_up, checksum_down, version, transaction_mode, created_by, created_at, updated_at
+SELECT `+migrationColumns+`
 FROM migrations
 WHERE project_id = $1
 ORDER BY created_at DESC
@@ -157,11 +236,11 @@ ORDER BY created_at DESC
 
 	var list []Migration
 	for rows.Next() {
-		var m Migration
-		if err := rows.Scan(&m.ID, &m.ProjectID, &m.Key, &m.Name, &m.Jira, &m.Description, &m.SQLUp, &m.SQLDown, &m.ChecksumUp, &m.ChecksumDown, &m.Version, &m.TransactionMode, &m.CreatedBy, &m.CreatedAt, &m.UpdatedAt); err != nil {
+		m, err := scanMigration(rows)
+		if err != nil {
 			return nil, err
 		}
-		list = append(list, m)
+		list = append(list, *m)
 	}
 	return list, rows.Err()
 }
@@ -199,6 +278,44 @@ func UpdateMigration(ctx context.Context, pool *pgxpool.Pool, projectID uuid.UUI
 		txMode = mode
 	}
 
+	policy := current.FailurePolicy
+	if input.FailurePolicy != nil {
+		normalized, err := normalizeFailurePolicy(*input.FailurePolicy)
+		if err != nil {
+			return nil, false, err
+		}
+		policy = nullableString(normalized)
+	}
+
+	advisoryLockTimeout, lockTimeout, statementTimeout := current.AdvisoryLockTimeout, current.LockTimeout, current.StatementTimeout
+	for _, f := range []struct {
+		key   string
+		input *string
+		dst   **string
+	}{
+		{TimeoutAdvisoryLock, input.AdvisoryLockTimeout, &advisoryLockTimeout},
+		{TimeoutLock, input.LockTimeout, &lockTimeout},
+		{TimeoutStatement, input.StatementTimeout, &statementTimeout},
+	} {
+		if f.input == nil {
+			continue
+		}
+		normalized, err := normalizeTimeout(f.key, *f.input)
+		if err != nil {
+			return nil, false, err
+		}
+		*f.dst = normalized
+	}
+
+	precheckSQL, precheckExpect, err := updateCheck(current.PrecheckSQL, current.PrecheckExpect, input.PrecheckSQL, input.PrecheckExpect)
+	if err != nil {
+		return nil, false, err
+	}
+	postcheckSQL, postcheckExpect, err := updateCheck(current.PostcheckSQL, current.PostcheckExpect, input.PostcheckSQL, input.PostcheckExpect)
+	if err != nil {
+		return nil, false, err
+	}
+
 	if strings.TrimSpace(name) == "" {
 		return nil, false, ErrMigrationNameEmpty
 	}
@@ -213,13 +330,22 @@ func UpdateMigration(ctx context.Context, pool *pgxpool.Pool, projectID uuid.UUI
 	if sqlDown != nil && current.SQLDown != nil && *sqlDown != *current.SQLDown {
 		sqlChanged = true
 	}
+	if !equalNullable(precheckSQL, current.PrecheckSQL) || !equalNullable(postcheckSQL, current.PostcheckSQL) ||
+		precheckExpect != current.PrecheckExpect || postcheckExpect != current.PostcheckExpect {
+		sqlChanged = true
+	}
+	if sqlChanged || txMode != current.TransactionMode {
+		if err := checkProjectTxMode(ctx, pool, projectID, txMode, sqlUp, sqlDown); err != nil {
+			return nil, false, err
+		}
+	}
 
 	version := current.Version
 	checksumUp := current.ChecksumUp
 	checksumDown := current.ChecksumDown
 	if sqlChanged {
 		version++
-		checksumUp = checksum(sqlUp)
+		checksumUp = sqlUpChecksum(sqlUp, newCheck(CheckPre, precheckSQL, precheckExpect), newCheck(CheckPost, postcheckSQL, postcheckExpect))
 		if sqlDown != nil {
 			down := checksum(*sqlDown)
 			checksumDown = &down
@@ -233,9 +359,11 @@ func UpdateMigration(ctx context.Context, pool *pgxpool.Pool, projectID uuid.UUI
 UPDATE migrations
 SET name = $1, jira = $2, description = $3, sql_up = $4, sql_down = $5,
     checksum_up = $6, checksum_down = $7, version = $8, transaction_mode = $9,
-    updated_at = $10
-WHERE id = $11 AND project_id = $12
-`, name, jira, description, sqlUp, sqlDown, checksumUp, checksumDown, version, txMode, now, id, projectID)
+    failure_policy = $10, advisory_lock_timeout = $11, lock_timeout = $12, statement_timeout = $13,
+    precheck_sql = $14, precheck_expect = $15, postcheck_sql = $16, postcheck_expect = $17, updated_at = $18
+WHERE id = $19 AND project_id = $20
+`, name, jira, description, sqlUp, sqlDown, checksumUp, checksumDown, version, txMode, policy, advisoryLockTimeout, lockTimeout, statementTimeout,
+		precheckSQL, precheckExpect, postcheckSQL, postcheckExpect, now, id, projectID)
 	if err != nil {
 		return nil, sqlChanged, err
 	}
@@ -249,6 +377,14 @@ WHERE id = $11 AND project_id = $12
 	current.ChecksumDown = checksumDown
 	current.Version = version
 	current.TransactionMode = txMode
+	current.FailurePolicy = policy
+	current.AdvisoryLockTimeout = advisoryLockTimeout
+	current.LockTimeout = lockTimeout
+	current.StatementTimeout = statementTimeout
+	current.PrecheckSQL = precheckSQL
+	current.PrecheckExpect = precheckExpect
+	current.PostcheckSQL = postcheckSQL
+	current.PostcheckExpect = postcheckExpect
 	current.UpdatedAt = now
 
 	return current, sqlChanged, nil
@@ -259,6 +395,16 @@ func DeleteApprovalsForMigration(ctx context.Context, pool *pgxpool.Pool, migrat
 	return err
 }
 
+const migrationColumns = `id, project_id, migration_key, name, jira, description, sql_up, sql_down, checksum_up, checksum_down, version, transaction_mode, failure_policy, advisory_lock_timeout, lock_timeout, statement_timeout, precheck_sql, precheck_expect, postcheck_sql, postcheck_expect, created_by, created_at, updated_at`
+
+func scanMigration(row pgx.Row) (*Migration, error) {
+	var m Migration
+	if err := row.Scan(&m.ID, &m.ProjectID, &m.Key, &m.Name, &m.Jira, &m.Description, &m.SQLUp, &m.SQLDown, &m.ChecksumUp, &m.ChecksumDown, &m.Version, &m.TransactionMode, &m.FailurePolicy, &m.AdvisoryLockTimeout, &m.LockTimeout, &m.StatementTimeout, &m.PrecheckSQL, &m.PrecheckExpect, &m.PostcheckSQL, &m.PostcheckExpect, &m.CreatedBy, &m.CreatedAt, &m.UpdatedAt); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
 func checksum(sql string) string {
 	sum := sha256.Sum256([]byte(sql))
 	return hex.EncodeToString(sum[:])
@@ -277,6 +423,74 @@ func normalizeTxMode(mode string) string {
 	}
 }
 
+// CheckTxMode rejects single_transaction in the MySQL dialect when a statement
+// would commit the transaction implicitly, since the script could then apply
+// partially.
+func CheckTxMode(dialect string, mode string, stmts []sqlscript.Statement) error {
+	if mode != "single_transaction" || dialect != "mysql" {
+		return nil
+	}
+	if stmt, reason, ok := sqlscript.FirstTransactionBlocker(dialect, stmts); ok {
+		return fmt.Errorf("%w: statement %d (line %d): %s", ErrTxModeImplicitCommit, stmt.Index, stmt.Line, reason)
+	}
+	return nil
+}
+
+// checkProjectTxMode runs CheckTxMode on both scripts of a migration when
+// its project has active MySQL targets. Scripts that do not split are
+// reported by the executor per item.
+func checkProjectTxMode(ctx context.Context, db querier, projectID uuid.UUID, mode string, sqlUp string, sqlDown *string) error {
+	if mode != "single_transaction" {
+		return nil
+	}
+	rows, err := db.Query(ctx, `
+SELECT DISTINCT t.engine
+FROM db_targets t
+JOIN db_sets s ON s.id = t.db_set_id
+WHERE s.project_id = $1 AND s.is_active AND t.is_active
+`, projectID)
+	if err != nil {
+		return err
+	}
+	names, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return err
+	}
+	mysql := false
+	for _, name := range names {
+		if eng, err := engine.Lookup(name); err == nil && eng.Dialect() == "mysql" {
+			mysql = true
+		}
+	}
+	if !mysql {
+		return nil
+	}
+	scripts := []string{sqlUp}
+	if sqlDown != nil {
+		scripts = append(scripts, *sqlDown)
+	}
+	for _, script := range scripts {
+		if stmts, err := sqlscript.Split("mysql", script); err == nil {
+			if err := CheckTxMode("mysql", mode, stmts); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// updateCheck applies the update input to a check; nil inputs keep the current values.
+func updateCheck(currentSQL *string, currentExpect string, sql *string, expect *string) (*string, string, error) {
+	nextSQL := ""
+	if currentSQL != nil {
+		nextSQL = *currentSQL
+	}
+	if sql != nil {
+		nextSQL = *sql
+	}
+	return normalizeCheck(nextSQL, coalesceString(expect, currentExpect))
+}
+
 func coalesceString(ptr *string, current string) string {
 	if ptr == nil {
 		return current
@@ -0,0 +1,50 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"db_inner_migrator_syncer/internal/engine"
+)
+
+// targetOptions are the options_json keys the store and executor read on
+// every target; engines add their session options.
+var targetOptions = []string{TimeoutAdvisoryLock, TimeoutLock, TimeoutStatement, LedgerOptionTable, LedgerOptionSchema}
+
+// TargetOptionKeys lists the options_json keys a target of the named engine
+// accepts, or nil for an unknown engine.
+func TargetOptionKeys(engineName string) []string {
+	eng, err := engine.Lookup(engineName)
+	if err != nil {
+		return nil
+	}
+	return append(slices.Clone(targetOptions), eng.SessionOptions()...)
+}
+
+// checkOptions rejects options_json keys nothing reads, and invalid timeouts
+// or ledger names. The engine checks its session options in Validate.
+func checkOptions(eng engine.Engine, options json.RawMessage) error {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(options, &raw); err != nil {
+		return fmt.Errorf("options: %w", err)
+	}
+	known := TargetOptionKeys(eng.Name())
+	keys := make([]string, 0, len(raw))
+	for key := range raw {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if !slices.Contains(known, key) {
+			return fmt.Errorf("%w: unknown option %q; %s targets accept %s", ErrDBTargetInvalid, key, eng.Name(), strings.Join(known, ", "))
+		}
+	}
+	if _, err := TargetTimeouts(options); err != nil {
+		return err
+	}
+	_, err := TargetLedger(options)
+	return err
+}
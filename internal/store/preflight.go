@@ -0,0 +1,122 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var ErrPreflightFailed = errors.New("pre-flight checks did not pass")
+
+// Pre-flight check names, in the order they run on a target.
+const (
+	PreflightTarget      = "target"
+	PreflightCredentials = "credentials"
+	PreflightVersion     = "version"
+	PreflightPrivileges  = "privileges"
+	PreflightLock        = "lock"
+	PreflightLedger      = "ledger"
+)
+
+// Pre-flight statuses of a check, a target and a whole report.
+const (
+	PreflightPass = "pass"
+	PreflightWarn = "warn"
+	PreflightFail = "fail"
+)
+
+// PreflightReport is the outcome of checking every target of a run before
+// execution. Failures always block execution; warnings block it unless each
+// warning id is in the run's accepted warnings.
+type PreflightReport struct {
+	RunID     uuid.UUID         `json:"run_id"`
+	CheckedAt time.Time         `json:"checked_at"`
+	Status    string            `json:"status"`
+	Targets   []PreflightResult `json:"targets"`
+	// Warnings lists the ids of all warn checks, for accept_warnings.
+	Warnings []string `json:"warnings"`
+}
+
+type PreflightResult struct {
+	DBTargetID uuid.UUID        `json:"db_target_id"`
+	Target     string           `json:"target"`
+	Engine     string           `json:"engine"`
+	Version    string           `json:"version,omitempty"`
+	Status     string           `json:"status"`
+	Checks     []PreflightCheck `json:"checks"`
+	// Tenant is set on the results of a fan-out target, one per tenant.
+	Tenant string `json:"tenant,omitempty"`
+}
+
+type PreflightCheck struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"`
+	Message string `json:"message,omitempty"`
+	// WarningID identifies a warn check in accept_warnings: <db_target_id>:<name>,
+	// or <db_target_id>/<tenant>:<name> on a fan-out target.
+	WarningID string `json:"warning_id,omitempty"`
+}
+
+// Add records a check on the target and folds its status into the target status.
+func (r *PreflightResult) Add(name string, status string, message string) {
+	check := PreflightCheck{Name: name, Status: status, Message: message}
+	if status == PreflightWarn {
+		check.WarningID = r.DBTargetID.String() + ":" + name
+		if r.Tenant != "" {
+			check.WarningID = r.DBTargetID.String() + "/" + r.Tenant + ":" + name
+		}
+	}
+	r.Checks = append(r.Checks, check)
+	r.Status = worsePreflightStatus(r.Status, status)
+}
+
+// Finish derives the report status and warning list from its targets.
+func (p *PreflightReport) Finish() {
+	p.Status = PreflightPass
+	p.Warnings = nil
+	for _, t := range p.Targets {
+		p.Status = worsePreflightStatus(p.Status, t.Status)
+		for _, c := range t.Checks {
+			if c.WarningID != "" {
+				p.Warnings = append(p.Warnings, c.WarningID)
+			}
+		}
+	}
+}
+
+// Blocking describes the failed checks and the warnings not in accepted.
+func (p *PreflightReport) Blocking(accepted []string) []string {
+	ok := make(map[string]bool, len(accepted))
+	for _, id := range accepted {
+		ok[id] = true
+	}
+	var out []string
+	for _, t := range p.Targets {
+		for _, c := range t.Checks {
+			switch {
+			case c.Status == PreflightFail:
+				out = append(out, t.Target+" "+c.Name+": "+c.Message)
+			case c.Status == PreflightWarn && !ok[c.WarningID]:
+				out = append(out, "warning "+c.WarningID+" not accepted ("+t.Target+": "+c.Message+")")
+			}
+		}
+	}
+	return out
+}
+
+func worsePreflightStatus(a string, b string) string {
+	rank := map[string]int{"": 0, PreflightPass: 1, PreflightWarn: 2, PreflightFail: 3}
+	if rank[b] > rank[a] {
+		return b
+	}
+	return a
+}
+
+// SavePreflight stores the latest pre-flight report on the run.
+func SavePreflight(ctx context.Context, pool *pgxpool.Pool, report *PreflightReport) error {
+	_, err := pool.Exec(ctx, `UPDATE runs SET preflight = $2 WHERE id = $1`, report.RunID, report)
+	return err
+}
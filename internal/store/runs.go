@@ -9,16 +9,28 @@ import (
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
+
+	"db_inner_migrator_syncer/internal/sqlscript"
 )
 
 var (
 	ErrRunNotFound        = errors.New("run not found")
 	ErrRunInvalidStatus   = errors.New("run is not awaiting approval")
+	ErrRunNotApproved     = errors.New("run must be approved before execution")
 	ErrRunNoTargets       = errors.New("no active targets in db set")
 	ErrRunEnvInvalid      = errors.New("invalid env")
 	ErrChecksumMismatch   = errors.New("migration checksum changed; request new approval")
 	ErrAlreadyApplied     = errors.New("migration already applied with same checksum")
+	ErrNotApplied         = errors.New("migration not applied on target")
 	ErrRollbackMissingSQL = errors.New("sql_down is required for rollback")
+	ErrRunNotCancelable   = errors.New("only approved, queued, paused or running runs can be canceled")
+	ErrCancelReasonEmpty  = errors.New("cancel reason required")
+	ErrRunCanceled        = errors.New("run canceled")
+	ErrRunNotRetryable    = errors.New("only failed or canceled runs can be retried")
+	ErrRunNothingToRetry  = errors.New("run has no failed or canceled items to retry")
+	ErrApprovalExpired    = errors.New("approval is outside the retry validity window; request new approval")
+	ErrDryRunOfInvalid    = errors.New("dry_run_of must be apply or rollback")
+	ErrNotDryRunnable     = errors.New("not dry-runnable")
 )
 
 type Run struct {
@@ -37,8 +49,40 @@ type Run struct {
 	ExecutedBy            *uuid.UUID `json:"executed_by,omitempty"`
 	StartedAt             *time.Time `json:"started_at,omitempty"`
 	FinishedAt            *time.Time `json:"finished_at,omitempty"`
+	HeartbeatAt           *time.Time `json:"heartbeat_at,omitempty"`
 	ChecksumUpAtRequest   string     `json:"checksum_up_at_request"`
 	ChecksumDownAtRequest *string    `json:"checksum_down_at_request,omitempty"`
+	MaxParallel           int        `json:"max_parallel"`
+	FailurePolicy         string     `json:"failure_policy"`
+	CancelRequestedAt     *time.Time `json:"cancel_requested_at,omitempty"`
+	CancelRequestedBy     *uuid.UUID `json:"cancel_requested_by,omitempty"`
+	CancelReason          *string    `json:"cancel_reason,omitempty"`
+	// DryRunOf is the run type a dry run tries: apply (sql_up) or rollback (sql_down).
+	DryRunOf *string `json:"dry_run_of,omitempty"`
+	// Preflight is the latest pre-flight report of the run.
+	Preflight *PreflightReport `json:"preflight,omitempty"`
+	// AcceptedWarnings are the pre-flight warning ids execution may proceed with.
+	AcceptedWarnings []string `json:"accepted_warnings"`
+	// WavePlan and WaveGate control how the items roll out; see ParseWavePlan.
+	WavePlan string `json:"wave_plan"`
+	WaveGate string `json:"wave_gate"`
+	// ScheduledFor is when the scheduler queues the approved run; ScheduledUntil
+	// is the end of the maintenance window it was scheduled into.
+	ScheduledFor   *time.Time `json:"scheduled_for,omitempty"`
+	ScheduledUntil *time.Time `json:"scheduled_until,omitempty"`
+	ScheduledBy    *uuid.UUID `json:"scheduled_by,omitempty"`
+	// ScheduleSkipReason says why the scheduler dropped the schedule instead of starting the run.
+	ScheduleSkippedAt  *time.Time `json:"schedule_skipped_at,omitempty"`
+	ScheduleSkipReason *string    `json:"schedule_skip_reason,omitempty"`
+	// VerifySQL is the assertion a baseline run checks on each target before
+	// it writes the ledger row; VerifyExpect is as for migration checks.
+	VerifySQL    *string `json:"verify_sql,omitempty"`
+	VerifyExpect string  `json:"verify_expect,omitempty"`
+}
+
+// RollsBack reports whether the run executes sql_down.
+func (r Run) RollsBack() bool {
+	return r.RunType == "rollback" || (r.RunType == "dry_run" && r.DryRunOf != nil && *r.DryRunOf == "rollback")
 }
 
 type RunItem struct {
@@ -49,7 +93,33 @@ type RunItem struct {
 	StartedAt  *time.Time `json:"started_at,omitempty"`
 	FinishedAt *time.Time `json:"finished_at,omitempty"`
 	Error      *string    `json:"error,omitempty"`
+	ErrorCode  *string    `json:"error_code,omitempty"`
+	Log        *string    `json:"log,omitempty"`
+	Attempt    int        `json:"attempt"`
+	// RowsAffected is the total over the script's statements once the item executed.
+	RowsAffected *int64 `json:"rows_affected,omitempty"`
+	// Wave is the 1-based wave the item runs in; Position is its rollout order.
+	Wave     int `json:"wave"`
+	Position int `json:"position"`
+	// Tenant is the schema or database of a fan-out target the item runs in.
+	Tenant string `json:"tenant,omitempty"`
+	// Attempts holds the earlier attempts of a retried item, oldest first.
+	Attempts []RunItemAttempt `json:"attempts,omitempty"`
+}
+
+// RunItemAttempt is an earlier attempt of a run item, archived when the item was retried.
+type RunItemAttempt struct {
+	ID         uuid.UUID  `json:"id"`
+	RunItemID  uuid.UUID  `json:"run_item_id"`
+	Attempt    int        `json:"attempt"`
+	Status     string     `json:"status"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	Error      *string    `json:"error,omitempty"`
+	ErrorCode  *string    `json:"error_code,omitempty"`
 	Log        *string    `json:"log,omitempty"`
+	RetriedBy  *uuid.UUID `json:"retried_by,omitempty"`
+	RetriedAt  time.Time  `json:"retried_at"`
 }
 
 type RunWithItems struct {
@@ -64,6 +134,18 @@ type RequestRunInput struct {
 	Env         string
 	RequestedBy uuid.UUID
 	RunType     string
+	DryRunOf    string // dry runs only: apply (default) or rollback
+	MaxParallel int    // 0 uses the db set default
+	WavePlan    string // empty uses the db set default
+	WaveGate    string // empty uses the db set default
+
+	// Baseline runs only: optional assertion checked before the ledger row is written.
+	VerifySQL    string
+	VerifyExpect string
+
+	// Tenants are the schemas or databases found for each active fan-out
+	// target of the db set; the run gets an item per tenant.
+	Tenants map[uuid.UUID][]string
 }
 
 type ApprovalDecisionInput struct {
@@ -72,6 +154,10 @@ type ApprovalDecisionInput struct {
 	ActorID   uuid.UUID
 	Comment   string
 	Decision  string // approved or denied
+	// Tenants are the tenants of the fan-out targets found at approval. When
+	// they differ from the run's items, the items are replaced and the approval
+	// is refused with a TenantsChangedError. Nil keeps the items.
+	Tenants map[uuid.UUID][]string
 }
 
 func RequestRun(ctx context.Context, pool *pgxpool.Pool, input RequestRunInput) (*RunWithItems, error) {
@@ -83,15 +169,39 @@ func RequestRun(ctx context.Context, pool *pgxpool.Pool, input RequestRunInput)
 	if runType == "" {
 		runType = "apply"
 	}
-	if runType != "apply" && runType != "rollback" {
+	if runType != "apply" && runType != "rollback" && runType != "dry_run" && runType != "baseline" {
 		return nil, errors.New("invalid run type")
 	}
+	var verifySQL *string
+	verifyExpect := CheckExpectTrue
+	if runType == "baseline" {
+		var err error
+		verifySQL, verifyExpect, err = normalizeCheck(input.VerifySQL, input.VerifyExpect)
+		if errors.Is(err, ErrCheckInvalid) {
+			return nil, ErrVerifyInvalid
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	var dryRunOf *string
+	if runType == "dry_run" {
+		of := strings.ToLower(strings.TrimSpace(input.DryRunOf))
+		if of == "" {
+			of = "apply"
+		}
+		if of != "apply" && of != "rollback" {
+			return nil, ErrDryRunOfInvalid
+		}
+		dryRunOf = &of
+	}
 
 	mig, err := GetMigration(ctx, pool, input.ProjectID, input.MigrationID)
 	if err != nil {
 		return nil, err
 	}
-	if runType == "rollback" && (mig.SQLDown == nil || strings.TrimSpace(*mig.SQLDown) == "") {
+	rollsBack := runType == "rollback" || (dryRunOf != nil && *dryRunOf == "rollback")
+	if rollsBack && (mig.SQLDown == nil || strings.TrimSpace(*mig.SQLDown) == "") {
 		return nil, ErrRollbackMissingSQL
 	}
 
@@ -106,6 +216,40 @@ func RequestRun(ctx context.Context, pool *pgxpool.Pool, input RequestRunInput)
 		return nil, errors.New("db set env mismatch")
 	}
 
+	maxParallel := set.MaxParallel
+	if input.MaxParallel != 0 {
+		maxParallel = input.MaxParallel
+	}
+	if err := validateMaxParallel(maxParallel); err != nil {
+		return nil, err
+	}
+	failurePolicy := set.FailurePolicy
+	if mig.FailurePolicy != nil {
+		failurePolicy = *mig.FailurePolicy
+	}
+	wavePlan, err := normalizeWavePlan(input.WavePlan)
+	if err != nil {
+		return nil, err
+	}
+	if wavePlan == "" {
+		wavePlan = set.WavePlan
+	}
+	waveGate, err := normalizeWaveGate(input.WaveGate)
+	if err != nil {
+		return nil, err
+	}
+	if waveGate == "" {
+		waveGate = set.WaveGate
+	}
+	// Dry runs change nothing, so there is nothing to stage.
+	if runType == "dry_run" {
+		wavePlan, waveGate = "", WaveGateNone
+	}
+	sizes, err := ParseWavePlan(wavePlan)
+	if err != nil {
+		return nil, err
+	}
+
 	targets, err := ListDBTargetsBySet(ctx, pool, input.DBSetID)
 	if err != nil {
 		return nil, err
@@ -119,6 +263,39 @@ func RequestRun(ctx context.Context, pool *pgxpool.Pool, input RequestRunInput)
 	if len(activeTargets) == 0 {
 		return nil, ErrRunNoTargets
 	}
+	items, err := expandTargets(activeTargets, input.Tenants)
+	if err != nil {
+		return nil, err
+	}
+	if len(items) == 0 {
+		return nil, ErrRunNoTargets
+	}
+	script := mig.SQLUp
+	if rollsBack {
+		script = *mig.SQLDown
+	}
+	for _, t := range activeTargets {
+		// Dry runs report such scripts per item as not dry-runnable instead; baselines run no script.
+		dialect, err := t.Dialect()
+		if err != nil || dialect != "mysql" || mig.TransactionMode != "single_transaction" || runType == "dry_run" || runType == "baseline" {
+			continue
+		}
+		// Scripts that do not split are reported by the executor per item.
+		if stmts, err := sqlscript.Split(dialect, script); err == nil {
+			if err := CheckTxMode(dialect, mig.TransactionMode, stmts); err != nil {
+				return nil, err
+			}
+		}
+		break
+	}
+
+	// Dry runs never change a target, so they need no approval and are queued right away.
+	status := "awaiting_approval"
+	var executedBy *uuid.UUID
+	if runType == "dry_run" {
+		status = "queued"
+		executedBy = &input.RequestedBy
+	}
 
 	runID := uuid.New()
 	now := time.Now().UTC()
@@ -129,11 +306,20 @@ func RequestRun(ctx context.Context, pool *pgxpool.Pool, input RequestRunInput)
 		ProjectID:             input.ProjectID,
 		Env:                   env,
 		DBSetID:               input.DBSetID,
-		Status:                "awaiting_approval",
+		Status:                status,
 		RequestedBy:           input.RequestedBy,
 		RequestedAt:           now,
+		ExecutedBy:            executedBy,
 		ChecksumUpAtRequest:   mig.ChecksumUp,
 		ChecksumDownAtRequest: mig.ChecksumDown,
+		MaxParallel:           maxParallel,
+		FailurePolicy:         failurePolicy,
+		DryRunOf:              dryRunOf,
+		AcceptedWarnings:      []string{},
+		WavePlan:              wavePlan,
+		WaveGate:              waveGate,
+		VerifySQL:             verifySQL,
+		VerifyExpect:          verifyExpect,
 	}
 
 	tx, err := pool.BeginTx(ctx, pgx.TxOptions{})
@@ -143,27 +329,14 @@ func RequestRun(ctx context.Context, pool *pgxpool.Pool, input RequestRunInput)
 	defer tx.Rollback(ctx) // nolint:errcheck
 
 	if _, err := tx.Exec(ctx, `
-INSERT INTO runs (id, run_type, migration_id, project_id, env, db_set_id, status, requested_by, requested_at, checksum_up_at_request, checksum_down_at_request)
-VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
-`, run.ID, run.RunType, run.MigrationID, run.ProjectID, run.Env, run.DBSetID, run.Status, run.RequestedBy, run.RequestedAt, run.ChecksumUpAtRequest, run.ChecksumDownAtRequest); err != nil {
+INSERT INTO runs (id, run_type, migration_id, project_id, env, db_set_id, status, requested_by, requested_at, executed_by, checksum_up_at_request, checksum_down_at_request, max_parallel, failure_policy, dry_run_of, wave_plan, wave_gate, verify_sql, verify_expect)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19)
+`, run.ID, run.RunType, run.MigrationID, run.ProjectID, run.Env, run.DBSetID, run.Status, run.RequestedBy, run.RequestedAt, run.ExecutedBy, run.ChecksumUpAtRequest, run.ChecksumDownAtRequest, run.MaxParallel, run.FailurePolicy, run.DryRunOf, run.WavePlan, run.WaveGate, run.VerifySQL, run.VerifyExpect); err != nil {
 		return nil, err
 	}
 
-	var items []RunItem
-	for _, t := range activeTargets {
-		item := RunItem{
-			ID:         uuid.New(),
-			RunID:      run.ID,
-			DBTargetID: t.ID,
-			Status:     "queued",
-		}
-		items = append(items, item)
-		if _, err := tx.Exec(ctx, `
-INSERT INTO run_items (id, run_id, db_target_id, status)
-VALUES ($1, $2, $3, $4)
-`, item.ID, item.RunID, item.DBTargetID, item.Status); err != nil {
-			return nil, err
-		}
+	if err := insertRunItems(ctx, tx, run.ID, items, sizes); err != nil {
+		return nil, err
 	}
 
 	if err := tx.Commit(ctx); err != nil {
@@ -173,6 +346,30 @@ VALUES ($1, $2, $3, $4)
 	return &RunWithItems{Run: run, Items: items}, nil
 }
 
+// insertRunItems writes the queued items of a new run, or of a run whose
+// items were replaced at approval, filling in their ids, waves and positions.
+func insertRunItems(ctx context.Context, tx pgx.Tx, runID uuid.UUID, items []RunItem, sizes []int) error {
+	// Targets come in rollout order (priority first), so the canary leads.
+	// Tenants of a fan-out target count as targets in the wave plan.
+	waves := AssignWaves(len(items), sizes)
+	for i := range items {
+		item := &items[i]
+		item.ID = uuid.New()
+		item.RunID = runID
+		item.Status = "queued"
+		item.Attempt = 1
+		item.Wave = waves[i]
+		item.Position = i + 1
+		if _, err := tx.Exec(ctx, `
+INSERT INTO run_items (id, run_id, db_target_id, status, wave, position, tenant)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
+`, item.ID, item.RunID, item.DBTargetID, item.Status, item.Wave, item.Position, item.Tenant); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func ApproveRun(ctx context.Context, pool *pgxpool.Pool, input ApprovalDecisionInput) (*Run, error) {
 	run, err := getRun(ctx, pool, input.RunID, input.ProjectID)
 	if err != nil {
@@ -199,6 +396,20 @@ func ApproveRun(ctx context.Context, pool *pgxpool.Pool, input ApprovalDecisionI
 	}
 	defer tx.Rollback(ctx) // nolint:errcheck
 
+	if input.Tenants != nil {
+		changed, err := refreezeRunItems(ctx, pool, tx, run, input.Tenants)
+		if err != nil {
+			return nil, err
+		}
+		if changed != nil {
+			// Keep the new list for the next review, without approving it.
+			if err := tx.Commit(ctx); err != nil {
+				return nil, err
+			}
+			return nil, changed
+		}
+	}
+
 	if _, err := tx.Exec(ctx, `
 UPDATE runs
 SET status = 'approved', approved_by = $1, approved_at = $2, approval_comment = $3
@@ -269,6 +480,305 @@ VALUES ($1, $2, $3, 'denied', $4, $5, $6, $7, $8)
 	return run, nil
 }
 
+// QueueRun moves an approved run to queued so an executor worker can claim it.
+// acceptWarnings are the pre-flight warning ids the executing user accepts.
+func QueueRun(ctx context.Context, pool *pgxpool.Pool, projectID uuid.UUID, runID uuid.UUID, actorID uuid.UUID, acceptWarnings []string) (*Run, error) {
+	run, err := getRun(ctx, pool, runID, projectID)
+	if err != nil {
+		return nil, err
+	}
+	if run.Status != "approved" {
+		return nil, ErrRunNotApproved
+	}
+
+	mig, err := GetMigration(ctx, pool, run.ProjectID, run.MigrationID)
+	if err != nil {
+		return nil, err
+	}
+	if mig.ChecksumUp != run.ChecksumUpAtRequest || !equalNullable(mig.ChecksumDown, run.ChecksumDownAtRequest) {
+		return nil, ErrChecksumMismatch
+	}
+
+	accepted := normalizeWarnings(acceptWarnings)
+	ct, err := pool.Exec(ctx, `
+UPDATE runs SET status = 'queued', executed_by = $1, accepted_warnings = $3
+WHERE id = $2 AND status = 'approved'
+`, actorID, run.ID, accepted)
+	if err != nil {
+		return nil, err
+	}
+	if ct.RowsAffected() == 0 {
+		return nil, ErrRunNotApproved
+	}
+
+	run.Status = "queued"
+	run.ExecutedBy = &actorID
+	run.AcceptedWarnings = accepted
+	return run, nil
+}
+
+// ClaimQueuedRun marks the oldest queued run as running and returns it. Concurrent
+// workers skip rows locked by each other. It returns nil when nothing is queued.
+func ClaimQueuedRun(ctx context.Context, pool *pgxpool.Pool) (*Run, error) {
+	var runID, projectID uuid.UUID
+	err := pool.QueryRow(ctx, `
+UPDATE runs SET status = 'running', started_at = now(), heartbeat_at = now()
+WHERE id = (
+  SELECT id FROM runs
+  WHERE status = 'queued'
+  ORDER BY requested_at
+  LIMIT 1
+  FOR UPDATE SKIP LOCKED
+)
+RETURNING id, project_id
+`).Scan(&runID, &projectID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return getRun(ctx, pool, runID, projectID)
+}
+
+// CancelRun cancels an approved, queued or paused run immediately, together
+// with its queued items. A running run only gets the cancel request recorded; the
+// executor running it picks the request up and stops the in-flight statements.
+func CancelRun(ctx context.Context, pool *pgxpool.Pool, projectID uuid.UUID, runID uuid.UUID, actorID uuid.UUID, reason string) (*Run, error) {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return nil, ErrCancelReasonEmpty
+	}
+
+	tx, err := pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx) // nolint:errcheck
+
+	var status string
+	if err := tx.QueryRow(ctx, `
+SELECT status FROM runs WHERE id = $1 AND project_id = $2 FOR UPDATE
+`, runID, projectID).Scan(&status); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrRunNotFound
+		}
+		return nil, err
+	}
+
+	switch status {
+	case "approved", "queued", "paused":
+		if _, err := tx.Exec(ctx, `
+UPDATE runs
+SET status = 'canceled', finished_at = now(), cancel_requested_at = now(), cancel_requested_by = $1, cancel_reason = $2
+WHERE id = $3
+`, actorID, reason, runID); err != nil {
+			return nil, err
+		}
+		if _, err := tx.Exec(ctx, `
+UPDATE run_items SET status = 'canceled', error = $2, finished_at = now()
+WHERE run_id = $1 AND status = 'queued'
+`, runID, "canceled: "+reason); err != nil {
+			return nil, err
+		}
+	case "running":
+		if _, err := tx.Exec(ctx, `
+UPDATE runs
+SET cancel_requested_at = COALESCE(cancel_requested_at, now()),
+    cancel_requested_by = COALESCE(cancel_requested_by, $1),
+    cancel_reason = COALESCE(cancel_reason, $2)
+WHERE id = $3
+`, actorID, reason, runID); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, ErrRunNotCancelable
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return getRun(ctx, pool, runID, projectID)
+}
+
+// PauseRun stops a running run between two waves behind a manual gate. It
+// reports false when a cancel was requested in the meantime.
+func PauseRun(ctx context.Context, pool *pgxpool.Pool, runID uuid.UUID) (bool, error) {
+	ct, err := pool.Exec(ctx, `
+UPDATE runs SET status = 'paused', heartbeat_at = NULL
+WHERE id = $1 AND status = 'running' AND cancel_requested_at IS NULL
+`, runID)
+	if err != nil {
+		return false, err
+	}
+	return ct.RowsAffected() == 1, nil
+}
+
+// ContinueRun re-queues a paused run so its next wave starts.
+func ContinueRun(ctx context.Context, pool *pgxpool.Pool, projectID uuid.UUID, runID uuid.UUID) (*Run, error) {
+	run, err := getRun(ctx, pool, runID, projectID)
+	if err != nil {
+		return nil, err
+	}
+	if run.Status != "paused" {
+		return nil, ErrRunNotPaused
+	}
+	ct, err := pool.Exec(ctx, `
+UPDATE runs SET status = 'queued' WHERE id = $1 AND status = 'paused'
+`, run.ID)
+	if err != nil {
+		return nil, err
+	}
+	if ct.RowsAffected() == 0 {
+		return nil, ErrRunNotPaused
+	}
+	run.Status = "queued"
+	return run, nil
+}
+
+// RetryRun re-queues the failed and canceled items of a finished run without a
+// new approval. The migration checksums must still match the request and the
+// approval must be younger than validity; dry runs have no approval and skip
+// that check. Each retried item's previous attempt
+// is archived in run_item_attempts before the item is reset. acceptWarnings
+// replaces the pre-flight warnings accepted for the run.
+func RetryRun(ctx context.Context, pool *pgxpool.Pool, projectID uuid.UUID, runID uuid.UUID, actorID uuid.UUID, validity time.Duration, acceptWarnings []string) (*RunWithItems, error) {
+	tx, err := pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx) // nolint:errcheck
+
+	run, err := scanRun(tx.QueryRow(ctx, `
+SELECT `+runColumns+`
+FROM runs
+WHERE id = $1 AND project_id = $2
+FOR UPDATE
+`, runID, projectID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrRunNotFound
+		}
+		return nil, err
+	}
+	if run.Status != "failed" && run.Status != "canceled" {
+		return nil, ErrRunNotRetryable
+	}
+	if run.RunType != "dry_run" && (run.ApprovedAt == nil || time.Since(*run.ApprovedAt) > validity) {
+		return nil, ErrApprovalExpired
+	}
+
+	mig, err := GetMigration(ctx, pool, run.ProjectID, run.MigrationID)
+	if err != nil {
+		return nil, err
+	}
+	if mig.ChecksumUp != run.ChecksumUpAtRequest || !equalNullable(mig.ChecksumDown, run.ChecksumDownAtRequest) {
+		return nil, ErrChecksumMismatch
+	}
+
+	rows, err := tx.Query(ctx, `
+SELECT id FROM run_items WHERE run_id = $1 AND status IN ('failed', 'canceled') ORDER BY id
+`, run.ID)
+	if err != nil {
+		return nil, err
+	}
+	itemIDs, err := pgx.CollectRows(rows, pgx.RowTo[uuid.UUID])
+	if err != nil {
+		return nil, err
+	}
+	if len(itemIDs) == 0 {
+		return nil, ErrRunNothingToRetry
+	}
+	for _, itemID := range itemIDs {
+		if _, err := tx.Exec(ctx, `
+INSERT INTO run_item_attempts (id, run_item_id, attempt, status, started_at, finished_at, error, error_code, log, retried_by)
+SELECT $1, id, attempt, status, started_at, finished_at, error, error_code, log, $3
+FROM run_items
+WHERE id = $2
+`, uuid.New(), itemID, actorID); err != nil {
+			return nil, err
+		}
+		if _, err := tx.Exec(ctx, `
+UPDATE run_items
+SET status = 'queued', attempt = attempt + 1, started_at = NULL, finished_at = NULL, error = NULL, error_code = NULL, log = NULL, rows_affected = NULL
+WHERE id = $1
+`, itemID); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := tx.Exec(ctx, `
+UPDATE runs
+SET status = 'queued', executed_by = $2, finished_at = NULL, heartbeat_at = NULL,
+    cancel_requested_at = NULL, cancel_requested_by = NULL, cancel_reason = NULL,
+    accepted_warnings = $3
+WHERE id = $1
+`, run.ID, actorID, normalizeWarnings(acceptWarnings)); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return GetRunWithItems(ctx, pool, projectID, runID)
+}
+
+// HeartbeatRun records that the executing instance is still working on the run
+// and returns the cancel reason when a cancel was requested.
+func HeartbeatRun(ctx context.Context, pool *pgxpool.Pool, runID uuid.UUID) (bool, string, error) {
+	var requestedAt *time.Time
+	var reason *string
+	if err := pool.QueryRow(ctx, `
+UPDATE runs SET heartbeat_at = now() WHERE id = $1
+RETURNING cancel_requested_at, cancel_reason
+`, runID).Scan(&requestedAt, &reason); err != nil {
+		return false, "", err
+	}
+	if requestedAt == nil {
+		return false, "", nil
+	}
+	if reason == nil {
+		return true, "", nil
+	}
+	return true, *reason, nil
+}
+
+// ListStaleRunningRuns returns running runs whose executor stopped sending heartbeats.
+func ListStaleRunningRuns(ctx context.Context, pool *pgxpool.Pool, staleAfter time.Duration) ([]Run, error) {
+	rows, err := pool.Query(ctx, `
+SELECT `+runColumns+`
+FROM runs
+WHERE status = 'running' AND (heartbeat_at IS NULL OR heartbeat_at < now() - make_interval(secs => $1))
+ORDER BY started_at
+`, staleAfter.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var runs []Run
+	for rows.Next() {
+		run, err := scanRun(rows)
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, *run)
+	}
+	return runs, rows.Err()
+}
+
+// ClaimStaleRun takes over a stale running run by bumping its heartbeat. It
+// reports false when the run finished or another instance claimed it first.
+func ClaimStaleRun(ctx context.Context, pool *pgxpool.Pool, runID uuid.UUID, staleAfter time.Duration) (bool, error) {
+	ct, err := pool.Exec(ctx, `
+UPDATE runs SET heartbeat_at = now()
+WHERE id = $1 AND status = 'running' AND (heartbeat_at IS NULL OR heartbeat_at < now() - make_interval(secs => $2))
+`, runID, staleAfter.Seconds())
+	if err != nil {
+		return false, err
+	}
+	return ct.RowsAffected() == 1, nil
+}
+
 func GetRunWithItems(ctx context.Context, pool *pgxpool.Pool, projectID uuid.UUID, runID uuid.UUID) (*RunWithItems, error) {
 	run, err := getRun(ctx, pool, runID, projectID)
 	if err != nil {
@@ -278,12 +788,19 @@ func GetRunWithItems(ctx context.Context, pool *pgxpool.Pool, projectID uuid.UUI
 	if err != nil {
 		return nil, err
 	}
+	attempts, err := listRunItemAttempts(ctx, pool, run.ID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range items {
+		items[i].Attempts = attempts[items[i].ID]
+	}
 	return &RunWithItems{Run: *run, Items: items}, nil
 }
 
 func ListRunsForMigration(ctx context.Context, pool *pgxpool.Pool, projectID uuid.UUID, migrationID uuid.UUID) ([]Run, error) {
 	rows, err := pool.Query(ctx, `
-SELECT id, run_type, migration_id, project_id, env, db_set_id, status, requested_by, requested_at, approved_by, approved_at, approval_comment, executed_by, started_at, finished_at, checksum_up_at_request, checksum_down_at_request
+SELECT `+runColumns+`
 FROM runs
 WHERE project_id = $1 AND migration_id = $2
 ORDER BY requested_at DESC
@@ -295,38 +812,46 @@ ORDER BY requested_at DESC
 
 	var runs []Run
 	for rows.Next() {
-		var run Run
-		if err := rows.Scan(&run.ID, &run.RunType, &run.MigrationID, &run.ProjectID, &run.Env, &run.DBSetID, &run.Status, &run.RequestedBy, &run.RequestedAt, &run.ApprovedBy, &run.ApprovedAt, &run.ApprovalComment, &run.ExecutedBy, &run.StartedAt, &run.FinishedAt, &run.ChecksumUpAtRequest, &run.ChecksumDownAtRequest); err != nil {
+		run, err := scanRun(rows)
+		if err != nil {
 			return nil, err
 		}
-		runs = append(runs, run)
+		runs = append(runs, *run)
 	}
 	return runs, rows.Err()
 }
 
 func getRun(ctx context.Context, pool *pgxpool.Pool, runID uuid.UUID, projectID uuid.UUID) (*Run, error) {
-	var run Run
-	if err := pool.QueryRow(ctx, `
-SELECT id, run_type, migration_id, project_id, env, db_set_id, status, requested_by, requested_at, approved_by, approved_at, approval_comment, executed_by, started_at, finished_at, checksum_up_at_request, checksum_down_at_request
+	run, err := scanRun(pool.QueryRow(ctx, `
+SELECT `+runColumns+`
 FROM runs
 WHERE id = $1 AND project_id = $2
-`, runID, projectID).Scan(
-		&run.ID, &run.RunType, &run.MigrationID, &run.ProjectID, &run.Env, &run.DBSetID, &run.Status, &run.RequestedBy, &run.RequestedAt, &run.ApprovedBy, &run.ApprovedAt, &run.ApprovalComment, &run.ExecutedBy, &run.StartedAt, &run.FinishedAt, &run.ChecksumUpAtRequest, &run.ChecksumDownAtRequest,
-	); err != nil {
+`, runID, projectID))
+	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return nil, ErrRunNotFound
 		}
 		return nil, err
 	}
+	return run, nil
+}
+
+const runColumns = `id, run_type, migration_id, project_id, env, db_set_id, status, requested_by, requested_at, approved_by, approved_at, approval_comment, executed_by, started_at, finished_at, heartbeat_at, checksum_up_at_request, checksum_down_at_request, max_parallel, failure_policy, cancel_requested_at, cancel_requested_by, cancel_reason, dry_run_of, preflight, accepted_warnings, wave_plan, wave_gate, scheduled_for, scheduled_until, scheduled_by, schedule_skipped_at, schedule_skip_reason, verify_sql, verify_expect`
+
+func scanRun(row pgx.Row) (*Run, error) {
+	var run Run
+	if err := row.Scan(&run.ID, &run.RunType, &run.MigrationID, &run.ProjectID, &run.Env, &run.DBSetID, &run.Status, &run.RequestedBy, &run.RequestedAt, &run.ApprovedBy, &run.ApprovedAt, &run.ApprovalComment, &run.ExecutedBy, &run.StartedAt, &run.FinishedAt, &run.HeartbeatAt, &run.ChecksumUpAtRequest, &run.ChecksumDownAtRequest, &run.MaxParallel, &run.FailurePolicy, &run.CancelRequestedAt, &run.CancelRequestedBy, &run.CancelReason, &run.DryRunOf, &run.Preflight, &run.AcceptedWarnings, &run.WavePlan, &run.WaveGate, &run.ScheduledFor, &run.ScheduledUntil, &run.ScheduledBy, &run.ScheduleSkippedAt, &run.ScheduleSkipReason, &run.VerifySQL, &run.VerifyExpect); err != nil {
+		return nil, err
+	}
 	return &run, nil
 }
 
 func listRunItems(ctx context.Context, pool *pgxpool.Pool, runID uuid.UUID) ([]RunItem, error) {
 	rows, err := pool.Query(ctx, `
-SELECT id, run_id, db_target_id, status, started_at, finished_at, error, log
+SELECT id, run_id, db_target_id, status, started_at, finished_at, error, error_code, log, attempt, rows_affected, wave, position, tenant
 FROM run_items
 WHERE run_id = $1
-ORDER BY id
+ORDER BY wave, position, id
 `, runID)
 	if err != nil {
 		return nil, err
@@ -336,7 +861,7 @@ ORDER BY id
 	var items []RunItem
 	for rows.Next() {
 		var it RunItem
-		if err := rows.Scan(&it.ID, &it.RunID, &it.DBTargetID, &it.Status, &it.StartedAt, &it.FinishedAt, &it.Error, &it.Log); err != nil {
+		if err := rows.Scan(&it.ID, &it.RunID, &it.DBTargetID, &it.Status, &it.StartedAt, &it.FinishedAt, &it.Error, &it.ErrorCode, &it.Log, &it.Attempt, &it.RowsAffected, &it.Wave, &it.Position, &it.Tenant); err != nil {
 			return nil, err
 		}
 		items = append(items, it)
@@ -344,6 +869,46 @@ ORDER BY id
 	return items, rows.Err()
 }
 
+// listRunItemAttempts returns the archived attempts of a run keyed by run item id.
+func listRunItemAttempts(ctx context.Context, pool *pgxpool.Pool, runID uuid.UUID) (map[uuid.UUID][]RunItemAttempt, error) {
+	rows, err := pool.Query(ctx, `
+SELECT a.id, a.run_item_id, a.attempt, a.status, a.started_at, a.finished_at, a.error, a.error_code, a.log, a.retried_by, a.retried_at
+FROM run_item_attempts a
+JOIN run_items ri ON a.run_item_id = ri.id
+WHERE ri.run_id = $1
+ORDER BY a.run_item_id, a.attempt
+`, runID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make(map[uuid.UUID][]RunItemAttempt)
+	for rows.Next() {
+		var a RunItemAttempt
+		if err := rows.Scan(&a.ID, &a.RunItemID, &a.Attempt, &a.Status, &a.StartedAt, &a.FinishedAt, &a.Error, &a.ErrorCode, &a.Log, &a.RetriedBy, &a.RetriedAt); err != nil {
+			return nil, err
+		}
+		out[a.RunItemID] = append(out[a.RunItemID], a)
+	}
+	return out, rows.Err()
+}
+
+// normalizeWarnings trims and de-duplicates accepted warning ids.
+func normalizeWarnings(ids []string) []string {
+	out := []string{}
+	seen := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		id = strings.TrimSpace(id)
+		if id == "" || seen[id] {
+			continue
+		}
+		seen[id] = true
+		out = append(out, id)
+	}
+	return out
+}
+
 func nullableString(s string) *string {
 	if strings.TrimSpace(s) == "" {
 		return nil
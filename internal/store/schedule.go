@@ -0,0 +1,362 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var (
+	ErrScheduleInvalid          = errors.New("set either scheduled_for (in the future) or maintenance_window")
+	ErrMaintenanceWindowInvalid = errors.New("invalid maintenance_window; use <days> HH:MM-HH:MM [time zone], e.g. mon-fri 02:00-04:00 UTC")
+	ErrNoMaintenanceWindow      = errors.New("db set has no maintenance window")
+	ErrRunNotScheduled          = errors.New("run is not scheduled")
+)
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// MaintenanceWindow is a recurring window such as "mon-fri 02:00-04:00 UTC".
+// A window whose end is not after its start ends on the next day.
+type MaintenanceWindow struct {
+	Days     [7]bool
+	Start    time.Duration // offset from midnight
+	Length   time.Duration
+	Location *time.Location
+}
+
+// ParseMaintenanceWindow parses "<days> HH:MM-HH:MM [zone]". Days are daily,
+// a day name (mon), a range (mon-fri) or a comma-separated list of those.
+// The zone is an IANA name and defaults to UTC.
+func ParseMaintenanceWindow(spec string) (*MaintenanceWindow, error) {
+	fields := strings.Fields(strings.TrimSpace(spec))
+	if len(fields) < 2 || len(fields) > 3 {
+		return nil, ErrMaintenanceWindowInvalid
+	}
+	w := &MaintenanceWindow{Location: time.UTC}
+	for _, part := range strings.Split(strings.ToLower(fields[0]), ",") {
+		if part == "daily" {
+			for i := range w.Days {
+				w.Days[i] = true
+			}
+			continue
+		}
+		from, to, isRange := strings.Cut(part, "-")
+		first, ok := weekdays[from]
+		if !ok {
+			return nil, ErrMaintenanceWindowInvalid
+		}
+		last := first
+		if isRange {
+			if last, ok = weekdays[to]; !ok {
+				return nil, ErrMaintenanceWindowInvalid
+			}
+		}
+		for d := first; ; d = (d + 1) % 7 {
+			w.Days[d] = true
+			if d == last {
+				break
+			}
+		}
+	}
+	startText, endText, ok := strings.Cut(fields[1], "-")
+	if !ok {
+		return nil, ErrMaintenanceWindowInvalid
+	}
+	start, err := parseClock(startText)
+	if err != nil {
+		return nil, err
+	}
+	end, err := parseClock(endText)
+	if err != nil {
+		return nil, err
+	}
+	w.Start = start
+	w.Length = end - start
+	if w.Length <= 0 {
+		w.Length += 24 * time.Hour
+	}
+	if len(fields) == 3 {
+		loc, err := time.LoadLocation(fields[2])
+		if err != nil {
+			return nil, ErrMaintenanceWindowInvalid
+		}
+		w.Location = loc
+	}
+	return w, nil
+}
+
+func parseClock(s string) (time.Duration, error) {
+	h, m, ok := strings.Cut(s, ":")
+	if !ok {
+		return 0, ErrMaintenanceWindowInvalid
+	}
+	hours, err := strconv.Atoi(h)
+	if err != nil || hours < 0 || hours > 23 {
+		return 0, ErrMaintenanceWindowInvalid
+	}
+	minutes, err := strconv.Atoi(m)
+	if err != nil || len(m) != 2 || minutes < 0 || minutes > 59 {
+		return 0, ErrMaintenanceWindowInvalid
+	}
+	return time.Duration(hours)*time.Hour + time.Duration(minutes)*time.Minute, nil
+}
+
+// Next returns the window that contains now, or else the next one to open.
+func (w *MaintenanceWindow) Next(now time.Time) (time.Time, time.Time) {
+	local := now.In(w.Location)
+	midnight := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, w.Location)
+	for d := -1; d <= 7; d++ {
+		day := midnight.AddDate(0, 0, d)
+		if !w.Days[day.Weekday()] {
+			continue
+		}
+		start := day.Add(w.Start)
+		end := start.Add(w.Length)
+		if now.Before(end) {
+			return start, end
+		}
+	}
+	return time.Time{}, time.Time{}
+}
+
+// normalizeMaintenanceWindow validates spec and returns it trimmed ("" for none).
+func normalizeMaintenanceWindow(spec string) (string, error) {
+	spec = strings.Join(strings.Fields(spec), " ")
+	if spec == "" {
+		return "", nil
+	}
+	if _, err := ParseMaintenanceWindow(spec); err != nil {
+		return "", err
+	}
+	return spec, nil
+}
+
+type ScheduleRunInput struct {
+	ProjectID uuid.UUID
+	RunID     uuid.UUID
+	ActorID   uuid.UUID
+	// ScheduledFor starts the run at a fixed time; UseWindow starts it in the
+	// next maintenance window of the run's db set instead.
+	ScheduledFor   *time.Time
+	UseWindow      bool
+	AcceptWarnings []string
+}
+
+// ScheduleRun attaches a start time to an approved run. The scheduler queues it
+// once the time has come, as if the scheduling user had pressed Execute.
+func ScheduleRun(ctx context.Context, pool *pgxpool.Pool, input ScheduleRunInput) (*Run, error) {
+	if (input.ScheduledFor == nil) == !input.UseWindow {
+		return nil, ErrScheduleInvalid
+	}
+	run, err := getRun(ctx, pool, input.RunID, input.ProjectID)
+	if err != nil {
+		return nil, err
+	}
+	if run.Status != "approved" {
+		return nil, ErrRunNotApproved
+	}
+	mig, err := GetMigration(ctx, pool, run.ProjectID, run.MigrationID)
+	if err != nil {
+		return nil, err
+	}
+	if mig.ChecksumUp != run.ChecksumUpAtRequest || !equalNullable(mig.ChecksumDown, run.ChecksumDownAtRequest) {
+		return nil, ErrChecksumMismatch
+	}
+
+	now := time.Now().UTC()
+	var from time.Time
+	var until *time.Time
+	if input.UseWindow {
+		set, err := GetDBSet(ctx, pool, run.DBSetID)
+		if err != nil {
+			return nil, err
+		}
+		if set.MaintenanceWindow == "" {
+			return nil, ErrNoMaintenanceWindow
+		}
+		window, err := ParseMaintenanceWindow(set.MaintenanceWindow)
+		if err != nil {
+			return nil, err
+		}
+		start, end := window.Next(now)
+		if start.Before(now) {
+			start = now
+		}
+		from = start.UTC()
+		end = end.UTC()
+		until = &end
+	} else {
+		if !input.ScheduledFor.After(now) {
+			return nil, ErrScheduleInvalid
+		}
+		from = input.ScheduledFor.UTC()
+	}
+
+	accepted := normalizeWarnings(input.AcceptWarnings)
+	ct, err := pool.Exec(ctx, `
+UPDATE runs
+SET scheduled_for = $2, scheduled_until = $3, scheduled_by = $4, accepted_warnings = $5,
+    schedule_skipped_at = NULL, schedule_skip_reason = NULL
+WHERE id = $1 AND status = 'approved'
+`, run.ID, from, until, input.ActorID, accepted)
+	if err != nil {
+		return nil, err
+	}
+	if ct.RowsAffected() == 0 {
+		return nil, ErrRunNotApproved
+	}
+	run.ScheduledFor = &from
+	run.ScheduledUntil = until
+	run.ScheduledBy = &input.ActorID
+	run.AcceptedWarnings = accepted
+	run.ScheduleSkippedAt = nil
+	run.ScheduleSkipReason = nil
+	return run, nil
+}
+
+// UnscheduleRun removes the start time of a scheduled run; it stays approved.
+func UnscheduleRun(ctx context.Context, pool *pgxpool.Pool, projectID uuid.UUID, runID uuid.UUID) (*Run, error) {
+	run, err := getRun(ctx, pool, runID, projectID)
+	if err != nil {
+		return nil, err
+	}
+	ct, err := pool.Exec(ctx, `
+UPDATE runs SET scheduled_for = NULL, scheduled_until = NULL
+WHERE id = $1 AND status = 'approved' AND scheduled_for IS NOT NULL
+`, run.ID)
+	if err != nil {
+		return nil, err
+	}
+	if ct.RowsAffected() == 0 {
+		return nil, ErrRunNotScheduled
+	}
+	run.ScheduledFor = nil
+	run.ScheduledUntil = nil
+	return run, nil
+}
+
+// ListDueScheduledRuns returns approved runs whose scheduled time has come.
+func ListDueScheduledRuns(ctx context.Context, pool *pgxpool.Pool) ([]Run, error) {
+	rows, err := pool.Query(ctx, `
+SELECT `+runColumns+`
+FROM runs
+WHERE status = 'approved' AND scheduled_for <= now()
+ORDER BY scheduled_for
+`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var runs []Run
+	for rows.Next() {
+		run, err := scanRun(rows)
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, *run)
+	}
+	return runs, rows.Err()
+}
+
+// StartScheduledRun queues a due scheduled run. When its maintenance window
+// has passed, the migration checksums changed or its approval was invalidated,
+// the schedule is dropped instead and the reason is returned and stored on the
+// run. It returns a nil run when the run is no longer due (started or
+// unscheduled elsewhere).
+func StartScheduledRun(ctx context.Context, pool *pgxpool.Pool, runID uuid.UUID) (*Run, string, error) {
+	tx, err := pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return nil, "", err
+	}
+	defer tx.Rollback(ctx) // nolint:errcheck
+
+	run, err := scanRun(tx.QueryRow(ctx, `
+SELECT `+runColumns+`
+FROM runs
+WHERE id = $1 AND status = 'approved' AND scheduled_for <= now()
+FOR UPDATE
+`, runID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, "", nil
+		}
+		return nil, "", err
+	}
+
+	reason, err := scheduleSkipReason(ctx, tx, run)
+	if err != nil {
+		return nil, "", err
+	}
+	if reason != "" {
+		now := time.Now().UTC()
+		if _, err := tx.Exec(ctx, `
+UPDATE runs
+SET scheduled_for = NULL, scheduled_until = NULL, schedule_skipped_at = $2, schedule_skip_reason = $3
+WHERE id = $1
+`, run.ID, now, reason); err != nil {
+			return nil, "", err
+		}
+		if err := tx.Commit(ctx); err != nil {
+			return nil, "", err
+		}
+		run.ScheduleSkippedAt = &now
+		run.ScheduleSkipReason = &reason
+		return run, reason, nil
+	}
+
+	if _, err := tx.Exec(ctx, `
+UPDATE runs SET status = 'queued', executed_by = scheduled_by WHERE id = $1
+`, run.ID); err != nil {
+		return nil, "", err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, "", err
+	}
+	run.Status = "queued"
+	run.ExecutedBy = run.ScheduledBy
+	return run, "", nil
+}
+
+func scheduleSkipReason(ctx context.Context, tx pgx.Tx, run *Run) (string, error) {
+	if run.ScheduledUntil != nil && !time.Now().Before(*run.ScheduledUntil) {
+		return fmt.Sprintf("maintenance window ended at %s before the run could start", run.ScheduledUntil.UTC().Format(time.RFC3339)), nil
+	}
+	var checksumUp string
+	var checksumDown *string
+	if err := tx.QueryRow(ctx, `
+SELECT checksum_up, checksum_down FROM migrations WHERE id = $1
+`, run.MigrationID).Scan(&checksumUp, &checksumDown); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrMigrationNotFound.Error(), nil
+		}
+		return "", err
+	}
+	if checksumUp != run.ChecksumUpAtRequest || !equalNullable(checksumDown, run.ChecksumDownAtRequest) {
+		return ErrChecksumMismatch.Error(), nil
+	}
+	var approved bool
+	if err := tx.QueryRow(ctx, `
+SELECT EXISTS (
+  SELECT 1 FROM approvals
+  WHERE migration_id = $1 AND env = $2 AND decision = 'approved'
+    AND checksum_up = $3 AND checksum_down IS NOT DISTINCT FROM $4
+)
+`, run.MigrationID, run.Env, run.ChecksumUpAtRequest, run.ChecksumDownAtRequest).Scan(&approved); err != nil {
+		return "", err
+	}
+	if !approved {
+		return "approval was invalidated; request new approval", nil
+	}
+	return "", nil
+}
@@ -0,0 +1,189 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"db_inner_migrator_syncer/internal/engine"
+	"db_inner_migrator_syncer/internal/secret"
+)
+
+// DefaultSSHPort is the bastion port of tunnels set without one.
+const DefaultSSHPort = 22
+
+// SSHTunnel is the SSH bastion of a db set or target, without its
+// credentials. A target's own tunnel overrides its db set's.
+type SSHTunnel struct {
+	Host        string `json:"host"`
+	Port        int    `json:"port"`
+	User        string `json:"user"`
+	HostKey     string `json:"host_key"`
+	HasKey      bool   `json:"has_key"`
+	HasPassword bool   `json:"has_password"`
+}
+
+// String names the bastion as user@host:port.
+func (t SSHTunnel) String() string {
+	return t.User + "@" + net.JoinHostPort(t.Host, strconv.Itoa(t.Port))
+}
+
+// SSHInput sets the tunnel of a db set or target. Key (PEM) and Password
+// replace the stored ones when not blank, like target passwords.
+type SSHInput struct {
+	Host     string
+	Port     int // 0 uses DefaultSSHPort
+	User     string
+	HostKey  string
+	Key      string
+	Password string
+}
+
+// sshColumns are the tunnel columns of db_sets and db_targets, in the order
+// of the sshRow fields.
+const sshColumns = "ssh_host, ssh_port, ssh_user, ssh_host_key, ssh_key_enc, ssh_password_enc"
+
+type sshRow struct {
+	host        string
+	port        int
+	user        string
+	hostKey     string
+	keyEnc      []byte
+	passwordEnc []byte
+}
+
+// tunnel returns the tunnel, or nil when none is set.
+func (r sshRow) tunnel() *SSHTunnel {
+	if r.host == "" {
+		return nil
+	}
+	return &SSHTunnel{Host: r.host, Port: r.port, User: r.user, HostKey: r.hostKey, HasKey: r.keyEnc != nil, HasPassword: r.passwordEnc != nil}
+}
+
+// engineTunnel decrypts the tunnel credentials. Check that a tunnel is set
+// first; see tunnel.
+func (r sshRow) engineTunnel(key []byte) (*engine.Tunnel, error) {
+	out := &engine.Tunnel{Host: r.host, Port: r.port, User: r.user, HostKey: r.hostKey}
+	if r.keyEnc != nil {
+		plain, err := secret.Decrypt(key, r.keyEnc)
+		if err != nil {
+			return nil, fmt.Errorf("decrypt ssh key: %w", err)
+		}
+		out.Key = plain
+	}
+	if r.passwordEnc != nil {
+		plain, err := secret.Decrypt(key, r.passwordEnc)
+		if err != nil {
+			return nil, fmt.Errorf("decrypt ssh password: %w", err)
+		}
+		out.Password = string(plain)
+	}
+	return out, nil
+}
+
+// SetDBSetSSH sets the tunnel the targets of a db set connect through,
+// unless they have their own.
+func SetDBSetSSH(ctx context.Context, pool *pgxpool.Pool, key []byte, setID uuid.UUID, input SSHInput) (*SSHTunnel, error) {
+	return setSSH(ctx, pool, key, "db_sets", setID, ErrDBSetNotFound, input, func(t *engine.Tunnel) error { return t.Validate() })
+}
+
+// SetDBTargetSSH sets the target's own tunnel.
+func SetDBTargetSSH(ctx context.Context, pool *pgxpool.Pool, key []byte, targetID uuid.UUID, input SSHInput) (*SSHTunnel, error) {
+	target, _, err := GetDBTarget(ctx, pool, targetID)
+	if err != nil {
+		return nil, err
+	}
+	eng, err := engine.Lookup(target.Engine)
+	if err != nil {
+		return nil, err
+	}
+	return setSSH(ctx, pool, key, "db_targets", targetID, ErrDBTargetNotFound, input, func(t *engine.Tunnel) error {
+		check := target.engineTarget()
+		check.Tunnel = t
+		return eng.Validate(check)
+	})
+}
+
+// RemoveDBSetSSH removes the tunnel of a db set.
+func RemoveDBSetSSH(ctx context.Context, pool *pgxpool.Pool, setID uuid.UUID) error {
+	return removeSSH(ctx, pool, "db_sets", setID, ErrDBSetNotFound)
+}
+
+// RemoveDBTargetSSH removes the target's own tunnel; it then uses its db
+// set's, if any.
+func RemoveDBTargetSSH(ctx context.Context, pool *pgxpool.Pool, targetID uuid.UUID) error {
+	return removeSSH(ctx, pool, "db_targets", targetID, ErrDBTargetNotFound)
+}
+
+// setSSH writes the tunnel of a row of table, which is db_sets or db_targets.
+func setSSH(ctx context.Context, pool *pgxpool.Pool, key []byte, table string, id uuid.UUID, notFound error, input SSHInput, validate func(*engine.Tunnel) error) (*SSHTunnel, error) {
+	var current sshRow
+	if err := pool.QueryRow(ctx, `SELECT `+sshColumns+` FROM `+table+` WHERE id = $1`, id).Scan(&current.host, &current.port, &current.user, &current.hostKey, &current.keyEnc, &current.passwordEnc); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, notFound
+		}
+		return nil, err
+	}
+	row := sshRow{
+		host:        strings.TrimSpace(input.Host),
+		port:        input.Port,
+		user:        strings.TrimSpace(input.User),
+		hostKey:     strings.TrimSpace(input.HostKey),
+		keyEnc:      current.keyEnc,
+		passwordEnc: current.passwordEnc,
+	}
+	if row.port == 0 {
+		row.port = DefaultSSHPort
+	}
+	if strings.TrimSpace(input.Key) != "" {
+		enc, err := secret.Encrypt(key, []byte(strings.TrimSpace(input.Key)+"\n"))
+		if err != nil {
+			return nil, err
+		}
+		row.keyEnc = enc
+	}
+	if input.Password != "" {
+		enc, err := secret.Encrypt(key, []byte(input.Password))
+		if err != nil {
+			return nil, err
+		}
+		row.passwordEnc = enc
+	}
+	tunnel, err := row.engineTunnel(key)
+	if err != nil {
+		return nil, err
+	}
+	if err := validate(tunnel); err != nil {
+		return nil, err
+	}
+	if _, err := pool.Exec(ctx, `
+UPDATE `+table+`
+SET ssh_host = $1, ssh_port = $2, ssh_user = $3, ssh_host_key = $4, ssh_key_enc = $5, ssh_password_enc = $6
+WHERE id = $7
+`, row.host, row.port, row.user, row.hostKey, row.keyEnc, row.passwordEnc, id); err != nil {
+		return nil, err
+	}
+	return row.tunnel(), nil
+}
+
+func removeSSH(ctx context.Context, pool *pgxpool.Pool, table string, id uuid.UUID, notFound error) error {
+	ct, err := pool.Exec(ctx, `
+UPDATE `+table+`
+SET ssh_host = '', ssh_port = $1, ssh_user = '', ssh_host_key = '', ssh_key_enc = NULL, ssh_password_enc = NULL
+WHERE id = $2
+`, DefaultSSHPort, id)
+	if err != nil {
+		return err
+	}
+	if ct.RowsAffected() == 0 {
+		return notFound
+	}
+	return nil
+}
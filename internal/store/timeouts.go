@@ -0,0 +1,122 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Timeout keys used in target options_json and as migration fields.
+const (
+	TimeoutAdvisoryLock = "advisory_lock_timeout"
+	TimeoutLock         = "lock_timeout"
+	TimeoutStatement    = "statement_timeout"
+
+	// DefaultAdvisoryLockTimeout bounds the wait for the per-target migration lock
+	// when neither the migration nor the target sets one.
+	DefaultAdvisoryLockTimeout = 10 * time.Second
+)
+
+var ErrTimeoutInvalid = errors.New("invalid timeout; use a positive duration such as 500ms, 30s or 5m")
+
+// Timeouts are the session time limits the executor applies on a target.
+// A zero Lock or Statement leaves the server setting untouched.
+type Timeouts struct {
+	// AdvisoryLock is how long to wait for the per-target migration lock.
+	AdvisoryLock time.Duration
+	// Lock is how long a statement may wait for table/metadata/row locks
+	// (Postgres lock_timeout, MySQL lock_wait_timeout and innodb_lock_wait_timeout).
+	Lock time.Duration
+	// Statement is how long a single statement may run.
+	Statement time.Duration
+}
+
+// TargetTimeouts reads the timeout defaults from a target's options_json.
+func TargetTimeouts(options json.RawMessage) (Timeouts, error) {
+	var t Timeouts
+	if len(options) == 0 {
+		return t, nil
+	}
+	var raw map[string]any
+	if err := json.Unmarshal(options, &raw); err != nil {
+		return t, fmt.Errorf("options: %w", err)
+	}
+	for _, f := range []struct {
+		key string
+		dst *time.Duration
+	}{
+		{TimeoutAdvisoryLock, &t.AdvisoryLock},
+		{TimeoutLock, &t.Lock},
+		{TimeoutStatement, &t.Statement},
+	} {
+		key, dst := f.key, f.dst
+		val, ok := raw[key]
+		if !ok || val == nil {
+			continue
+		}
+		s, ok := val.(string)
+		if !ok {
+			return t, fmt.Errorf("%s: %w", key, ErrTimeoutInvalid)
+		}
+		d, err := parseTimeout(s)
+		if err != nil {
+			return t, fmt.Errorf("%s: %w", key, err)
+		}
+		*dst = d
+	}
+	return t, nil
+}
+
+// ResolveTimeouts combines the migration overrides with the target defaults.
+func ResolveTimeouts(target *DBTarget, mig *Migration) (Timeouts, error) {
+	t, err := TargetTimeouts(target.Options)
+	if err != nil {
+		return t, err
+	}
+	for _, override := range []struct {
+		key   string
+		value *string
+		dst   *time.Duration
+	}{
+		{TimeoutAdvisoryLock, mig.AdvisoryLockTimeout, &t.AdvisoryLock},
+		{TimeoutLock, mig.LockTimeout, &t.Lock},
+		{TimeoutStatement, mig.StatementTimeout, &t.Statement},
+	} {
+		if override.value == nil {
+			continue
+		}
+		d, err := parseTimeout(*override.value)
+		if err != nil {
+			return t, fmt.Errorf("migration %s: %w", override.key, err)
+		}
+		*override.dst = d
+	}
+	if t.AdvisoryLock == 0 {
+		t.AdvisoryLock = DefaultAdvisoryLockTimeout
+	}
+	return t, nil
+}
+
+// normalizeTimeout returns the canonical form of a duration string. Empty
+// input stays empty so callers can tell "not set" from an explicit value.
+func normalizeTimeout(key string, value string) (*string, error) {
+	if strings.TrimSpace(value) == "" {
+		return nil, nil
+	}
+	d, err := parseTimeout(value)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", key, err)
+	}
+	s := d.String()
+	return &s, nil
+}
+
+func parseTimeout(value string) (time.Duration, error) {
+	d, err := time.ParseDuration(strings.TrimSpace(value))
+	if err != nil || d < time.Millisecond {
+		return 0, ErrTimeoutInvalid
+	}
+	return d, nil
+}
@@ -21,6 +21,12 @@ type RunSummary struct {
 	ProjectName  string    `json:"project_name"`
 	MigrationKey string    `json:"migration_key"`
 	RequestedBy  string    `json:"requested_by"`
+	// ScheduledFor is set on scheduled runs; ScheduleSkipReason when the scheduler dropped the schedule.
+	ScheduledFor       *time.Time `json:"scheduled_for,omitempty"`
+	ScheduleSkipReason *string    `json:"schedule_skip_reason,omitempty"`
+	// Tenants are the fan-out tenants the run covers, in rollout order;
+	// pending approvals only.
+	Tenants []string `json:"tenants,omitempty"`
 }
 
 type RunListFilter struct {
@@ -58,7 +64,7 @@ SELECT COUNT(1) FROM runs WHERE project_id = $1 AND status = 'failed' AND finish
 
 func ListRecentRuns(ctx context.Context, pool *pgxpool.Pool, projectID uuid.UUID, limit int) ([]RunSummary, error) {
 	rows, err := pool.Query(ctx, `
-SELECT r.id, r.run_type, r.env, r.status, r.requested_at, p.name, m.migration_key, u.email
+SELECT r.id, r.run_type, r.env, r.status, r.requested_at, p.name, m.migration_key, u.email, r.scheduled_for, r.schedule_skip_reason
 FROM runs r
 JOIN migrations m ON r.migration_id = m.id
 JOIN projects p ON r.project_id = p.id
@@ -75,7 +81,36 @@ LIMIT $2
 	var list []RunSummary
 	for rows.Next() {
 		var item RunSummary
-		if err := rows.Scan(&item.ID, &item.RunType, &item.Env, &item.Status, &item.RequestedAt, &item.ProjectName, &item.MigrationKey, &item.RequestedBy); err != nil {
+		if err := rows.Scan(&item.ID, &item.RunType, &item.Env, &item.Status, &item.RequestedAt, &item.ProjectName, &item.MigrationKey, &item.RequestedBy, &item.ScheduledFor, &item.ScheduleSkipReason); err != nil {
+			return nil, err
+		}
+		list = append(list, item)
+	}
+	return list, rows.Err()
+}
+
+// ListScheduledRuns returns the upcoming scheduled runs, soonest first, and
+// the runs whose schedule the scheduler dropped since skippedSince.
+func ListScheduledRuns(ctx context.Context, pool *pgxpool.Pool, projectID uuid.UUID, skippedSince time.Time) ([]RunSummary, error) {
+	rows, err := pool.Query(ctx, `
+SELECT r.id, r.run_type, r.env, r.status, r.requested_at, p.name, m.migration_key, u.email, r.scheduled_for, r.schedule_skip_reason
+FROM runs r
+JOIN migrations m ON r.migration_id = m.id
+JOIN projects p ON r.project_id = p.id
+LEFT JOIN users u ON r.requested_by = u.id
+WHERE r.project_id = $1 AND r.status = 'approved'
+  AND (r.scheduled_for IS NOT NULL OR r.schedule_skipped_at >= $2)
+ORDER BY r.scheduled_for NULLS LAST, r.schedule_skipped_at DESC
+`, projectID, skippedSince)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []RunSummary
+	for rows.Next() {
+		var item RunSummary
+		if err := rows.Scan(&item.ID, &item.RunType, &item.Env, &item.Status, &item.RequestedAt, &item.ProjectName, &item.MigrationKey, &item.RequestedBy, &item.ScheduledFor, &item.ScheduleSkipReason); err != nil {
 			return nil, err
 		}
 		list = append(list, item)
@@ -85,7 +120,7 @@ LIMIT $2
 
 func ListRuns(ctx context.Context, pool *pgxpool.Pool, projectID uuid.UUID, filter RunListFilter, limit int) ([]RunSummary, error) {
 	query := `
-SELECT r.id, r.run_type, r.env, r.status, r.requested_at, p.name, m.migration_key, u.email
+SELECT r.id, r.run_type, r.env, r.status, r.requested_at, p.name, m.migration_key, u.email, r.scheduled_for, r.schedule_skip_reason
 FROM runs r
 JOIN migrations m ON r.migration_id = m.id
 JOIN projects p ON r.project_id = p.id
@@ -99,7 +134,12 @@ WHERE r.project_id = $1
 		args = append(args, filter.Env)
 		argIdx++
 	}
-	if filter.Status != "" {
+	switch filter.Status {
+	case "":
+	case "scheduled":
+		// Scheduled runs are approved runs with a start time.
+		query += " AND r.status = 'approved' AND r.scheduled_for IS NOT NULL"
+	default:
 		query += " AND r.status = $" + itoa(argIdx)
 		args = append(args, filter.Status)
 		argIdx++
@@ -124,7 +164,7 @@ WHERE r.project_id = $1
 	var list []RunSummary
 	for rows.Next() {
 		var item RunSummary
-		if err := rows.Scan(&item.ID, &item.RunType, &item.Env, &item.Status, &item.RequestedAt, &item.ProjectName, &item.MigrationKey, &item.RequestedBy); err != nil {
+		if err := rows.Scan(&item.ID, &item.RunType, &item.Env, &item.Status, &item.RequestedAt, &item.ProjectName, &item.MigrationKey, &item.RequestedBy, &item.ScheduledFor, &item.ScheduleSkipReason); err != nil {
 			return nil, err
 		}
 		list = append(list, item)
@@ -134,7 +174,8 @@ WHERE r.project_id = $1
 
 func ListPendingApprovals(ctx context.Context, pool *pgxpool.Pool, projectID uuid.UUID, envFilter string) ([]RunSummary, error) {
 	query := `
-SELECT r.id, r.run_type, r.env, r.status, r.requested_at, p.name, m.migration_key, u.email
+SELECT r.id, r.run_type, r.env, r.status, r.requested_at, p.name, m.migration_key, u.email, r.scheduled_for, r.schedule_skip_reason,
+  ARRAY(SELECT ri.tenant FROM run_items ri WHERE ri.run_id = r.id AND ri.tenant <> '' ORDER BY ri.position)
 FROM runs r
 JOIN migrations m ON r.migration_id = m.id
 JOIN projects p ON r.project_id = p.id
@@ -157,7 +198,7 @@ WHERE r.project_id = $1 AND r.status = 'awaiting_approval'
 	var list []RunSummary
 	for rows.Next() {
 		var item RunSummary
-		if err := rows.Scan(&item.ID, &item.RunType, &item.Env, &item.Status, &item.RequestedAt, &item.ProjectName, &item.MigrationKey, &item.RequestedBy); err != nil {
+		if err := rows.Scan(&item.ID, &item.RunType, &item.Env, &item.Status, &item.RequestedAt, &item.ProjectName, &item.MigrationKey, &item.RequestedBy, &item.ScheduledFor, &item.ScheduleSkipReason, &item.Tenants); err != nil {
 			return nil, err
 		}
 		list = append(list, item)
@@ -216,26 +257,31 @@ func formatPayload(raw []byte) string {
 func GetRunItemLog(ctx context.Context, pool *pgxpool.Pool, projectID uuid.UUID, runID uuid.UUID, itemID uuid.UUID) (*RunItem, error) {
 	var item RunItem
 	err := pool.QueryRow(ctx, `
-SELECT ri.id, ri.run_id, ri.db_target_id, ri.status, ri.started_at, ri.finished_at, ri.error, ri.log
+SELECT ri.id, ri.run_id, ri.db_target_id, ri.status, ri.started_at, ri.finished_at, ri.error, ri.error_code, ri.log, ri.attempt, ri.rows_affected, ri.tenant
 FROM run_items ri
 JOIN runs r ON ri.run_id = r.id
 WHERE ri.id = $1 AND r.id = $2 AND r.project_id = $3
-`, itemID, runID, projectID).Scan(&item.ID, &item.RunID, &item.DBTargetID, &item.Status, &item.StartedAt, &item.FinishedAt, &item.Error, &item.Log)
+`, itemID, runID, projectID).Scan(&item.ID, &item.RunID, &item.DBTargetID, &item.Status, &item.StartedAt, &item.FinishedAt, &item.Error, &item.ErrorCode, &item.Log, &item.Attempt, &item.RowsAffected, &item.Tenant)
 	if err != nil {
 		if err == pgx.ErrNoRows {
 			return nil, ErrRunNotFound
 		}
 		return nil, err
 	}
+	attempts, err := listRunItemAttempts(ctx, pool, runID)
+	if err != nil {
+		return nil, err
+	}
+	item.Attempts = attempts[item.ID]
 	return &item, nil
 }
 
 func LatestRunsByMigrationEnv(ctx context.Context, pool *pgxpool.Pool, projectID uuid.UUID) (map[uuid.UUID]map[string]Run, error) {
 	rows, err := pool.Query(ctx, `
 SELECT DISTINCT ON (migration_id, env)
-  id, run_type, migration_id, project_id, env, db_set_id, status, requested_by, requested_at, approved_by, approved_at, approval_comment, executed_by, started_at, finished_at, checksum_up_at_request, checksum_down_at_request
+  `+runColumns+`
 FROM runs
-WHERE project_id = $1
+WHERE project_id = $1 AND run_type <> 'dry_run'
 ORDER BY migration_id, env, requested_at DESC
 `, projectID)
 	if err != nil {
@@ -245,14 +291,14 @@ ORDER BY migration_id, env, requested_at DESC
 
 	out := make(map[uuid.UUID]map[string]Run)
 	for rows.Next() {
-		var run Run
-		if err := rows.Scan(&run.ID, &run.RunType, &run.MigrationID, &run.ProjectID, &run.Env, &run.DBSetID, &run.Status, &run.RequestedBy, &run.RequestedAt, &run.ApprovedBy, &run.ApprovedAt, &run.ApprovalComment, &run.ExecutedBy, &run.StartedAt, &run.FinishedAt, &run.ChecksumUpAtRequest, &run.ChecksumDownAtRequest); err != nil {
+		run, err := scanRun(rows)
+		if err != nil {
 			return nil, err
 		}
 		if _, ok := out[run.MigrationID]; !ok {
 			out[run.MigrationID] = make(map[string]Run)
 		}
-		out[run.MigrationID][run.Env] = run
+		out[run.MigrationID][run.Env] = *run
 	}
 	return out, rows.Err()
 }
@@ -263,14 +309,16 @@ SELECT DISTINCT ON (ri.db_target_id, r.migration_id)
   ri.db_target_id, r.migration_id, r.id, r.run_type, r.status, ri.status, r.requested_at, r.finished_at
 FROM run_items ri
 JOIN runs r ON ri.run_id = r.id
-WHERE r.project_id = $1
+WHERE r.project_id = $1 AND r.run_type <> 'dry_run'
 `
 	args := []any{projectID}
 	if env != "" {
 		query += " AND r.env = $2"
 		args = append(args, env)
 	}
-	query += " ORDER BY ri.db_target_id, r.migration_id, r.requested_at DESC"
+	// A fan-out target has an item per tenant; one that did not execute wins,
+	// so the target only shows applied once every tenant is.
+	query += " ORDER BY ri.db_target_id, r.migration_id, r.requested_at DESC, ri.status = 'executed'"
 
 	rows, err := pool.Query(ctx, query, args...)
 	if err != nil {
@@ -295,3 +343,99 @@ WHERE r.project_id = $1
 func itoa(v int) string {
 	return strconv.Itoa(v)
 }
+
+// DryRunResult is the latest dry run that tried the exact script of a run.
+type DryRunResult struct {
+	RunID      uuid.UUID
+	Status     string
+	FinishedAt *time.Time
+	Targets    []DryRunTargetResult
+}
+
+type DryRunTargetResult struct {
+	TargetID     uuid.UUID
+	Target       string
+	Status       string
+	Error        *string
+	RowsAffected *int64
+}
+
+// LatestDryRuns returns, per run id, the newest dry run of the same migration,
+// db set and script (matching direction and checksums) with its item results.
+// Runs without such a dry run are missing from the map.
+func LatestDryRuns(ctx context.Context, pool *pgxpool.Pool, projectID uuid.UUID, runIDs []uuid.UUID) (map[uuid.UUID]*DryRunResult, error) {
+	out := make(map[uuid.UUID]*DryRunResult)
+	if len(runIDs) == 0 {
+		return out, nil
+	}
+	rows, err := pool.Query(ctx, `
+SELECT r.id, d.id, d.status, d.finished_at, t.id, t.host || ':' || t.port || '/' || t.dbname || CASE WHEN ri.tenant <> '' THEN ' (' || ri.tenant || ')' ELSE '' END, ri.status, ri.error, ri.rows_affected
+FROM runs r
+JOIN LATERAL (
+  SELECT id, status, finished_at
+  FROM runs d
+  WHERE d.project_id = r.project_id AND d.migration_id = r.migration_id AND d.db_set_id = r.db_set_id
+    AND d.run_type = 'dry_run' AND d.dry_run_of = r.run_type::text
+    AND d.checksum_up_at_request = r.checksum_up_at_request
+    AND d.checksum_down_at_request IS NOT DISTINCT FROM r.checksum_down_at_request
+  ORDER BY d.requested_at DESC
+  LIMIT 1
+) d ON true
+JOIN run_items ri ON ri.run_id = d.id
+JOIN db_targets t ON ri.db_target_id = t.id
+WHERE r.project_id = $1 AND r.id = ANY($2)
+ORDER BY r.id, t.host, t.port, t.dbname, ri.tenant
+`, projectID, runIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var runID uuid.UUID
+		var res DryRunResult
+		var target DryRunTargetResult
+		if err := rows.Scan(&runID, &res.RunID, &res.Status, &res.FinishedAt, &target.TargetID, &target.Target, &target.Status, &target.Error, &target.RowsAffected); err != nil {
+			return nil, err
+		}
+		if _, ok := out[runID]; !ok {
+			out[runID] = &res
+		}
+		out[runID].Targets = append(out[runID].Targets, target)
+	}
+	return out, rows.Err()
+}
+
+// ListRunChecks returns, per run id, the precheck and postcheck of the run's
+// migration. Runs whose migration has no checks are missing from the map.
+func ListRunChecks(ctx context.Context, pool *pgxpool.Pool, projectID uuid.UUID, runIDs []uuid.UUID) (map[uuid.UUID][]Check, error) {
+	out := make(map[uuid.UUID][]Check)
+	if len(runIDs) == 0 {
+		return out, nil
+	}
+	rows, err := pool.Query(ctx, `
+SELECT r.id, m.precheck_sql, m.precheck_expect, m.postcheck_sql, m.postcheck_expect
+FROM runs r
+JOIN migrations m ON r.migration_id = m.id
+WHERE r.project_id = $1 AND r.id = ANY($2) AND (m.precheck_sql IS NOT NULL OR m.postcheck_sql IS NOT NULL)
+`, projectID, runIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var runID uuid.UUID
+		var m Migration
+		if err := rows.Scan(&runID, &m.PrecheckSQL, &m.PrecheckExpect, &m.PostcheckSQL, &m.PostcheckExpect); err != nil {
+			return nil, err
+		}
+		pre, post := m.Checks()
+		for _, c := range []*Check{pre, post} {
+			if c != nil {
+				out[runID] = append(out[runID], *c)
+			}
+		}
+	}
+	return out, rows.Err()
+}
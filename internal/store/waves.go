@@ -0,0 +1,125 @@
+package store
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	WaveGateNone   = "none"
+	WaveGateManual = "manual"
+
+	waveGateSoakPrefix = "soak="
+)
+
+var (
+	ErrWavePlanInvalid = errors.New("invalid wave_plan; use comma-separated wave sizes such as 1,5,10 (the last size repeats)")
+	ErrWaveGateInvalid = errors.New("invalid wave_gate; use none, manual or soak=<duration>")
+	ErrRunNotPaused    = errors.New("only paused runs can be continued")
+)
+
+// ParseWavePlan parses comma-separated wave sizes. The first wave is usually
+// a single canary target; the last size repeats until every target has a wave.
+// An empty plan runs all targets in one wave.
+func ParseWavePlan(plan string) ([]int, error) {
+	plan = strings.TrimSpace(plan)
+	if plan == "" {
+		return nil, nil
+	}
+	var sizes []int
+	for _, part := range strings.Split(plan, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || n < 1 {
+			return nil, ErrWavePlanInvalid
+		}
+		sizes = append(sizes, n)
+	}
+	return sizes, nil
+}
+
+// AssignWaves returns the 1-based wave of each of n targets in rollout order.
+func AssignWaves(n int, sizes []int) []int {
+	waves := make([]int, n)
+	wave, left, next := 1, n, 0
+	if len(sizes) > 0 {
+		left = sizes[0]
+	}
+	for i := range waves {
+		if left == 0 {
+			wave++
+			if next+1 < len(sizes) {
+				next++
+			}
+			left = sizes[next]
+		}
+		waves[i] = wave
+		left--
+	}
+	return waves
+}
+
+// WaveGate decides what happens between two waves of a run.
+type WaveGate struct {
+	// Manual pauses the run until someone continues it.
+	Manual bool
+	// Soak is how long to wait before the next wave starts.
+	Soak time.Duration
+}
+
+// ParseWaveGate parses none, manual or soak=<duration>.
+func ParseWaveGate(gate string) (WaveGate, error) {
+	gate = strings.ToLower(strings.TrimSpace(gate))
+	switch {
+	case gate == "" || gate == WaveGateNone:
+		return WaveGate{}, nil
+	case gate == WaveGateManual:
+		return WaveGate{Manual: true}, nil
+	case strings.HasPrefix(gate, waveGateSoakPrefix):
+		d, err := time.ParseDuration(strings.TrimSpace(strings.TrimPrefix(gate, waveGateSoakPrefix)))
+		if err != nil || d <= 0 {
+			return WaveGate{}, ErrWaveGateInvalid
+		}
+		return WaveGate{Soak: d}, nil
+	default:
+		return WaveGate{}, ErrWaveGateInvalid
+	}
+}
+
+func (g WaveGate) String() string {
+	switch {
+	case g.Manual:
+		return WaveGateManual
+	case g.Soak > 0:
+		return waveGateSoakPrefix + g.Soak.String()
+	default:
+		return WaveGateNone
+	}
+}
+
+// normalizeWavePlan returns the canonical form of plan ("" for a single wave).
+func normalizeWavePlan(plan string) (string, error) {
+	sizes, err := ParseWavePlan(plan)
+	if err != nil {
+		return "", err
+	}
+	parts := make([]string, len(sizes))
+	for i, n := range sizes {
+		parts[i] = strconv.Itoa(n)
+	}
+	return strings.Join(parts, ","), nil
+}
+
+// normalizeWaveGate returns the canonical form of gate. Empty input stays
+// empty so callers can tell "not set" from an explicit gate.
+func normalizeWaveGate(gate string) (string, error) {
+	if strings.TrimSpace(gate) == "" {
+		return "", nil
+	}
+	g, err := ParseWaveGate(gate)
+	if err != nil {
+		return "", err
+	}
+	return g.String(), nil
+}
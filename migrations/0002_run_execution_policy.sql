@@ -0,0 +1,7 @@
+ALTER TABLE db_sets ADD COLUMN IF NOT EXISTS max_parallel INT NOT NULL DEFAULT 1;
+ALTER TABLE db_sets ADD COLUMN IF NOT EXISTS failure_policy TEXT NOT NULL DEFAULT 'stop_on_first_failure';
+
+ALTER TABLE migrations ADD COLUMN IF NOT EXISTS failure_policy TEXT;
+
+ALTER TABLE runs ADD COLUMN IF NOT EXISTS max_parallel INT NOT NULL DEFAULT 1;
+ALTER TABLE runs ADD COLUMN IF NOT EXISTS failure_policy TEXT NOT NULL DEFAULT 'stop_on_first_failure';
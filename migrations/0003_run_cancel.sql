@@ -0,0 +1,3 @@
+ALTER TABLE runs ADD COLUMN IF NOT EXISTS cancel_requested_at TIMESTAMPTZ;
+ALTER TABLE runs ADD COLUMN IF NOT EXISTS cancel_requested_by UUID REFERENCES users(id);
+ALTER TABLE runs ADD COLUMN IF NOT EXISTS cancel_reason TEXT;
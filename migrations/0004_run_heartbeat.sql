@@ -0,0 +1,3 @@
+ALTER TABLE runs ADD COLUMN IF NOT EXISTS heartbeat_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS runs_running_heartbeat_idx ON runs(heartbeat_at) WHERE status = 'running';
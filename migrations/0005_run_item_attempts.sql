@@ -0,0 +1,15 @@
+ALTER TABLE run_items ADD COLUMN IF NOT EXISTS attempt INT NOT NULL DEFAULT 1;
+
+CREATE TABLE IF NOT EXISTS run_item_attempts (
+  id          UUID PRIMARY KEY,
+  run_item_id UUID NOT NULL REFERENCES run_items(id) ON DELETE CASCADE,
+  attempt     INT NOT NULL,
+  status      run_item_status NOT NULL,
+  started_at  TIMESTAMPTZ,
+  finished_at TIMESTAMPTZ,
+  error       TEXT,
+  log         TEXT,
+  retried_by  UUID REFERENCES users(id),
+  retried_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+  UNIQUE (run_item_id, attempt)
+);
@@ -0,0 +1,6 @@
+ALTER TABLE migrations ADD COLUMN IF NOT EXISTS advisory_lock_timeout TEXT;
+ALTER TABLE migrations ADD COLUMN IF NOT EXISTS lock_timeout TEXT;
+ALTER TABLE migrations ADD COLUMN IF NOT EXISTS statement_timeout TEXT;
+
+ALTER TABLE run_items ADD COLUMN IF NOT EXISTS error_code TEXT;
+ALTER TABLE run_item_attempts ADD COLUMN IF NOT EXISTS error_code TEXT;
@@ -0,0 +1,6 @@
+ALTER TYPE run_type ADD VALUE IF NOT EXISTS 'dry_run';
+
+-- dry runs execute sql_up (apply) or sql_down (rollback) and always roll back
+ALTER TABLE runs ADD COLUMN IF NOT EXISTS dry_run_of TEXT CHECK (dry_run_of IN ('apply', 'rollback'));
+
+ALTER TABLE run_items ADD COLUMN IF NOT EXISTS rows_affected BIGINT;
@@ -0,0 +1,3 @@
+-- latest pre-flight report and the warning ids the executing user accepted
+ALTER TABLE runs ADD COLUMN IF NOT EXISTS preflight JSONB;
+ALTER TABLE runs ADD COLUMN IF NOT EXISTS accepted_warnings TEXT[] NOT NULL DEFAULT '{}';
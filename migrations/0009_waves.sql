@@ -0,0 +1,13 @@
+ALTER TYPE run_status ADD VALUE IF NOT EXISTS 'paused';
+
+-- lower priority values run first; the first target of a wave plan is the canary
+ALTER TABLE db_targets ADD COLUMN IF NOT EXISTS priority INT NOT NULL DEFAULT 100;
+
+ALTER TABLE db_sets ADD COLUMN IF NOT EXISTS wave_plan TEXT NOT NULL DEFAULT '';
+ALTER TABLE db_sets ADD COLUMN IF NOT EXISTS wave_gate TEXT NOT NULL DEFAULT 'none';
+
+ALTER TABLE runs ADD COLUMN IF NOT EXISTS wave_plan TEXT NOT NULL DEFAULT '';
+ALTER TABLE runs ADD COLUMN IF NOT EXISTS wave_gate TEXT NOT NULL DEFAULT 'none';
+
+ALTER TABLE run_items ADD COLUMN IF NOT EXISTS wave INT NOT NULL DEFAULT 1;
+ALTER TABLE run_items ADD COLUMN IF NOT EXISTS position INT NOT NULL DEFAULT 0;
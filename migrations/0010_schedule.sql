@@ -0,0 +1,10 @@
+-- recurring maintenance window such as 'mon-fri 02:00-04:00 UTC'; '' = none
+ALTER TABLE db_sets ADD COLUMN IF NOT EXISTS maintenance_window TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE runs ADD COLUMN IF NOT EXISTS scheduled_for TIMESTAMPTZ;
+ALTER TABLE runs ADD COLUMN IF NOT EXISTS scheduled_until TIMESTAMPTZ;
+ALTER TABLE runs ADD COLUMN IF NOT EXISTS scheduled_by UUID REFERENCES users(id);
+ALTER TABLE runs ADD COLUMN IF NOT EXISTS schedule_skipped_at TIMESTAMPTZ;
+ALTER TABLE runs ADD COLUMN IF NOT EXISTS schedule_skip_reason TEXT;
+
+CREATE INDEX IF NOT EXISTS runs_scheduled_for_idx ON runs(scheduled_for) WHERE status = 'approved';
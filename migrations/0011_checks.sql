@@ -0,0 +1,5 @@
+-- assertion queries run around sql_up; expect 'true' (one truthy value) or 'no_rows'
+ALTER TABLE migrations ADD COLUMN IF NOT EXISTS precheck_sql TEXT;
+ALTER TABLE migrations ADD COLUMN IF NOT EXISTS precheck_expect TEXT NOT NULL DEFAULT 'true';
+ALTER TABLE migrations ADD COLUMN IF NOT EXISTS postcheck_sql TEXT;
+ALTER TABLE migrations ADD COLUMN IF NOT EXISTS postcheck_expect TEXT NOT NULL DEFAULT 'true';
@@ -0,0 +1,12 @@
+-- latest ledger sync per target: what the target ledger holds compared with the hub
+CREATE TABLE IF NOT EXISTS target_syncs (
+  db_target_id  UUID PRIMARY KEY REFERENCES db_targets(id) ON DELETE CASCADE,
+  claimed_at    TIMESTAMPTZ NOT NULL DEFAULT now(),
+  checked_at    TIMESTAMPTZ,
+  status        TEXT, -- ok | drift | error; NULL until the first sync finishes
+  error         TEXT,
+  ledger        TEXT NOT NULL DEFAULT '',
+  drift_count   INT NOT NULL DEFAULT 0,
+  drift_since   TIMESTAMPTZ,
+  entries       JSONB NOT NULL DEFAULT '[]'::jsonb
+);
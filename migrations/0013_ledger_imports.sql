@@ -0,0 +1,14 @@
+-- ledgers imported from other migration tools; the id is the tool_run_id of the seeded ledger rows
+CREATE TABLE IF NOT EXISTS ledger_imports (
+  id            UUID PRIMARY KEY,
+  project_id    UUID NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+  db_target_id  UUID NOT NULL REFERENCES db_targets(id) ON DELETE CASCADE,
+  source        TEXT NOT NULL, -- flyway | golang-migrate | goose | liquibase
+  source_table  TEXT NOT NULL,
+  ledger        TEXT NOT NULL,
+  entries       JSONB NOT NULL DEFAULT '[]'::jsonb,
+  imported_by   UUID NOT NULL REFERENCES users(id),
+  imported_at   TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS ledger_imports_target_idx ON ledger_imports(db_target_id);
@@ -0,0 +1,5 @@
+ALTER TYPE run_type ADD VALUE IF NOT EXISTS 'baseline';
+
+-- baseline runs only: assertion query that must pass before the ledger row is written
+ALTER TABLE runs ADD COLUMN IF NOT EXISTS verify_sql TEXT;
+ALTER TABLE runs ADD COLUMN IF NOT EXISTS verify_expect TEXT NOT NULL DEFAULT 'true';
@@ -0,0 +1,3 @@
+-- target engines are registered in the application (internal/engine), not in an enum
+ALTER TABLE db_targets ALTER COLUMN engine TYPE TEXT USING engine::text;
+DROP TYPE IF EXISTS db_engine;
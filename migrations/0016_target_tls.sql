@@ -0,0 +1,6 @@
+-- per-target TLS; certificates and keys are encrypted like passwords
+ALTER TABLE db_targets ADD COLUMN IF NOT EXISTS tls_mode TEXT NOT NULL DEFAULT 'disable'; -- disable | require | verify-ca | verify-full
+ALTER TABLE db_targets ADD COLUMN IF NOT EXISTS tls_server_name TEXT NOT NULL DEFAULT '';
+ALTER TABLE db_targets ADD COLUMN IF NOT EXISTS tls_ca_enc BYTEA;
+ALTER TABLE db_targets ADD COLUMN IF NOT EXISTS tls_cert_enc BYTEA;
+ALTER TABLE db_targets ADD COLUMN IF NOT EXISTS tls_key_enc BYTEA;
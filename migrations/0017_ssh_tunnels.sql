@@ -0,0 +1,14 @@
+-- optional SSH bastion per db set or target; a target's own tunnel overrides its set's
+ALTER TABLE db_sets ADD COLUMN IF NOT EXISTS ssh_host TEXT NOT NULL DEFAULT ''; -- '' = no tunnel
+ALTER TABLE db_sets ADD COLUMN IF NOT EXISTS ssh_port INT NOT NULL DEFAULT 22;
+ALTER TABLE db_sets ADD COLUMN IF NOT EXISTS ssh_user TEXT NOT NULL DEFAULT '';
+ALTER TABLE db_sets ADD COLUMN IF NOT EXISTS ssh_host_key TEXT NOT NULL DEFAULT '';
+ALTER TABLE db_sets ADD COLUMN IF NOT EXISTS ssh_key_enc BYTEA;
+ALTER TABLE db_sets ADD COLUMN IF NOT EXISTS ssh_password_enc BYTEA;
+
+ALTER TABLE db_targets ADD COLUMN IF NOT EXISTS ssh_host TEXT NOT NULL DEFAULT '';
+ALTER TABLE db_targets ADD COLUMN IF NOT EXISTS ssh_port INT NOT NULL DEFAULT 22;
+ALTER TABLE db_targets ADD COLUMN IF NOT EXISTS ssh_user TEXT NOT NULL DEFAULT '';
+ALTER TABLE db_targets ADD COLUMN IF NOT EXISTS ssh_host_key TEXT NOT NULL DEFAULT '';
+ALTER TABLE db_targets ADD COLUMN IF NOT EXISTS ssh_key_enc BYTEA;
+ALTER TABLE db_targets ADD COLUMN IF NOT EXISTS ssh_password_enc BYTEA;
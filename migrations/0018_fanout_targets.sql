@@ -0,0 +1,3 @@
+-- fan-out targets expand to the schemas (Postgres) or databases (MySQL) matching a LIKE pattern
+ALTER TABLE db_targets ADD COLUMN IF NOT EXISTS fanout_pattern TEXT NOT NULL DEFAULT ''; -- '' = a plain target
+ALTER TABLE run_items ADD COLUMN IF NOT EXISTS tenant TEXT NOT NULL DEFAULT ''; -- schema or database of a fan-out item